@@ -0,0 +1,57 @@
+/*
+Copyright 2025 shtsukada.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"encoding/json"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ConversionDataAnnotation holds the v1beta1 fields that have no v1alpha1
+// representation, so that a hub -> spoke -> hub round trip is lossless.
+const ConversionDataAnnotation = "observability.shtsukada.dev/conversion-data"
+
+// saveHubData は hub の内容をアノテーションに退避する。
+func saveHubData(obj metav1.Object, data any) error {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	ann := obj.GetAnnotations()
+	if ann == nil {
+		ann = map[string]string{}
+	}
+	ann[ConversionDataAnnotation] = string(raw)
+	obj.SetAnnotations(ann)
+	return nil
+}
+
+// restoreHubData は退避済みの内容を data に戻し、アノテーションを取り除く。
+func restoreHubData(obj metav1.Object, data any) error {
+	ann := obj.GetAnnotations()
+	raw, ok := ann[ConversionDataAnnotation]
+	if !ok {
+		return nil
+	}
+	delete(ann, ConversionDataAnnotation)
+	if len(ann) == 0 {
+		ann = nil
+	}
+	obj.SetAnnotations(ann)
+	return json.Unmarshal([]byte(raw), data)
+}
@@ -0,0 +1,103 @@
+/*
+Copyright 2025 shtsukada.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"math/rand"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/api/apitesting/fuzzer"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metafuzzer "k8s.io/apimachinery/pkg/apis/meta/fuzzer"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/serializer"
+	"k8s.io/apimachinery/pkg/util/diff"
+	"sigs.k8s.io/controller-runtime/pkg/conversion"
+
+	"github.com/shtsukada/cloudnative-observability-operator/api/v1beta1"
+)
+
+const fuzzIterations = 500
+
+type convertible interface {
+	conversion.Convertible
+	GetObjectMeta() metav1.Object
+}
+
+// normalize は比較に影響しない差分 (退避用アノテーション、空の map) を取り除く。
+func normalize(obj metav1.Object) {
+	ann := obj.GetAnnotations()
+	delete(ann, ConversionDataAnnotation)
+	if len(ann) == 0 {
+		obj.SetAnnotations(nil)
+	}
+}
+
+func fuzzRoundTrip(t *testing.T, newSpoke func() convertible, newHub func() conversion.Hub) {
+	t.Helper()
+	f := fuzzer.FuzzerFor(metafuzzer.Funcs, rand.NewSource(rand.Int63()), serializer.NewCodecFactory(runtime.NewScheme()))
+
+	for i := 0; i < fuzzIterations; i++ {
+		// spoke -> hub -> spoke
+		spoke := newSpoke()
+		f.Fill(spoke)
+		normalize(spoke.GetObjectMeta())
+		hub := newHub()
+		if err := spoke.ConvertTo(hub); err != nil {
+			t.Fatalf("ConvertTo: %v", err)
+		}
+		back := newSpoke()
+		if err := back.ConvertFrom(hub); err != nil {
+			t.Fatalf("ConvertFrom: %v", err)
+		}
+		normalize(back.GetObjectMeta())
+		if !equality.Semantic.DeepEqual(spoke, back) {
+			t.Fatalf("spoke -> hub -> spoke is lossy:\n%s", diff.ObjectReflectDiff(spoke, back))
+		}
+
+		// hub -> spoke -> hub
+		hub = newHub()
+		f.Fill(hub)
+		hubMeta := hub.(metav1.ObjectMetaAccessor).GetObjectMeta()
+		normalize(hubMeta)
+		spoke = newSpoke()
+		if err := spoke.ConvertFrom(hub); err != nil {
+			t.Fatalf("ConvertFrom: %v", err)
+		}
+		hubBack := newHub()
+		if err := spoke.ConvertTo(hubBack); err != nil {
+			t.Fatalf("ConvertTo: %v", err)
+		}
+		normalize(hubBack.(metav1.ObjectMetaAccessor).GetObjectMeta())
+		if !equality.Semantic.DeepEqual(hub, hubBack) {
+			t.Fatalf("hub -> spoke -> hub is lossy:\n%s", diff.ObjectReflectDiff(hub, hubBack))
+		}
+	}
+}
+
+func TestGrpcBurnerConversionRoundTrip(t *testing.T) {
+	fuzzRoundTrip(t,
+		func() convertible { return &GrpcBurner{} },
+		func() conversion.Hub { return &v1beta1.GrpcBurner{} })
+}
+
+func TestObservabilityConfigConversionRoundTrip(t *testing.T) {
+	fuzzRoundTrip(t,
+		func() convertible { return &ObservabilityConfig{} },
+		func() conversion.Hub { return &v1beta1.ObservabilityConfig{} })
+}
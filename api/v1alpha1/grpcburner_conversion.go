@@ -0,0 +1,141 @@
+/*
+Copyright 2025 shtsukada.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"sigs.k8s.io/controller-runtime/pkg/conversion"
+
+	"github.com/shtsukada/cloudnative-observability-operator/api/v1beta1"
+)
+
+// ConvertTo converts this GrpcBurner to the Hub version (v1beta1).
+func (src *GrpcBurner) ConvertTo(dstRaw conversion.Hub) error {
+	dst := dstRaw.(*v1beta1.GrpcBurner)
+	in := src.DeepCopy()
+
+	dst.ObjectMeta = in.ObjectMeta
+	// load や protocol など v1beta1 のみのフィールドを先に戻してから上書きする
+	if err := restoreHubData(&dst.ObjectMeta, &dst.Spec); err != nil {
+		return err
+	}
+
+	s, d := &in.Spec, &dst.Spec
+	d.Image = s.Image
+	d.Replicas = s.Replicas
+	d.Env = s.Env
+	d.Resources = s.Resources
+	d.Ports = nil
+	if s.Ports != nil {
+		d.Ports = make([]v1beta1.PortSpec, len(s.Ports))
+		for i, p := range s.Ports {
+			d.Ports[i] = v1beta1.PortSpec(p)
+		}
+	}
+	d.Sidecars = s.Sidecars
+	d.InitContainers = s.InitContainers
+	d.Volumes = s.Volumes
+	d.VolumeMounts = s.VolumeMounts
+	if s.OTLPEndpoint == nil {
+		d.OTLP = nil
+	} else {
+		var protocol v1beta1.OTLPProtocol
+		if d.OTLP != nil {
+			protocol = d.OTLP.Protocol
+		}
+		d.OTLP = &v1beta1.OTLPSpec{
+			Endpoint: s.OTLPEndpoint.Endpoint,
+			Protocol: protocol,
+			Insecure: s.OTLPEndpoint.Insecure,
+			Headers:  s.OTLPEndpoint.Headers,
+			Timeout:  s.OTLPEndpoint.Timeout,
+		}
+	}
+	d.UpdateStrategy = v1beta1.UpdateStrategyType(s.UpdateStrategy)
+	d.Probe = (*v1beta1.ProbeSpec)(s.Probe)
+	d.RollbackOnFailure = s.RollbackOnFailure
+	d.RevisionHistoryLimit = s.RevisionHistoryLimit
+
+	st := &in.Status
+	dst.Status = v1beta1.GrpcBurnerStatus{
+		ObservedGeneration: st.ObservedGeneration,
+		Conditions:         st.Conditions,
+		ReadyReplicas:      st.ReadyReplicas,
+		Endpoint:           st.Endpoint,
+		Phase:              st.Phase,
+		LastKnownGood:      (*v1beta1.KnownGoodRevision)(st.LastKnownGood),
+	}
+	if st.History != nil {
+		dst.Status.History = make([]v1beta1.RevisionRecord, len(st.History))
+		for i, r := range st.History {
+			dst.Status.History[i] = v1beta1.RevisionRecord(r)
+		}
+	}
+	return nil
+}
+
+// ConvertFrom converts from the Hub version (v1beta1) to this version.
+func (dst *GrpcBurner) ConvertFrom(srcRaw conversion.Hub) error {
+	in := srcRaw.(*v1beta1.GrpcBurner).DeepCopy()
+
+	dst.ObjectMeta = in.ObjectMeta
+	s, d := &in.Spec, &dst.Spec
+	d.Image = s.Image
+	d.Replicas = s.Replicas
+	d.Env = s.Env
+	d.Resources = s.Resources
+	d.Ports = nil
+	if s.Ports != nil {
+		d.Ports = make([]PortSpec, len(s.Ports))
+		for i, p := range s.Ports {
+			d.Ports[i] = PortSpec(p)
+		}
+	}
+	d.Sidecars = s.Sidecars
+	d.InitContainers = s.InitContainers
+	d.Volumes = s.Volumes
+	d.VolumeMounts = s.VolumeMounts
+	d.OTLPEndpoint = nil
+	if s.OTLP != nil {
+		d.OTLPEndpoint = &OTLPEndpoint{
+			Endpoint: s.OTLP.Endpoint,
+			Insecure: s.OTLP.Insecure,
+			Headers:  s.OTLP.Headers,
+			Timeout:  s.OTLP.Timeout,
+		}
+	}
+	d.UpdateStrategy = UpdateStrategyType(s.UpdateStrategy)
+	d.Probe = (*ProbeSpec)(s.Probe)
+	d.RollbackOnFailure = s.RollbackOnFailure
+	d.RevisionHistoryLimit = s.RevisionHistoryLimit
+
+	st := &in.Status
+	dst.Status = GrpcBurnerStatus{
+		ObservedGeneration: st.ObservedGeneration,
+		Conditions:         st.Conditions,
+		ReadyReplicas:      st.ReadyReplicas,
+		Endpoint:           st.Endpoint,
+		Phase:              st.Phase,
+		LastKnownGood:      (*KnownGoodRevision)(st.LastKnownGood),
+	}
+	if st.History != nil {
+		dst.Status.History = make([]RevisionRecord, len(st.History))
+		for i, r := range st.History {
+			dst.Status.History[i] = RevisionRecord(r)
+		}
+	}
+	return saveHubData(dst, in.Spec)
+}
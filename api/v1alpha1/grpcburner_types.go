@@ -15,6 +15,9 @@ type UpdateStrategyType string
 
 type ConditionType = string
 
+// ServerContainerName is the name of the operator-managed container.
+const ServerContainerName = "server"
+
 const (
 	UpdateStrategyRollingUpdate UpdateStrategyType = "RollingUpdate"
 	UpdateStrategyRecreate      UpdateStrategyType = "Recreate"
@@ -22,6 +25,7 @@ const (
 	ConditionReady       ConditionType = "Ready"
 	ConditionProgressing ConditionType = "Progressing"
 	ConditionDegraded    ConditionType = "Degraded"
+	ConditionRolledBack  ConditionType = "RolledBack"
 )
 
 type PortSpec struct {
@@ -58,6 +62,30 @@ type OTLPEndpoint struct {
 	Timeout *metav1.Duration `json:"timeout,omitempty"`
 }
 
+type ProbeSpec struct {
+	// Port is the container port to probe. Defaults to 50051.
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=65535
+	// +optional
+	Port *int32 `json:"port,omitempty"`
+
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	InitialDelaySeconds *int32 `json:"initialDelaySeconds,omitempty"`
+
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	PeriodSeconds *int32 `json:"periodSeconds,omitempty"`
+
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	TimeoutSeconds *int32 `json:"timeoutSeconds,omitempty"`
+
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	FailureThreshold *int32 `json:"failureThreshold,omitempty"`
+}
+
 type GrpcBurnerSpec struct {
 	// +kubebuilder:validation:MinLength=1
 	Image string `json:"image"`
@@ -75,12 +103,75 @@ type GrpcBurnerSpec struct {
 	// +kubebuilder:validation:MinItems=1
 	Ports []PortSpec `json:"ports"`
 
+	// Extra containers running next to the "server" container.
+	// +optional
+	Sidecars []corev1.Container `json:"sidecars,omitempty"`
+
+	// +optional
+	InitContainers []corev1.Container `json:"initContainers,omitempty"`
+
+	// +optional
+	Volumes []corev1.Volume `json:"volumes,omitempty"`
+
+	// VolumeMounts for the "server" container.
+	// +optional
+	VolumeMounts []corev1.VolumeMount `json:"volumeMounts,omitempty"`
+
 	// +optional
 	OTLPEndpoint *OTLPEndpoint `json:"otlpEndpoint,omitempty"`
 
 	// +kubebuilder:validation:Enum=RollingUpdate;Recreate
 	// +kubebuilder:default:=RollingUpdate
 	UpdateStrategy UpdateStrategyType `json:"updateStrategy,omitempty"`
+
+	// Probe configures the TCP readiness and liveness probes of the server container.
+	// +optional
+	Probe *ProbeSpec `json:"probe,omitempty"`
+
+	// RollbackOnFailure re-renders the last known-good revision when the
+	// current one hits ProgressDeadlineExceeded or crash-loops.
+	// +optional
+	RollbackOnFailure bool `json:"rollbackOnFailure,omitempty"`
+
+	// RevisionHistoryLimit bounds status.history, like apps/v1 Deployments.
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:default:=10
+	// +optional
+	RevisionHistoryLimit *int32 `json:"revisionHistoryLimit,omitempty"`
+}
+
+// RevisionRecord is one applied revision of a GrpcBurner.
+type RevisionRecord struct {
+	// Generation whose pod template was applied. After an automatic rollback
+	// this is the generation of the restored last known-good template.
+	Generation int64 `json:"generation"`
+
+	SpecHash string `json:"specHash"`
+
+	Image string `json:"image"`
+
+	AppliedAt metav1.Time `json:"appliedAt"`
+
+	// +optional
+	ReadyAt *metav1.Time `json:"readyAt,omitempty"`
+
+	// +optional
+	FailureReason string `json:"failureReason,omitempty"`
+}
+
+// KnownGoodRevision is the last revision that reached Ready.
+type KnownGoodRevision struct {
+	Generation int64 `json:"generation"`
+
+	SpecHash string `json:"specHash"`
+
+	Image string `json:"image"`
+
+	// Rendered pod template of the revision.
+	// +kubebuilder:validation:Schemaless
+	// +kubebuilder:validation:Type=object
+	// +kubebuilder:pruning:PreserveUnknownFields
+	Template corev1.PodTemplateSpec `json:"template"`
 }
 
 type GrpcBurnerStatus struct {
@@ -98,6 +189,14 @@ type GrpcBurnerStatus struct {
 
 	// +optional
 	Phase string `json:"phase,omitempty"`
+
+	// +optional
+	LastKnownGood *KnownGoodRevision `json:"lastKnownGood,omitempty"`
+
+	// History of applied revisions, oldest first.
+	// +listType=atomic
+	// +optional
+	History []RevisionRecord `json:"history,omitempty"`
 }
 
 // +kubebuilder:object:root=true
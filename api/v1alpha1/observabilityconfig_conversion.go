@@ -0,0 +1,79 @@
+/*
+Copyright 2025 shtsukada.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"sigs.k8s.io/controller-runtime/pkg/conversion"
+
+	"github.com/shtsukada/cloudnative-observability-operator/api/v1beta1"
+)
+
+// observabilityConfigHubData は v1alpha1 で表現できない v1beta1 の spec/status を運ぶ。
+type observabilityConfigHubData struct {
+	Spec   *v1beta1.ObservabilityConfigSpec   `json:"spec"`
+	Status *v1beta1.ObservabilityConfigStatus `json:"status"`
+}
+
+// ConvertTo converts this ObservabilityConfig to the Hub version (v1beta1).
+func (src *ObservabilityConfig) ConvertTo(dstRaw conversion.Hub) error {
+	dst := dstRaw.(*v1beta1.ObservabilityConfig)
+	in := src.DeepCopy()
+
+	dst.ObjectMeta = in.ObjectMeta
+	if err := restoreHubData(&dst.ObjectMeta, &observabilityConfigHubData{Spec: &dst.Spec, Status: &dst.Status}); err != nil {
+		return err
+	}
+
+	// 先頭の endpoint だけが v1alpha1 と対応する
+	switch {
+	case len(dst.Spec.Endpoints) > 0:
+		dst.Spec.Endpoints[0] = v1beta1.Endpoint(in.Spec.Endpoint)
+	case in.Spec.Endpoint != "":
+		dst.Spec.Endpoints = []v1beta1.Endpoint{v1beta1.Endpoint(in.Spec.Endpoint)}
+	}
+	dst.Spec.SamplingPercent = in.Spec.SamplingPercent
+	dst.Spec.MetricsEnabled = in.Spec.MetricsEnabled
+
+	dst.Status.Phase = in.Status.Phase
+	dst.Status.Reason = in.Status.Reason
+	dst.Status.Conditions = in.Status.Conditions
+	dst.Status.ObservedGeneration = in.Status.ObservedGeneration
+	dst.Status.ReadyReplicas = in.Status.ReadyReplicas
+	return nil
+}
+
+// ConvertFrom converts from the Hub version (v1beta1) to this version.
+func (dst *ObservabilityConfig) ConvertFrom(srcRaw conversion.Hub) error {
+	in := srcRaw.(*v1beta1.ObservabilityConfig).DeepCopy()
+
+	dst.ObjectMeta = in.ObjectMeta
+	dst.Spec = ObservabilityConfigSpec{
+		SamplingPercent: in.Spec.SamplingPercent,
+		MetricsEnabled:  in.Spec.MetricsEnabled,
+	}
+	if len(in.Spec.Endpoints) > 0 {
+		dst.Spec.Endpoint = string(in.Spec.Endpoints[0])
+	}
+	dst.Status = ObservabilityConfigStatus{
+		Phase:              in.Status.Phase,
+		Reason:             in.Status.Reason,
+		Conditions:         in.Status.Conditions,
+		ObservedGeneration: in.Status.ObservedGeneration,
+		ReadyReplicas:      in.Status.ReadyReplicas,
+	}
+	return saveHubData(dst, observabilityConfigHubData{Spec: &in.Spec, Status: &in.Status})
+}
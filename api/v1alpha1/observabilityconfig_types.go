@@ -37,7 +37,8 @@ type ObservabilityConfigSpec struct {
 
 	// Enable metrics pipeline
 	// +kubebuilder:default:=true
-	MetricsEnabled bool `json:"metricsEnabled,omitempty"`
+	// +optional
+	MetricsEnabled *bool `json:"metricsEnabled,omitempty"`
 }
 
 // ObservabilityConfigStatus defines the observed state of ObservabilityConfig.
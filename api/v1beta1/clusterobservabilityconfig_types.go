@@ -0,0 +1,103 @@
+/*
+Copyright 2025 shtsukada.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// InheritableSpec holds the ObservabilityConfig settings a ClusterObservabilityConfig
+// provides to the namespaces it selects.
+type InheritableSpec struct {
+	// +listType=atomic
+	// +kubebuilder:validation:MaxItems=16
+	// +optional
+	Endpoints []Endpoint `json:"endpoints,omitempty"`
+
+	// Exporters are merged by name; a namespaced exporter with the same name replaces
+	// the inherited one. Secrets referenced here are read from the inheriting namespace.
+	// +listType=map
+	// +listMapKey=name
+	// +optional
+	Exporters []ExporterSpec `json:"exporters,omitempty"`
+
+	// +optional
+	Pipelines *PipelinesSpec `json:"pipelines,omitempty"`
+
+	// Inherited processors run before the namespaced ones; a namespaced processor
+	// with the same name replaces the inherited one in place.
+	// +listType=map
+	// +listMapKey=name
+	// +kubebuilder:validation:MaxItems=32
+	// +optional
+	Processors []ProcessorSpec `json:"processors,omitempty"`
+
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=100
+	// +optional
+	SamplingPercent *int32 `json:"samplingPercent,omitempty"`
+
+	// +optional
+	MetricsEnabled *bool `json:"metricsEnabled,omitempty"`
+
+	// +optional
+	Mode AgentMode `json:"mode,omitempty"`
+
+	// Agent settings are merged field by field.
+	// +optional
+	Agent *AgentSpec `json:"agent,omitempty"`
+}
+
+// ClusterObservabilityConfigSpec defines the desired state of ClusterObservabilityConfig.
+type ClusterObservabilityConfigSpec struct {
+	// Namespaces whose ObservabilityConfigs may inherit from this config.
+	// An empty selector selects every namespace.
+	// +optional
+	NamespaceSelector metav1.LabelSelector `json:"namespaceSelector,omitempty"`
+
+	InheritableSpec `json:",inline"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:path=clusterobservabilityconfigs,scope=Cluster,shortName=cobscfg,categories=all
+// +kubebuilder:printcolumn:name="Mode",type=string,JSONPath=`.spec.mode`
+// +kubebuilder:printcolumn:name="Endpoints",type=string,JSONPath=`.spec.endpoints`
+// +kubebuilder:printcolumn:name="Sampling",type=integer,JSONPath=`.spec.samplingPercent`
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// ClusterObservabilityConfig provides shared defaults that ObservabilityConfigs in the
+// selected namespaces inherit through spec.inheritFrom.
+type ClusterObservabilityConfig struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	// +required
+	Spec ClusterObservabilityConfigSpec `json:"spec,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// ClusterObservabilityConfigList contains a list of ClusterObservabilityConfig
+type ClusterObservabilityConfigList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ClusterObservabilityConfig `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&ClusterObservabilityConfig{}, &ClusterObservabilityConfigList{})
+}
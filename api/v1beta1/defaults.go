@@ -0,0 +1,191 @@
+package v1beta1
+
+import (
+	"slices"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+)
+
+const (
+	DefaultReplicas              int32 = 1
+	DefaultRevisionHistoryLimit  int32 = 10
+	DefaultProbePort             int32 = 50051
+	DefaultProbePeriodSeconds    int32 = 10
+	DefaultProbeTimeoutSeconds   int32 = 1
+	DefaultProbeFailureThreshold int32 = 3
+	DefaultOTLPTimeout                 = 10 * time.Second
+	DefaultScrapeInterval              = 30 * time.Second
+	DefaultSamplingPercent       int32 = 10
+	DefaultTargetCPUUtilization  int32 = 80
+	DefaultGatewayReplicas       int32 = 2
+)
+
+// DefaultPersistenceSize is the volume size requested per agent replica by spec.persistence.
+var DefaultPersistenceSize = resource.MustParse("1Gi")
+
+// DefaultKubernetesMetadata is what spec.kubernetesAttributes extracts when metadata is empty.
+var DefaultKubernetesMetadata = []KubernetesMetadata{
+	"k8s.pod.name", "k8s.pod.uid", "k8s.namespace.name", "k8s.node.name", "k8s.deployment.name",
+}
+
+// ApplyDefaults materializes every default of the GrpcBurner spec.
+// It is shared by the defaulting webhook and the controller.
+func (gb *GrpcBurner) ApplyDefaults() {
+	s := &gb.Spec
+	if s.Replicas == nil {
+		s.Replicas = ptr.To(DefaultReplicas)
+	}
+	if s.UpdateStrategy == "" {
+		s.UpdateStrategy = UpdateStrategyRollingUpdate
+	}
+	if s.RevisionHistoryLimit == nil {
+		s.RevisionHistoryLimit = ptr.To(DefaultRevisionHistoryLimit)
+	}
+	for i := range s.Ports {
+		p := &s.Ports[i]
+		if p.Protocol == "" {
+			p.Protocol = corev1.ProtocolTCP
+		}
+		if p.ServicePort == nil {
+			p.ServicePort = ptr.To(p.ContainerPort)
+		}
+	}
+
+	if s.Probe == nil {
+		s.Probe = &ProbeSpec{}
+	}
+	if s.Probe.Port == nil {
+		s.Probe.Port = ptr.To(DefaultProbePort)
+	}
+	if s.Probe.InitialDelaySeconds == nil {
+		s.Probe.InitialDelaySeconds = ptr.To(int32(0))
+	}
+	if s.Probe.PeriodSeconds == nil {
+		s.Probe.PeriodSeconds = ptr.To(DefaultProbePeriodSeconds)
+	}
+	if s.Probe.TimeoutSeconds == nil {
+		s.Probe.TimeoutSeconds = ptr.To(DefaultProbeTimeoutSeconds)
+	}
+	if s.Probe.FailureThreshold == nil {
+		s.Probe.FailureThreshold = ptr.To(DefaultProbeFailureThreshold)
+	}
+
+	if o := s.OTLP; o != nil {
+		if o.Protocol == "" {
+			o.Protocol = OTLPProtocolGRPC
+		}
+		if o.Insecure == nil {
+			o.Insecure = ptr.To(false)
+		}
+		if o.Timeout == nil {
+			o.Timeout = &metav1.Duration{Duration: DefaultOTLPTimeout}
+		}
+	}
+
+	if l := s.Load; l != nil && l.Mode == "" {
+		l.Mode = LoadModeCPU
+	}
+}
+
+// ApplyDefaults materializes every default of the ObservabilityConfig spec.
+func (oc *ObservabilityConfig) ApplyDefaults() {
+	s := &oc.Spec
+	if s.SamplingPercent == nil {
+		s.SamplingPercent = ptr.To(DefaultSamplingPercent)
+	}
+	if s.MetricsEnabled == nil {
+		s.MetricsEnabled = ptr.To(true)
+	}
+
+	if s.Mode == "" {
+		s.Mode = AgentModeDeployment
+		switch {
+		case s.Persistence != nil:
+			s.Mode = AgentModeStatefulSet
+		case s.Topology == TopologyGateway:
+			s.Mode = AgentModeDaemonSet
+		}
+	}
+	if s.Topology == "" {
+		s.Topology = TopologyAgent
+	}
+	if s.Topology == TopologyGateway {
+		if s.Gateway == nil {
+			s.Gateway = &GatewaySpec{}
+		}
+		if s.Gateway.Replicas == nil {
+			s.Gateway.Replicas = ptr.To(DefaultGatewayReplicas)
+		}
+	}
+	if s.Agent == nil {
+		s.Agent = &AgentSpec{}
+	}
+	if s.Agent.Replicas == nil {
+		s.Agent.Replicas = ptr.To(DefaultReplicas)
+	}
+	if a := s.Agent.Autoscaling; a != nil {
+		if a.MinReplicas == nil {
+			a.MinReplicas = ptr.To(DefaultReplicas)
+		}
+		if a.TargetCPUUtilization == nil && a.TargetMemoryUtilization == nil && a.TargetQueueSize == nil {
+			a.TargetCPUUtilization = ptr.To(DefaultTargetCPUUtilization)
+		}
+	}
+
+	if l := s.Logs; l != nil {
+		if l.StartAt == "" {
+			l.StartAt = "end"
+		}
+		if l.TolerateAllTaints == nil {
+			l.TolerateAllTaints = ptr.To(true)
+		}
+	}
+
+	if p := s.Persistence; p != nil {
+		if p.Size == nil {
+			p.Size = ptr.To(DefaultPersistenceSize.DeepCopy())
+		}
+		if p.RetentionPolicy == nil {
+			p.RetentionPolicy = &PersistenceRetentionPolicy{}
+		}
+		if p.RetentionPolicy.WhenScaled == "" {
+			p.RetentionPolicy.WhenScaled = PersistenceRetain
+		}
+		if p.RetentionPolicy.WhenDeleted == "" {
+			p.RetentionPolicy.WhenDeleted = PersistenceDelete
+		}
+	}
+
+	for i := range s.Scrape {
+		j := &s.Scrape[i]
+		if j.Kind == "" {
+			j.Kind = ScrapeGrpcBurner
+		}
+		if j.Path == "" {
+			j.Path = "/metrics"
+		}
+		if j.Interval == nil {
+			j.Interval = &metav1.Duration{Duration: DefaultScrapeInterval}
+		}
+	}
+
+	if k := s.KubernetesAttributes; k != nil {
+		if len(k.Metadata) == 0 {
+			k.Metadata = slices.Clone(DefaultKubernetesMetadata)
+		}
+		if k.Scope == "" {
+			k.Scope = KubernetesAttributesScopeCluster
+		}
+		for _, tags := range [][]KubernetesTag{k.Labels, k.Annotations} {
+			for i := range tags {
+				if tags[i].From == "" {
+					tags[i].From = KubernetesTagFromPod
+				}
+			}
+		}
+	}
+}
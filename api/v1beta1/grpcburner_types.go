@@ -0,0 +1,292 @@
+// SPDX-License-Identifier: Apache-2.0
+
+// +kubebuilder:object:generate=true
+// +groupName=observability.shtsukada.dev
+
+package v1beta1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+type UpdateStrategyType string
+
+type ConditionType = string
+
+// ServerContainerName is the name of the operator-managed container.
+const ServerContainerName = "server"
+
+const (
+	UpdateStrategyRollingUpdate UpdateStrategyType = "RollingUpdate"
+	UpdateStrategyRecreate      UpdateStrategyType = "Recreate"
+
+	ConditionReady       ConditionType = "Ready"
+	ConditionProgressing ConditionType = "Progressing"
+	ConditionDegraded    ConditionType = "Degraded"
+	ConditionAvailable   ConditionType = "Available"
+	ConditionRolledBack  ConditionType = "RolledBack"
+)
+
+type PortSpec struct {
+	// +optional
+	Name string `json:"name,omitempty"`
+
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=65535
+	ContainerPort int32 `json:"containerPort"`
+
+	// +kubebuilder:validation:Enum=TCP;UDP;SCTP
+	// +kubebuilder:default:=TCP
+	Protocol corev1.Protocol `json:"protocol,omitempty"`
+
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=65535
+	// +optional
+	ServicePort *int32 `json:"servicePort,omitempty"`
+}
+
+// +kubebuilder:validation:Enum=grpc;http/protobuf
+type OTLPProtocol string
+
+const (
+	OTLPProtocolGRPC         OTLPProtocol = "grpc"
+	OTLPProtocolHTTPProtobuf OTLPProtocol = "http/protobuf"
+)
+
+// OTLPSpec configures the OTLP exporter of the burner's OpenTelemetry SDK.
+type OTLPSpec struct {
+	// e.g. "otel-collector.monitoring.svc:4317"
+	// +kubebuilder:validation:MinLength=1
+	Endpoint string `json:"endpoint"`
+
+	// +kubebuilder:default:=grpc
+	// +optional
+	Protocol OTLPProtocol `json:"protocol,omitempty"`
+
+	// +kubebuilder:default:=false
+	// +optional
+	Insecure *bool `json:"insecure,omitempty"`
+
+	// +optional
+	Headers map[string]string `json:"headers,omitempty"`
+
+	// +optional
+	Timeout *metav1.Duration `json:"timeout,omitempty"`
+}
+
+// +kubebuilder:validation:Enum=cpu;memory;io;idle
+type LoadMode string
+
+const (
+	LoadModeCPU    LoadMode = "cpu"
+	LoadModeMemory LoadMode = "memory"
+	LoadModeIO     LoadMode = "io"
+	LoadModeIdle   LoadMode = "idle"
+)
+
+// LoadProfile is the synthetic load generated by the burner.
+type LoadProfile struct {
+	// +kubebuilder:default:=cpu
+	// +optional
+	Mode LoadMode `json:"mode,omitempty"`
+
+	// Requests per second.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	QPS *int32 `json:"qps,omitempty"`
+
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	Concurrency *int32 `json:"concurrency,omitempty"`
+
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	PayloadBytes *int32 `json:"payloadBytes,omitempty"`
+}
+
+type ProbeSpec struct {
+	// Port is the container port to probe. Defaults to 50051.
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=65535
+	// +optional
+	Port *int32 `json:"port,omitempty"`
+
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	InitialDelaySeconds *int32 `json:"initialDelaySeconds,omitempty"`
+
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	PeriodSeconds *int32 `json:"periodSeconds,omitempty"`
+
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	TimeoutSeconds *int32 `json:"timeoutSeconds,omitempty"`
+
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	FailureThreshold *int32 `json:"failureThreshold,omitempty"`
+}
+
+type GrpcBurnerSpec struct {
+	// +kubebuilder:validation:MinLength=1
+	Image string `json:"image"`
+
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:default:=1
+	Replicas *int32 `json:"replicas,omitempty"`
+
+	// +optional
+	Env []corev1.EnvVar `json:"env,omitempty"`
+
+	// +optional
+	Resources corev1.ResourceRequirements `json:"resources,omitempty"`
+
+	// +kubebuilder:validation:MinItems=1
+	Ports []PortSpec `json:"ports"`
+
+	// Extra containers running next to the "server" container.
+	// +optional
+	Sidecars []corev1.Container `json:"sidecars,omitempty"`
+
+	// +optional
+	InitContainers []corev1.Container `json:"initContainers,omitempty"`
+
+	// +optional
+	Volumes []corev1.Volume `json:"volumes,omitempty"`
+
+	// VolumeMounts for the "server" container.
+	// +optional
+	VolumeMounts []corev1.VolumeMount `json:"volumeMounts,omitempty"`
+
+	// +optional
+	OTLP *OTLPSpec `json:"otlp,omitempty"`
+
+	// +optional
+	Load *LoadProfile `json:"load,omitempty"`
+
+	// +kubebuilder:validation:Enum=RollingUpdate;Recreate
+	// +kubebuilder:default:=RollingUpdate
+	UpdateStrategy UpdateStrategyType `json:"updateStrategy,omitempty"`
+
+	// Probe configures the TCP readiness and liveness probes of the server container.
+	// +optional
+	Probe *ProbeSpec `json:"probe,omitempty"`
+
+	// RollbackOnFailure re-renders the last known-good revision when the
+	// current one hits ProgressDeadlineExceeded or crash-loops.
+	// +optional
+	RollbackOnFailure bool `json:"rollbackOnFailure,omitempty"`
+
+	// RevisionHistoryLimit bounds status.history, like apps/v1 Deployments.
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:default:=10
+	// +optional
+	RevisionHistoryLimit *int32 `json:"revisionHistoryLimit,omitempty"`
+}
+
+// RevisionRecord is one applied revision of a GrpcBurner.
+type RevisionRecord struct {
+	// Generation whose pod template was applied. After an automatic rollback
+	// this is the generation of the restored last known-good template.
+	Generation int64 `json:"generation"`
+
+	SpecHash string `json:"specHash"`
+
+	Image string `json:"image"`
+
+	AppliedAt metav1.Time `json:"appliedAt"`
+
+	// +optional
+	ReadyAt *metav1.Time `json:"readyAt,omitempty"`
+
+	// +optional
+	FailureReason string `json:"failureReason,omitempty"`
+}
+
+// KnownGoodRevision is the last revision that reached Ready.
+type KnownGoodRevision struct {
+	Generation int64 `json:"generation"`
+
+	SpecHash string `json:"specHash"`
+
+	Image string `json:"image"`
+
+	// Rendered pod template of the revision.
+	// +kubebuilder:validation:Schemaless
+	// +kubebuilder:validation:Type=object
+	// +kubebuilder:pruning:PreserveUnknownFields
+	Template corev1.PodTemplateSpec `json:"template"`
+}
+
+type GrpcBurnerStatus struct {
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// +optional
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+
+	// +optional
+	ReadyReplicas int32 `json:"readyReplicas,omitempty"`
+
+	// +optional
+	Endpoint string `json:"endpoint,omitempty"`
+
+	// +optional
+	Phase string `json:"phase,omitempty"`
+
+	// +optional
+	LastKnownGood *KnownGoodRevision `json:"lastKnownGood,omitempty"`
+
+	// History of applied revisions, oldest first.
+	// +listType=atomic
+	// +optional
+	History []RevisionRecord `json:"history,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:storageversion
+// +kubebuilder:resource:shortName=gb,singular=grpcburner,scope=Namespaced
+// +kubebuilder:printcolumn:name="Phase",type=string,JSONPath=`.status.phase`,description="Summary phase"
+// +kubebuilder:printcolumn:name="Ready",type=integer,JSONPath=`.status.readyReplicas`,description="Ready replicas"
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+type GrpcBurner struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   GrpcBurnerSpec   `json:"spec,omitempty"`
+	Status GrpcBurnerStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+type GrpcBurnerList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []GrpcBurner `json:"items"`
+}
+
+func (gb *GrpcBurner) SetCondition(t string, s metav1.ConditionStatus, reason, msg string) {
+	apimeta.SetStatusCondition(&gb.Status.Conditions, metav1.Condition{
+		Type:               t,
+		Status:             s,
+		Reason:             reason,
+		Message:            msg,
+		ObservedGeneration: gb.GetGeneration(),
+	})
+}
+
+func (gb *GrpcBurner) GetCondition(t string) *metav1.Condition {
+	return apimeta.FindStatusCondition(gb.Status.Conditions, t)
+}
+
+func (gb *GrpcBurner) IsConditionTrue(t string) bool {
+	c := gb.GetCondition(t)
+	return c != nil && c.Status == metav1.ConditionTrue
+}
+
+func init() {
+	SchemeBuilder.Register(&GrpcBurner{}, &GrpcBurnerList{})
+}
@@ -0,0 +1,1010 @@
+/*
+Copyright 2025 shtsukada.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Endpoint is an OTLP endpoint, either an http(s) URL or host:port.
+// +kubebuilder:validation:MinLength=1
+// +kubebuilder:validation:MaxLength=2048
+// +kubebuilder:validation:XValidation:rule="(self.matches('^https?://.+')) || (self.matches('^[A-Za-z0-9_.-]+:[0-9]{1,5}$'))",message="endpoint must be http(s) URL or host:port"
+type Endpoint string
+
+// ExporterType is the collector exporter component an ExporterSpec renders to.
+// +kubebuilder:validation:Enum=otlp;otlphttp;prometheusremotewrite;debug
+type ExporterType string
+
+const (
+	ExporterOTLP                  ExporterType = "otlp"
+	ExporterOTLPHTTP              ExporterType = "otlphttp"
+	ExporterPrometheusRemoteWrite ExporterType = "prometheusremotewrite"
+	ExporterDebug                 ExporterType = "debug"
+)
+
+// ExporterTLS configures TLS towards the exporter's backend.
+type ExporterTLS struct {
+	// Disable TLS (gRPC exporters only).
+	// +optional
+	Insecure bool `json:"insecure,omitempty"`
+
+	// +optional
+	InsecureSkipVerify bool `json:"insecureSkipVerify,omitempty"`
+
+	// +optional
+	ServerName string `json:"serverName,omitempty"`
+
+	// Secret in the same namespace holding ca.crt, and tls.crt/tls.key when MTLS is set.
+	// +optional
+	SecretName string `json:"secretName,omitempty"`
+
+	// Present the client certificate from SecretName.
+	// +optional
+	MTLS bool `json:"mtls,omitempty"`
+}
+
+// SecretHeader is a request header whose value is read from a Secret.
+type SecretHeader struct {
+	// +kubebuilder:validation:MinLength=1
+	Name string `json:"name"`
+
+	SecretKeyRef corev1.SecretKeySelector `json:"secretKeyRef"`
+}
+
+// RetrySpec maps to the exporter's retry_on_failure settings.
+type RetrySpec struct {
+	// +optional
+	Enabled *bool `json:"enabled,omitempty"`
+
+	// +optional
+	InitialInterval *metav1.Duration `json:"initialInterval,omitempty"`
+
+	// +optional
+	MaxInterval *metav1.Duration `json:"maxInterval,omitempty"`
+
+	// +optional
+	MaxElapsedTime *metav1.Duration `json:"maxElapsedTime,omitempty"`
+}
+
+// QueueSpec maps to the exporter's sending_queue settings.
+type QueueSpec struct {
+	// +optional
+	Enabled *bool `json:"enabled,omitempty"`
+
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	NumConsumers *int32 `json:"numConsumers,omitempty"`
+
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	QueueSize *int32 `json:"queueSize,omitempty"`
+}
+
+// ExporterSpec is a named exporter of the agent.
+// +kubebuilder:validation:XValidation:rule="self.type == 'debug' || has(self.endpoint)",message="endpoint is required unless type is debug"
+type ExporterSpec struct {
+	// Name referenced from spec.pipelines. The collector component ID is <type>/<name>.
+	// +kubebuilder:validation:Pattern=`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`
+	// +kubebuilder:validation:MaxLength=32
+	Name string `json:"name"`
+
+	Type ExporterType `json:"type"`
+
+	// Backend address: host:port for otlp, a URL for otlphttp and prometheusremotewrite.
+	// +optional
+	Endpoint string `json:"endpoint,omitempty"`
+
+	// +optional
+	TLS *ExporterTLS `json:"tls,omitempty"`
+
+	// Static request headers.
+	// +optional
+	Headers map[string]string `json:"headers,omitempty"`
+
+	// Request headers read from Secrets.
+	// +listType=map
+	// +listMapKey=name
+	// +optional
+	HeadersFrom []SecretHeader `json:"headersFrom,omitempty"`
+
+	// +kubebuilder:validation:Enum=gzip;zstd;snappy;none
+	// +optional
+	Compression string `json:"compression,omitempty"`
+
+	// +optional
+	Retry *RetrySpec `json:"retry,omitempty"`
+
+	// +optional
+	Queue *QueueSpec `json:"queue,omitempty"`
+}
+
+// PipelinesSpec routes each signal to exporters by name. Exporters generated
+// from spec.endpoints are named endpoint-0, endpoint-1, ...
+type PipelinesSpec struct {
+	// +listType=set
+	// +optional
+	Traces []string `json:"traces,omitempty"`
+
+	// +listType=set
+	// +optional
+	Metrics []string `json:"metrics,omitempty"`
+
+	// +listType=set
+	// +optional
+	Logs []string `json:"logs,omitempty"`
+}
+
+// Signal is a telemetry signal handled by a pipeline.
+// +kubebuilder:validation:Enum=traces;metrics;logs
+type Signal string
+
+const (
+	SignalTraces  Signal = "traces"
+	SignalMetrics Signal = "metrics"
+	SignalLogs    Signal = "logs"
+)
+
+// ProcessorType is the collector processor component a ProcessorSpec renders to.
+// +kubebuilder:validation:Enum=attributes;filter;resource;tail_sampling;transform
+type ProcessorType string
+
+const (
+	ProcessorAttributes   ProcessorType = "attributes"
+	ProcessorFilter       ProcessorType = "filter"
+	ProcessorResource     ProcessorType = "resource"
+	ProcessorTailSampling ProcessorType = "tail_sampling"
+	ProcessorTransform    ProcessorType = "transform"
+)
+
+// AttributeAction changes one attribute.
+// +kubebuilder:validation:XValidation:rule="self.action == 'delete' ? !has(self.value) && !has(self.fromAttribute) : has(self.value) != has(self.fromAttribute)",message="delete takes no value; other actions need exactly one of value or fromAttribute"
+type AttributeAction struct {
+	// +kubebuilder:validation:MinLength=1
+	Key string `json:"key"`
+
+	// +kubebuilder:validation:Enum=insert;update;upsert;delete
+	Action string `json:"action"`
+
+	// +optional
+	Value *string `json:"value,omitempty"`
+
+	// Copy the value of another attribute.
+	// +optional
+	FromAttribute string `json:"fromAttribute,omitempty"`
+}
+
+// AttributeMatch matches telemetry carrying an attribute with the given value.
+type AttributeMatch struct {
+	// +kubebuilder:validation:MinLength=1
+	Key string `json:"key"`
+
+	Value string `json:"value"`
+}
+
+// FilterProcessor drops telemetry matching any of its conditions.
+type FilterProcessor struct {
+	// Regular expressions; spans whose name matches one are dropped.
+	// +listType=atomic
+	// +optional
+	SpanNames []string `json:"spanNames,omitempty"`
+
+	// Regular expressions; metrics whose name matches one are dropped.
+	// +listType=atomic
+	// +optional
+	MetricNames []string `json:"metricNames,omitempty"`
+
+	// Spans, metric data points and log records carrying one of these attributes are dropped.
+	// +listType=atomic
+	// +optional
+	Attributes []AttributeMatch `json:"attributes,omitempty"`
+}
+
+// TailSamplingPolicyType selects the tail_sampling policy.
+// +kubebuilder:validation:Enum=always_sample;latency;status_code;probabilistic;string_attribute
+type TailSamplingPolicyType string
+
+// TailSamplingPolicy keeps a trace when it matches.
+type TailSamplingPolicy struct {
+	// +kubebuilder:validation:Pattern=`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`
+	Name string `json:"name"`
+
+	Type TailSamplingPolicyType `json:"type"`
+
+	// Minimum trace duration for latency policies.
+	// +optional
+	Latency *metav1.Duration `json:"latency,omitempty"`
+
+	// Span status codes for status_code policies.
+	// +listType=set
+	// +kubebuilder:validation:items:Enum=OK;ERROR;UNSET
+	// +optional
+	StatusCodes []string `json:"statusCodes,omitempty"`
+
+	// Percentage of traces kept by probabilistic policies.
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=100
+	// +optional
+	SamplingPercent *int32 `json:"samplingPercent,omitempty"`
+
+	// Attribute key and accepted values for string_attribute policies.
+	// +optional
+	Key string `json:"key,omitempty"`
+
+	// +listType=atomic
+	// +optional
+	Values []string `json:"values,omitempty"`
+}
+
+// TailSamplingProcessor samples whole traces after they complete. It applies to traces only.
+type TailSamplingProcessor struct {
+	// Time to wait for the spans of a trace before deciding.
+	// +optional
+	DecisionWait *metav1.Duration `json:"decisionWait,omitempty"`
+
+	// Number of traces kept in memory while waiting.
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	NumTraces *int32 `json:"numTraces,omitempty"`
+
+	// +listType=map
+	// +listMapKey=name
+	// +kubebuilder:validation:MinItems=1
+	Policies []TailSamplingPolicy `json:"policies"`
+}
+
+// TransformStatements is a group of OTTL statements evaluated in one context.
+type TransformStatements struct {
+	// +kubebuilder:validation:Enum=resource;scope;span;spanevent;metric;datapoint;log
+	Context string `json:"context"`
+
+	// +listType=atomic
+	// +kubebuilder:validation:MinItems=1
+	Statements []string `json:"statements"`
+}
+
+// TransformProcessor rewrites telemetry with OTTL statements.
+type TransformProcessor struct {
+	// +listType=atomic
+	// +optional
+	Traces []TransformStatements `json:"traces,omitempty"`
+
+	// +listType=atomic
+	// +optional
+	Metrics []TransformStatements `json:"metrics,omitempty"`
+
+	// +listType=atomic
+	// +optional
+	Logs []TransformStatements `json:"logs,omitempty"`
+}
+
+// ProcessorSpec is a named processor of the agent. The block named after Type must be set.
+// +kubebuilder:validation:XValidation:rule="has(self.attributes) == (self.type == 'attributes') && has(self.filter) == (self.type == 'filter') && has(self.resource) == (self.type == 'resource') && has(self.tailSampling) == (self.type == 'tail_sampling') && has(self.transform) == (self.type == 'transform')",message="exactly the settings block matching type must be set"
+type ProcessorSpec struct {
+	// The collector component ID is <type>/<name>.
+	// +kubebuilder:validation:Pattern=`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`
+	// +kubebuilder:validation:MaxLength=32
+	Name string `json:"name"`
+
+	Type ProcessorType `json:"type"`
+
+	// Pipelines the processor runs in. Defaults to every signal its settings apply to.
+	// +listType=set
+	// +optional
+	Signals []Signal `json:"signals,omitempty"`
+
+	// Span, data point and log record attribute changes.
+	// +listType=atomic
+	// +kubebuilder:validation:MaxItems=64
+	// +optional
+	Attributes []AttributeAction `json:"attributes,omitempty"`
+
+	// +optional
+	Filter *FilterProcessor `json:"filter,omitempty"`
+
+	// Resource attribute changes.
+	// +listType=atomic
+	// +kubebuilder:validation:MaxItems=64
+	// +optional
+	Resource []AttributeAction `json:"resource,omitempty"`
+
+	// +optional
+	TailSampling *TailSamplingProcessor `json:"tailSampling,omitempty"`
+
+	// +optional
+	Transform *TransformProcessor `json:"transform,omitempty"`
+}
+
+// AgentMode is the kind of workload that runs the agent.
+// +kubebuilder:validation:Enum=Deployment;DaemonSet;StatefulSet
+type AgentMode string
+
+const (
+	AgentModeDeployment  AgentMode = "Deployment"
+	AgentModeDaemonSet   AgentMode = "DaemonSet"
+	AgentModeStatefulSet AgentMode = "StatefulSet"
+)
+
+// AgentSpec configures the collector agent workload.
+type AgentSpec struct {
+	// Collector image. Defaults to the operator's --default-agent-image.
+	// +optional
+	Image string `json:"image,omitempty"`
+
+	// Image tag that replaces the tag of Image (or of the default image).
+	// Ignored when the image is pinned by digest.
+	// +kubebuilder:validation:Pattern=`^[A-Za-z0-9_][A-Za-z0-9_.-]{0,127}$`
+	// +optional
+	Version string `json:"version,omitempty"`
+
+	// Defaults to 1. Ignored while Autoscaling is set.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	Replicas *int32 `json:"replicas,omitempty"`
+
+	// +optional
+	Resources corev1.ResourceRequirements `json:"resources,omitempty"`
+
+	// +optional
+	NodeSelector map[string]string `json:"nodeSelector,omitempty"`
+
+	// +optional
+	Tolerations []corev1.Toleration `json:"tolerations,omitempty"`
+
+	// Service account the agent pods run as. Defaults to the namespace's default account.
+	// +optional
+	ServiceAccountName string `json:"serviceAccountName,omitempty"`
+
+	// Expose the OTLP ports on each node's IP. Only valid in DaemonSet mode;
+	// without it the node-local agent is reached through the Service, whose
+	// internalTrafficPolicy is Local in DaemonSet mode.
+	// +optional
+	HostPort bool `json:"hostPort,omitempty"`
+
+	// Extra command-line arguments appended after --config.
+	// +listType=atomic
+	// +optional
+	ExtraArgs []string `json:"extraArgs,omitempty"`
+
+	// Scale the agent with a HorizontalPodAutoscaler instead of a fixed
+	// Replicas. Only valid in Deployment and StatefulSet mode.
+	// +optional
+	Autoscaling *AutoscalingSpec `json:"autoscaling,omitempty"`
+}
+
+// QueueSizeMetric is the collector metric the autoscaler reads through the
+// custom metrics API when TargetQueueSize is set.
+const QueueSizeMetric = "otelcol_exporter_queue_size"
+
+// AutoscalingSpec configures the HorizontalPodAutoscaler owned by the config.
+// Without any target the agent scales on 80% average CPU utilization.
+// +kubebuilder:validation:XValidation:rule="!has(self.minReplicas) || self.minReplicas <= self.maxReplicas",message="minReplicas must not exceed maxReplicas"
+type AutoscalingSpec struct {
+	// Defaults to 1.
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	MinReplicas *int32 `json:"minReplicas,omitempty"`
+
+	// +kubebuilder:validation:Minimum=1
+	MaxReplicas int32 `json:"maxReplicas"`
+
+	// Average CPU utilization of the agent pods, in percent of the CPU request.
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	TargetCPUUtilization *int32 `json:"targetCPUUtilization,omitempty"`
+
+	// Average memory utilization of the agent pods, in percent of the memory request.
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	TargetMemoryUtilization *int32 `json:"targetMemoryUtilization,omitempty"`
+
+	// Average number of batches waiting in the exporter sending queues per pod,
+	// read as otelcol_exporter_queue_size through the custom metrics API. The
+	// cluster needs an adapter (e.g. prometheus-adapter) serving that metric
+	// from the agent's telemetry port.
+	// +optional
+	TargetQueueSize *resource.Quantity `json:"targetQueueSize,omitempty"`
+}
+
+// ObservabilityConfigSpec defines the desired state of ObservabilityConfig
+// +kubebuilder:validation:XValidation:rule="has(self.inheritFrom) || (has(self.endpoints) && size(self.endpoints) > 0) || (has(self.exporters) && size(self.exporters) > 0)",message="at least one of endpoints or exporters is required"
+// +kubebuilder:validation:XValidation:rule="!has(self.agent) || !has(self.agent.hostPort) || !self.agent.hostPort || has(self.inheritFrom) || (has(self.mode) && self.mode == 'DaemonSet')",message="agent.hostPort requires mode DaemonSet"
+// +kubebuilder:validation:XValidation:rule="!has(self.logs) || has(self.inheritFrom) || (has(self.mode) && self.mode == 'DaemonSet')",message="logs requires mode DaemonSet"
+// +kubebuilder:validation:XValidation:rule="!has(self.agent) || !has(self.agent.autoscaling) || has(self.inheritFrom) || !has(self.mode) || self.mode != 'DaemonSet'",message="agent.autoscaling is not supported in mode DaemonSet"
+// +kubebuilder:validation:XValidation:rule="!has(self.persistence) || has(self.inheritFrom) || !has(self.mode) || self.mode == 'StatefulSet'",message="persistence requires mode StatefulSet"
+// +kubebuilder:validation:XValidation:rule="!has(self.topology) || self.topology != 'gateway' || has(self.inheritFrom) || !has(self.mode) || self.mode == 'DaemonSet'",message="topology gateway requires mode DaemonSet"
+type ObservabilityConfigSpec struct {
+	// Name of a ClusterObservabilityConfig selecting this namespace. Its settings
+	// apply wherever this spec leaves a field unset; exporters are merged by name
+	// and agent settings field by field.
+	// +kubebuilder:validation:MinLength=1
+	// +optional
+	InheritFrom string `json:"inheritFrom,omitempty"`
+
+	// Endpoints of OTLP/gRPC backends the agent exports to. Each one becomes
+	// an otlp exporter named endpoint-<index>.
+	// +listType=atomic
+	// +kubebuilder:validation:MaxItems=16
+	// +optional
+	Endpoints []Endpoint `json:"endpoints,omitempty"`
+
+	// Exporters with full settings, in addition to Endpoints.
+	// +listType=map
+	// +listMapKey=name
+	// +optional
+	Exporters []ExporterSpec `json:"exporters,omitempty"`
+
+	// Routing of signals to exporters. When unset, traces and metrics go to
+	// every exporter and logs are not collected.
+	// +optional
+	Pipelines *PipelinesSpec `json:"pipelines,omitempty"`
+
+	// Processors run in order between the memory limiter and the batch processor
+	// of every pipeline they apply to. A tail_sampling processor must come after
+	// every other processor that handles traces.
+	// +listType=map
+	// +listMapKey=name
+	// +kubebuilder:validation:MaxItems=32
+	// +optional
+	Processors []ProcessorSpec `json:"processors,omitempty"`
+
+	// Percentage of traces kept by the collector's probabilistic sampler (the gateway
+	// in gateway topology). Traces are sampled there only: consumers are told to keep
+	// every trace with OTEL_TRACES_SAMPLER=parentbased_always_on in the <name>-oc-env
+	// ConfigMap. Defaults to 10.
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=100
+	// +optional
+	SamplingPercent *int32 `json:"samplingPercent,omitempty"`
+
+	// Enable metrics pipeline. When false the agent does not accept metrics. Defaults to true.
+	// +optional
+	MetricsEnabled *bool `json:"metricsEnabled,omitempty"`
+
+	// Workload kind of the agent. DaemonSet runs one node-local agent per node,
+	// StatefulSet gives agents a stable identity for persistent queues. Defaults to
+	// StatefulSet when persistence is set, DaemonSet with topology gateway and to
+	// Deployment otherwise.
+	// +optional
+	Mode AgentMode `json:"mode,omitempty"`
+
+	// Agent workload settings.
+	// +optional
+	Agent *AgentSpec `json:"agent,omitempty"`
+
+	// Inject OTEL_* environment variables into matching workloads in this namespace.
+	// Removing it reverts the injected variables.
+	// +optional
+	Inject *InjectSpec `json:"inject,omitempty"`
+
+	// Collect container logs from every node. Requires mode DaemonSet. Unless
+	// pipelines.logs is set, the logs go to the same exporters as traces.
+	// +optional
+	Logs *LogsSpec `json:"logs,omitempty"`
+
+	// Enrich telemetry with the sending pod's Kubernetes metadata through the
+	// k8sattributes processor. The operator grants the agent's ServiceAccount
+	// read access to pods and replicasets for as long as this is set.
+	// +optional
+	KubernetesAttributes *KubernetesAttributesSpec `json:"kubernetesAttributes,omitempty"`
+
+	// Buffer the exporters' sending queues on a PersistentVolume so data
+	// survives backend outages and agent restarts. Runs the agent as a
+	// StatefulSet with one volume per replica; mode defaults to StatefulSet
+	// and no other mode is allowed.
+	// +optional
+	Persistence *PersistenceSpec `json:"persistence,omitempty"`
+
+	// agent runs a single tier that exports directly. gateway runs node-local
+	// agents (mode DaemonSet) that forward to a gateway Deployment; traces are
+	// load balanced by trace ID so tail sampling in the gateway sees whole
+	// traces. The exporters, sampling and spec.processors then run in the
+	// gateway. Defaults to agent.
+	// +optional
+	Topology Topology `json:"topology,omitempty"`
+
+	// Gateway tier settings, used when topology is gateway.
+	// +optional
+	Gateway *GatewaySpec `json:"gateway,omitempty"`
+
+	// Prometheus scrape jobs for GrpcBurners or Services in this namespace. The
+	// scraped metrics join the agent's metrics pipeline. In mode DaemonSet each
+	// agent scrapes the pods on its own node; other modes need a single agent
+	// replica. Injected sidecars do not scrape.
+	// +listType=map
+	// +listMapKey=name
+	// +kubebuilder:validation:MaxItems=16
+	// +optional
+	Scrape []ScrapeJob `json:"scrape,omitempty"`
+}
+
+// ScrapeTargetKind is the kind of object a scrape job selects.
+// +kubebuilder:validation:Enum=GrpcBurner;Service
+type ScrapeTargetKind string
+
+const (
+	// ScrapeGrpcBurner scrapes the Services of the selected GrpcBurners.
+	ScrapeGrpcBurner ScrapeTargetKind = "GrpcBurner"
+	// ScrapeService scrapes the selected Services.
+	ScrapeService ScrapeTargetKind = "Service"
+)
+
+// ScrapeJob scrapes the endpoints behind a named Service port.
+type ScrapeJob struct {
+	// Job name, set as the job label of the scraped metrics.
+	// +kubebuilder:validation:Pattern=`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`
+	// +kubebuilder:validation:MaxLength=63
+	Name string `json:"name"`
+
+	// Defaults to GrpcBurner.
+	// +optional
+	Kind ScrapeTargetKind `json:"kind,omitempty"`
+
+	// Label selector over the GrpcBurners or Services. Empty selects all of them.
+	// +optional
+	Selector metav1.LabelSelector `json:"selector,omitempty"`
+
+	// Name of the Service port serving metrics, e.g. a port in GrpcBurner spec.ports.
+	// +kubebuilder:validation:MinLength=1
+	// +kubebuilder:validation:MaxLength=15
+	Port string `json:"port"`
+
+	// Defaults to /metrics.
+	// +kubebuilder:validation:Pattern=`^/`
+	// +optional
+	Path string `json:"path,omitempty"`
+
+	// Defaults to 30s.
+	// +optional
+	Interval *metav1.Duration `json:"interval,omitempty"`
+}
+
+// Topology is how the collectors of a config are laid out.
+// +kubebuilder:validation:Enum=agent;gateway
+type Topology string
+
+const (
+	TopologyAgent   Topology = "agent"
+	TopologyGateway Topology = "gateway"
+)
+
+// GatewaySpec configures the gateway Deployment. It runs the agent image and
+// version and the agent's ServiceAccount.
+type GatewaySpec struct {
+	// Defaults to 2.
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	Replicas *int32 `json:"replicas,omitempty"`
+
+	// +optional
+	Resources corev1.ResourceRequirements `json:"resources,omitempty"`
+}
+
+// PersistenceSpec configures the volumes backing the file_storage extension.
+type PersistenceSpec struct {
+	// StorageClass of the queue volumes. The cluster's default class when unset.
+	// +optional
+	StorageClassName *string `json:"storageClassName,omitempty"`
+
+	// Requested size of each replica's volume. Defaults to 1Gi.
+	// +optional
+	Size *resource.Quantity `json:"size,omitempty"`
+
+	// What happens to the volumes when the agent scales down or the config is deleted.
+	// +optional
+	RetentionPolicy *PersistenceRetentionPolicy `json:"retentionPolicy,omitempty"`
+}
+
+// PersistenceRetention is whether a queue volume outlives its agent pod.
+// +kubebuilder:validation:Enum=Retain;Delete
+type PersistenceRetention string
+
+const (
+	PersistenceRetain PersistenceRetention = "Retain"
+	PersistenceDelete PersistenceRetention = "Delete"
+)
+
+// PersistenceRetentionPolicy maps to the StatefulSet's persistentVolumeClaimRetentionPolicy.
+type PersistenceRetentionPolicy struct {
+	// Volumes of replicas removed by a scale-down. Defaults to Retain, so data
+	// still queued there is sent once the replica comes back.
+	// +optional
+	WhenScaled PersistenceRetention `json:"whenScaled,omitempty"`
+
+	// Volumes left when the config is deleted or persistence is turned off.
+	// Defaults to Delete.
+	// +optional
+	WhenDeleted PersistenceRetention `json:"whenDeleted,omitempty"`
+}
+
+// LogsStartAt is where the agent starts reading a log file it has not seen before.
+// +kubebuilder:validation:Enum=beginning;end
+type LogsStartAt string
+
+// LogsSpec configures the filelog receiver reading /var/log/pods on each node.
+// JSON log lines carrying trace_id and span_id are linked to their traces.
+type LogsSpec struct {
+	// Namespaces whose pod logs are collected. All namespaces when empty.
+	// +listType=set
+	// +kubebuilder:validation:MaxItems=64
+	// +optional
+	IncludeNamespaces []string `json:"includeNamespaces,omitempty"`
+
+	// Namespaces whose pod logs are skipped, applied after IncludeNamespaces.
+	// The agent's own logs are always skipped.
+	// +listType=set
+	// +kubebuilder:validation:MaxItems=64
+	// +optional
+	ExcludeNamespaces []string `json:"excludeNamespaces,omitempty"`
+
+	// Defaults to end, so logs written before the agent started are not re-sent.
+	// +optional
+	StartAt LogsStartAt `json:"startAt,omitempty"`
+
+	// Tolerate every taint so the agent runs, and collects logs, on all nodes.
+	// Defaults to true.
+	// +optional
+	TolerateAllTaints *bool `json:"tolerateAllTaints,omitempty"`
+}
+
+// KubernetesMetadata is a resource attribute the k8sattributes processor derives from the pod.
+// +kubebuilder:validation:Enum=k8s.pod.name;k8s.pod.uid;k8s.pod.start_time;k8s.namespace.name;k8s.node.name;k8s.deployment.name;k8s.replicaset.name;k8s.statefulset.name;k8s.daemonset.name;k8s.job.name;k8s.cronjob.name;k8s.container.name
+type KubernetesMetadata string
+
+// KubernetesAttributesScope is the set of pods the agent watches for metadata.
+// +kubebuilder:validation:Enum=Cluster;Namespace
+type KubernetesAttributesScope string
+
+const (
+	// KubernetesAttributesScopeCluster watches pods in every namespace through a ClusterRole.
+	KubernetesAttributesScopeCluster KubernetesAttributesScope = "Cluster"
+	// KubernetesAttributesScopeNamespace watches only the config's namespace through a Role.
+	KubernetesAttributesScopeNamespace KubernetesAttributesScope = "Namespace"
+)
+
+// KubernetesTagSource is the object a label or annotation is read from.
+// +kubebuilder:validation:Enum=Pod;Namespace
+type KubernetesTagSource string
+
+const (
+	KubernetesTagFromPod       KubernetesTagSource = "Pod"
+	KubernetesTagFromNamespace KubernetesTagSource = "Namespace"
+)
+
+// KubernetesAttributesSpec selects the metadata copied onto telemetry.
+type KubernetesAttributesSpec struct {
+	// Defaults to k8s.pod.name, k8s.pod.uid, k8s.namespace.name, k8s.node.name
+	// and k8s.deployment.name.
+	// +listType=set
+	// +optional
+	Metadata []KubernetesMetadata `json:"metadata,omitempty"`
+
+	// Labels copied to resource attributes.
+	// +listType=atomic
+	// +kubebuilder:validation:MaxItems=32
+	// +optional
+	Labels []KubernetesTag `json:"labels,omitempty"`
+
+	// Annotations copied to resource attributes.
+	// +listType=atomic
+	// +kubebuilder:validation:MaxItems=32
+	// +optional
+	Annotations []KubernetesTag `json:"annotations,omitempty"`
+
+	// Defaults to Cluster. Namespace limits the agent to pods in the config's
+	// namespace and needs no cluster-wide permissions.
+	// +optional
+	Scope KubernetesAttributesScope `json:"scope,omitempty"`
+}
+
+// KubernetesTag copies one label or annotation to a resource attribute.
+type KubernetesTag struct {
+	// +kubebuilder:validation:MinLength=1
+	// +kubebuilder:validation:MaxLength=317
+	Key string `json:"key"`
+
+	// Attribute name. Defaults to k8s.pod.labels.<key> (or annotations, or
+	// k8s.namespace.* when read from the namespace).
+	// +kubebuilder:validation:MaxLength=256
+	// +optional
+	TagName string `json:"tagName,omitempty"`
+
+	// Defaults to Pod. Namespace requires scope Cluster.
+	// +optional
+	From KubernetesTagSource `json:"from,omitempty"`
+}
+
+// InjectSpec selects the workloads that send telemetry to this config's agents.
+type InjectSpec struct {
+	// Label selector for Deployments, StatefulSets and DaemonSets in this namespace.
+	// Workloads controlled by another object are skipped.
+	// +required
+	Selector metav1.LabelSelector `json:"selector"`
+
+	// Containers to patch. All containers are patched when empty.
+	// +listType=set
+	// +optional
+	Containers []string `json:"containers,omitempty"`
+}
+
+// ObservabilityConfigStatus defines the observed state of ObservabilityConfig.
+type ObservabilityConfigStatus struct {
+	// +kubebuilder:validation:Enum=Ready;Error;Reconciling
+	// +kubebuilder:default:=Reconciling
+	Phase string `json:"phase,omitempty"`
+
+	// Reason for last transition
+	// +kubebuilder:validation:MaxLength=1024
+	Reason string `json:"reason,omitempty"`
+
+	// +optional
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// +optional
+	ReadyReplicas int32 `json:"readyReplicas,omitempty"`
+
+	// Percentage of traces kept end to end as of the last successful reconcile,
+	// read from the sampler in the applied traces pipeline. Unset without a traces pipeline.
+	// +optional
+	EffectiveSamplingPercent *int32 `json:"effectiveSamplingPercent,omitempty"`
+
+	// Pipelines rendered into the agent configuration, e.g. traces, metrics.
+	// +listType=atomic
+	// +optional
+	ActivePipelines []string `json:"activePipelines,omitempty"`
+
+	// Result of the last reachability check per exporter destination.
+	// +listType=map
+	// +listMapKey=name
+	// +optional
+	Endpoints []EndpointStatus `json:"endpoints,omitempty"`
+
+	// Workloads currently patched by spec.inject.
+	// +listType=map
+	// +listMapKey=kind
+	// +listMapKey=name
+	// +optional
+	InstrumentedWorkloads []WorkloadReference `json:"instrumentedWorkloads,omitempty"`
+
+	// Sidecar injection into Pods annotated with observability.shtsukada.dev/inject.
+	// +optional
+	Injection *InjectionStatus `json:"injection,omitempty"`
+
+	// Settings merged from spec.inheritFrom, set only when inheriting.
+	// +optional
+	Inheritance *InheritanceStatus `json:"inheritance,omitempty"`
+
+	// Scale reported by the agent's HorizontalPodAutoscaler, set only when
+	// spec.agent.autoscaling is.
+	// +optional
+	Autoscaling *AutoscalingStatus `json:"autoscaling,omitempty"`
+
+	// Queue volumes of the agent, set only when spec.persistence is.
+	// +optional
+	Persistence *PersistenceStatus `json:"persistence,omitempty"`
+
+	// Health and self-telemetry reported by the agent pods at the last poll.
+	// +optional
+	Pipeline *PipelineStatus `json:"pipeline,omitempty"`
+}
+
+// ConditionExportFailing reports whether the exporters failed to send more
+// than the operator's threshold of items since the previous poll.
+const ConditionExportFailing ConditionType = "ExportFailing"
+
+// PipelineStatus summarises the agents' health_check extension and internal
+// metrics. Counts are totals over the polled pods since each pod started. With
+// topology gateway, the receiver counts come from the agents and the exporter
+// counts from the gateway, which runs spec.exporters.
+type PipelineStatus struct {
+	// Pods polled, and how many of them answered the health check as healthy.
+	Pods        int32 `json:"pods"`
+	HealthyPods int32 `json:"healthyPods"`
+
+	// Spans the receivers accepted into and refused from the pipeline.
+	// +optional
+	AcceptedSpans int64 `json:"acceptedSpans,omitempty"`
+	// +optional
+	RefusedSpans int64 `json:"refusedSpans,omitempty"`
+
+	// Spans, metric points and log records the exporters sent or failed to send.
+	// +optional
+	SentItems int64 `json:"sentItems,omitempty"`
+	// +optional
+	SendFailedItems int64 `json:"sendFailedItems,omitempty"`
+
+	// +optional
+	LastPollTime *metav1.Time `json:"lastPollTime,omitempty"`
+
+	// Why the last poll could not reach some pods.
+	// +kubebuilder:validation:MaxLength=1024
+	// +optional
+	LastError string `json:"lastError,omitempty"`
+}
+
+// PersistenceStatus reports whether the exporters' queues are on persistent volumes.
+type PersistenceStatus struct {
+	// True once the agent StatefulSet mounts the queue volumes. False while it
+	// is being recreated to add or change them.
+	Active bool `json:"active"`
+
+	// Queue volume claims of the agent that are bound.
+	// +optional
+	BoundClaims int32 `json:"boundClaims,omitempty"`
+}
+
+// AutoscalingStatus mirrors the status of the agent's HorizontalPodAutoscaler.
+type AutoscalingStatus struct {
+	// +optional
+	CurrentReplicas int32 `json:"currentReplicas,omitempty"`
+
+	// +optional
+	DesiredReplicas int32 `json:"desiredReplicas,omitempty"`
+
+	// When the autoscaler last changed the replica count.
+	// +optional
+	LastScaleTime *metav1.Time `json:"lastScaleTime,omitempty"`
+
+	// Last scaling decision of the autoscaler, e.g. why it could not scale.
+	// +kubebuilder:validation:MaxLength=1024
+	// +optional
+	LastScaleEvent string `json:"lastScaleEvent,omitempty"`
+}
+
+// ConditionInherited reports whether the ClusterObservabilityConfig named in spec.inheritFrom was applied.
+const ConditionInherited ConditionType = "Inherited"
+
+// ConfigSource is where an effective setting came from.
+// +kubebuilder:validation:Enum=ObservabilityConfig;ClusterObservabilityConfig;Default
+type ConfigSource string
+
+const (
+	ConfigSourceLocal   ConfigSource = "ObservabilityConfig"
+	ConfigSourceCluster ConfigSource = "ClusterObservabilityConfig"
+	ConfigSourceDefault ConfigSource = "Default"
+)
+
+// FieldSource records the origin of one effective setting.
+type FieldSource struct {
+	// Path relative to spec, e.g. samplingPercent, exporters[vendor] or agent.image.
+	Field string `json:"field"`
+
+	Source ConfigSource `json:"source"`
+}
+
+// InheritanceStatus shows the merged configuration the agent runs with.
+type InheritanceStatus struct {
+	// ClusterObservabilityConfig the settings were merged from.
+	From string `json:"from"`
+
+	// +optional
+	Effective InheritableSpec `json:"effective,omitempty"`
+
+	// Origin of every setting in Effective.
+	// +listType=map
+	// +listMapKey=field
+	// +optional
+	Sources []FieldSource `json:"sources,omitempty"`
+}
+
+// ConditionAgentReady and ConditionGatewayReady report the rollout of each tier
+// with topology gateway. Ready is true only when both are.
+const (
+	ConditionAgentReady   ConditionType = "AgentReady"
+	ConditionGatewayReady ConditionType = "GatewayReady"
+)
+
+// ConditionEndpointReachable reports whether every exporter destination answered the last check.
+const ConditionEndpointReachable ConditionType = "EndpointReachable"
+
+// WorkloadReference identifies a workload in the config's namespace.
+type WorkloadReference struct {
+	// +kubebuilder:validation:Enum=Deployment;StatefulSet;DaemonSet
+	Kind string `json:"kind"`
+	Name string `json:"name"`
+}
+
+// ConditionSidecarInjected reports whether every Pod requesting this config received its sidecar.
+const ConditionSidecarInjected ConditionType = "SidecarInjected"
+
+// InjectionStatus summarises sidecar injection for Pods referencing this config.
+type InjectionStatus struct {
+	// Number of Pods that received the agent sidecar.
+	InjectedPods int32 `json:"injectedPods"`
+
+	// Pods the webhook could not inject, at most 10.
+	// +listType=map
+	// +listMapKey=pod
+	// +kubebuilder:validation:MaxItems=10
+	// +optional
+	Failures []InjectionFailure `json:"failures,omitempty"`
+}
+
+// InjectionFailure records why a Pod did not receive the sidecar.
+type InjectionFailure struct {
+	Pod string `json:"pod"`
+
+	// +kubebuilder:validation:MaxLength=1024
+	Error string `json:"error"`
+}
+
+// EndpointStatus is the result of probing one exporter destination.
+type EndpointStatus struct {
+	// Exporter name, endpoint-<i> for spec.endpoints.
+	Name string `json:"name"`
+
+	// Address that was probed, as host:port.
+	Address string `json:"address"`
+
+	Reachable bool `json:"reachable"`
+
+	// Time taken by the last successful check.
+	// +optional
+	Latency *metav1.Duration `json:"latency,omitempty"`
+
+	// Error returned by the last failed check.
+	// +kubebuilder:validation:MaxLength=1024
+	// +optional
+	LastError string `json:"lastError,omitempty"`
+
+	// +optional
+	LastCheckTime *metav1.Time `json:"lastCheckTime,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:path=observabilityconfigs,scope=Namespaced,shortName=obscfg,categories=all
+// +kubebuilder:subresource:status
+// +kubebuilder:storageversion
+// +kubebuilder:printcolumn:name="Mode",type=string,JSONPath=`.spec.mode`
+// +kubebuilder:printcolumn:name="Endpoints",type=string,JSONPath=`.spec.endpoints`
+// +kubebuilder:printcolumn:name="Inherits",type=string,JSONPath=`.spec.inheritFrom`,priority=1
+// +kubebuilder:printcolumn:name="Sampling",type=integer,JSONPath=`.status.effectiveSamplingPercent`
+// +kubebuilder:printcolumn:name="Pipelines",type=string,JSONPath=`.status.activePipelines`
+// +kubebuilder:printcolumn:name="Phase",type=string,JSONPath=`.status.phase`
+// +kubebuilder:printcolumn:name="Replicas",type=integer,JSONPath=`.status.readyReplicas`,description="Ready agent replicas"
+// +kubebuilder:printcolumn:name="Ready",type=string,JSONPath=`.status.conditions[?(@.type=="Ready")].status`,priority=1
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// ObservabilityConfig is the Schema for the observabilityconfigs API
+type ObservabilityConfig struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	// +required
+	Spec ObservabilityConfigSpec `json:"spec,omitempty"`
+
+	// +optional
+	Status ObservabilityConfigStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// ObservabilityConfigList contains a list of ObservabilityConfig
+type ObservabilityConfigList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ObservabilityConfig `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&ObservabilityConfig{}, &ObservabilityConfigList{})
+}
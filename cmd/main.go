@@ -21,21 +21,32 @@ import (
 	"flag"
 	"fmt"
 	"os"
+	"time"
 
 	"github.com/go-logr/zapr"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 
 	observabilityv1alpha1 "github.com/shtsukada/cloudnative-observability-operator/api/v1alpha1"
+	observabilityv1beta1 "github.com/shtsukada/cloudnative-observability-operator/api/v1beta1"
+	"github.com/shtsukada/cloudnative-observability-operator/internal/agenthealth"
+	"github.com/shtsukada/cloudnative-observability-operator/internal/collector"
 	internalcontrollers "github.com/shtsukada/cloudnative-observability-operator/internal/controller"
+	"github.com/shtsukada/cloudnative-observability-operator/internal/migration"
+	"github.com/shtsukada/cloudnative-observability-operator/internal/reachability"
 	tel "github.com/shtsukada/cloudnative-observability-operator/internal/shared/telemetry"
+	webhookv1 "github.com/shtsukada/cloudnative-observability-operator/internal/webhook/v1"
+	webhookv1beta1 "github.com/shtsukada/cloudnative-observability-operator/internal/webhook/v1beta1"
 
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
 	"sigs.k8s.io/controller-runtime/pkg/metrics/server"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
 	// +kubebuilder:scaffold:imports
 )
 
@@ -46,7 +57,9 @@ var (
 
 func init() {
 	utilruntime.Must(clientgoscheme.AddToScheme(scheme))
+	utilruntime.Must(apiextensionsv1.AddToScheme(scheme))
 	utilruntime.Must(observabilityv1alpha1.AddToScheme(scheme))
+	utilruntime.Must(observabilityv1beta1.AddToScheme(scheme))
 	// +kubebuilder:scaffold:scheme
 }
 
@@ -92,10 +105,35 @@ func run() error {
 	var metricsAddr string
 	var probeAddr string
 	var enableLeaderElection bool
+	var migrateStorageVersion bool
+	var defaultAgentImage string
+	var endpointCheckInterval time.Duration
+	var endpointCheckHandshake bool
+	var endpointCheckConcurrency int
+	var agentPollInterval time.Duration
+	var exportFailureThreshold float64
+	var webhookCertPath, webhookCertName, webhookCertKey string
 
 	flag.StringVar(&metricsAddr, "metrics-bind-address", ":8080", "The address the metrics endpoint binds to.")
 	flag.StringVar(&probeAddr, "health-probe-bind-address", ":8081", "The address the probe endpoint binds to.")
 	flag.BoolVar(&enableLeaderElection, "leader-elect", false, "Enable leader election for controller manager.")
+	flag.BoolVar(&migrateStorageVersion, "migrate-storage-version", true,
+		"Rewrite stored custom resources in the current storage version and prune status.storedVersions on startup.")
+	flag.StringVar(&defaultAgentImage, "default-agent-image", collector.DefaultImage,
+		"Collector image used for ObservabilityConfig agents that do not set spec.agent.image.")
+	flag.DurationVar(&endpointCheckInterval, "endpoint-check-interval", internalcontrollers.DefaultEndpointCheckInterval,
+		"How often ObservabilityConfig exporter endpoints are checked for reachability. 0 disables the checks.")
+	flag.BoolVar(&endpointCheckHandshake, "endpoint-check-handshake", false,
+		"Send an empty OTLP export to each endpoint after connecting instead of only dialing it.")
+	flag.IntVar(&endpointCheckConcurrency, "endpoint-check-concurrency", reachability.DefaultMaxConcurrent,
+		"Maximum number of endpoint checks running at the same time.")
+	flag.DurationVar(&agentPollInterval, "agent-poll-interval", internalcontrollers.DefaultAgentPollInterval,
+		"How often ObservabilityConfig agent pods are polled for health and internal metrics. 0 disables the polls.")
+	flag.Float64Var(&exportFailureThreshold, "export-failure-threshold", internalcontrollers.DefaultExportFailureThreshold,
+		"Fraction of items failing to send between two agent polls above which ExportFailing is set.")
+	flag.StringVar(&webhookCertPath, "webhook-cert-path", "", "The directory that contains the webhook certificate.")
+	flag.StringVar(&webhookCertName, "webhook-cert-name", "tls.crt", "The name of the webhook certificate file.")
+	flag.StringVar(&webhookCertKey, "webhook-cert-key", "tls.key", "The name of the webhook key file.")
 	flag.Parse()
 
 	// ロガー初期化と controller-runtime への設定
@@ -106,12 +144,21 @@ func run() error {
 	}()
 	ctrl.SetLogger(zapr.NewLogger(zl))
 
+	// Webhook サーバ（証明書パス未指定時は controller-runtime 既定のディレクトリ）
+	webhookServerOptions := webhook.Options{}
+	if len(webhookCertPath) > 0 {
+		webhookServerOptions.CertDir = webhookCertPath
+		webhookServerOptions.CertName = webhookCertName
+		webhookServerOptions.KeyName = webhookCertKey
+	}
+
 	// Manager 構築
 	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
 		Scheme: scheme,
 		Metrics: server.Options{
 			BindAddress: metricsAddr,
 		},
+		WebhookServer:          webhook.NewServer(webhookServerOptions),
 		HealthProbeBindAddress: probeAddr,
 		LeaderElection:         enableLeaderElection,
 		LeaderElectionID:       "cno-operator.shtsukada.dev",
@@ -145,9 +192,20 @@ func run() error {
 	}
 	{
 		oc := &internalcontrollers.ObservabilityConfigReconciler{
-			Client:   mgr.GetClient(),
-			Scheme:   mgr.GetScheme(),
-			Recorder: mgr.GetEventRecorderFor("cloudnative-observability-operator"),
+			Client:            mgr.GetClient(),
+			Scheme:            mgr.GetScheme(),
+			Recorder:          mgr.GetEventRecorderFor("cloudnative-observability-operator"),
+			DefaultAgentImage: defaultAgentImage,
+		}
+		if endpointCheckInterval > 0 {
+			oc.Checker = reachability.NewChecker(endpointCheckHandshake)
+			oc.Checker.MaxConcurrent = endpointCheckConcurrency
+			oc.CheckInterval = endpointCheckInterval
+		}
+		if agentPollInterval > 0 {
+			oc.Poller = agenthealth.NewPoller()
+			oc.PollInterval = agentPollInterval
+			oc.ExportFailureThreshold = exportFailureThreshold
 		}
 		if err := oc.SetupWithManager(mgr); err != nil {
 			setupLog.Error(err, "unable to create controller", "controller", "ObservabilityConfig")
@@ -155,6 +213,42 @@ func run() error {
 		}
 	}
 
+	// Webhook 登録（ローカル実行では ENABLE_WEBHOOKS=false で無効化できる）
+	if os.Getenv("ENABLE_WEBHOOKS") != "false" {
+		if err := webhookv1beta1.SetupGrpcBurnerWebhookWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "GrpcBurner")
+			return err
+		}
+		if err := webhookv1beta1.SetupObservabilityConfigWebhookWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "ObservabilityConfig")
+			return err
+		}
+		if err := webhookv1.SetupPodWebhookWithManager(mgr, defaultAgentImage); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "Pod")
+			return err
+		}
+	}
+
+	// 旧 storage version (v1alpha1) で保存済みのオブジェクトを v1beta1 で書き直す
+	if migrateStorageVersion {
+		if err := mgr.Add(&migration.StorageVersionMigrator{
+			Client: mgr.GetClient(),
+			Resources: []migration.Resource{
+				{
+					CRD:     "grpcburners.observability.shtsukada.dev",
+					NewList: func() client.ObjectList { return &observabilityv1beta1.GrpcBurnerList{} },
+				},
+				{
+					CRD:     "observabilityconfigs.observability.shtsukada.dev",
+					NewList: func() client.ObjectList { return &observabilityv1beta1.ObservabilityConfigList{} },
+				},
+			},
+		}); err != nil {
+			setupLog.Error(err, "unable to set up storage version migration")
+			return err
+		}
+	}
+
 	// ヘルスチェック
 	if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
 		setupLog.Error(err, "unable to set up health check")
@@ -0,0 +1,289 @@
+package agenthealth
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/shtsukada/cloudnative-observability-operator/internal/collector"
+)
+
+const (
+	DefaultTimeout       = 3 * time.Second
+	DefaultMaxConcurrent = 8
+)
+
+// Target は 1 つの agent の Pod。
+type Target struct {
+	Pod string
+	// UID は再作成された同名の Pod と区別するため。
+	UID string
+	// Host は Pod の IP。
+	Host string
+}
+
+// Metrics は collector が自身について公開するメトリクスのうち status に載せるもの。
+// 値は Pod の起動からの累計。
+type Metrics struct {
+	AcceptedSpans   int64
+	RefusedSpans    int64
+	SentItems       int64
+	SendFailedItems int64
+}
+
+// Add は m に o を足す。
+func (m *Metrics) Add(o Metrics) {
+	m.AcceptedSpans += o.AcceptedSpans
+	m.RefusedSpans += o.RefusedSpans
+	m.SentItems += o.SentItems
+	m.SendFailedItems += o.SendFailedItems
+}
+
+// Result は 1 つの Target に対する確認結果。
+type Result struct {
+	Target  Target
+	Healthy bool
+	Metrics Metrics
+	// Err は health_check かメトリクスを読めなかった理由。
+	Err error
+}
+
+// Poller は agent の health_check extension と自身のメトリクスを読む。
+// 増分を求めるため Pod ごとに前回の累計を覚えておく。複数の Reconcile から共有して使う。
+type Poller struct {
+	Client        *http.Client
+	Timeout       time.Duration
+	MaxConcurrent int
+
+	// テストで手元の HTTP サーバに向けるため
+	HealthPort  int32
+	MetricsPort int32
+
+	once     sync.Once
+	sem      chan struct{}
+	mu       sync.Mutex
+	counters map[string]Metrics
+}
+
+// NewPoller は既定値で初期化した Poller を返す。
+func NewPoller() *Poller {
+	return &Poller{}
+}
+
+func (p *Poller) init() {
+	p.once.Do(func() {
+		if p.Client == nil {
+			p.Client = &http.Client{}
+		}
+		if p.Timeout == 0 {
+			p.Timeout = DefaultTimeout
+		}
+		if p.MaxConcurrent <= 0 {
+			p.MaxConcurrent = DefaultMaxConcurrent
+		}
+		if p.HealthPort == 0 {
+			p.HealthPort = collector.HealthCheckPort
+		}
+		if p.MetricsPort == 0 {
+			p.MetricsPort = collector.MetricsPort
+		}
+		p.sem = make(chan struct{}, p.MaxConcurrent)
+		p.counters = map[string]Metrics{}
+	})
+}
+
+// PollAll は targets を並行して確認し、入力と同じ順で結果を返す。
+func (p *Poller) PollAll(ctx context.Context, targets []Target) []Result {
+	p.init()
+	results := make([]Result, len(targets))
+	var wg sync.WaitGroup
+	for i, t := range targets {
+		wg.Add(1)
+		go func(i int, t Target) {
+			defer wg.Done()
+			select {
+			case p.sem <- struct{}{}:
+			case <-ctx.Done():
+				results[i] = Result{Target: t, Err: ctx.Err()}
+				return
+			}
+			results[i] = p.Poll(ctx, t)
+			<-p.sem
+		}(i, t)
+	}
+	wg.Wait()
+	return results
+}
+
+// Poll は 1 つの Target を確認する。health_check が 200 以外を返した Pod も、
+// メトリクスを読めれば Metrics を埋める。
+func (p *Poller) Poll(ctx context.Context, t Target) Result {
+	p.init()
+	ctx, cancel := context.WithTimeout(ctx, p.Timeout)
+	defer cancel()
+
+	res := Result{Target: t}
+	status, _, err := p.get(ctx, p.url(t, p.HealthPort, "/"))
+	if err != nil {
+		res.Err = fmt.Errorf("health check: %w", err)
+		return res
+	}
+	res.Healthy = status == http.StatusOK
+
+	status, body, err := p.get(ctx, p.url(t, p.MetricsPort, "/metrics"))
+	if err == nil && status != http.StatusOK {
+		err = fmt.Errorf("status %d", status)
+	}
+	if err == nil {
+		res.Metrics, err = ParseMetrics(strings.NewReader(body))
+	}
+	if err != nil {
+		res.Err = fmt.Errorf("metrics: %w", err)
+	}
+	return res
+}
+
+// Increase は owner (namespace/name) の Pod ごとに前回の Increase からの増分を求めて合計する。
+// 累計が減った Pod (再起動) と初めて見る Pod は今回の累計を増分とする。
+// 読めなかった Pod は前回の累計を残して次に持ち越し、results に無い Pod の記録は破棄する。
+// 読めた Pod が無ければ false を返す。
+func (p *Poller) Increase(owner string, results []Result) (Metrics, bool) {
+	p.init()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var sum Metrics
+	polled := false
+	keep := map[string]bool{}
+	for _, res := range results {
+		key := counterKey(owner, res.Target)
+		keep[key] = true
+		if res.Err != nil {
+			continue
+		}
+		cur, delta := res.Metrics, res.Metrics
+		if prev, ok := p.counters[key]; ok && !cur.lessThan(prev) {
+			delta = cur.sub(prev)
+		}
+		p.counters[key] = cur
+		sum.Add(delta)
+		polled = true
+	}
+	for key := range p.counters {
+		if strings.HasPrefix(key, owner+"|") && !keep[key] {
+			delete(p.counters, key)
+		}
+	}
+	return sum, polled
+}
+
+// Forget は owner の記録をすべて破棄する。
+func (p *Poller) Forget(owner string) {
+	p.init()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for key := range p.counters {
+		if strings.HasPrefix(key, owner+"|") {
+			delete(p.counters, key)
+		}
+	}
+}
+
+func counterKey(owner string, t Target) string {
+	return owner + "|" + t.Pod + "|" + t.UID
+}
+
+// lessThan はいずれかの累計が o より小さい (途中で 0 に戻った) かを返す。
+func (m Metrics) lessThan(o Metrics) bool {
+	return m.AcceptedSpans < o.AcceptedSpans || m.RefusedSpans < o.RefusedSpans ||
+		m.SentItems < o.SentItems || m.SendFailedItems < o.SendFailedItems
+}
+
+func (m Metrics) sub(o Metrics) Metrics {
+	return Metrics{
+		AcceptedSpans:   m.AcceptedSpans - o.AcceptedSpans,
+		RefusedSpans:    m.RefusedSpans - o.RefusedSpans,
+		SentItems:       m.SentItems - o.SentItems,
+		SendFailedItems: m.SendFailedItems - o.SendFailedItems,
+	}
+}
+
+func (p *Poller) url(t Target, port int32, path string) string {
+	return "http://" + net.JoinHostPort(t.Host, strconv.Itoa(int(port))) + path
+}
+
+func (p *Poller) get(ctx context.Context, url string) (int, string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return 0, "", err
+	}
+	resp, err := p.Client.Do(req)
+	if err != nil {
+		return 0, "", err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	// 自身のメトリクスは大きくても数百 KB
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 4<<20))
+	return resp.StatusCode, string(body), err
+}
+
+// counter は集計するメトリクスなら足し込む先を返す。
+// collector のバージョンによって counter に _total が付くので、付かない名前で引く。
+func counter(m *Metrics, name string) *int64 {
+	switch strings.TrimSuffix(name, "_total") {
+	case "otelcol_receiver_accepted_spans":
+		return &m.AcceptedSpans
+	case "otelcol_receiver_refused_spans":
+		return &m.RefusedSpans
+	case "otelcol_exporter_sent_spans", "otelcol_exporter_sent_metric_points", "otelcol_exporter_sent_log_records":
+		return &m.SentItems
+	case "otelcol_exporter_send_failed_spans", "otelcol_exporter_send_failed_metric_points", "otelcol_exporter_send_failed_log_records":
+		return &m.SendFailedItems
+	}
+	return nil
+}
+
+// ParseMetrics は Prometheus のテキスト形式から counter の値を label をまたいで合計する。
+func ParseMetrics(r io.Reader) (Metrics, error) {
+	var m Metrics
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 0, 64*1024), 1<<20)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		name, rest := line, ""
+		if i := strings.IndexAny(line, "{ "); i >= 0 {
+			name, rest = line[:i], line[i:]
+		}
+		field := counter(&m, name)
+		if field == nil {
+			continue
+		}
+		// label の値には空白や "}" が入りうるので、最後の "}" の後ろを値とする
+		if strings.HasPrefix(rest, "{") {
+			i := strings.LastIndex(rest, "}")
+			if i < 0 {
+				return Metrics{}, fmt.Errorf("malformed sample %q", line)
+			}
+			rest = rest[i+1:]
+		}
+		fields := strings.Fields(rest)
+		if len(fields) == 0 {
+			return Metrics{}, fmt.Errorf("malformed sample %q", line)
+		}
+		v, err := strconv.ParseFloat(fields[0], 64)
+		if err != nil {
+			return Metrics{}, fmt.Errorf("malformed sample %q: %w", line, err)
+		}
+		*field += int64(v)
+	}
+	return m, sc.Err()
+}
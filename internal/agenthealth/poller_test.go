@@ -0,0 +1,115 @@
+package agenthealth
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+const sampleMetrics = `# HELP otelcol_receiver_accepted_spans_total Number of spans successfully pushed into the pipeline.
+# TYPE otelcol_receiver_accepted_spans_total counter
+otelcol_receiver_accepted_spans_total{receiver="otlp",transport="grpc"} 120
+otelcol_receiver_accepted_spans_total{receiver="otlp",transport="http"} 30
+otelcol_receiver_refused_spans_total{receiver="otlp",transport="grpc"} 2
+otelcol_exporter_sent_spans_total{exporter="otlp/0"} 140
+otelcol_exporter_sent_metric_points{exporter="otlp/0"} 10
+otelcol_exporter_send_failed_spans_total{exporter="otlp/0",note="a } b"} 8
+otelcol_exporter_queue_size{exporter="otlp/0"} 5
+otelcol_process_uptime 1.2e+03
+`
+
+func TestParseMetrics(t *testing.T) {
+	m, err := ParseMetrics(strings.NewReader(sampleMetrics))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Metrics{AcceptedSpans: 150, RefusedSpans: 2, SentItems: 150, SendFailedItems: 8}
+	if m != want {
+		t.Fatalf("metrics = %+v, want %+v", m, want)
+	}
+	if _, err := ParseMetrics(strings.NewReader("otelcol_exporter_sent_spans{exporter=\"otlp\"\n")); err == nil {
+		t.Fatal("malformed sample accepted")
+	}
+}
+
+func TestPoll(t *testing.T) {
+	healthy := true
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/":
+			if !healthy {
+				w.WriteHeader(http.StatusServiceUnavailable)
+			}
+		case "/metrics":
+			_, _ = w.Write([]byte(sampleMetrics))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+	host, port, _ := net.SplitHostPort(srv.Listener.Addr().String())
+	n, _ := strconv.Atoi(port)
+	p := &Poller{HealthPort: int32(n), MetricsPort: int32(n)}
+	ctx := context.Background()
+
+	res := p.PollAll(ctx, []Target{{Pod: "agent-0", Host: host}})
+	if len(res) != 1 || !res[0].Healthy || res[0].Err != nil || res[0].Metrics.SentItems != 150 {
+		t.Fatalf("result = %+v", res)
+	}
+
+	// 不健全でもメトリクスは読む
+	healthy = false
+	if r := p.Poll(ctx, Target{Pod: "agent-0", Host: host}); r.Healthy || r.Err != nil || r.Metrics.AcceptedSpans != 150 {
+		t.Fatalf("result = %+v", r)
+	}
+
+	srv.Close()
+	if r := p.Poll(ctx, Target{Pod: "agent-0", Host: host}); r.Err == nil || r.Healthy {
+		t.Fatalf("result = %+v", r)
+	}
+}
+
+func TestIncrease(t *testing.T) {
+	p := &Poller{}
+	a, b := Target{Pod: "agent-a", UID: "a"}, Target{Pod: "agent-b", UID: "b"}
+	res := func(t Target, sent, failed int64) Result {
+		return Result{Target: t, Metrics: Metrics{SentItems: sent, SendFailedItems: failed}}
+	}
+
+	// 初めて見る Pod は累計がそのまま増分
+	if m, ok := p.Increase("default/oc", []Result{res(a, 100, 0), res(b, 100, 0)}); !ok || m.SentItems != 200 {
+		t.Fatalf("increase = %+v, %v", m, ok)
+	}
+	if m, _ := p.Increase("default/oc", []Result{res(a, 110, 20), res(b, 200, 0)}); m.SentItems != 110 || m.SendFailedItems != 20 {
+		t.Fatalf("increase = %+v", m)
+	}
+	// 読めなかった Pod は数えず、次に読めたときに持ち越した分を数える
+	if m, _ := p.Increase("default/oc", []Result{res(a, 120, 20), {Target: b, Err: errors.New("timeout")}}); m.SentItems != 10 || m.SendFailedItems != 0 {
+		t.Fatalf("increase = %+v", m)
+	}
+	// 再起動した Pod は今回の累計を増分とする
+	if m, _ := p.Increase("default/oc", []Result{res(a, 5, 0), res(b, 300, 0)}); m.SentItems != 105 {
+		t.Fatalf("increase = %+v", m)
+	}
+	// 同名でも作り直された Pod は別に数える
+	b2 := Target{Pod: "agent-b", UID: "b2"}
+	if m, _ := p.Increase("default/oc", []Result{res(a, 5, 0), res(b2, 7, 0)}); m.SentItems != 7 {
+		t.Fatalf("increase = %+v", m)
+	}
+	if _, ok := p.Increase("default/oc", []Result{{Target: a, Err: errors.New("timeout")}}); ok {
+		t.Fatal("increase reported without a polled pod")
+	}
+	// いなくなった Pod の記録は残さない
+	if len(p.counters) != 1 {
+		t.Fatalf("counters = %v", p.counters)
+	}
+	p.Forget("default/oc")
+	if len(p.counters) != 0 {
+		t.Fatalf("counters = %v", p.counters)
+	}
+}
@@ -0,0 +1,108 @@
+package collector
+
+import (
+	"slices"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+
+	apiv1beta1 "github.com/shtsukada/cloudnative-observability-operator/api/v1beta1"
+)
+
+// ConfigVolumeName は描画済みの設定を agent にマウントする volume の名前。
+const ConfigVolumeName = "config"
+
+// SidecarConfigKey は注入した sidecar が読む設定のキー。sidecar は Pod ごとにいるので、
+// scrape すると同じ target を Pod の数だけ読んでしまう。
+const SidecarConfigKey = "sidecar.yaml"
+
+// ConfigMapName は Render の結果を保存する ConfigMap の名前。
+func ConfigMapName(oc *apiv1beta1.ObservabilityConfig) string {
+	return oc.Name + "-oc-config"
+}
+
+// AgentImage は spec.agent.image/version と既定イメージから実際のイメージを決める。
+func AgentImage(agent *apiv1beta1.AgentSpec, defaultImage string) string {
+	image := defaultImage
+	if agent.Image != "" {
+		image = agent.Image
+	}
+	if agent.Version == "" || strings.Contains(image, "@") {
+		return image
+	}
+	// registry のポート番号と区別するため最後の "/" 以降でタグを探す
+	slash := strings.LastIndex(image, "/")
+	if i := strings.LastIndex(image, ":"); i > slash {
+		image = image[:i]
+	}
+	return image + ":" + agent.Version
+}
+
+// AgentContainer は cfg を読み込む agent コンテナを返す。
+// workload の Pod と sidecar 注入で同じものを使う。
+func AgentContainer(name, image string, agent *apiv1beta1.AgentSpec, cfg *Config) corev1.Container {
+	return corev1.Container{
+		Name:            name,
+		Image:           image,
+		ImagePullPolicy: corev1.PullIfNotPresent,
+		Args:            append([]string{"--config=" + ConfigMountPath + "/" + ConfigKey}, agent.ExtraArgs...),
+		Resources:       agent.Resources,
+		Ports: append([]corev1.ContainerPort{
+			{Name: "otlp-grpc", ContainerPort: OTLPGRPCPort},
+			{Name: "otlp-http", ContainerPort: OTLPHTTPPort},
+		}, cfg.Ports...),
+		Env: cfg.Env,
+		VolumeMounts: append([]corev1.VolumeMount{
+			{Name: ConfigVolumeName, MountPath: ConfigMountPath, ReadOnly: true},
+		}, cfg.VolumeMounts...),
+	}
+}
+
+// AgentVolumes は AgentContainer がマウントする volume を返す。
+func AgentVolumes(oc *apiv1beta1.ObservabilityConfig, cfg *Config) []corev1.Volume {
+	return append([]corev1.Volume{
+		{
+			Name: ConfigVolumeName,
+			VolumeSource: corev1.VolumeSource{
+				ConfigMap: &corev1.ConfigMapVolumeSource{
+					LocalObjectReference: corev1.LocalObjectReference{Name: ConfigMapName(oc)},
+				},
+			},
+		},
+	}, cfg.Volumes...)
+}
+
+// SidecarYAML は workload の agent だけが行うもの (scrape と operator からの監視の待ち受け) を
+// 除いた設定を YAML に変換する。sidecar はアプリのポートと衝突しないよう localhost 以外で待ち受けない。
+// k8sattributes は RBAC の無いアプリの ServiceAccount では Pod を引けないので、
+// filelog はノードのログを DaemonSet の agent しかマウントしないので入れない。
+func (c *Config) SidecarYAML() ([]byte, error) {
+	sidecar := *c
+	sidecar.Receivers = without(without(c.Receivers, PrometheusID), FileLogID)
+	sidecar.Receivers["otlp"] = otlpReceiver("localhost")
+	sidecar.Processors = without(c.Processors, K8sAttributesID)
+	sidecar.Extensions = without(c.Extensions, HealthCheckID)
+	sidecar.Service.Extensions = withoutID(c.Service.Extensions, HealthCheckID)
+	sidecar.Service.Telemetry = nil
+	sidecar.Service.Pipelines = map[string]Pipeline{}
+	for signal, p := range c.Service.Pipelines {
+		p.Receivers = withoutID(withoutID(p.Receivers, PrometheusID), FileLogID)
+		p.Processors = withoutID(p.Processors, K8sAttributesID)
+		sidecar.Service.Pipelines[signal] = p
+	}
+	return sidecar.YAML()
+}
+
+func without(components map[string]any, id string) map[string]any {
+	out := make(map[string]any, len(components))
+	for k, v := range components {
+		if k != id {
+			out[k] = v
+		}
+	}
+	return out
+}
+
+func withoutID(ids []string, id string) []string {
+	return slices.DeleteFunc(slices.Clone(ids), func(v string) bool { return v == id })
+}
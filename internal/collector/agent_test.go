@@ -0,0 +1,25 @@
+package collector
+
+import (
+	"testing"
+
+	apiv1beta1 "github.com/shtsukada/cloudnative-observability-operator/api/v1beta1"
+)
+
+func TestAgentImage(t *testing.T) {
+	const def = "otel/opentelemetry-collector-contrib:0.114.0"
+	tests := []struct {
+		agent apiv1beta1.AgentSpec
+		want  string
+	}{
+		{agent: apiv1beta1.AgentSpec{}, want: def},
+		{agent: apiv1beta1.AgentSpec{Version: "0.115.1"}, want: "otel/opentelemetry-collector-contrib:0.115.1"},
+		{agent: apiv1beta1.AgentSpec{Image: "registry.local:5000/otelcol", Version: "1.2.3"}, want: "registry.local:5000/otelcol:1.2.3"},
+		{agent: apiv1beta1.AgentSpec{Image: "otelcol@sha256:abcd", Version: "1.2.3"}, want: "otelcol@sha256:abcd"},
+	}
+	for _, tt := range tests {
+		if got := AgentImage(&tt.agent, def); got != tt.want {
+			t.Errorf("AgentImage(%+v) = %s, want %s", tt.agent, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,230 @@
+package collector
+
+import (
+	"fmt"
+	"slices"
+
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/yaml"
+
+	apiv1beta1 "github.com/shtsukada/cloudnative-observability-operator/api/v1beta1"
+)
+
+// agent コンテナとの取り決め
+const (
+	DefaultImage = "otel/opentelemetry-collector-contrib:0.114.0"
+
+	ConfigKey       = "collector.yaml"
+	ConfigMountPath = "/conf"
+
+	OTLPGRPCPort    int32 = 4317
+	OTLPHTTPPort    int32 = 4318
+	MetricsPort     int32 = 8888
+	HealthCheckPort int32 = 13133
+
+	// HealthCheckID は operator が agent の状態を確認する extension の ID。
+	HealthCheckID = "health_check"
+)
+
+// Config は OpenTelemetry Collector の設定ファイルの構造。
+// 各コンポーネントの中身は collector 側のスキーマに任せて map で持つ。
+type Config struct {
+	Extensions map[string]any `json:"extensions,omitempty"`
+	Receivers  map[string]any `json:"receivers"`
+	Processors map[string]any `json:"processors,omitempty"`
+	Exporters  map[string]any `json:"exporters"`
+	Service    Service        `json:"service"`
+
+	// 設定が参照する Secret などを agent の Pod に渡すための追加分 (設定ファイルには出さない)
+	Env          []corev1.EnvVar        `json:"-"`
+	Volumes      []corev1.Volume        `json:"-"`
+	VolumeMounts []corev1.VolumeMount   `json:"-"`
+	Ports        []corev1.ContainerPort `json:"-"`
+}
+
+type Service struct {
+	Extensions []string            `json:"extensions,omitempty"`
+	Pipelines  map[string]Pipeline `json:"pipelines"`
+	Telemetry  map[string]any      `json:"telemetry,omitempty"`
+}
+
+type Pipeline struct {
+	Receivers  []string `json:"receivers"`
+	Processors []string `json:"processors,omitempty"`
+	Exporters  []string `json:"exporters"`
+}
+
+// Render は ObservabilityConfig から agent の collector の設定を組み立てる。
+// topology が gateway なら gateway へ転送する agent の設定になる。
+func Render(oc *apiv1beta1.ObservabilityConfig) (*Config, error) {
+	if oc.Spec.Topology == apiv1beta1.TopologyGateway {
+		return renderForwarder(oc)
+	}
+	return render(oc, true)
+}
+
+// newConfig は OTLP の receiver と両端の processor、operator が監視に使う
+// health_check と自身のメトリクスだけを持つ設定を返す。
+func newConfig() *Config {
+	cfg := &Config{
+		Extensions: map[string]any{
+			HealthCheckID: map[string]any{"endpoint": fmt.Sprintf("0.0.0.0:%d", HealthCheckPort)},
+		},
+		Service:   Service{Extensions: []string{HealthCheckID}},
+		Receivers: map[string]any{"otlp": otlpReceiver("0.0.0.0")},
+		// memory_limiter は先頭、batch は末尾に置くのが collector の推奨順
+		Processors: map[string]any{
+			"memory_limiter": map[string]any{
+				"check_interval":         "1s",
+				"limit_percentage":       80,
+				"spike_limit_percentage": 25,
+			},
+			"batch": map[string]any{
+				"send_batch_size": 8192,
+				"timeout":         "200ms",
+			},
+		},
+		Exporters: map[string]any{},
+		Ports:     []corev1.ContainerPort{{Name: "health", ContainerPort: HealthCheckPort}},
+	}
+	cfg.exposeMetrics()
+	return cfg
+}
+
+// otlpReceiver は host の gRPC と HTTP のポートで待ち受ける otlp receiver の設定を返す。
+func otlpReceiver(host string) map[string]any {
+	return map[string]any{
+		"protocols": map[string]any{
+			"grpc": map[string]any{"endpoint": fmt.Sprintf("%s:%d", host, OTLPGRPCPort)},
+			"http": map[string]any{"endpoint": fmt.Sprintf("%s:%d", host, OTLPHTTPPort)},
+		},
+	}
+}
+
+// render は spec の exporter へ送る collector の設定を組み立てる。
+// nodeLocal のときは送信元の Pod やノードに近い所でしかできない処理 (k8sattributes と filelog) も入れる。
+func render(oc *apiv1beta1.ObservabilityConfig, nodeLocal bool) (*Config, error) {
+	if len(oc.Spec.Endpoints) == 0 && len(oc.Spec.Exporters) == 0 {
+		return nil, fmt.Errorf("no endpoints or exporters")
+	}
+
+	cfg := newConfig()
+	if p := SamplingPercent(oc); p < 100 {
+		cfg.Processors["probabilistic_sampler"] = map[string]any{"sampling_percentage": p}
+	}
+
+	exporters := cfg.renderExporters(oc)
+	cfg.renderPersistence(oc)
+	k8sAttributes, fileLog := false, false
+	if nodeLocal {
+		k8sAttributes = cfg.renderK8sAttributes(oc)
+		fileLog = cfg.renderFileLog(oc)
+	}
+	custom := cfg.renderProcessors(oc)
+	cfg.Service.Pipelines = map[string]Pipeline{}
+	for _, signal := range []string{"traces", "metrics", "logs"} {
+		if signal == "metrics" && oc.Spec.MetricsEnabled != nil && !*oc.Spec.MetricsEnabled {
+			continue
+		}
+		ids, err := exporters.route(oc.Spec.Pipelines, signal)
+		if err != nil {
+			return nil, err
+		}
+		receivers := []string{"otlp"}
+		if signal == "logs" && fileLog {
+			receivers = append(receivers, FileLogID)
+		}
+		// ノードから集めたログは trace と突き合わせられるよう同じ送り先に出す
+		if signal == "logs" && oc.Spec.Logs != nil && len(ids) == 0 {
+			if ids, err = exporters.route(oc.Spec.Pipelines, "traces"); err != nil {
+				return nil, err
+			}
+		}
+		if len(ids) == 0 {
+			continue
+		}
+		// spec.processors は sampler で減らした後、batch の前に spec の順で入れる
+		processors := []string{"memory_limiter"}
+		// k8s の属性は後続の processor が参照できるよう最初に付ける
+		if k8sAttributes {
+			processors = append(processors, K8sAttributesID)
+		}
+		if _, ok := cfg.Processors["probabilistic_sampler"]; ok && signal == "traces" {
+			processors = append(processors, "probabilistic_sampler")
+		}
+		processors = append(processors, custom[signal]...)
+		processors = append(processors, "batch")
+		cfg.Service.Pipelines[signal] = Pipeline{Receivers: receivers, Processors: processors, Exporters: ids}
+	}
+	if len(cfg.Service.Pipelines) == 0 {
+		return nil, fmt.Errorf("no pipeline has an exporter")
+	}
+	return cfg, nil
+}
+
+// exposeMetrics は collector 自身のメトリクスを Pod の外から scrape できるようにする。
+// 既定では localhost でしか待ち受けないため、operator の監視と queue size での autoscaling に必要。
+func (c *Config) exposeMetrics() {
+	c.Service.Telemetry = map[string]any{
+		"metrics": map[string]any{"level": "normal", "address": fmt.Sprintf("0.0.0.0:%d", MetricsPort)},
+	}
+	c.Ports = append(c.Ports, corev1.ContainerPort{Name: "metrics", ContainerPort: MetricsPort})
+}
+
+// SamplingPercent は既定値を補った実効サンプリング率を返す。
+func SamplingPercent(oc *apiv1beta1.ObservabilityConfig) int32 {
+	if oc.Spec.SamplingPercent == nil {
+		return apiv1beta1.DefaultSamplingPercent
+	}
+	return *oc.Spec.SamplingPercent
+}
+
+// SamplerEnv は利用側の SDK 向けのサンプラーを OTEL_TRACES_SAMPLER で表す。
+// 間引くのは collector の probabilistic_sampler だけで、SDK では親の判断に従って全て送らせる
+// (SDK でも同じ割合で間引くと実効値が二乗になる)。そのため spec.samplingPercent には依らない。
+func SamplerEnv() map[string]string {
+	return map[string]string{"OTEL_TRACES_SAMPLER": "parentbased_always_on"}
+}
+
+// TracesSamplingPercent は traces パイプラインの probabilistic_sampler が残す割合を返す。
+// traces パイプラインが無いか、割合が読み取れなければ false を返す。
+func (c *Config) TracesSamplingPercent() (int32, bool) {
+	traces, ok := c.Service.Pipelines["traces"]
+	if !ok {
+		return 0, false
+	}
+	if !slices.Contains(traces.Processors, "probabilistic_sampler") {
+		return 100, true
+	}
+	sampler, ok := c.Processors["probabilistic_sampler"].(map[string]any)
+	if !ok {
+		return 0, false
+	}
+	// YAML から読み戻した設定では数値の型が render 時と変わる
+	switch p := sampler["sampling_percentage"].(type) {
+	case int32:
+		return p, true
+	case int:
+		return int32(p), true
+	case int64:
+		return int32(p), true
+	case float64:
+		return int32(p), true
+	}
+	return 0, false
+}
+
+// PipelineNames は有効なパイプライン名を昇順で返す。
+func (c *Config) PipelineNames() []string {
+	names := make([]string, 0, len(c.Service.Pipelines))
+	for name := range c.Service.Pipelines {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// YAML は設定を決定的な順序で YAML に変換する。
+func (c *Config) YAML() ([]byte, error) {
+	return yaml.Marshal(c)
+}
@@ -0,0 +1,195 @@
+package collector
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/yaml"
+
+	apiv1beta1 "github.com/shtsukada/cloudnative-observability-operator/api/v1beta1"
+)
+
+func testConfig(endpoints ...apiv1beta1.Endpoint) *apiv1beta1.ObservabilityConfig {
+	oc := &apiv1beta1.ObservabilityConfig{
+		ObjectMeta: metav1.ObjectMeta{Name: "oc", Namespace: "default"},
+		Spec:       apiv1beta1.ObservabilityConfigSpec{Endpoints: endpoints},
+	}
+	oc.ApplyDefaults()
+	return oc
+}
+
+func TestRender(t *testing.T) {
+	cfg, err := Render(testConfig("otel-collector.monitoring:4317", "https://otlp.example.com:4317"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{"traces", "metrics"} {
+		p, ok := cfg.Service.Pipelines[name]
+		if !ok {
+			t.Fatalf("missing %s pipeline", name)
+		}
+		if n := len(p.Processors); p.Processors[0] != "memory_limiter" || p.Processors[n-1] != "batch" {
+			t.Fatalf("%s processors = %v", name, p.Processors)
+		}
+		if len(p.Exporters) != 2 {
+			t.Fatalf("%s exporters = %v", name, p.Exporters)
+		}
+	}
+
+	plain := cfg.Exporters["otlp/endpoint-0"].(map[string]any)
+	if plain["endpoint"] != "otel-collector.monitoring:4317" || plain["tls"] == nil {
+		t.Fatalf("otlp/endpoint-0 = %v", plain)
+	}
+	if tls := cfg.Exporters["otlp/endpoint-1"].(map[string]any)["tls"]; tls != nil {
+		t.Fatalf("https endpoint must keep TLS, got %v", tls)
+	}
+}
+
+func TestRenderYAML(t *testing.T) {
+	oc := testConfig("otel-collector.monitoring:4317")
+	a, err := mustRender(t, oc).YAML()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := mustRender(t, oc).YAML()
+	if !bytes.Equal(a, b) {
+		t.Fatal("rendering is not deterministic")
+	}
+
+	// collector が読めるよう、各ポートで listen する
+	var out map[string]any
+	if err := yaml.Unmarshal(a, &out); err != nil {
+		t.Fatal(err)
+	}
+	protocols := out["receivers"].(map[string]any)["otlp"].(map[string]any)["protocols"].(map[string]any)
+	if protocols["grpc"].(map[string]any)["endpoint"] != "0.0.0.0:4317" ||
+		protocols["http"].(map[string]any)["endpoint"] != "0.0.0.0:4318" {
+		t.Fatalf("unexpected receivers: %v", protocols)
+	}
+}
+
+func mustRender(t *testing.T, oc *apiv1beta1.ObservabilityConfig) *Config {
+	t.Helper()
+	cfg, err := Render(oc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cfg
+}
+
+func TestRenderSelfMonitoring(t *testing.T) {
+	cfg := mustRender(t, testConfig("otel-collector.monitoring:4317"))
+	if ep := cfg.Extensions[HealthCheckID].(map[string]any)["endpoint"]; ep != "0.0.0.0:13133" || cfg.Service.Extensions[0] != HealthCheckID {
+		t.Fatalf("health_check = %v, service.extensions = %v", ep, cfg.Service.Extensions)
+	}
+	if cfg.Service.Telemetry == nil || len(cfg.Ports) != 2 {
+		t.Fatalf("telemetry = %v, ports = %v", cfg.Service.Telemetry, cfg.Ports)
+	}
+
+	// sidecar はアプリのポートと衝突しないよう外から読ませない
+	data, err := cfg.SidecarYAML()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sidecar Config
+	if err := yaml.Unmarshal(data, &sidecar); err != nil {
+		t.Fatal(err)
+	}
+	if len(sidecar.Extensions) != 0 || len(sidecar.Service.Extensions) != 0 || sidecar.Service.Telemetry != nil {
+		t.Fatalf("sidecar config:\n%s", data)
+	}
+	for _, protocol := range []string{"grpc", "http"} {
+		ep := sidecar.Receivers["otlp"].(map[string]any)["protocols"].(map[string]any)[protocol].(map[string]any)["endpoint"].(string)
+		if !strings.HasPrefix(ep, "localhost:") {
+			t.Fatalf("sidecar %s endpoint = %s", protocol, ep)
+		}
+	}
+	if err := Validate(data); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := cfg.Extensions[HealthCheckID]; !ok || cfg.Receivers["otlp"].(map[string]any)["protocols"].(map[string]any)["grpc"].(map[string]any)["endpoint"] != "0.0.0.0:4317" {
+		t.Fatal("SidecarYAML modified the agent config")
+	}
+}
+
+func TestRenderSamplingAndMetrics(t *testing.T) {
+	oc := testConfig("otel-collector.monitoring:4317")
+	oc.Spec.SamplingPercent = ptr.To(int32(25))
+	oc.Spec.MetricsEnabled = ptr.To(false)
+
+	cfg := mustRender(t, oc)
+	if got := cfg.PipelineNames(); len(got) != 1 || got[0] != "traces" {
+		t.Fatalf("pipelines = %v", got)
+	}
+	traces := cfg.Service.Pipelines["traces"]
+	if traces.Processors[1] != "probabilistic_sampler" || traces.Processors[2] != "batch" {
+		t.Fatalf("traces processors = %v", traces.Processors)
+	}
+	if p := cfg.Processors["probabilistic_sampler"].(map[string]any)["sampling_percentage"]; p != int32(25) {
+		t.Fatalf("sampling_percentage = %v", p)
+	}
+
+	// 100% なら sampler を挟まない
+	oc.Spec.SamplingPercent = ptr.To(int32(100))
+	if _, ok := mustRender(t, oc).Processors["probabilistic_sampler"]; ok {
+		t.Fatal("sampler rendered for 100%")
+	}
+	if p, ok := mustRender(t, oc).TracesSamplingPercent(); !ok || p != 100 {
+		t.Fatalf("traces sampling = %d, %v", p, ok)
+	}
+}
+
+func TestSampledOnlyByCollector(t *testing.T) {
+	oc := testConfig("otel-collector.monitoring:4317")
+	oc.Spec.SamplingPercent = ptr.To(int32(10))
+	// SDK は全て送り、collector だけが間引く
+	if env := SamplerEnv(); env["OTEL_TRACES_SAMPLER"] != "parentbased_always_on" || len(env) != 1 {
+		t.Fatalf("sampler env = %v", env)
+	}
+	if p, ok := mustRender(t, oc).TracesSamplingPercent(); !ok || p != 10 {
+		t.Fatalf("traces sampling = %d, %v", p, ok)
+	}
+
+	// gateway 構成では agent は間引かずに転送する
+	oc.Spec.Topology = apiv1beta1.TopologyGateway
+	if p, _ := mustRender(t, oc).TracesSamplingPercent(); p != 100 {
+		t.Fatalf("forwarder sampling = %d", p)
+	}
+	gateway, err := RenderGateway(oc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p, _ := gateway.TracesSamplingPercent(); p != 10 {
+		t.Fatalf("gateway sampling = %d", p)
+	}
+}
+
+func TestTracesSamplingPercentFromYAML(t *testing.T) {
+	oc := testConfig("otel-collector.monitoring:4317")
+	oc.Spec.SamplingPercent = ptr.To(int32(10))
+	data, err := mustRender(t, oc).YAML()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := &Config{}
+	if err := yaml.Unmarshal(data, cfg); err != nil {
+		t.Fatal(err)
+	}
+	if p, ok := cfg.TracesSamplingPercent(); !ok || p != 10 {
+		t.Fatalf("traces sampling = %d, %v", p, ok)
+	}
+
+	// 壊れた設定でも panic せずに false を返す
+	cfg.Processors["probabilistic_sampler"] = "broken"
+	if p, ok := cfg.TracesSamplingPercent(); ok {
+		t.Fatalf("malformed sampler = %d, %v", p, ok)
+	}
+	delete(cfg.Processors, "probabilistic_sampler")
+	if p, ok := cfg.TracesSamplingPercent(); ok {
+		t.Fatalf("missing sampler = %d, %v", p, ok)
+	}
+}
@@ -0,0 +1,200 @@
+package collector
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+
+	apiv1beta1 "github.com/shtsukada/cloudnative-observability-operator/api/v1beta1"
+)
+
+// TLSMountPath 以下に exporter ごとの TLS Secret をマウントする。
+const TLSMountPath = "/tls"
+
+// EndpointExporterName は spec.endpoints[i] から生成する exporter の名前。
+func EndpointExporterName(i int) string {
+	return fmt.Sprintf("endpoint-%d", i)
+}
+
+// exporterSet は spec の exporter 名と collector のコンポーネント ID の対応を持つ。
+type exporterSet struct {
+	ids   map[string]string
+	types map[string]apiv1beta1.ExporterType
+	order []string
+}
+
+func (s *exporterSet) add(name string, typ apiv1beta1.ExporterType) string {
+	id := string(typ) + "/" + name
+	s.ids[name] = id
+	s.types[name] = typ
+	s.order = append(s.order, name)
+	return id
+}
+
+// renderExporters は spec.endpoints と spec.exporters を collector の exporter に変換する。
+func (c *Config) renderExporters(oc *apiv1beta1.ObservabilityConfig) *exporterSet {
+	set := &exporterSet{ids: map[string]string{}, types: map[string]apiv1beta1.ExporterType{}}
+	for i, ep := range oc.Spec.Endpoints {
+		id := set.add(EndpointExporterName(i), apiv1beta1.ExporterOTLP)
+		c.Exporters[id] = otlpExporter(string(ep))
+	}
+	for i := range oc.Spec.Exporters {
+		e := &oc.Spec.Exporters[i]
+		id := set.add(e.Name, e.Type)
+		c.Exporters[id] = c.exporter(i, e)
+	}
+	return set
+}
+
+// otlpExporter は endpoint の書式から TLS の要否を決める。
+// https:// 以外 (http:// や host:port) はクラスタ内の平文接続とみなす。
+func otlpExporter(endpoint string) map[string]any {
+	exp := map[string]any{"endpoint": endpoint}
+	if !strings.HasPrefix(endpoint, "https://") {
+		exp["tls"] = map[string]any{"insecure": true}
+	}
+	return exp
+}
+
+func (c *Config) exporter(idx int, e *apiv1beta1.ExporterSpec) map[string]any {
+	if e.Type == apiv1beta1.ExporterDebug {
+		return map[string]any{"verbosity": "basic"}
+	}
+
+	exp := map[string]any{"endpoint": e.Endpoint}
+	if tls := c.exporterTLS(e); tls != nil {
+		exp["tls"] = tls
+	}
+
+	headers := map[string]any{}
+	for k, v := range e.Headers {
+		headers[k] = v
+	}
+	// Secret の値は設定ファイルに書かず、環境変数経由で collector に展開させる
+	for j, h := range e.HeadersFrom {
+		env := fmt.Sprintf("EXPORTER_%d_HEADER_%d", idx, j)
+		headers[h.Name] = "${env:" + env + "}"
+		c.Env = append(c.Env, corev1.EnvVar{
+			Name:      env,
+			ValueFrom: &corev1.EnvVarSource{SecretKeyRef: h.SecretKeyRef.DeepCopy()},
+		})
+	}
+	if len(headers) > 0 {
+		exp["headers"] = headers
+	}
+
+	if e.Compression != "" {
+		exp["compression"] = e.Compression
+	}
+	if r := e.Retry; r != nil {
+		retry := map[string]any{}
+		if r.Enabled != nil {
+			retry["enabled"] = *r.Enabled
+		}
+		if r.InitialInterval != nil {
+			retry["initial_interval"] = r.InitialInterval.Duration.String()
+		}
+		if r.MaxInterval != nil {
+			retry["max_interval"] = r.MaxInterval.Duration.String()
+		}
+		if r.MaxElapsedTime != nil {
+			retry["max_elapsed_time"] = r.MaxElapsedTime.Duration.String()
+		}
+		exp["retry_on_failure"] = retry
+	}
+	if q := e.Queue; q != nil {
+		queue := map[string]any{}
+		if q.Enabled != nil {
+			queue["enabled"] = *q.Enabled
+		}
+		if q.NumConsumers != nil {
+			queue["num_consumers"] = *q.NumConsumers
+		}
+		if q.QueueSize != nil {
+			queue["queue_size"] = *q.QueueSize
+		}
+		exp["sending_queue"] = queue
+	}
+	return exp
+}
+
+func (c *Config) exporterTLS(e *apiv1beta1.ExporterSpec) map[string]any {
+	t := e.TLS
+	if t == nil {
+		return nil
+	}
+	tls := map[string]any{}
+	if t.Insecure {
+		tls["insecure"] = true
+	}
+	if t.InsecureSkipVerify {
+		tls["insecure_skip_verify"] = true
+	}
+	if t.ServerName != "" {
+		tls["server_name_override"] = t.ServerName
+	}
+	if t.SecretName != "" {
+		volume := "tls-" + e.Name
+		dir := path.Join(TLSMountPath, e.Name)
+		tls["ca_file"] = path.Join(dir, "ca.crt")
+		if t.MTLS {
+			tls["cert_file"] = path.Join(dir, corev1.TLSCertKey)
+			tls["key_file"] = path.Join(dir, corev1.TLSPrivateKeyKey)
+		}
+		c.Volumes = append(c.Volumes, corev1.Volume{
+			Name:         volume,
+			VolumeSource: corev1.VolumeSource{Secret: &corev1.SecretVolumeSource{SecretName: t.SecretName}},
+		})
+		c.VolumeMounts = append(c.VolumeMounts, corev1.VolumeMount{Name: volume, MountPath: dir, ReadOnly: true})
+	}
+	return tls
+}
+
+// route は signal ごとの送り先 exporter の ID を返す。
+// spec.pipelines が無ければ、その signal を扱える全 exporter に送る (logs は送らない。
+// spec.logs のログは Render で traces の送り先に出す)。
+func (s *exporterSet) route(pipelines *apiv1beta1.PipelinesSpec, signal string) ([]string, error) {
+	var names []string
+	if pipelines == nil {
+		if signal == "logs" {
+			return nil, nil
+		}
+		for _, name := range s.order {
+			if Supports(s.types[name], signal) {
+				names = append(names, name)
+			}
+		}
+	} else {
+		switch signal {
+		case "traces":
+			names = pipelines.Traces
+		case "metrics":
+			names = pipelines.Metrics
+		case "logs":
+			names = pipelines.Logs
+		}
+	}
+
+	ids := make([]string, 0, len(names))
+	for _, name := range names {
+		id, ok := s.ids[name]
+		if !ok {
+			return nil, fmt.Errorf("%s pipeline references unknown exporter %q", signal, name)
+		}
+		if !Supports(s.types[name], signal) {
+			return nil, fmt.Errorf("exporter %q of type %s does not support %s", name, s.types[name], signal)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// Supports は exporter の種類が signal を扱えるかを返す。
+func Supports(typ apiv1beta1.ExporterType, signal string) bool {
+	if typ == apiv1beta1.ExporterPrometheusRemoteWrite {
+		return signal == "metrics"
+	}
+	return true
+}
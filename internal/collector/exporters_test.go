@@ -0,0 +1,102 @@
+package collector
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+
+	apiv1beta1 "github.com/shtsukada/cloudnative-observability-operator/api/v1beta1"
+)
+
+func TestRenderExporters(t *testing.T) {
+	oc := testConfig()
+	oc.Spec.Exporters = []apiv1beta1.ExporterSpec{
+		{
+			Name:     "vendor",
+			Type:     apiv1beta1.ExporterOTLPHTTP,
+			Endpoint: "https://otlp.vendor.example.com",
+			TLS:      &apiv1beta1.ExporterTLS{SecretName: "vendor-tls", MTLS: true},
+			Headers:  map[string]string{"x-team": "obs"},
+			HeadersFrom: []apiv1beta1.SecretHeader{{
+				Name: "api-key",
+				SecretKeyRef: corev1.SecretKeySelector{
+					LocalObjectReference: corev1.LocalObjectReference{Name: "vendor"},
+					Key:                  "api-key",
+				},
+			}},
+			Compression: "zstd",
+			Retry:       &apiv1beta1.RetrySpec{MaxElapsedTime: &metav1.Duration{Duration: 5 * time.Minute}},
+			Queue:       &apiv1beta1.QueueSpec{QueueSize: ptr.To(int32(5000))},
+		},
+		{Name: "tempo", Type: apiv1beta1.ExporterOTLP, Endpoint: "tempo.monitoring:4317", TLS: &apiv1beta1.ExporterTLS{Insecure: true}},
+		{Name: "mimir", Type: apiv1beta1.ExporterPrometheusRemoteWrite, Endpoint: "http://mimir.monitoring/api/v1/push"},
+		{Name: "stdout", Type: apiv1beta1.ExporterDebug},
+	}
+	oc.Spec.Pipelines = &apiv1beta1.PipelinesSpec{
+		Traces:  []string{"vendor", "tempo"},
+		Metrics: []string{"mimir"},
+		Logs:    []string{"vendor", "stdout"},
+	}
+
+	cfg := mustRender(t, oc)
+	vendor := cfg.Exporters["otlphttp/vendor"].(map[string]any)
+	headers := vendor["headers"].(map[string]any)
+	if headers["x-team"] != "obs" || headers["api-key"] != "${env:EXPORTER_0_HEADER_0}" {
+		t.Fatalf("headers = %v", headers)
+	}
+	if len(cfg.Env) != 1 || cfg.Env[0].ValueFrom.SecretKeyRef.Name != "vendor" {
+		t.Fatalf("secret header env = %+v", cfg.Env)
+	}
+	tls := vendor["tls"].(map[string]any)
+	if tls["ca_file"] != "/tls/vendor/ca.crt" || tls["key_file"] != "/tls/vendor/tls.key" {
+		t.Fatalf("tls = %v", tls)
+	}
+	if len(cfg.Volumes) != 1 || cfg.Volumes[0].Secret.SecretName != "vendor-tls" || cfg.VolumeMounts[0].MountPath != "/tls/vendor" {
+		t.Fatalf("tls volume = %+v %+v", cfg.Volumes, cfg.VolumeMounts)
+	}
+	if vendor["compression"] != "zstd" ||
+		vendor["retry_on_failure"].(map[string]any)["max_elapsed_time"] != "5m0s" ||
+		vendor["sending_queue"].(map[string]any)["queue_size"] != int32(5000) {
+		t.Fatalf("vendor = %v", vendor)
+	}
+
+	want := map[string]string{
+		"traces":  "otlphttp/vendor,otlp/tempo",
+		"metrics": "prometheusremotewrite/mimir",
+		"logs":    "otlphttp/vendor,debug/stdout",
+	}
+	for signal, exporters := range want {
+		if got := strings.Join(cfg.Service.Pipelines[signal].Exporters, ","); got != exporters {
+			t.Errorf("%s exporters = %s, want %s", signal, got, exporters)
+		}
+	}
+}
+
+func TestRenderDefaultRouting(t *testing.T) {
+	oc := testConfig("otel-collector.monitoring:4317")
+	oc.Spec.Exporters = []apiv1beta1.ExporterSpec{
+		{Name: "mimir", Type: apiv1beta1.ExporterPrometheusRemoteWrite, Endpoint: "http://mimir.monitoring/api/v1/push"},
+	}
+	cfg := mustRender(t, oc)
+	if got := strings.Join(cfg.Service.Pipelines["traces"].Exporters, ","); got != "otlp/endpoint-0" {
+		t.Fatalf("traces exporters = %s", got)
+	}
+	if got := strings.Join(cfg.Service.Pipelines["metrics"].Exporters, ","); got != "otlp/endpoint-0,prometheusremotewrite/mimir" {
+		t.Fatalf("metrics exporters = %s", got)
+	}
+	if _, ok := cfg.Service.Pipelines["logs"]; ok {
+		t.Fatal("logs pipeline rendered without routing")
+	}
+}
+
+func TestRenderRejectsUnknownExporter(t *testing.T) {
+	oc := testConfig("otel-collector.monitoring:4317")
+	oc.Spec.Pipelines = &apiv1beta1.PipelinesSpec{Traces: []string{"missing"}}
+	if _, err := Render(oc); err == nil {
+		t.Fatal("expected an error for an unknown exporter")
+	}
+}
@@ -0,0 +1,91 @@
+package collector
+
+import (
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+
+	apiv1beta1 "github.com/shtsukada/cloudnative-observability-operator/api/v1beta1"
+)
+
+const (
+	// LoadBalancingID は trace を traceID ごとに同じ gateway へ送る exporter の ID。
+	LoadBalancingID = "loadbalancing"
+
+	// GatewayExporterID は metrics と logs を gateway の Service へ送る exporter の ID。
+	GatewayExporterID = "otlp/gateway"
+)
+
+// GatewayName は gateway の Deployment と Service の名前。
+func GatewayName(oc *apiv1beta1.ObservabilityConfig) string {
+	return oc.Name + "-oc-gateway"
+}
+
+// GatewayHeadlessServiceName は loadbalancing exporter が gateway の Pod を DNS で引くための Service。
+func GatewayHeadlessServiceName(oc *apiv1beta1.ObservabilityConfig) string {
+	return oc.Name + "-oc-gateway-headless"
+}
+
+// GatewayConfigMapName は RenderGateway の結果を保存する ConfigMap の名前。
+func GatewayConfigMapName(oc *apiv1beta1.ObservabilityConfig) string {
+	return oc.Name + "-oc-gateway-config"
+}
+
+// GatewayVolumes は gateway のコンテナがマウントする volume を返す。
+func GatewayVolumes(oc *apiv1beta1.ObservabilityConfig, cfg *Config) []corev1.Volume {
+	volumes := AgentVolumes(oc, cfg)
+	volumes[0].ConfigMap.Name = GatewayConfigMapName(oc)
+	return volumes
+}
+
+// RenderGateway は topology が gateway のときの gateway の設定を組み立てる。
+// spec の exporter、sampling、processors はここで動く。
+func RenderGateway(oc *apiv1beta1.ObservabilityConfig) (*Config, error) {
+	return render(oc, false)
+}
+
+// renderForwarder は受け取ったものを gateway へ転送する agent の設定を組み立てる。
+// trace は tail sampling が 1 つの trace を 1 つの gateway で見られるよう traceID で振り分ける。
+func renderForwarder(oc *apiv1beta1.ObservabilityConfig) (*Config, error) {
+	// 転送するのは gateway が送り先を持つ signal だけ
+	gateway, err := RenderGateway(oc)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := newConfig()
+	k8sAttributes := cfg.renderK8sAttributes(oc)
+	fileLog := cfg.renderFileLog(oc)
+	cfg.Exporters[LoadBalancingID] = map[string]any{
+		"routing_key": "traceID",
+		"protocol": map[string]any{
+			"otlp": map[string]any{"tls": map[string]any{"insecure": true}},
+		},
+		"resolver": map[string]any{
+			"dns": map[string]any{
+				"hostname": GatewayHeadlessServiceName(oc) + "." + oc.Namespace + ".svc",
+				"port":     fmt.Sprint(OTLPGRPCPort),
+			},
+		},
+	}
+	cfg.Exporters[GatewayExporterID] = otlpExporter(fmt.Sprintf("%s.%s.svc:%d", GatewayName(oc), oc.Namespace, OTLPGRPCPort))
+
+	cfg.Service.Pipelines = map[string]Pipeline{}
+	for signal := range gateway.Service.Pipelines {
+		receivers := []string{"otlp"}
+		if signal == "logs" && fileLog {
+			receivers = append(receivers, FileLogID)
+		}
+		processors := []string{"memory_limiter"}
+		if k8sAttributes {
+			processors = append(processors, K8sAttributesID)
+		}
+		processors = append(processors, "batch")
+		exporter := GatewayExporterID
+		if signal == "traces" {
+			exporter = LoadBalancingID
+		}
+		cfg.Service.Pipelines[signal] = Pipeline{Receivers: receivers, Processors: processors, Exporters: []string{exporter}}
+	}
+	return cfg, nil
+}
@@ -0,0 +1,66 @@
+package collector
+
+import (
+	"slices"
+	"testing"
+
+	apiv1beta1 "github.com/shtsukada/cloudnative-observability-operator/api/v1beta1"
+)
+
+func TestRenderGatewayTopology(t *testing.T) {
+	oc := testConfig("otel-collector.monitoring:4317")
+	oc.Spec.Topology = apiv1beta1.TopologyGateway
+	oc.Spec.Logs = &apiv1beta1.LogsSpec{}
+	oc.Spec.KubernetesAttributes = &apiv1beta1.KubernetesAttributesSpec{}
+	oc.Spec.Processors = []apiv1beta1.ProcessorSpec{{
+		Name: "errors", Type: apiv1beta1.ProcessorTailSampling,
+		TailSampling: &apiv1beta1.TailSamplingProcessor{Policies: []apiv1beta1.TailSamplingPolicy{{Name: "errors", Type: "status_code", StatusCodes: []string{"ERROR"}}}},
+	}}
+	oc.ApplyDefaults()
+
+	agent := mustRender(t, oc)
+	traces := agent.Service.Pipelines["traces"]
+	if !slices.Equal(traces.Exporters, []string{LoadBalancingID}) || !slices.Equal(traces.Processors, []string{"memory_limiter", K8sAttributesID, "batch"}) {
+		t.Fatalf("agent traces pipeline = %+v", traces)
+	}
+	lb := agent.Exporters[LoadBalancingID].(map[string]any)
+	dns := lb["resolver"].(map[string]any)["dns"].(map[string]any)
+	if lb["routing_key"] != "traceID" || dns["hostname"] != "oc-oc-gateway-headless.default.svc" {
+		t.Fatalf("loadbalancing = %v", lb)
+	}
+	logs := agent.Service.Pipelines["logs"]
+	if !slices.Equal(logs.Receivers, []string{"otlp", FileLogID}) || !slices.Equal(logs.Exporters, []string{GatewayExporterID}) {
+		t.Fatalf("agent logs pipeline = %+v", logs)
+	}
+	if ep := agent.Exporters[GatewayExporterID].(map[string]any)["endpoint"]; ep != "oc-oc-gateway.default.svc:4317" {
+		t.Fatalf("gateway endpoint = %v", ep)
+	}
+	// sampling と spec の exporter は gateway だけが持つ
+	if _, ok := agent.Processors["probabilistic_sampler"]; ok {
+		t.Fatal("agent samples before the gateway")
+	}
+
+	gateway, err := RenderGateway(oc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"memory_limiter", "probabilistic_sampler", "tail_sampling/errors", "batch"}
+	if got := gateway.Service.Pipelines["traces"].Processors; !slices.Equal(got, want) {
+		t.Fatalf("gateway traces processors = %v, want %v", got, want)
+	}
+	if got := gateway.Service.Pipelines["logs"].Receivers; !slices.Equal(got, []string{"otlp"}) {
+		t.Fatalf("gateway logs receivers = %v", got)
+	}
+	if _, ok := gateway.Processors[K8sAttributesID]; ok {
+		t.Fatal("gateway has no pod connections to associate")
+	}
+	for _, cfg := range []*Config{agent, gateway} {
+		data, err := cfg.YAML()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := Validate(data); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
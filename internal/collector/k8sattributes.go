@@ -0,0 +1,75 @@
+package collector
+
+import (
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+
+	apiv1beta1 "github.com/shtsukada/cloudnative-observability-operator/api/v1beta1"
+)
+
+// K8sAttributesID は spec.kubernetesAttributes から描画する processor の ID。
+const K8sAttributesID = "k8sattributes"
+
+// NodeNameEnv は DaemonSet の agent が自ノードの Pod だけを監視するための変数。
+const NodeNameEnv = "K8S_NODE_NAME"
+
+// renderK8sAttributes は k8sattributes processor を追加する。
+// 送信元 Pod の特定は SDK が付けた属性を優先し、無ければ接続元 IP を使う。
+func (c *Config) renderK8sAttributes(oc *apiv1beta1.ObservabilityConfig) bool {
+	k := oc.Spec.KubernetesAttributes
+	if k == nil {
+		return false
+	}
+	metadata := make([]string, 0, len(k.Metadata))
+	for _, m := range k.Metadata {
+		metadata = append(metadata, string(m))
+	}
+	extract := map[string]any{"metadata": metadata}
+	if len(k.Labels) > 0 {
+		extract["labels"] = k8sTags(k.Labels)
+	}
+	if len(k.Annotations) > 0 {
+		extract["annotations"] = k8sTags(k.Annotations)
+	}
+
+	cfg := map[string]any{
+		"auth_type":   "serviceAccount",
+		"passthrough": false,
+		"extract":     extract,
+		"pod_association": []any{
+			map[string]any{"sources": []any{map[string]any{"from": "resource_attribute", "name": "k8s.pod.ip"}}},
+			map[string]any{"sources": []any{map[string]any{"from": "resource_attribute", "name": "k8s.pod.uid"}}},
+			map[string]any{"sources": []any{map[string]any{"from": "connection"}}},
+		},
+	}
+	filter := map[string]any{}
+	if k.Scope == apiv1beta1.KubernetesAttributesScopeNamespace {
+		filter["namespace"] = oc.Namespace
+	}
+	// DaemonSet ではノードごとに agent がいるので、自ノードの Pod だけを watch する
+	if oc.Spec.Mode == apiv1beta1.AgentModeDaemonSet {
+		filter["node_from_env_var"] = NodeNameEnv
+		c.Env = append(c.Env, corev1.EnvVar{
+			Name:      NodeNameEnv,
+			ValueFrom: &corev1.EnvVarSource{FieldRef: &corev1.ObjectFieldSelector{FieldPath: "spec.nodeName"}},
+		})
+	}
+	if len(filter) > 0 {
+		cfg["filter"] = filter
+	}
+	c.Processors[K8sAttributesID] = cfg
+	return true
+}
+
+func k8sTags(tags []apiv1beta1.KubernetesTag) []any {
+	out := make([]any, 0, len(tags))
+	for _, t := range tags {
+		tag := map[string]any{"key": t.Key, "from": strings.ToLower(string(t.From))}
+		if t.TagName != "" {
+			tag["tag_name"] = t.TagName
+		}
+		out = append(out, tag)
+	}
+	return out
+}
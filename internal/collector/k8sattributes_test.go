@@ -0,0 +1,73 @@
+package collector
+
+import (
+	"slices"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/api/equality"
+	"sigs.k8s.io/yaml"
+
+	apiv1beta1 "github.com/shtsukada/cloudnative-observability-operator/api/v1beta1"
+)
+
+func TestRenderK8sAttributes(t *testing.T) {
+	oc := testConfig("otel-collector.monitoring:4317")
+	oc.Spec.KubernetesAttributes = &apiv1beta1.KubernetesAttributesSpec{
+		Labels:      []apiv1beta1.KubernetesTag{{Key: "app.kubernetes.io/name", TagName: "service.name"}, {Key: "team", From: apiv1beta1.KubernetesTagFromNamespace}},
+		Annotations: []apiv1beta1.KubernetesTag{{Key: "owner"}},
+	}
+	oc.Spec.Processors = []apiv1beta1.ProcessorSpec{
+		{Name: "drop-health", Type: apiv1beta1.ProcessorFilter, Filter: &apiv1beta1.FilterProcessor{SpanNames: []string{"^grpc.health"}}},
+	}
+	oc.ApplyDefaults()
+	cfg := mustRender(t, oc)
+
+	// 後続の processor が k8s の属性を使えるよう memory_limiter の直後に入る
+	want := []string{"memory_limiter", K8sAttributesID, "probabilistic_sampler", "filter/drop-health", "batch"}
+	if got := cfg.Service.Pipelines["traces"].Processors; !slices.Equal(got, want) {
+		t.Fatalf("traces processors = %v, want %v", got, want)
+	}
+	k := cfg.Processors[K8sAttributesID].(map[string]any)
+	extract := k["extract"].(map[string]any)
+	if md := extract["metadata"].([]string); !slices.Contains(md, "k8s.deployment.name") || len(md) != len(apiv1beta1.DefaultKubernetesMetadata) {
+		t.Fatalf("metadata = %v", md)
+	}
+	labels := extract["labels"].([]any)
+	if !equality.Semantic.DeepEqual(labels[0], map[string]any{"key": "app.kubernetes.io/name", "from": "pod", "tag_name": "service.name"}) ||
+		labels[1].(map[string]any)["from"] != "namespace" {
+		t.Fatalf("labels = %v", labels)
+	}
+	if _, ok := k["filter"]; ok {
+		t.Fatalf("cluster-scoped gateway should watch every pod: %v", k["filter"])
+	}
+	if len(cfg.Env) != 0 {
+		t.Fatalf("env = %v", cfg.Env)
+	}
+
+	oc.Spec.Mode = apiv1beta1.AgentModeDaemonSet
+	oc.Spec.KubernetesAttributes.Scope = apiv1beta1.KubernetesAttributesScopeNamespace
+	cfg = mustRender(t, oc)
+	filter := cfg.Processors[K8sAttributesID].(map[string]any)["filter"].(map[string]any)
+	if filter["namespace"] != "default" || filter["node_from_env_var"] != NodeNameEnv {
+		t.Fatalf("filter = %v", filter)
+	}
+	if len(cfg.Env) != 1 || cfg.Env[0].Name != NodeNameEnv || cfg.Env[0].ValueFrom.FieldRef.FieldPath != "spec.nodeName" {
+		t.Fatalf("env = %v", cfg.Env)
+	}
+
+	// sidecar はアプリの ServiceAccount で動き、Pod を引く権限が無い
+	data, err := cfg.SidecarYAML()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sidecar Config
+	if err := yaml.Unmarshal(data, &sidecar); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := sidecar.Processors[K8sAttributesID]; ok || slices.Contains(sidecar.Service.Pipelines["traces"].Processors, K8sAttributesID) {
+		t.Fatalf("sidecar config:\n%s", data)
+	}
+	if err := Validate(data); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,75 @@
+package collector
+
+import (
+	apiv1beta1 "github.com/shtsukada/cloudnative-observability-operator/api/v1beta1"
+)
+
+const (
+	// FileLogID は spec.logs から描画する receiver の ID。
+	FileLogID = "filelog"
+
+	// PodLogsPath は kubelet がコンテナのログを置くディレクトリ。
+	PodLogsPath = "/var/log/pods"
+
+	// AgentContainerName は agent workload のコンテナ名。自分のログを読まないよう除外に使う。
+	AgentContainerName = "oc-agent"
+)
+
+// podLogGlob は namespace のコンテナログに一致するパターン。
+// ファイルは <namespace>_<pod>_<uid>/<container>/<n>.log に置かれる。
+func podLogGlob(namespace string) string {
+	return PodLogsPath + "/" + namespace + "_*/*/*.log"
+}
+
+// renderFileLog は spec.logs から filelog receiver を追加する。
+func (c *Config) renderFileLog(oc *apiv1beta1.ObservabilityConfig) bool {
+	l := oc.Spec.Logs
+	if l == nil {
+		return false
+	}
+	include := []string{PodLogsPath + "/*/*/*.log"}
+	if len(l.IncludeNamespaces) > 0 {
+		include = include[:0]
+		for _, ns := range l.IncludeNamespaces {
+			include = append(include, podLogGlob(ns))
+		}
+	}
+	// agent 自身のログを送ると、その送信ログがまた送られて増え続ける
+	exclude := []string{PodLogsPath + "/" + oc.Namespace + "_*/" + AgentContainerName + "/*.log"}
+	for _, ns := range l.ExcludeNamespaces {
+		exclude = append(exclude, podLogGlob(ns))
+	}
+
+	startAt := string(l.StartAt)
+	if startAt == "" {
+		startAt = "end"
+	}
+	c.Receivers[FileLogID] = map[string]any{
+		"include":           include,
+		"exclude":           exclude,
+		"start_at":          startAt,
+		"include_file_path": true,
+		"include_file_name": false,
+		"operators": []any{
+			// CRI / docker の形式を判別し、パスから k8s.pod.name などの resource 属性を付ける
+			map[string]any{"type": "container", "id": "container-parser"},
+			// JSON のログは属性に展開し、trace_id / span_id があれば trace と結び付ける
+			map[string]any{
+				"type":     "json_parser",
+				"id":       "json-parser",
+				"if":       `body matches "^\\s*\\{"`,
+				"parse_to": "attributes",
+				"on_error": "send_quiet",
+			},
+			map[string]any{
+				"type":     "trace_parser",
+				"id":       "trace-parser",
+				"if":       `attributes.trace_id != nil`,
+				"trace_id": map[string]any{"parse_from": "attributes.trace_id"},
+				"span_id":  map[string]any{"parse_from": "attributes.span_id"},
+				"on_error": "send_quiet",
+			},
+		},
+	}
+	return true
+}
@@ -0,0 +1,69 @@
+package collector
+
+import (
+	"slices"
+	"testing"
+
+	"sigs.k8s.io/yaml"
+
+	apiv1beta1 "github.com/shtsukada/cloudnative-observability-operator/api/v1beta1"
+)
+
+func TestRenderFileLog(t *testing.T) {
+	oc := testConfig("otel-collector.monitoring:4317")
+	oc.Spec.Mode = apiv1beta1.AgentModeDaemonSet
+	oc.Spec.Exporters = []apiv1beta1.ExporterSpec{
+		{Name: "mimir", Type: apiv1beta1.ExporterPrometheusRemoteWrite, Endpoint: "http://mimir/api/v1/push"},
+	}
+	oc.Spec.Logs = &apiv1beta1.LogsSpec{IncludeNamespaces: []string{"burners"}, ExcludeNamespaces: []string{"kube-system"}}
+	oc.ApplyDefaults()
+	cfg := mustRender(t, oc)
+
+	// spec.pipelines.logs が無ければ traces と同じ送り先に出す
+	logs, ok := cfg.Service.Pipelines["logs"]
+	if !ok || !slices.Equal(logs.Receivers, []string{"otlp", FileLogID}) || !slices.Equal(logs.Exporters, cfg.Service.Pipelines["traces"].Exporters) {
+		t.Fatalf("logs pipeline = %+v, traces = %+v", logs, cfg.Service.Pipelines["traces"])
+	}
+	r := cfg.Receivers[FileLogID].(map[string]any)
+	if include := r["include"].([]string); !slices.Equal(include, []string{"/var/log/pods/burners_*/*/*.log"}) {
+		t.Fatalf("include = %v", include)
+	}
+	want := []string{"/var/log/pods/default_*/oc-agent/*.log", "/var/log/pods/kube-system_*/*/*.log"}
+	if exclude := r["exclude"].([]string); !slices.Equal(exclude, want) {
+		t.Fatalf("exclude = %v", exclude)
+	}
+	if r["start_at"] != "end" {
+		t.Fatalf("start_at = %v", r["start_at"])
+	}
+	data, err := cfg.YAML()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Validate(data); err != nil {
+		t.Fatal(err)
+	}
+
+	// sidecar の Pod にはノードのログが無い
+	data, err = cfg.SidecarYAML()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sidecar Config
+	if err := yaml.Unmarshal(data, &sidecar); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := sidecar.Receivers[FileLogID]; ok || !slices.Equal(sidecar.Service.Pipelines["logs"].Receivers, []string{"otlp"}) {
+		t.Fatalf("sidecar config:\n%s", data)
+	}
+	if err := Validate(data); err != nil {
+		t.Fatal(err)
+	}
+
+	// 明示した logs パイプラインはそのまま使う
+	oc.Spec.Exporters = append(oc.Spec.Exporters, apiv1beta1.ExporterSpec{Name: "stdout", Type: apiv1beta1.ExporterDebug})
+	oc.Spec.Pipelines = &apiv1beta1.PipelinesSpec{Traces: []string{"endpoint-0"}, Logs: []string{"stdout"}}
+	cfg = mustRender(t, oc)
+	if got := cfg.Service.Pipelines["logs"].Exporters; !slices.Equal(got, []string{"debug/stdout"}) {
+		t.Fatalf("logs exporters = %v", got)
+	}
+}
@@ -0,0 +1,62 @@
+package collector
+
+import (
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+
+	apiv1beta1 "github.com/shtsukada/cloudnative-observability-operator/api/v1beta1"
+)
+
+const (
+	// FileStorageID は spec.persistence から描画する extension の ID。
+	FileStorageID = "file_storage/queue"
+
+	// QueueVolumeName は sending_queue を置く volume の名前。
+	// StatefulSet ではこの名前の volumeClaimTemplate が emptyDir の代わりになる。
+	QueueVolumeName = "queue"
+
+	// QueueMountPath は file_storage extension のディレクトリ。
+	QueueMountPath = "/var/lib/otelcol/queue"
+
+	// AgentGID は collector のイメージが動くグループ。volume をこのグループで書けるようにする。
+	AgentGID int64 = 10001
+)
+
+// renderPersistence は file_storage extension を追加し、exporter の sending_queue をそこに置く。
+func (c *Config) renderPersistence(oc *apiv1beta1.ObservabilityConfig) {
+	if oc.Spec.Persistence == nil {
+		return
+	}
+	if c.Extensions == nil {
+		c.Extensions = map[string]any{}
+	}
+	c.Extensions[FileStorageID] = map[string]any{
+		"directory": QueueMountPath,
+		"timeout":   "1s",
+		// 送り終えた分の領域を起動時に詰めて、volume が埋まり続けないようにする
+		"compaction": map[string]any{"on_start": true, "directory": QueueMountPath},
+	}
+	c.Service.Extensions = append(c.Service.Extensions, FileStorageID)
+
+	for id, v := range c.Exporters {
+		// debug は送り先が無いので queue を持たない
+		if strings.HasPrefix(id, string(apiv1beta1.ExporterDebug)+"/") {
+			continue
+		}
+		exp := v.(map[string]any)
+		queue, _ := exp["sending_queue"].(map[string]any)
+		if queue == nil {
+			queue = map[string]any{}
+			exp["sending_queue"] = queue
+		}
+		queue["storage"] = FileStorageID
+	}
+
+	// sidecar では emptyDir に置き、コンテナの再起動では失われないようにする
+	c.Volumes = append(c.Volumes, corev1.Volume{
+		Name:         QueueVolumeName,
+		VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}},
+	})
+	c.VolumeMounts = append(c.VolumeMounts, corev1.VolumeMount{Name: QueueVolumeName, MountPath: QueueMountPath})
+}
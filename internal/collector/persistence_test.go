@@ -0,0 +1,51 @@
+package collector
+
+import (
+	"slices"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/utils/ptr"
+
+	apiv1beta1 "github.com/shtsukada/cloudnative-observability-operator/api/v1beta1"
+)
+
+func TestRenderPersistence(t *testing.T) {
+	oc := testConfig("otel-collector.monitoring:4317")
+	oc.Spec.Exporters = []apiv1beta1.ExporterSpec{
+		{Name: "vendor", Type: apiv1beta1.ExporterOTLPHTTP, Endpoint: "https://otlp.example.com", Queue: &apiv1beta1.QueueSpec{NumConsumers: ptr.To(int32(4))}},
+		{Name: "console", Type: apiv1beta1.ExporterDebug},
+	}
+	oc.Spec.Persistence = &apiv1beta1.PersistenceSpec{}
+	oc.ApplyDefaults()
+	cfg := mustRender(t, oc)
+
+	if !slices.Equal(cfg.Service.Extensions, []string{HealthCheckID, FileStorageID}) {
+		t.Fatalf("service.extensions = %v", cfg.Service.Extensions)
+	}
+	if dir := cfg.Extensions[FileStorageID].(map[string]any)["directory"]; dir != QueueMountPath {
+		t.Fatalf("directory = %v", dir)
+	}
+	endpoint := cfg.Exporters["otlp/"+EndpointExporterName(0)].(map[string]any)["sending_queue"].(map[string]any)
+	if endpoint["storage"] != FileStorageID {
+		t.Fatalf("endpoint sending_queue = %v", endpoint)
+	}
+	// spec の queue 設定はそのまま残す
+	vendor := cfg.Exporters["otlphttp/vendor"].(map[string]any)["sending_queue"].(map[string]any)
+	if vendor["storage"] != FileStorageID || vendor["num_consumers"] != int32(4) {
+		t.Fatalf("vendor sending_queue = %v", vendor)
+	}
+	if _, ok := cfg.Exporters["debug/console"].(map[string]any)["sending_queue"]; ok {
+		t.Fatal("debug exporter got a sending_queue")
+	}
+	if !slices.ContainsFunc(cfg.VolumeMounts, func(m corev1.VolumeMount) bool { return m.Name == QueueVolumeName && m.MountPath == QueueMountPath }) {
+		t.Fatalf("volume mounts = %v", cfg.VolumeMounts)
+	}
+	data, err := cfg.YAML()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Validate(data); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,194 @@
+package collector
+
+import (
+	"slices"
+	"strconv"
+
+	apiv1beta1 "github.com/shtsukada/cloudnative-observability-operator/api/v1beta1"
+)
+
+// ProcessorID は spec.processors の要素に対応する collector のコンポーネント ID。
+func ProcessorID(p *apiv1beta1.ProcessorSpec) string {
+	return string(p.Type) + "/" + p.Name
+}
+
+// ProcessorSignals は processor が実際に入るパイプラインを返す。
+// 設定が扱える signal のうち、spec.processors[].signals があればそれに絞る。
+func ProcessorSignals(p *apiv1beta1.ProcessorSpec) []string {
+	var supported []string
+	switch p.Type {
+	case apiv1beta1.ProcessorTailSampling:
+		supported = []string{"traces"}
+	case apiv1beta1.ProcessorFilter:
+		if f := p.Filter; f != nil {
+			if len(f.SpanNames) > 0 || len(f.Attributes) > 0 {
+				supported = append(supported, "traces")
+			}
+			if len(f.MetricNames) > 0 || len(f.Attributes) > 0 {
+				supported = append(supported, "metrics")
+			}
+			if len(f.Attributes) > 0 {
+				supported = append(supported, "logs")
+			}
+		}
+	case apiv1beta1.ProcessorTransform:
+		if t := p.Transform; t != nil {
+			if len(t.Traces) > 0 {
+				supported = append(supported, "traces")
+			}
+			if len(t.Metrics) > 0 {
+				supported = append(supported, "metrics")
+			}
+			if len(t.Logs) > 0 {
+				supported = append(supported, "logs")
+			}
+		}
+	default:
+		supported = []string{"traces", "metrics", "logs"}
+	}
+	if len(p.Signals) == 0 {
+		return supported
+	}
+	return slices.DeleteFunc(supported, func(s string) bool {
+		return !slices.Contains(p.Signals, apiv1beta1.Signal(s))
+	})
+}
+
+// renderProcessors は spec.processors を collector の processor に変換し、
+// signal ごとに spec の順で並べた ID を返す。
+func (c *Config) renderProcessors(oc *apiv1beta1.ObservabilityConfig) map[string][]string {
+	bySignal := map[string][]string{}
+	for i := range oc.Spec.Processors {
+		p := &oc.Spec.Processors[i]
+		signals := ProcessorSignals(p)
+		if len(signals) == 0 {
+			continue
+		}
+		id := ProcessorID(p)
+		c.Processors[id] = processor(p, signals)
+		for _, s := range signals {
+			bySignal[s] = append(bySignal[s], id)
+		}
+	}
+	return bySignal
+}
+
+func processor(p *apiv1beta1.ProcessorSpec, signals []string) map[string]any {
+	switch p.Type {
+	case apiv1beta1.ProcessorAttributes:
+		return map[string]any{"actions": attributeActions(p.Attributes)}
+	case apiv1beta1.ProcessorResource:
+		return map[string]any{"attributes": attributeActions(p.Resource)}
+	case apiv1beta1.ProcessorFilter:
+		return filterProcessor(p.Filter, signals)
+	case apiv1beta1.ProcessorTailSampling:
+		return tailSamplingProcessor(p.TailSampling)
+	case apiv1beta1.ProcessorTransform:
+		return transformProcessor(p.Transform, signals)
+	}
+	return map[string]any{}
+}
+
+func attributeActions(actions []apiv1beta1.AttributeAction) []any {
+	out := make([]any, 0, len(actions))
+	for _, a := range actions {
+		action := map[string]any{"key": a.Key, "action": a.Action}
+		if a.Value != nil {
+			action["value"] = *a.Value
+		}
+		if a.FromAttribute != "" {
+			action["from_attribute"] = a.FromAttribute
+		}
+		out = append(out, action)
+	}
+	return out
+}
+
+// filterProcessor は条件を OTTL に変換する。一致したものを捨てる。
+func filterProcessor(f *apiv1beta1.FilterProcessor, signals []string) map[string]any {
+	var attrs []string
+	for _, m := range f.Attributes {
+		attrs = append(attrs, "attributes["+strconv.Quote(m.Key)+"] == "+strconv.Quote(m.Value))
+	}
+	names := func(patterns []string) []string {
+		var conds []string
+		for _, p := range patterns {
+			conds = append(conds, "IsMatch(name, "+strconv.Quote(p)+")")
+		}
+		return conds
+	}
+
+	// 条件の誤りで telemetry を止めないよう、評価エラーは無視して通す
+	cfg := map[string]any{"error_mode": "ignore"}
+	for _, s := range signals {
+		switch s {
+		case "traces":
+			cfg["traces"] = map[string]any{"span": append(names(f.SpanNames), attrs...)}
+		case "metrics":
+			m := map[string]any{}
+			if len(f.MetricNames) > 0 {
+				m["metric"] = names(f.MetricNames)
+			}
+			if len(attrs) > 0 {
+				m["datapoint"] = attrs
+			}
+			cfg["metrics"] = m
+		case "logs":
+			cfg["logs"] = map[string]any{"log_record": attrs}
+		}
+	}
+	return cfg
+}
+
+func tailSamplingProcessor(t *apiv1beta1.TailSamplingProcessor) map[string]any {
+	cfg := map[string]any{}
+	if t.DecisionWait != nil {
+		cfg["decision_wait"] = t.DecisionWait.Duration.String()
+	}
+	if t.NumTraces != nil {
+		cfg["num_traces"] = *t.NumTraces
+	}
+	policies := make([]any, 0, len(t.Policies))
+	for _, p := range t.Policies {
+		policy := map[string]any{"name": p.Name, "type": string(p.Type)}
+		switch p.Type {
+		case "latency":
+			if p.Latency != nil {
+				policy["latency"] = map[string]any{"threshold_ms": p.Latency.Milliseconds()}
+			}
+		case "status_code":
+			policy["status_code"] = map[string]any{"status_codes": p.StatusCodes}
+		case "probabilistic":
+			if p.SamplingPercent != nil {
+				policy["probabilistic"] = map[string]any{"sampling_percentage": *p.SamplingPercent}
+			}
+		case "string_attribute":
+			policy["string_attribute"] = map[string]any{"key": p.Key, "values": p.Values}
+		}
+		policies = append(policies, policy)
+	}
+	cfg["policies"] = policies
+	return cfg
+}
+
+func transformProcessor(t *apiv1beta1.TransformProcessor, signals []string) map[string]any {
+	groups := func(in []apiv1beta1.TransformStatements) []any {
+		out := make([]any, 0, len(in))
+		for _, g := range in {
+			out = append(out, map[string]any{"context": g.Context, "statements": g.Statements})
+		}
+		return out
+	}
+	cfg := map[string]any{"error_mode": "ignore"}
+	for _, s := range signals {
+		switch s {
+		case "traces":
+			cfg["trace_statements"] = groups(t.Traces)
+		case "metrics":
+			cfg["metric_statements"] = groups(t.Metrics)
+		case "logs":
+			cfg["log_statements"] = groups(t.Logs)
+		}
+	}
+	return cfg
+}
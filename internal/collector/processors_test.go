@@ -0,0 +1,87 @@
+package collector
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+
+	apiv1beta1 "github.com/shtsukada/cloudnative-observability-operator/api/v1beta1"
+)
+
+func TestRenderProcessors(t *testing.T) {
+	oc := testConfig("otel-collector.monitoring:4317")
+	oc.Spec.Pipelines = &apiv1beta1.PipelinesSpec{
+		Traces: []string{"endpoint-0"}, Metrics: []string{"endpoint-0"}, Logs: []string{"endpoint-0"},
+	}
+	oc.Spec.Processors = []apiv1beta1.ProcessorSpec{
+		{Name: "drop-health", Type: apiv1beta1.ProcessorFilter, Filter: &apiv1beta1.FilterProcessor{
+			SpanNames: []string{`^grpc\.health\.v1\.Health/`},
+		}},
+		{Name: "tags", Type: apiv1beta1.ProcessorResource, Resource: []apiv1beta1.AttributeAction{
+			{Key: "k8s.cluster.name", Action: "upsert", Value: ptr.To("prod-1")},
+			{Key: "team", Action: "insert", FromAttribute: "k8s.namespace.name"},
+		}},
+		{Name: "scrub", Type: apiv1beta1.ProcessorAttributes, Signals: []apiv1beta1.Signal{apiv1beta1.SignalLogs},
+			Attributes: []apiv1beta1.AttributeAction{{Key: "user.email", Action: "delete"}}},
+		{Name: "errors", Type: apiv1beta1.ProcessorTailSampling, TailSampling: &apiv1beta1.TailSamplingProcessor{
+			DecisionWait: &metav1.Duration{Duration: 5 * time.Second},
+			Policies: []apiv1beta1.TailSamplingPolicy{
+				{Name: "errors", Type: "status_code", StatusCodes: []string{"ERROR"}},
+				{Name: "slow", Type: "latency", Latency: &metav1.Duration{Duration: 500 * time.Millisecond}},
+			},
+		}},
+	}
+	cfg := mustRender(t, oc)
+
+	want := map[string][]string{
+		"traces":  {"memory_limiter", "probabilistic_sampler", "filter/drop-health", "resource/tags", "tail_sampling/errors", "batch"},
+		"metrics": {"memory_limiter", "resource/tags", "batch"},
+		"logs":    {"memory_limiter", "resource/tags", "attributes/scrub", "batch"},
+	}
+	for signal, procs := range want {
+		if got := cfg.Service.Pipelines[signal].Processors; !slices.Equal(got, procs) {
+			t.Errorf("%s processors = %v, want %v", signal, got, procs)
+		}
+	}
+
+	filter := cfg.Processors["filter/drop-health"].(map[string]any)
+	if spans := filter["traces"].(map[string]any)["span"].([]string); !slices.Equal(spans, []string{`IsMatch(name, "^grpc\\.health\\.v1\\.Health/")`}) {
+		t.Fatalf("filter = %v", filter)
+	}
+	if _, ok := filter["metrics"]; ok {
+		t.Fatalf("span filter rendered for metrics: %v", filter)
+	}
+	resource := cfg.Processors["resource/tags"].(map[string]any)["attributes"].([]any)
+	if !equality.Semantic.DeepEqual(resource[1], map[string]any{"key": "team", "action": "insert", "from_attribute": "k8s.namespace.name"}) {
+		t.Fatalf("resource = %v", resource)
+	}
+	tail := cfg.Processors["tail_sampling/errors"].(map[string]any)
+	policies := tail["policies"].([]any)
+	if tail["decision_wait"] != "5s" || policies[1].(map[string]any)["latency"].(map[string]any)["threshold_ms"] != int64(500) {
+		t.Fatalf("tail_sampling = %v", tail)
+	}
+}
+
+func TestProcessorSignals(t *testing.T) {
+	cases := []struct {
+		p    apiv1beta1.ProcessorSpec
+		want []string
+	}{
+		{apiv1beta1.ProcessorSpec{Type: apiv1beta1.ProcessorAttributes}, []string{"traces", "metrics", "logs"}},
+		{apiv1beta1.ProcessorSpec{Type: apiv1beta1.ProcessorTailSampling}, []string{"traces"}},
+		{apiv1beta1.ProcessorSpec{Type: apiv1beta1.ProcessorFilter, Filter: &apiv1beta1.FilterProcessor{MetricNames: []string{"^rpc_"}}}, []string{"metrics"}},
+		{apiv1beta1.ProcessorSpec{Type: apiv1beta1.ProcessorTransform, Transform: &apiv1beta1.TransformProcessor{
+			Logs: []apiv1beta1.TransformStatements{{Context: "log", Statements: []string{`set(severity_text, "INFO")`}}},
+		}}, []string{"logs"}},
+		{apiv1beta1.ProcessorSpec{Type: apiv1beta1.ProcessorResource, Signals: []apiv1beta1.Signal{apiv1beta1.SignalMetrics}}, []string{"metrics"}},
+	}
+	for _, tc := range cases {
+		if got := ProcessorSignals(&tc.p); !slices.Equal(got, tc.want) {
+			t.Errorf("%s: got %v, want %v", tc.p.Type, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package collector
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+
+	apiv1beta1 "github.com/shtsukada/cloudnative-observability-operator/api/v1beta1"
+)
+
+// PrometheusID は spec.scrape から描画する receiver の ID。
+const PrometheusID = "prometheus"
+
+// ScrapeTarget は Service の label selector を解決済みの scrape job。
+type ScrapeTarget struct {
+	Job apiv1beta1.ScrapeJob
+	// ServiceSelector は対象の Service を選ぶ label selector (空なら全て)。
+	ServiceSelector string
+}
+
+// AddScrape は targets を scrape する prometheus receiver を metrics パイプラインに追加する。
+// 対象は oc の namespace の Service の endpoint で、job の port 名のものだけ。
+func (c *Config) AddScrape(oc *apiv1beta1.ObservabilityConfig, targets []ScrapeTarget) error {
+	if len(targets) == 0 {
+		return nil
+	}
+	metrics, ok := c.Service.Pipelines["metrics"]
+	if !ok {
+		return fmt.Errorf("spec.scrape needs a metrics pipeline with an exporter")
+	}
+	nodeLocal := oc.Spec.Mode == apiv1beta1.AgentModeDaemonSet
+
+	jobs := make([]any, 0, len(targets))
+	for _, t := range targets {
+		sd := map[string]any{
+			"role":       "endpoints",
+			"namespaces": map[string]any{"names": []string{oc.Namespace}},
+		}
+		// endpoints role は Service の label でも絞れる (API server 側で絞るので watch が軽い)
+		if t.ServiceSelector != "" {
+			sd["selectors"] = []any{map[string]any{"role": "service", "label": t.ServiceSelector}}
+		}
+		relabel := []any{
+			map[string]any{"source_labels": []string{"__meta_kubernetes_endpoint_port_name"}, "regex": t.Job.Port, "action": "keep"},
+		}
+		// DaemonSet では各 agent が自ノードの Pod だけを受け持つ
+		if nodeLocal {
+			relabel = append(relabel, map[string]any{
+				"source_labels": []string{"__meta_kubernetes_pod_node_name"}, "regex": "${env:" + NodeNameEnv + "}", "action": "keep",
+			})
+		}
+		for _, l := range []struct{ from, to string }{
+			{"__meta_kubernetes_namespace", "namespace"},
+			{"__meta_kubernetes_service_name", "service"},
+			{"__meta_kubernetes_pod_name", "pod"},
+		} {
+			relabel = append(relabel, map[string]any{"source_labels": []string{l.from}, "target_label": l.to, "action": "replace"})
+		}
+		// "$" は collector が環境変数の展開に使う
+		job := map[string]any{
+			"job_name":              t.Job.Name,
+			"metrics_path":          strings.ReplaceAll(t.Job.Path, "$", "$$"),
+			"kubernetes_sd_configs": []any{sd},
+			"relabel_configs":       relabel,
+		}
+		if t.Job.Interval != nil {
+			job["scrape_interval"] = promDuration(t.Job.Interval.Duration)
+		}
+		jobs = append(jobs, job)
+	}
+	c.Receivers[PrometheusID] = map[string]any{"config": map[string]any{"scrape_configs": jobs}}
+	metrics.Receivers = append(slices.Clone(metrics.Receivers), PrometheusID)
+	c.Service.Pipelines["metrics"] = metrics
+
+	if nodeLocal && !slices.ContainsFunc(c.Env, func(e corev1.EnvVar) bool { return e.Name == NodeNameEnv }) {
+		c.Env = append(c.Env, corev1.EnvVar{
+			Name:      NodeNameEnv,
+			ValueFrom: &corev1.EnvVarSource{FieldRef: &corev1.ObjectFieldSelector{FieldPath: "spec.nodeName"}},
+		})
+	}
+	return nil
+}
+
+// promDuration は Prometheus の duration 形式 ("1.5s" のような小数は使えない) で表す。
+func promDuration(d time.Duration) string {
+	if d%time.Second == 0 {
+		return fmt.Sprintf("%ds", d/time.Second)
+	}
+	return fmt.Sprintf("%dms", d.Milliseconds())
+}
@@ -0,0 +1,77 @@
+package collector
+
+import (
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	apiv1beta1 "github.com/shtsukada/cloudnative-observability-operator/api/v1beta1"
+)
+
+func TestAddScrape(t *testing.T) {
+	oc := testConfig("otel-collector.monitoring:4317")
+	oc.Spec.Mode = apiv1beta1.AgentModeDaemonSet
+	oc.Spec.Scrape = []apiv1beta1.ScrapeJob{{Name: "burners", Port: "metrics", Interval: &metav1.Duration{Duration: time.Minute}}}
+	oc.ApplyDefaults()
+
+	cfg := mustRender(t, oc)
+	err := cfg.AddScrape(oc, []ScrapeTarget{{
+		Job:             oc.Spec.Scrape[0],
+		ServiceSelector: "app.kubernetes.io/instance in (a,b),app.kubernetes.io/name=grpcburner",
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := cfg.Service.Pipelines["metrics"].Receivers; !slices.Equal(got, []string{"otlp", PrometheusID}) {
+		t.Fatalf("metrics receivers = %v", got)
+	}
+	if got := cfg.Service.Pipelines["traces"].Receivers; !slices.Equal(got, []string{"otlp"}) {
+		t.Fatalf("traces receivers = %v", got)
+	}
+	job := cfg.Receivers[PrometheusID].(map[string]any)["config"].(map[string]any)["scrape_configs"].([]any)[0].(map[string]any)
+	if job["job_name"] != "burners" || job["metrics_path"] != "/metrics" || job["scrape_interval"] != "60s" {
+		t.Fatalf("job = %v", job)
+	}
+	sd := job["kubernetes_sd_configs"].([]any)[0].(map[string]any)
+	if sd["role"] != "endpoints" || !slices.Equal(sd["namespaces"].(map[string]any)["names"].([]string), []string{"default"}) {
+		t.Fatalf("kubernetes_sd = %v", sd)
+	}
+	if sel := sd["selectors"].([]any)[0].(map[string]any); sel["role"] != "service" || !strings.Contains(sel["label"].(string), "in (a,b)") {
+		t.Fatalf("selectors = %v", sel)
+	}
+	relabel := job["relabel_configs"].([]any)
+	if r := relabel[1].(map[string]any); r["regex"] != "${env:K8S_NODE_NAME}" {
+		t.Fatalf("node filter = %v", r)
+	}
+	if len(cfg.Env) != 1 || cfg.Env[0].Name != NodeNameEnv {
+		t.Fatalf("env = %v", cfg.Env)
+	}
+
+	data, err := cfg.YAML()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Validate(data); err != nil {
+		t.Fatal(err)
+	}
+	// sidecar は Pod ごとにいるので scrape しない
+	sidecar, err := cfg.SidecarYAML()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(sidecar), PrometheusID) {
+		t.Fatalf("sidecar config scrapes:\n%s", sidecar)
+	}
+	if err := Validate(sidecar); err != nil {
+		t.Fatal(err)
+	}
+
+	// metrics を送らない設定には足せない
+	oc.Spec.MetricsEnabled = new(bool)
+	if err := mustRender(t, oc).AddScrape(oc, []ScrapeTarget{{Job: oc.Spec.Scrape[0]}}); err == nil {
+		t.Fatal("scrape added without a metrics pipeline")
+	}
+}
@@ -0,0 +1,145 @@
+package collector
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+
+	"sigs.k8s.io/yaml"
+)
+
+// collector の component ID は type[/name]
+var componentID = regexp.MustCompile(`^[a-zA-Z][0-9a-zA-Z_]*(/[^/\s]+)?$`)
+
+// Validate は描画した設定ファイルを collector と同じ観点で検査する。
+// agent に配る前に、起動できない設定をここで止める。
+func Validate(data []byte) error {
+	var cfg Config
+	if err := yaml.UnmarshalStrict(data, &cfg); err != nil {
+		return fmt.Errorf("parse collector config: %w", err)
+	}
+
+	var errs []error
+	for _, section := range []struct {
+		kind       string
+		components map[string]any
+	}{
+		{"extensions", cfg.Extensions},
+		{"receivers", cfg.Receivers},
+		{"processors", cfg.Processors},
+		{"exporters", cfg.Exporters},
+	} {
+		for _, id := range sortedKeys(section.components) {
+			if !componentID.MatchString(id) {
+				errs = append(errs, fmt.Errorf("%s: invalid component ID %q", section.kind, id))
+			}
+		}
+	}
+
+	if len(cfg.Service.Pipelines) == 0 {
+		errs = append(errs, errors.New("service: no pipelines"))
+	}
+	for _, id := range cfg.Service.Extensions {
+		if _, ok := cfg.Extensions[id]; !ok {
+			errs = append(errs, fmt.Errorf("service.extensions: references undefined extension %q", id))
+		}
+	}
+	used := map[string]bool{}
+	for _, name := range sortedKeys(cfg.Service.Pipelines) {
+		p := cfg.Service.Pipelines[name]
+		signal, _, _ := strings.Cut(name, "/")
+		if !slices.Contains([]string{"traces", "metrics", "logs"}, signal) || !componentID.MatchString(name) {
+			errs = append(errs, fmt.Errorf("service.pipelines: invalid pipeline ID %q", name))
+		}
+		if len(p.Receivers) == 0 {
+			errs = append(errs, fmt.Errorf("pipeline %q: no receivers", name))
+		}
+		if len(p.Exporters) == 0 {
+			errs = append(errs, fmt.Errorf("pipeline %q: no exporters", name))
+		}
+		errs = append(errs, references(name, "receiver", p.Receivers, cfg.Receivers)...)
+		errs = append(errs, references(name, "processor", p.Processors, cfg.Processors)...)
+		errs = append(errs, references(name, "exporter", p.Exporters, cfg.Exporters)...)
+		for _, id := range p.Receivers {
+			used[id] = true
+		}
+	}
+
+	// collector が起動するのはパイプラインで使う receiver と service.extensions だけ
+	listeners := map[string]any{}
+	for id := range used {
+		listeners["receivers::"+id] = cfg.Receivers[id]
+	}
+	for _, id := range cfg.Service.Extensions {
+		listeners["extensions::"+id] = cfg.Extensions[id]
+	}
+	errs = append(errs, portCollisions(listeners)...)
+	return errors.Join(errs...)
+}
+
+func references(pipeline, kind string, ids []string, defined map[string]any) []error {
+	var errs []error
+	seen := map[string]bool{}
+	for _, id := range ids {
+		if _, ok := defined[id]; !ok {
+			errs = append(errs, fmt.Errorf("pipeline %q: references undefined %s %q", pipeline, kind, id))
+		}
+		if seen[id] {
+			errs = append(errs, fmt.Errorf("pipeline %q: %s %q is listed twice", pipeline, kind, id))
+		}
+		seen[id] = true
+	}
+	return errs
+}
+
+// portCollisions は複数の listener が同じポートで待ち受けないことを確認する。
+func portCollisions(listeners map[string]any) []error {
+	owners := map[string][]string{}
+	for _, owner := range sortedKeys(listeners) {
+		walkEndpoints(listeners[owner], owner, func(at, endpoint string) {
+			_, port, err := net.SplitHostPort(endpoint)
+			if err != nil || port == "" {
+				return
+			}
+			owners[port] = append(owners[port], at)
+		})
+	}
+	var errs []error
+	for _, port := range sortedKeys(owners) {
+		if at := owners[port]; len(at) > 1 {
+			errs = append(errs, fmt.Errorf("port %s is used by more than one listener: %s", port, strings.Join(at, ", ")))
+		}
+	}
+	return errs
+}
+
+// walkEndpoints は component の設定から endpoint キーの値を探す。
+func walkEndpoints(v any, at string, fn func(at, endpoint string)) {
+	m, ok := v.(map[string]any)
+	if !ok {
+		return
+	}
+	for _, k := range sortedKeys(m) {
+		switch child := m[k].(type) {
+		case string:
+			if k == "endpoint" {
+				fn(at, child)
+			}
+		case map[string]any:
+			walkEndpoints(child, at+"."+k, fn)
+		}
+	}
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
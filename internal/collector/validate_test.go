@@ -0,0 +1,102 @@
+package collector
+
+import (
+	"strings"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/utils/ptr"
+
+	apiv1beta1 "github.com/shtsukada/cloudnative-observability-operator/api/v1beta1"
+)
+
+func TestValidateRendered(t *testing.T) {
+	oc := testConfig("otel-collector.monitoring:4317")
+	oc.Spec.Processors = []apiv1beta1.ProcessorSpec{
+		{Name: "drop-health", Type: apiv1beta1.ProcessorFilter, Filter: &apiv1beta1.FilterProcessor{SpanNames: []string{"^grpc.health"}}},
+	}
+	oc.Spec.Agent = &apiv1beta1.AgentSpec{Autoscaling: &apiv1beta1.AutoscalingSpec{MaxReplicas: 3, TargetQueueSize: ptr.To(resource.MustParse("100"))}}
+	data, err := mustRender(t, oc).YAML()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Validate(data); err != nil {
+		t.Fatalf("rendered config is invalid: %v", err)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	cases := []struct {
+		name string
+		yaml string
+		want []string
+	}{
+		{
+			name: "unknown key",
+			yaml: "receivers: {}\nexporters: {}\nservice: {pipelines: {}}\nconnectors: {}\n",
+			want: []string{"parse collector config", `unknown field "connectors"`},
+		},
+		{
+			name: "undefined references",
+			yaml: `
+receivers: {otlp: {}}
+processors: {batch: {}}
+exporters: {debug: {}}
+service:
+  extensions: [health_check]
+  pipelines:
+    traces: {receivers: [otlp], processors: [batch, filter/x, batch], exporters: [debug]}
+    events: {receivers: [otlp], exporters: []}
+`,
+			want: []string{
+				`undefined extension "health_check"`,
+				`invalid pipeline ID "events"`,
+				`pipeline "events": no exporters`,
+				`pipeline "traces": references undefined processor "filter/x"`,
+				`processor "batch" is listed twice`,
+			},
+		},
+		{
+			name: "port collision",
+			yaml: `
+extensions: {health_check: {endpoint: "0.0.0.0:4318"}}
+receivers:
+  otlp: {protocols: {grpc: {endpoint: "0.0.0.0:4317"}, http: {endpoint: "0.0.0.0:4318"}}}
+  otlp/unused: {protocols: {grpc: {endpoint: "0.0.0.0:4317"}}}
+exporters: {debug: {}}
+service:
+  extensions: [health_check]
+  pipelines:
+    traces: {receivers: [otlp], exporters: [debug]}
+`,
+			want: []string{"port 4318 is used by more than one listener: extensions::health_check, receivers::otlp.protocols.http"},
+		},
+		{
+			name: "invalid component ID",
+			yaml: `
+receivers: {otlp: {}}
+processors: {"filter/drop health": {}}
+exporters: {debug: {}}
+service: {pipelines: {traces: {receivers: [otlp], processors: ["filter/drop health"], exporters: [debug]}}}
+`,
+			want: []string{`processors: invalid component ID "filter/drop health"`},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := Validate([]byte(tc.yaml))
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			for _, w := range tc.want {
+				if !strings.Contains(err.Error(), w) {
+					t.Errorf("error %q does not mention %q", err, w)
+				}
+			}
+			// 使われない receiver のポートは衝突とみなさない
+			if strings.Contains(err.Error(), "port 4317") {
+				t.Errorf("unused receiver reported: %v", err)
+			}
+		})
+	}
+}
@@ -3,6 +3,7 @@ package controller
 import (
 	"context"
 	"fmt"
+	"time"
 
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
@@ -10,16 +11,17 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation/field"
 	"k8s.io/client-go/tools/record"
-	"k8s.io/utils/ptr"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 	"sigs.k8s.io/controller-runtime/pkg/log"
 
-	apiv1alpha1 "github.com/shtsukada/cloudnative-observability-operator/api/v1alpha1"
+	apiv1beta1 "github.com/shtsukada/cloudnative-observability-operator/api/v1beta1"
 	conditions "github.com/shtsukada/cloudnative-observability-operator/internal/shared/conditions"
 	tel "github.com/shtsukada/cloudnative-observability-operator/internal/shared/telemetry"
+	"github.com/shtsukada/cloudnative-observability-operator/internal/shared/validation"
 )
 
 const finalizerName = "grpcburner.finalizers.observability.shtsukada.dev"
@@ -28,6 +30,7 @@ const finalizerName = "grpcburner.finalizers.observability.shtsukada.dev"
 // +kubebuilder:rbac:groups=observability.shtsukada.dev,resources=grpcburners/status,verbs=get;update;patch
 // +kubebuilder:rbac:groups=observability.shtsukada.dev,resources=grpcburners/finalizers,verbs=update
 // +kubebuilder:rbac:groups="",resources=serviceaccounts;services;events,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups="",resources=pods,verbs=get;list;watch
 // +kubebuilder:rbac:groups=apps,resources=deployments,verbs=get;list;watch;create;update;patch;delete
 
 type GrpcBurnerReconciler struct {
@@ -39,7 +42,7 @@ type GrpcBurnerReconciler struct {
 func (r *GrpcBurnerReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
 	logger := log.FromContext(ctx)
 
-	var gb apiv1alpha1.GrpcBurner
+	var gb apiv1beta1.GrpcBurner
 	if err := r.Get(ctx, req.NamespacedName, &gb); err != nil {
 		if apierrors.IsNotFound(err) {
 			return ctrl.Result{}, nil
@@ -65,7 +68,14 @@ func (r *GrpcBurnerReconciler) Reconcile(ctx context.Context, req ctrl.Request)
 		}
 	}
 
-	r.setCondition(&gb, apiv1alpha1.ConditionProgressing, metav1.ConditionTrue, conditions.ReasonReconciling, "Reconciling desired state")
+	// webhook を経由していないオブジェクトにも同じ既定値を適用してから描画する
+	gb.ApplyDefaults()
+
+	if errs := validation.GrpcBurnerSpec(&gb.Spec, field.NewPath("spec")); len(errs) > 0 {
+		return r.invalid(&gb, errs.ToAggregate())
+	}
+
+	r.setCondition(&gb, apiv1beta1.ConditionProgressing, metav1.ConditionTrue, conditions.ReasonReconciling, "Reconciling desired state")
 	if err := r.Status().Update(ctx, &gb); err != nil {
 		logger.V(1).Info("status update (progressing) failed", "err", err)
 	}
@@ -73,6 +83,12 @@ func (r *GrpcBurnerReconciler) Reconcile(ctx context.Context, req ctrl.Request)
 	sa := desiredServiceAccount(&gb)
 	svc := desiredService(&gb)
 	deploy := desiredDeployment(&gb)
+	hash := specHash(&gb)
+
+	rolledBack, err := r.applyRollback(ctx, &gb, deploy)
+	if err != nil {
+		return r.fail(&gb, err)
+	}
 
 	if err := r.createOrUpdate(ctx, &gb, sa, func() error { return nil }); err != nil {
 		return r.fail(&gb, err)
@@ -83,24 +99,45 @@ func (r *GrpcBurnerReconciler) Reconcile(ctx context.Context, req ctrl.Request)
 	if err := r.createOrUpdate(ctx, &gb, deploy, func() error { return nil }); err != nil {
 		return r.fail(&gb, err)
 	}
+	recordApplied(&gb, deploy, rolledBack, metav1.Now())
 
 	var d appsv1.Deployment
 	if err := r.Get(ctx, types.NamespacedName{Name: deploy.Name, Namespace: deploy.Namespace}, &d); err == nil {
 		gb.Status.ReadyReplicas = d.Status.ReadyReplicas
+		complete := deploymentComplete(&d)
+		if !rolledBack && complete && d.Spec.Template.Annotations[specHashAnnotation] == hash {
+			gb.Status.LastKnownGood = &apiv1beta1.KnownGoodRevision{
+				Generation: gb.Generation,
+				SpecHash:   hash,
+				Image:      gb.Spec.Image,
+				Template:   *d.Spec.Template.DeepCopy(),
+			}
+		}
+		if complete {
+			recordReady(&gb, d.Spec.Template.Annotations[specHashAnnotation], metav1.Now())
+		} else if !rolledBack {
+			if reason, _, err := r.detectFailure(ctx, &gb, deploy, hash); err == nil && reason != "" {
+				recordFailure(&gb, hash, reason)
+			}
+		}
 		_ = r.Status().Update(ctx, &gb)
-		if d.Status.ReadyReplicas == ptr.Deref(deploy.Spec.Replicas, 1) {
+		if d.Status.ReadyReplicas == *deploy.Spec.Replicas {
 			conditions.Emit(r.Recorder, &gb, corev1.EventTypeNormal, conditions.ReasonDeploymentAvailable, "deployment available: ready=%d", d.Status.ReadyReplicas)
-			gb.SetCondition(apiv1alpha1.ConditionReady, metav1.ConditionTrue, conditions.ReasonDeploymentAvailable, "Deployment ready")
-			gb.SetCondition(apiv1alpha1.ConditionProgressing, metav1.ConditionFalse, "Stable", "Reconcile stable")
+			gb.SetCondition(apiv1beta1.ConditionReady, metav1.ConditionTrue, conditions.ReasonDeploymentAvailable, "Deployment ready")
+			gb.SetCondition(apiv1beta1.ConditionProgressing, metav1.ConditionFalse, "Stable", "Reconcile stable")
 			_ = r.Status().Update(ctx, &gb)
 		} else {
-			conditions.Emit(r.Recorder, &gb, corev1.EventTypeNormal, conditions.ReasonDeploymentUnavailable, "deployment progressing: ready=%d/%d", d.Status.ReadyReplicas, ptr.Deref(deploy.Spec.Replicas, 1))
+			conditions.Emit(r.Recorder, &gb, corev1.EventTypeNormal, conditions.ReasonDeploymentUnavailable, "deployment progressing: ready=%d/%d", d.Status.ReadyReplicas, *deploy.Spec.Replicas)
+		}
+		// CrashLoopBackOff は Deployment の status を変えないことがあるので定期的に見直す
+		if gb.Spec.RollbackOnFailure && !rolledBack && !complete {
+			return ctrl.Result{RequeueAfter: 30 * time.Second}, nil
 		}
 	}
 	return ctrl.Result{}, nil
 }
 
-func (r *GrpcBurnerReconciler) createOrUpdate(ctx context.Context, owner *apiv1alpha1.GrpcBurner, obj client.Object, mutate func() error) error {
+func (r *GrpcBurnerReconciler) createOrUpdate(ctx context.Context, owner *apiv1beta1.GrpcBurner, obj client.Object, mutate func() error) error {
 	if err := controllerutil.SetControllerReference(owner, obj, r.Scheme); err != nil {
 		return err
 	}
@@ -131,24 +168,34 @@ func (r *GrpcBurnerReconciler) createOrUpdate(ctx context.Context, owner *apiv1a
 	return nil
 }
 
-func (r *GrpcBurnerReconciler) setCondition(gb *apiv1alpha1.GrpcBurner, cond string, status metav1.ConditionStatus, reason, msg string) {
+func (r *GrpcBurnerReconciler) setCondition(gb *apiv1beta1.GrpcBurner, cond string, status metav1.ConditionStatus, reason, msg string) {
 	gb.SetCondition(cond, status, reason, msg)
 }
 
-func (r *GrpcBurnerReconciler) fail(gb *apiv1alpha1.GrpcBurner, err error) (ctrl.Result, error) {
+func (r *GrpcBurnerReconciler) fail(gb *apiv1beta1.GrpcBurner, err error) (ctrl.Result, error) {
 	reason := conditions.ClassifyApplyError(err)
 	conditions.Emit(r.Recorder, gb, corev1.EventTypeWarning, reason, "apply failed:%v", err)
-	gb.SetCondition(apiv1alpha1.ConditionDegraded, metav1.ConditionTrue, reason, err.Error())
-	gb.SetCondition(apiv1alpha1.ConditionReady, metav1.ConditionFalse, reason, "Not ready")
+	gb.SetCondition(apiv1beta1.ConditionDegraded, metav1.ConditionTrue, reason, err.Error())
+	gb.SetCondition(apiv1beta1.ConditionReady, metav1.ConditionFalse, reason, "Not ready")
 	_ = r.Status().Update(context.Background(), gb)
 	return ctrl.Result{}, err
 }
 
+// invalid は spec の誤りを報告する。spec が直るまで再試行しない。
+func (r *GrpcBurnerReconciler) invalid(gb *apiv1beta1.GrpcBurner, err error) (ctrl.Result, error) {
+	conditions.Emit(r.Recorder, gb, corev1.EventTypeWarning, conditions.ReasonErrInvalid, "invalid spec: %v", err)
+	gb.SetCondition(apiv1beta1.ConditionDegraded, metav1.ConditionTrue, conditions.ReasonErrInvalid, err.Error())
+	gb.SetCondition(apiv1beta1.ConditionReady, metav1.ConditionFalse, conditions.ReasonErrInvalid, "Not ready")
+	gb.SetCondition(apiv1beta1.ConditionProgressing, metav1.ConditionFalse, conditions.ReasonErrInvalid, "Invalid spec")
+	_ = r.Status().Update(context.Background(), gb)
+	return ctrl.Result{}, nil
+}
+
 func (r *GrpcBurnerReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	r.Recorder = mgr.GetEventRecorderFor("cloudnative-observability-operator")
 	wrapped := tel.WrapReconciler("GrpcBurner", r)
 	return ctrl.NewControllerManagedBy(mgr).
-		For(&apiv1alpha1.GrpcBurner{}).
+		For(&apiv1beta1.GrpcBurner{}).
 		Owns(&appsv1.Deployment{}).
 		Owns(&corev1.Service{}).
 		Owns(&corev1.ServiceAccount{}).
@@ -0,0 +1,62 @@
+package controller
+
+import (
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+
+	apiv1beta1 "github.com/shtsukada/cloudnative-observability-operator/api/v1beta1"
+)
+
+const defaultRevisionHistoryLimit = 10
+
+// recordApplied は deploy に載った revision が直近の履歴と異なれば追記する。
+// rolledBack のときは deploy のテンプレートが last known-good のものなので、その generation を記録する。
+func recordApplied(gb *apiv1beta1.GrpcBurner, deploy *appsv1.Deployment, rolledBack bool, now metav1.Time) {
+	hash := deploy.Spec.Template.Annotations[specHashAnnotation]
+	if n := len(gb.Status.History); n > 0 && gb.Status.History[n-1].SpecHash == hash {
+		return
+	}
+	image := ""
+	for _, c := range deploy.Spec.Template.Spec.Containers {
+		if c.Name == apiv1beta1.ServerContainerName {
+			image = c.Image
+		}
+	}
+	generation := gb.Generation
+	if good := gb.Status.LastKnownGood; rolledBack && good != nil {
+		generation = good.Generation
+	}
+	gb.Status.History = append(gb.Status.History, apiv1beta1.RevisionRecord{
+		Generation: generation,
+		SpecHash:   hash,
+		Image:      image,
+		AppliedAt:  now,
+	})
+
+	limit := int(ptr.Deref(gb.Spec.RevisionHistoryLimit, defaultRevisionHistoryLimit))
+	if over := len(gb.Status.History) - limit; limit > 0 && over > 0 {
+		gb.Status.History = gb.Status.History[over:]
+	}
+}
+
+func latestRecord(gb *apiv1beta1.GrpcBurner, hash string) *apiv1beta1.RevisionRecord {
+	n := len(gb.Status.History)
+	if n == 0 || gb.Status.History[n-1].SpecHash != hash {
+		return nil
+	}
+	return &gb.Status.History[n-1]
+}
+
+func recordReady(gb *apiv1beta1.GrpcBurner, hash string, now metav1.Time) {
+	if rec := latestRecord(gb, hash); rec != nil && rec.ReadyAt == nil {
+		rec.ReadyAt = &now
+		rec.FailureReason = ""
+	}
+}
+
+func recordFailure(gb *apiv1beta1.GrpcBurner, hash, reason string) {
+	if rec := latestRecord(gb, hash); rec != nil && rec.ReadyAt == nil {
+		rec.FailureReason = reason
+	}
+}
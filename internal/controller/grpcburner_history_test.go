@@ -0,0 +1,80 @@
+package controller
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+
+	apiv1beta1 "github.com/shtsukada/cloudnative-observability-operator/api/v1beta1"
+)
+
+func TestRevisionHistory(t *testing.T) {
+	gb := testBurner()
+	gb.Spec.RevisionHistoryLimit = ptr.To(int32(3))
+	t0 := metav1.NewTime(time.Date(2025, 1, 1, 3, 0, 0, 0, time.UTC))
+
+	deploy := desiredDeployment(gb)
+	recordApplied(gb, deploy, false, t0)
+	recordApplied(gb, deploy, false, t0)
+	if len(gb.Status.History) != 1 {
+		t.Fatalf("same revision recorded twice: %d", len(gb.Status.History))
+	}
+
+	hash := deploy.Spec.Template.Annotations[specHashAnnotation]
+	recordFailure(gb, hash, "CrashLoopBackOff")
+	recordReady(gb, hash, t0)
+	rec := gb.Status.History[0]
+	if rec.ReadyAt == nil || rec.FailureReason != "" || rec.Image != gb.Spec.Image {
+		t.Fatalf("unexpected record: %+v", rec)
+	}
+
+	for i := 0; i < 5; i++ {
+		gb.Generation++
+		gb.Spec.Image = fmt.Sprintf("ghcr.io/shtsukada/grpc-burner:v%d", 10+i)
+		recordApplied(gb, desiredDeployment(gb), false, t0)
+	}
+	if len(gb.Status.History) != 3 {
+		t.Fatalf("history not bounded: %d", len(gb.Status.History))
+	}
+	if got := gb.Status.History[2].Image; got != "ghcr.io/shtsukada/grpc-burner:v14" {
+		t.Fatalf("latest image = %s", got)
+	}
+}
+
+func TestRevisionHistoryAfterRollback(t *testing.T) {
+	gb := testBurner()
+	gb.Generation = 1
+	t0 := metav1.NewTime(time.Date(2025, 1, 1, 3, 0, 0, 0, time.UTC))
+	good := desiredDeployment(gb)
+	recordApplied(gb, good, false, t0)
+	gb.Status.LastKnownGood = &apiv1beta1.KnownGoodRevision{
+		Generation: 1,
+		SpecHash:   good.Spec.Template.Annotations[specHashAnnotation],
+		Image:      gb.Spec.Image,
+		Template:   *good.Spec.Template.DeepCopy(),
+	}
+
+	gb.Generation = 2
+	goodImage := gb.Spec.Image
+	gb.Spec.Image = "ghcr.io/shtsukada/grpc-burner:v9"
+	bad := desiredDeployment(gb)
+	recordApplied(gb, bad, false, t0)
+	recordFailure(gb, bad.Spec.Template.Annotations[specHashAnnotation], "CrashLoopBackOff")
+
+	// rollback 後に載ったのは generation 1 のテンプレート
+	bad.Spec.Template = *gb.Status.LastKnownGood.Template.DeepCopy()
+	recordApplied(gb, bad, true, t0)
+	if len(gb.Status.History) != 3 {
+		t.Fatalf("history = %+v", gb.Status.History)
+	}
+	failed, restored := gb.Status.History[1], gb.Status.History[2]
+	if failed.Generation != 2 || failed.FailureReason != "CrashLoopBackOff" {
+		t.Fatalf("failed record = %+v", failed)
+	}
+	if restored.Generation != 1 || restored.Image != goodImage || restored.SpecHash != gb.Status.LastKnownGood.SpecHash {
+		t.Fatalf("restored record = %+v", restored)
+	}
+}
@@ -1,7 +1,12 @@
 package controller
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
+	"slices"
+	"strconv"
 
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
@@ -9,10 +14,23 @@ import (
 	"k8s.io/apimachinery/pkg/util/intstr"
 	"k8s.io/utils/ptr"
 
-	apiv1alpha1 "github.com/shtsukada/cloudnative-observability-operator/api/v1alpha1"
+	apiv1beta1 "github.com/shtsukada/cloudnative-observability-operator/api/v1beta1"
+	"github.com/shtsukada/cloudnative-observability-operator/internal/shared/loadenv"
 )
 
-func labels(gb *apiv1alpha1.GrpcBurner) map[string]string {
+const specHashAnnotation = "observability.shtsukada.dev/spec-hash"
+
+// specHash はテンプレートに影響する spec だけをハッシュ化する
+func specHash(gb *apiv1beta1.GrpcBurner) string {
+	spec := gb.Spec.DeepCopy()
+	spec.RollbackOnFailure = false
+	spec.RevisionHistoryLimit = nil
+	b, _ := json.Marshal(spec)
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])[:16]
+}
+
+func labels(gb *apiv1beta1.GrpcBurner) map[string]string {
 	return map[string]string{
 		"app.kubernetes.io/name":       "grpcburner",
 		"app.kubernetes.io/instance":   gb.Name,
@@ -20,7 +38,41 @@ func labels(gb *apiv1alpha1.GrpcBurner) map[string]string {
 	}
 }
 
-func desiredServiceAccount(gb *apiv1alpha1.GrpcBurner) *corev1.ServiceAccount {
+// loadEnv は spec.load を grpc-burner の環境変数に変換する。
+func loadEnv(gb *apiv1beta1.GrpcBurner) []corev1.EnvVar {
+	l := gb.Spec.Load
+	if l == nil {
+		return nil
+	}
+	env := []corev1.EnvVar{{Name: loadenv.Mode, Value: string(l.Mode)}}
+	for _, v := range []struct {
+		name  string
+		value *int32
+	}{
+		{loadenv.QPS, l.QPS},
+		{loadenv.Concurrency, l.Concurrency},
+		{loadenv.PayloadBytes, l.PayloadBytes},
+	} {
+		if v.value != nil {
+			env = append(env, corev1.EnvVar{Name: v.name, Value: strconv.Itoa(int(*v.value))})
+		}
+	}
+	return env
+}
+
+func probe(p *apiv1beta1.ProbeSpec) *corev1.Probe {
+	return &corev1.Probe{
+		ProbeHandler: corev1.ProbeHandler{
+			TCPSocket: &corev1.TCPSocketAction{Port: intstr.FromInt32(*p.Port)},
+		},
+		InitialDelaySeconds: *p.InitialDelaySeconds,
+		PeriodSeconds:       *p.PeriodSeconds,
+		TimeoutSeconds:      *p.TimeoutSeconds,
+		FailureThreshold:    *p.FailureThreshold,
+	}
+}
+
+func desiredServiceAccount(gb *apiv1beta1.GrpcBurner) *corev1.ServiceAccount {
 	return &corev1.ServiceAccount{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      fmt.Sprintf("%s-sa", gb.Name),
@@ -30,33 +82,18 @@ func desiredServiceAccount(gb *apiv1alpha1.GrpcBurner) *corev1.ServiceAccount {
 	}
 }
 
-func desiredService(gb *apiv1alpha1.GrpcBurner) *corev1.Service {
+func desiredService(gb *apiv1beta1.GrpcBurner) *corev1.Service {
 	lbl := labels(gb)
 
-	ports := func() []corev1.ServicePort {
-		if len(gb.Spec.Ports) == 0 {
-			return []corev1.ServicePort{{
-				Name:       "grpc",
-				Port:       50051,
-				Protocol:   corev1.ProtocolTCP,
-				TargetPort: intstr.FromInt(50051),
-			}}
-		}
-		out := make([]corev1.ServicePort, 0, len(gb.Spec.Ports))
-		for _, p := range gb.Spec.Ports {
-			svcPort := p.ContainerPort
-			if p.ServicePort != nil {
-				svcPort = *p.ServicePort
-			}
-			out = append(out, corev1.ServicePort{
-				Name:       p.Name,
-				Port:       svcPort,
-				Protocol:   p.Protocol,
-				TargetPort: intstr.FromInt(int(p.ContainerPort)),
-			})
-		}
-		return out
-	}()
+	ports := make([]corev1.ServicePort, 0, len(gb.Spec.Ports))
+	for _, p := range gb.Spec.Ports {
+		ports = append(ports, corev1.ServicePort{
+			Name:       p.Name,
+			Port:       *p.ServicePort,
+			Protocol:   p.Protocol,
+			TargetPort: intstr.FromInt(int(p.ContainerPort)),
+		})
+	}
 
 	return &corev1.Service{
 		ObjectMeta: metav1.ObjectMeta{
@@ -71,65 +108,52 @@ func desiredService(gb *apiv1alpha1.GrpcBurner) *corev1.Service {
 	}
 }
 
-func desiredDeployment(gb *apiv1alpha1.GrpcBurner) *appsv1.Deployment {
+func desiredDeployment(gb *apiv1beta1.GrpcBurner) *appsv1.Deployment {
 	lbl := labels(gb)
 
-	replicas := int32(1)
-	if gb.Spec.Replicas != nil {
-		replicas = *gb.Spec.Replicas
-	}
-
 	image := gb.Spec.Image
 
-	containerPorts := func() []corev1.ContainerPort {
-		if len(gb.Spec.Ports) == 0 {
-			return []corev1.ContainerPort{{Name: "grpc", ContainerPort: 50051}}
-		}
-		out := make([]corev1.ContainerPort, 0, len(gb.Spec.Ports))
-		for _, p := range gb.Spec.Ports {
-			out = append(out, corev1.ContainerPort{
-				Name:          p.Name,
-				ContainerPort: p.ContainerPort,
-				Protocol:      p.Protocol,
-			})
-		}
-		return out
-	}()
+	containerPorts := make([]corev1.ContainerPort, 0, len(gb.Spec.Ports))
+	for _, p := range gb.Spec.Ports {
+		containerPorts = append(containerPorts, corev1.ContainerPort{
+			Name:          p.Name,
+			ContainerPort: p.ContainerPort,
+			Protocol:      p.Protocol,
+		})
+	}
 
 	spec := appsv1.DeploymentSpec{
-		Replicas: ptr.To(replicas),
+		Replicas: ptr.To(*gb.Spec.Replicas),
 		Selector: &metav1.LabelSelector{MatchLabels: lbl},
 		Template: corev1.PodTemplateSpec{
 			ObjectMeta: metav1.ObjectMeta{
-				Labels: lbl,
+				Labels:      lbl,
+				Annotations: map[string]string{specHashAnnotation: specHash(gb)},
 			},
 			Spec: corev1.PodSpec{
 				ServiceAccountName: fmt.Sprintf("%s-sa", gb.Name),
+				InitContainers:     gb.Spec.InitContainers,
+				Volumes:            gb.Spec.Volumes,
 				Containers: []corev1.Container{
 					{
-						Name:      "server",
-						Image:     image,
-						Env:       gb.Spec.Env,
-						Resources: gb.Spec.Resources,
-						Ports:     containerPorts,
-						ReadinessProbe: &corev1.Probe{
-							ProbeHandler: corev1.ProbeHandler{
-								TCPSocket: &corev1.TCPSocketAction{Port: intstr.FromInt(50051)},
-							},
-						},
-						LivenessProbe: &corev1.Probe{
-							ProbeHandler: corev1.ProbeHandler{
-								TCPSocket: &corev1.TCPSocketAction{Port: intstr.FromInt(50051)},
-							},
-						},
+						Name:           apiv1beta1.ServerContainerName,
+						Image:          image,
+						Env:            slices.Concat(loadEnv(gb), gb.Spec.Env),
+						Resources:      gb.Spec.Resources,
+						Ports:          containerPorts,
+						VolumeMounts:   gb.Spec.VolumeMounts,
+						ReadinessProbe: probe(gb.Spec.Probe),
+						LivenessProbe:  probe(gb.Spec.Probe),
 					},
 				},
 			},
 		},
 	}
 
+	spec.Template.Spec.Containers = append(spec.Template.Spec.Containers, gb.Spec.Sidecars...)
+
 	switch gb.Spec.UpdateStrategy {
-	case apiv1alpha1.UpdateStrategyRecreate:
+	case apiv1beta1.UpdateStrategyRecreate:
 		spec.Strategy = appsv1.DeploymentStrategy{Type: appsv1.RecreateDeploymentStrategyType}
 	default:
 		spec.Strategy = appsv1.DeploymentStrategy{Type: appsv1.RollingUpdateDeploymentStrategyType}
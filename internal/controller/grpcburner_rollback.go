@@ -0,0 +1,93 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	apiv1beta1 "github.com/shtsukada/cloudnative-observability-operator/api/v1beta1"
+	conditions "github.com/shtsukada/cloudnative-observability-operator/internal/shared/conditions"
+)
+
+// applyRollback は現在の revision が失敗していれば deploy のテンプレートを
+// last known-good に差し替える。差し替えた場合 true を返す。
+func (r *GrpcBurnerReconciler) applyRollback(ctx context.Context, gb *apiv1beta1.GrpcBurner, deploy *appsv1.Deployment) (bool, error) {
+	if c := gb.GetCondition(apiv1beta1.ConditionRolledBack); c != nil && c.Status == metav1.ConditionTrue && c.ObservedGeneration != gb.Generation {
+		gb.SetCondition(apiv1beta1.ConditionRolledBack, metav1.ConditionFalse, conditions.ReasonSpecUpdated, "spec updated, rolling forward")
+	}
+
+	good := gb.Status.LastKnownGood
+	hash := deploy.Spec.Template.Annotations[specHashAnnotation]
+	if !gb.Spec.RollbackOnFailure || good == nil || good.SpecHash == hash {
+		return false, nil
+	}
+
+	if !gb.IsConditionTrue(apiv1beta1.ConditionRolledBack) {
+		reason, detail, err := r.detectFailure(ctx, gb, deploy, hash)
+		if err != nil || reason == "" {
+			return false, err
+		}
+		recordFailure(gb, hash, reason)
+		msg := fmt.Sprintf("generation %d failed (%s); rolled back to generation %d (image %s)", gb.Generation, detail, good.Generation, good.Image)
+		conditions.Emit(r.Recorder, gb, corev1.EventTypeWarning, conditions.ReasonRolledBack, "%s", msg)
+		gb.SetCondition(apiv1beta1.ConditionRolledBack, metav1.ConditionTrue, reason, msg)
+		gb.SetCondition(apiv1beta1.ConditionDegraded, metav1.ConditionTrue, conditions.ReasonRolledBack, msg)
+	}
+
+	deploy.Spec.Template = *good.Template.DeepCopy()
+	return true, nil
+}
+
+// detectFailure は hash の revision が ProgressDeadlineExceeded か CrashLoopBackOff かを判定する。
+func (r *GrpcBurnerReconciler) detectFailure(ctx context.Context, gb *apiv1beta1.GrpcBurner, deploy *appsv1.Deployment, hash string) (string, string, error) {
+	var d appsv1.Deployment
+	if err := r.Get(ctx, types.NamespacedName{Name: deploy.Name, Namespace: deploy.Namespace}, &d); err != nil {
+		if apierrors.IsNotFound(err) {
+			return "", "", nil
+		}
+		return "", "", err
+	}
+	// まだ新しい revision がロールアウトされていない
+	if d.Spec.Template.Annotations[specHashAnnotation] != hash {
+		return "", "", nil
+	}
+
+	for _, c := range d.Status.Conditions {
+		if c.Type == appsv1.DeploymentProgressing && c.Status == corev1.ConditionFalse && c.Reason == conditions.ReasonProgressDeadlineExceeded {
+			return conditions.ReasonProgressDeadlineExceeded, c.Message, nil
+		}
+	}
+
+	var pods corev1.PodList
+	if err := r.List(ctx, &pods, client.InNamespace(gb.Namespace), client.MatchingLabels(labels(gb))); err != nil {
+		return "", "", err
+	}
+	for _, p := range pods.Items {
+		if p.Annotations[specHashAnnotation] != hash {
+			continue
+		}
+		statuses := append(append([]corev1.ContainerStatus{}, p.Status.InitContainerStatuses...), p.Status.ContainerStatuses...)
+		for _, cs := range statuses {
+			if cs.State.Waiting != nil && cs.State.Waiting.Reason == conditions.ReasonCrashLoopBackOff {
+				return conditions.ReasonCrashLoopBackOff, fmt.Sprintf("pod %s container %s is in CrashLoopBackOff", p.Name, cs.Name), nil
+			}
+		}
+	}
+	return "", "", nil
+}
+
+// deploymentComplete はロールアウトが完了し全 replica が利用可能かを返す。
+func deploymentComplete(d *appsv1.Deployment) bool {
+	want := ptr.Deref(d.Spec.Replicas, 1)
+	return d.Status.ObservedGeneration >= d.Generation &&
+		d.Status.UpdatedReplicas == want &&
+		d.Status.ReadyReplicas == want &&
+		d.Status.AvailableReplicas == want
+}
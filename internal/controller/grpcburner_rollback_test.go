@@ -0,0 +1,111 @@
+package controller
+
+import (
+	"context"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	apiv1beta1 "github.com/shtsukada/cloudnative-observability-operator/api/v1beta1"
+	conditions "github.com/shtsukada/cloudnative-observability-operator/internal/shared/conditions"
+)
+
+func newTestScheme(t *testing.T) *runtime.Scheme {
+	t.Helper()
+	s := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(s); err != nil {
+		t.Fatal(err)
+	}
+	if err := apiv1beta1.AddToScheme(s); err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func testBurner() *apiv1beta1.GrpcBurner {
+	gb := &apiv1beta1.GrpcBurner{
+		ObjectMeta: metav1.ObjectMeta{Name: "gb", Namespace: "default", Generation: 3},
+		Spec: apiv1beta1.GrpcBurnerSpec{
+			Image:             "ghcr.io/shtsukada/grpc-burner:v2",
+			Replicas:          ptr.To(int32(1)),
+			Ports:             []apiv1beta1.PortSpec{{Name: "grpc", ContainerPort: 50051, Protocol: corev1.ProtocolTCP}},
+			RollbackOnFailure: true,
+		},
+	}
+	gb.ApplyDefaults()
+	return gb
+}
+
+func TestApplyRollbackOnProgressDeadline(t *testing.T) {
+	gb := testBurner()
+
+	good := gb.DeepCopy()
+	good.Generation = 2
+	good.Spec.Image = "ghcr.io/shtsukada/grpc-burner:v1"
+	goodDeploy := desiredDeployment(good)
+	gb.Status.LastKnownGood = &apiv1beta1.KnownGoodRevision{
+		Generation: 2,
+		SpecHash:   specHash(good),
+		Image:      good.Spec.Image,
+		Template:   goodDeploy.Spec.Template,
+	}
+
+	deploy := desiredDeployment(gb)
+	failing := deploy.DeepCopy()
+	failing.Status.Conditions = []appsv1.DeploymentCondition{{
+		Type:   appsv1.DeploymentProgressing,
+		Status: corev1.ConditionFalse,
+		Reason: conditions.ReasonProgressDeadlineExceeded,
+	}}
+
+	c := fake.NewClientBuilder().WithScheme(newTestScheme(t)).WithObjects(failing).WithStatusSubresource(failing).Build()
+	r := &GrpcBurnerReconciler{Client: c, Scheme: c.Scheme(), Recorder: record.NewFakeRecorder(8)}
+
+	rolledBack, err := r.applyRollback(context.Background(), gb, deploy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !rolledBack {
+		t.Fatal("expected rollback")
+	}
+	if got := deploy.Spec.Template.Spec.Containers[0].Image; got != good.Spec.Image {
+		t.Fatalf("image = %s, want %s", got, good.Spec.Image)
+	}
+	cond := gb.GetCondition(apiv1beta1.ConditionRolledBack)
+	if cond == nil || cond.Status != metav1.ConditionTrue || cond.Reason != conditions.ReasonProgressDeadlineExceeded {
+		t.Fatalf("unexpected RolledBack condition: %+v", cond)
+	}
+
+	// 次の generation では roll forward する
+	gb.Generation = 4
+	gb.Spec.Image = "ghcr.io/shtsukada/grpc-burner:v3"
+	deploy = desiredDeployment(gb)
+	rolledBack, err = r.applyRollback(context.Background(), gb, deploy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rolledBack || gb.IsConditionTrue(apiv1beta1.ConditionRolledBack) {
+		t.Fatal("expected roll forward after spec update")
+	}
+}
+
+func TestApplyRollbackDisabled(t *testing.T) {
+	gb := testBurner()
+	gb.Spec.RollbackOnFailure = false
+	gb.Status.LastKnownGood = &apiv1beta1.KnownGoodRevision{SpecHash: "other"}
+
+	c := fake.NewClientBuilder().WithScheme(newTestScheme(t)).Build()
+	r := &GrpcBurnerReconciler{Client: c, Scheme: c.Scheme(), Recorder: record.NewFakeRecorder(8)}
+
+	rolledBack, err := r.applyRollback(context.Background(), gb, desiredDeployment(gb))
+	if err != nil || rolledBack {
+		t.Fatalf("rolledBack=%v err=%v", rolledBack, err)
+	}
+}
@@ -0,0 +1,163 @@
+package controller
+
+import (
+	"context"
+
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+
+	observabilityv1beta1 "github.com/shtsukada/cloudnative-observability-operator/api/v1beta1"
+	conditions "github.com/shtsukada/cloudnative-observability-operator/internal/shared/conditions"
+)
+
+// autoscaled は HPA が agent の replica 数を管理しているかを返す。
+func autoscaled(oc *observabilityv1beta1.ObservabilityConfig) bool {
+	return agentSpec(oc).Autoscaling != nil && oc.Spec.Mode != observabilityv1beta1.AgentModeDaemonSet
+}
+
+// agentReplicas は workload に書く replica 数。autoscaling 中は作成時の初期値にだけ使う。
+func agentReplicas(oc *observabilityv1beta1.ObservabilityConfig) *int32 {
+	if autoscaled(oc) {
+		return agentSpec(oc).Autoscaling.MinReplicas
+	}
+	return agentSpec(oc).Replicas
+}
+
+func desiredHPA(oc *observabilityv1beta1.ObservabilityConfig) *autoscalingv2.HorizontalPodAutoscaler {
+	as := agentSpec(oc).Autoscaling
+	kind := "Deployment"
+	if oc.Spec.Mode == observabilityv1beta1.AgentModeStatefulSet {
+		kind = "StatefulSet"
+	}
+
+	var metrics []autoscalingv2.MetricSpec
+	utilization := func(name corev1.ResourceName, target *int32) {
+		if target == nil {
+			return
+		}
+		metrics = append(metrics, autoscalingv2.MetricSpec{
+			Type: autoscalingv2.ResourceMetricSourceType,
+			Resource: &autoscalingv2.ResourceMetricSource{
+				Name:   name,
+				Target: autoscalingv2.MetricTarget{Type: autoscalingv2.UtilizationMetricType, AverageUtilization: target},
+			},
+		})
+	}
+	utilization(corev1.ResourceCPU, as.TargetCPUUtilization)
+	utilization(corev1.ResourceMemory, as.TargetMemoryUtilization)
+	// queue size は custom metrics API 経由で Pod ごとの平均を見る
+	if as.TargetQueueSize != nil {
+		metrics = append(metrics, autoscalingv2.MetricSpec{
+			Type: autoscalingv2.PodsMetricSourceType,
+			Pods: &autoscalingv2.PodsMetricSource{
+				Metric: autoscalingv2.MetricIdentifier{Name: observabilityv1beta1.QueueSizeMetric},
+				Target: autoscalingv2.MetricTarget{Type: autoscalingv2.AverageValueMetricType, AverageValue: ptr.To(as.TargetQueueSize.DeepCopy())},
+			},
+		})
+	}
+
+	return &autoscalingv2.HorizontalPodAutoscaler{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      agentWorkloadName(oc),
+			Namespace: oc.Namespace,
+			Labels:    agentLabels(oc),
+		},
+		Spec: autoscalingv2.HorizontalPodAutoscalerSpec{
+			ScaleTargetRef: autoscalingv2.CrossVersionObjectReference{APIVersion: "apps/v1", Kind: kind, Name: agentWorkloadName(oc)},
+			MinReplicas:    as.MinReplicas,
+			MaxReplicas:    as.MaxReplicas,
+			Metrics:        metrics,
+		},
+	}
+}
+
+// applyAutoscaler は spec.agent.autoscaling に合わせて HPA を作成・更新し、不要なら削除する。
+func (r *ObservabilityConfigReconciler) applyAutoscaler(ctx context.Context, oc *observabilityv1beta1.ObservabilityConfig) error {
+	key := metav1.ObjectMeta{Name: agentWorkloadName(oc), Namespace: oc.Namespace}
+	if !autoscaled(oc) {
+		return r.deleteOwned(ctx, oc, &autoscalingv2.HorizontalPodAutoscaler{ObjectMeta: key})
+	}
+	want := desiredHPA(oc)
+	have := &autoscalingv2.HorizontalPodAutoscaler{ObjectMeta: key}
+	_, err := controllerutil.CreateOrPatch(ctx, r.Client, have, func() error {
+		if err := controllerutil.SetControllerReference(oc, have, r.Scheme); err != nil {
+			return err
+		}
+		have.Labels = want.Labels
+		// behavior は利用者が直接調整できるよう触らない
+		have.Spec.ScaleTargetRef = want.Spec.ScaleTargetRef
+		have.Spec.MinReplicas = want.Spec.MinReplicas
+		have.Spec.MaxReplicas = want.Spec.MaxReplicas
+		have.Spec.Metrics = want.Spec.Metrics
+		return nil
+	})
+	return err
+}
+
+// updateAutoscalingStatus は HPA の status を status.autoscaling に写し、
+// replica 数が変わったときに event を出す。
+func (r *ObservabilityConfigReconciler) updateAutoscalingStatus(ctx context.Context, oc, orig *observabilityv1beta1.ObservabilityConfig) error {
+	if !autoscaled(oc) {
+		oc.Status.Autoscaling = nil
+		return nil
+	}
+	var hpa autoscalingv2.HorizontalPodAutoscaler
+	if err := r.Get(ctx, client.ObjectKey{Name: agentWorkloadName(oc), Namespace: oc.Namespace}, &hpa); err != nil {
+		// 作成直後でキャッシュに無ければ次の reconcile で埋まる
+		oc.Status.Autoscaling = &observabilityv1beta1.AutoscalingStatus{}
+		return client.IgnoreNotFound(err)
+	}
+	st := autoscalingStatus(&hpa)
+	oc.Status.Autoscaling = st
+
+	var prev *metav1.Time
+	if orig.Status.Autoscaling != nil {
+		prev = orig.Status.Autoscaling.LastScaleTime
+	}
+	if st.LastScaleTime != nil && !st.LastScaleTime.Equal(prev) {
+		conditions.Emit(r.Recorder, oc, corev1.EventTypeNormal, conditions.ReasonScaled,
+			"agent scaled to %d replicas: %s", st.DesiredReplicas, st.LastScaleEvent)
+	}
+	return nil
+}
+
+func autoscalingStatus(hpa *autoscalingv2.HorizontalPodAutoscaler) *observabilityv1beta1.AutoscalingStatus {
+	st := &observabilityv1beta1.AutoscalingStatus{
+		CurrentReplicas: hpa.Status.CurrentReplicas,
+		DesiredReplicas: hpa.Status.DesiredReplicas,
+		LastScaleTime:   hpa.Status.LastScaleTime,
+	}
+	// AbleToScale が直近の判断 (拡縮した / できなかった理由) を持つ。
+	// 指標が取れないときは ScalingActive の方が原因を示す
+	for _, c := range hpa.Status.Conditions {
+		switch {
+		case c.Type == autoscalingv2.ScalingActive && c.Status == corev1.ConditionFalse:
+			st.LastScaleEvent = truncate(c.Message, 1024)
+			return st
+		case c.Type == autoscalingv2.AbleToScale:
+			st.LastScaleEvent = truncate(c.Message, 1024)
+		}
+	}
+	return st
+}
+
+// hpaScaleChanged は HPA の更新のうち、status.autoscaling に影響するものだけを通す。
+// 指標の現在値は HPA の同期ごとに変わるので無視する。
+var hpaScaleChanged = predicate.Funcs{
+	UpdateFunc: func(e event.UpdateEvent) bool {
+		older, ok1 := e.ObjectOld.(*autoscalingv2.HorizontalPodAutoscaler)
+		newer, ok2 := e.ObjectNew.(*autoscalingv2.HorizontalPodAutoscaler)
+		if !ok1 || !ok2 {
+			return true
+		}
+		return older.Generation != newer.Generation ||
+			!equality.Semantic.DeepEqual(autoscalingStatus(older), autoscalingStatus(newer))
+	},
+}
@@ -0,0 +1,152 @@
+package controller
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	apiv1beta1 "github.com/shtsukada/cloudnative-observability-operator/api/v1beta1"
+)
+
+func TestApplyAutoscaler(t *testing.T) {
+	oc := testObservabilityConfig()
+	oc.Spec.Agent.Autoscaling = &apiv1beta1.AutoscalingSpec{
+		MinReplicas:          ptr.To(int32(2)),
+		MaxReplicas:          5,
+		TargetCPUUtilization: ptr.To(int32(70)),
+		TargetQueueSize:      ptr.To(resource.MustParse("100")),
+	}
+	scheme := newTestScheme(t)
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(oc).Build()
+	r := &ObservabilityConfigReconciler{Client: c, Scheme: scheme}
+	ctx := context.Background()
+	key := types.NamespacedName{Namespace: "default", Name: "oc-oc-sidecar"}
+	apply := func() {
+		t.Helper()
+		if _, err := r.applyDesired(ctx, oc, mustRender(t, oc)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	apply()
+	var hpa autoscalingv2.HorizontalPodAutoscaler
+	if err := c.Get(ctx, key, &hpa); err != nil {
+		t.Fatal(err)
+	}
+	if ref := hpa.Spec.ScaleTargetRef; ref.Kind != "Deployment" || ref.Name != key.Name {
+		t.Fatalf("scaleTargetRef = %+v", ref)
+	}
+	if len(hpa.Spec.Metrics) != 2 || hpa.Spec.Metrics[1].Pods.Metric.Name != apiv1beta1.QueueSizeMetric {
+		t.Fatalf("metrics = %+v", hpa.Spec.Metrics)
+	}
+	var d appsv1.Deployment
+	if err := c.Get(ctx, key, &d); err != nil {
+		t.Fatal(err)
+	}
+	if *d.Spec.Replicas != 2 {
+		t.Fatalf("initial replicas = %d, want minReplicas", *d.Spec.Replicas)
+	}
+	// queue size を読むため collector のメトリクスを公開する
+	ports := d.Spec.Template.Spec.Containers[0].Ports
+	if !slices.ContainsFunc(ports, func(p corev1.ContainerPort) bool { return p.Name == "metrics" }) {
+		t.Fatalf("metrics port not exposed: %+v", ports)
+	}
+
+	// HPA が決めた replica 数は上書きしない
+	d.Spec.Replicas = ptr.To(int32(4))
+	if err := c.Update(ctx, &d); err != nil {
+		t.Fatal(err)
+	}
+	apply()
+	if err := c.Get(ctx, key, &d); err != nil {
+		t.Fatal(err)
+	}
+	if *d.Spec.Replicas != 4 {
+		t.Fatalf("replicas = %d, autoscaled value was overwritten", *d.Spec.Replicas)
+	}
+
+	oc.Spec.Agent.Autoscaling = nil
+	apply()
+	if err := c.Get(ctx, key, &hpa); !apierrors.IsNotFound(err) {
+		t.Fatalf("HPA not removed: %v", err)
+	}
+	if err := c.Get(ctx, key, &d); err != nil {
+		t.Fatal(err)
+	}
+	if *d.Spec.Replicas != 1 {
+		t.Fatalf("replicas = %d, want spec.agent.replicas", *d.Spec.Replicas)
+	}
+}
+
+func TestUpdateAutoscalingStatus(t *testing.T) {
+	oc := testObservabilityConfig()
+	oc.Spec.Agent.Autoscaling = &apiv1beta1.AutoscalingSpec{MaxReplicas: 5}
+	oc.ApplyDefaults()
+	scaled := metav1.NewTime(time.Now().Truncate(time.Second))
+	hpa := &autoscalingv2.HorizontalPodAutoscaler{
+		ObjectMeta: metav1.ObjectMeta{Name: "oc-oc-sidecar", Namespace: "default"},
+		Spec:       autoscalingv2.HorizontalPodAutoscalerSpec{MaxReplicas: 5},
+		Status: autoscalingv2.HorizontalPodAutoscalerStatus{
+			CurrentReplicas: 2,
+			DesiredReplicas: 3,
+			LastScaleTime:   &scaled,
+			Conditions: []autoscalingv2.HorizontalPodAutoscalerCondition{
+				{Type: autoscalingv2.AbleToScale, Status: corev1.ConditionTrue, Reason: "SucceededRescale", Message: "the HPA controller was able to update the target scale to 3"},
+				{Type: autoscalingv2.ScalingActive, Status: corev1.ConditionTrue, Reason: "ValidMetricFound"},
+			},
+		},
+	}
+	scheme := newTestScheme(t)
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(oc, hpa).Build()
+	recorder := record.NewFakeRecorder(10)
+	r := &ObservabilityConfigReconciler{Client: c, Scheme: scheme, Recorder: recorder}
+	ctx := context.Background()
+
+	orig := oc.DeepCopy()
+	if err := r.updateAutoscalingStatus(ctx, oc, orig); err != nil {
+		t.Fatal(err)
+	}
+	st := oc.Status.Autoscaling
+	if st == nil || st.CurrentReplicas != 2 || st.DesiredReplicas != 3 || !st.LastScaleTime.Equal(&scaled) ||
+		!strings.Contains(st.LastScaleEvent, "target scale to 3") {
+		t.Fatalf("status = %+v", st)
+	}
+	select {
+	case e := <-recorder.Events:
+		if !strings.Contains(e, "Scaled") || !strings.Contains(e, "3 replicas") {
+			t.Fatalf("event = %q", e)
+		}
+	default:
+		t.Fatal("no event for the scaling")
+	}
+
+	// 同じ拡縮で event を繰り返さない
+	if err := r.updateAutoscalingStatus(ctx, oc, oc.DeepCopy()); err != nil {
+		t.Fatal(err)
+	}
+	if len(recorder.Events) != 0 {
+		t.Fatalf("event repeated: %q", <-recorder.Events)
+	}
+
+	// 指標が取れないときはその理由を出す
+	hpa.Status.Conditions[1] = autoscalingv2.HorizontalPodAutoscalerCondition{
+		Type: autoscalingv2.ScalingActive, Status: corev1.ConditionFalse, Reason: "FailedGetPodsMetric",
+		Message: "unable to get metric otelcol_exporter_queue_size",
+	}
+	if got := autoscalingStatus(hpa).LastScaleEvent; got != "unable to get metric otelcol_exporter_queue_size" {
+		t.Fatalf("lastScaleEvent = %q", got)
+	}
+}
@@ -20,26 +20,37 @@ package controller
 
 import (
 	"context"
+	"fmt"
 	"sort"
 	"time"
 
 	appsv1 "k8s.io/api/apps/v1"
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
 	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	apimeta "k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/validation/field"
 	"k8s.io/client-go/tools/record"
 	"k8s.io/utils/ptr"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
 
-	observabilityv1alpha1 "github.com/shtsukada/cloudnative-observability-operator/api/v1alpha1"
+	observabilityv1beta1 "github.com/shtsukada/cloudnative-observability-operator/api/v1beta1"
+	"github.com/shtsukada/cloudnative-observability-operator/internal/agenthealth"
+	"github.com/shtsukada/cloudnative-observability-operator/internal/collector"
+	"github.com/shtsukada/cloudnative-observability-operator/internal/inherit"
+	"github.com/shtsukada/cloudnative-observability-operator/internal/reachability"
 	conditions "github.com/shtsukada/cloudnative-observability-operator/internal/shared/conditions"
 	tel "github.com/shtsukada/cloudnative-observability-operator/internal/shared/telemetry"
+	"github.com/shtsukada/cloudnative-observability-operator/internal/shared/validation"
 )
 
 // ObservabilityConfigReconciler reconciles a ObservabilityConfig object
@@ -47,40 +58,134 @@ type ObservabilityConfigReconciler struct {
 	client.Client
 	Scheme   *runtime.Scheme
 	Recorder record.EventRecorder
+
+	// DefaultAgentImage is used when spec.agent.image is empty.
+	DefaultAgentImage string
+
+	// Checker probes exporter endpoints; nil disables the EndpointReachable condition.
+	Checker *reachability.Checker
+	// CheckInterval is the requeue period for endpoint checks once in sync.
+	CheckInterval time.Duration
+
+	// Poller reads the agent pods' health check and internal metrics; nil
+	// disables status.pipeline and the ExportFailing condition.
+	Poller *agenthealth.Poller
+	// PollInterval is the requeue period for agent polls once in sync.
+	PollInterval time.Duration
+	// ExportFailureThreshold is the fraction of items failing to send between
+	// two polls above which ExportFailing is set.
+	ExportFailureThreshold float64
 }
 
 // +kubebuilder:rbac:groups=observability.shtsukada.dev,resources=observabilityconfigs,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=observability.shtsukada.dev,resources=observabilityconfigs/status,verbs=get;update;patch
 // +kubebuilder:rbac:groups=observability.shtsukada.dev,resources=observabilityconfigs/finalizers,verbs=update
+// +kubebuilder:rbac:groups=observability.shtsukada.dev,resources=clusterobservabilityconfigs,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",resources=namespaces,verbs=get;list;watch
 // +kubebuilder:rbac:groups="",resources=services;configmaps;secrets,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=apps,resources=deployments;daemonsets;statefulsets,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=autoscaling,resources=horizontalpodautoscalers,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups="",resources=serviceaccounts,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups="",resources=persistentvolumeclaims,verbs=get;list;watch;patch;delete
+// +kubebuilder:rbac:groups=rbac.authorization.k8s.io,resources=roles;rolebindings;clusterroles;clusterrolebindings,verbs=get;list;watch;create;update;patch;delete
+// 権限を委譲するには operator 自身が同じ権限を持っている必要がある (k8sattributes と scrape 用)
+// +kubebuilder:rbac:groups=apps,resources=replicasets,verbs=get;list;watch
+// +kubebuilder:rbac:groups=batch,resources=jobs,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",resources=endpoints,verbs=get;list;watch
 
 func (r *ObservabilityConfigReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
 	log := logf.FromContext(ctx)
 
-	var oc observabilityv1alpha1.ObservabilityConfig
+	var oc observabilityv1beta1.ObservabilityConfig
 	if err := r.Get(ctx, req.NamespacedName, &oc); err != nil {
 		if apierrors.IsNotFound(err) {
+			if r.Checker != nil {
+				r.Checker.Forget(req.String())
+			}
+			if r.Poller != nil {
+				r.Poller.Forget(req.String())
+			}
 			return ctrl.Result{}, nil
 		}
 		return ctrl.Result{}, err
 	}
 
+	if oc.DeletionTimestamp != nil {
+		if err := r.finalizeAgentRBAC(ctx, &oc); err != nil {
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{}, r.finalizeInstrumentation(ctx, &oc)
+	}
+	if err := r.ensureInstrumentationFinalizer(ctx, &oc); err != nil {
+		return ctrl.Result{}, err
+	}
+	if err := r.ensureAgentRBACFinalizer(ctx, &oc); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	// spec.inheritFrom の ClusterObservabilityConfig を合成してから既定値を適用する
+	inheritance, inheritErr := inherit.Resolve(ctx, r.Client, &oc)
+	// webhook を経由していないオブジェクトにも同じ既定値を適用する
+	oc.ApplyDefaults()
 	orig := oc.DeepCopy()
 
-	setProgressing(&oc, conditions.ReasonReconciling, "reconcile started")
+	if inheritErr != nil {
+		reason, ok := inheritReason(inheritErr)
+		if !ok {
+			return ctrl.Result{}, inheritErr
+		}
+		// 継承元が直れば watch で再評価される
+		setCondition(&oc, observabilityv1beta1.ConditionInherited, metav1.ConditionFalse, reason, inheritErr.Error())
+		return ctrl.Result{}, r.patchDegraded(ctx, &oc, orig, reason, fmt.Sprintf("inherit from %s: %v", oc.Spec.InheritFrom, inheritErr))
+	}
+	oc.Status.Inheritance = inheritance
+	if inheritance != nil {
+		setCondition(&oc, observabilityv1beta1.ConditionInherited, metav1.ConditionTrue, conditions.ReasonInherited,
+			"merged with ClusterObservabilityConfig "+inheritance.From)
+	} else {
+		apimeta.RemoveStatusCondition(&oc.Status.Conditions, observabilityv1beta1.ConditionInherited)
+	}
+
+	if errs := validation.ObservabilityConfigSpec(&oc.Spec, field.NewPath("spec")); len(errs) > 0 {
+		return ctrl.Result{}, r.patchDegraded(ctx, &oc, orig, conditions.ReasonErrInvalid, "invalid spec: "+errs.ToAggregate().Error())
+	}
 
-	if oc.Spec.Endpoint == "" {
-		conditions.Emit(r.Recorder, &oc, corev1.EventTypeWarning, conditions.ReasonErrInvalid, "spec.endpoint must not be empty")
-		setDegraded(&oc, conditions.ReasonErrInvalid, "spec.endpoint must not be empty")
-		oc.Status.ObservedGeneration = oc.Generation
-		if err := r.Status().Patch(ctx, &oc, client.MergeFrom(orig)); err != nil {
-			return ctrl.Result{}, err
+	cfg, err := collector.Render(&oc)
+	if err != nil {
+		return ctrl.Result{}, r.patchDegraded(ctx, &oc, orig, conditions.ReasonErrInvalid, "render collector config: "+err.Error())
+	}
+	targets, err := r.scrapeTargets(ctx, &oc)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if err := cfg.AddScrape(&oc, targets); err != nil {
+		return ctrl.Result{}, r.patchDegraded(ctx, &oc, orig, conditions.ReasonErrInvalid, "render collector config: "+err.Error())
+	}
+	// agent に配る前に検査し、通らなければ前回の ConfigMap をそのまま残す
+	if err := validateConfig(cfg); err != nil {
+		return ctrl.Result{}, r.patchDegraded(ctx, &oc, orig, conditions.ReasonInvalidConfig, "generated collector config is invalid: "+err.Error())
+	}
+	// trace を間引く collector の設定 (gateway 構成では gateway)
+	sampler := cfg
+	if gatewayEnabled(&oc) {
+		gateway, err := collector.RenderGateway(&oc)
+		if err == nil {
+			err = validateConfig(gateway)
+		}
+		if err != nil {
+			return ctrl.Result{}, r.patchDegraded(ctx, &oc, orig, conditions.ReasonInvalidConfig, "generated gateway config is invalid: "+err.Error())
 		}
-		return ctrl.Result{}, nil
+		sampler = gateway
 	}
 
-	changed, err := r.applyDesired(ctx, &oc)
+	changed, err := r.applyDesired(ctx, &oc, cfg)
+	if err == nil {
+		err = r.instrumentWorkloads(ctx, &oc)
+		// 読んだ後に workload が更新されていた。読み直してやり直す
+		if apierrors.IsConflict(err) {
+			return ctrl.Result{RequeueAfter: time.Second}, nil
+		}
+	}
 	if err != nil {
 		// ここは今のままでOK（Degradedにして返す）
 		reason := conditions.ClassifyApplyError(err)
@@ -91,37 +196,73 @@ func (r *ObservabilityConfigReconciler) Reconcile(ctx context.Context, req ctrl.
 		return ctrl.Result{RequeueAfter: 10 * time.Second}, nil
 	}
 
-	// ★ここで「適用成功」を即座に Ready=True として反映（Reason は文字列リテラルで "Reconciled"）
-	setReady(&oc, "Reconciled", "reconciled")
-	oc.Status.ObservedGeneration = oc.Generation
-	if err := r.Status().Patch(ctx, &oc, client.MergeFrom(orig)); err != nil {
+	// 実際に agent に反映した設定を status に載せる
+	oc.Status.EffectiveSamplingPercent = nil
+	if p, ok := sampler.TracesSamplingPercent(); ok {
+		oc.Status.EffectiveSamplingPercent = ptr.To(p)
+	}
+	oc.Status.ActivePipelines = cfg.PipelineNames()
+	r.checkEndpoints(ctx, &oc)
+	if err := r.pollAgents(ctx, &oc); err != nil {
 		return ctrl.Result{}, err
 	}
 
 	if changed {
-		conditions.Emit(r.Recorder, &oc, corev1.EventTypeNormal, conditions.ReasonApplySucceeded, "changes applied, waiting to settle")
-		setProgressing(&oc, conditions.ReasonWaitingForDeployment, "changes applied, waiting to settle")
-		oc.Status.ObservedGeneration = oc.Generation
-		if err := r.Status().Patch(ctx, &oc, client.MergeFrom(orig)); err != nil {
+		conditions.Emit(r.Recorder, &oc, corev1.EventTypeNormal, conditions.ReasonApplySucceeded, "changes applied, waiting for agent rollout")
+	}
+
+	// Ready は apply の成否ではなく agent のロールアウト完了で判断する
+	wasReady := apimeta.IsStatusConditionTrue(orig.Status.Conditions, observabilityv1beta1.ConditionReady)
+	rollout, err := r.agentRollout(ctx, &oc)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if gatewayEnabled(&oc) {
+		gateway, err := r.gatewayRollout(ctx, &oc)
+		if err != nil {
 			return ctrl.Result{}, err
 		}
-		return ctrl.Result{RequeueAfter: 2 * time.Second}, nil
+		rollout = setTierStatus(&oc, rollout, gateway)
+	} else {
+		apimeta.RemoveStatusCondition(&oc.Status.Conditions, observabilityv1beta1.ConditionAgentReady)
+		apimeta.RemoveStatusCondition(&oc.Status.Conditions, observabilityv1beta1.ConditionGatewayReady)
 	}
-
-	// ここに来るのは「今回の Reconcile で変更が不要」= 安定化した状態。
-	conditions.Emit(r.Recorder, &oc, corev1.EventTypeNormal, conditions.ReasonDeploymentAvailable, "resources are in sync")
-	setReady(&oc, conditions.ReasonDeploymentAvailable, "resources are in sync")
-
-	if oc.Status.ObservedGeneration != oc.Generation {
-		oc.Status.ObservedGeneration = oc.Generation
+	progressing := setRolloutStatus(&oc, rollout)
+	if err := r.updateAutoscalingStatus(ctx, &oc, orig); err != nil {
+		return ctrl.Result{}, err
 	}
-
+	if err := r.updatePersistenceStatus(ctx, &oc); err != nil {
+		return ctrl.Result{}, err
+	}
+	if err := r.updateInjection(ctx, &oc, orig); err != nil {
+		return ctrl.Result{}, err
+	}
+	oc.Status.ObservedGeneration = oc.Generation
 	if err := r.Status().Patch(ctx, &oc, client.MergeFrom(orig)); err != nil {
 		return ctrl.Result{}, err
 	}
 
+	switch {
+	case rollout.failed != "":
+		conditions.Emit(r.Recorder, &oc, corev1.EventTypeWarning, rollout.failed, "agent rollout failed: %s", rollout)
+		return ctrl.Result{RequeueAfter: 30 * time.Second}, nil
+	case progressing:
+		// CrashLoopBackOff などは workload の status を変えないことがあるので定期的に見直す
+		return ctrl.Result{RequeueAfter: 30 * time.Second}, nil
+	case !wasReady:
+		conditions.Emit(r.Recorder, &oc, corev1.EventTypeNormal, conditions.ReasonDeploymentAvailable, "agent rollout complete: %s", rollout)
+	}
+
 	log.V(1).Info("reconciled ObservabilityConfig", "name", req.NamespacedName)
-	return ctrl.Result{}, nil
+	// 送り先の疎通と agent の状態は定期的に確認し直す
+	var after time.Duration
+	if r.Checker != nil {
+		after = r.checkInterval()
+	}
+	if r.Poller != nil && (after == 0 || r.pollInterval() < after) {
+		after = r.pollInterval()
+	}
+	return ctrl.Result{RequeueAfter: after}, nil
 }
 
 // SetupWithManager sets up the controller with the Manager.
@@ -129,14 +270,48 @@ func (r *ObservabilityConfigReconciler) SetupWithManager(mgr ctrl.Manager) error
 	r.Recorder = mgr.GetEventRecorderFor("cloudnative-observability-operator")
 	wrapped := tel.WrapReconciler("ObservabilityConfig", r)
 	return ctrl.NewControllerManagedBy(mgr).
-		For(&observabilityv1alpha1.ObservabilityConfig{}).
+		For(&observabilityv1beta1.ObservabilityConfig{}).
 		Owns(&appsv1.Deployment{}).
+		Owns(&appsv1.DaemonSet{}).
+		Owns(&appsv1.StatefulSet{}).
+		Owns(&corev1.Service{}).
 		Owns(&corev1.ConfigMap{}).
+		Owns(&autoscalingv2.HorizontalPodAutoscaler{}, builder.WithPredicates(hpaScaleChanged)).
+		Owns(&corev1.ServiceAccount{}).
+		Owns(&rbacv1.Role{}).
+		Owns(&rbacv1.RoleBinding{}).
+		// spec.inject の対象になる利用者の workload
+		Watches(&appsv1.Deployment{}, handler.EnqueueRequestsFromMapFunc(r.workloadToObservabilityConfigs)).
+		Watches(&appsv1.StatefulSet{}, handler.EnqueueRequestsFromMapFunc(r.workloadToObservabilityConfigs)).
+		Watches(&appsv1.DaemonSet{}, handler.EnqueueRequestsFromMapFunc(r.workloadToObservabilityConfigs)).
+		// spec.inheritFrom の継承元と、その namespaceSelector が見る namespace
+		Watches(&observabilityv1beta1.ClusterObservabilityConfig{}, handler.EnqueueRequestsFromMapFunc(r.clusterConfigToObservabilityConfigs)).
+		Watches(&corev1.Namespace{}, handler.EnqueueRequestsFromMapFunc(r.namespaceToObservabilityConfigs)).
+		// spec.scrape の selector が選ぶ GrpcBurner
+		Watches(&observabilityv1beta1.GrpcBurner{}, handler.EnqueueRequestsFromMapFunc(r.grpcBurnerToObservabilityConfigs)).
+		// 注入結果は Pod の annotation にしか残らないので metadata だけ監視する
+		Watches(&corev1.Pod{}, handler.EnqueueRequestsFromMapFunc(podToObservabilityConfig), builder.OnlyMetadata).
 		Named("observabilityconfig").
 		Complete(wrapped)
 }
 
-func setCondition(oc *observabilityv1alpha1.ObservabilityConfig, t string, status metav1.ConditionStatus, reason, msg string) {
+// patchDegraded は spec を直すまで解消しない失敗を記録する。再試行はせず spec などの変更を待つ。
+func (r *ObservabilityConfigReconciler) patchDegraded(ctx context.Context, oc, orig *observabilityv1beta1.ObservabilityConfig, reason, msg string) error {
+	conditions.Emit(r.Recorder, oc, corev1.EventTypeWarning, reason, "%s", msg)
+	setDegraded(oc, reason, msg)
+	oc.Status.ObservedGeneration = oc.Generation
+	return r.Status().Patch(ctx, oc, client.MergeFrom(orig))
+}
+
+func validateConfig(cfg *collector.Config) error {
+	data, err := cfg.YAML()
+	if err != nil {
+		return err
+	}
+	return collector.Validate(data)
+}
+
+func setCondition(oc *observabilityv1beta1.ObservabilityConfig, t string, status metav1.ConditionStatus, reason, msg string) {
 	cond := metav1.Condition{
 		Type:               t,
 		Status:             status,
@@ -148,77 +323,36 @@ func setCondition(oc *observabilityv1alpha1.ObservabilityConfig, t string, statu
 	apimeta.SetStatusCondition(&oc.Status.Conditions, cond)
 }
 
-func setReady(oc *observabilityv1alpha1.ObservabilityConfig, reason, msg string) {
-	setCondition(oc, "Ready", metav1.ConditionTrue, reason, msg)
+func setReady(oc *observabilityv1beta1.ObservabilityConfig, reason, msg string) {
+	setCondition(oc, observabilityv1beta1.ConditionReady, metav1.ConditionTrue, reason, msg)
+	setCondition(oc, observabilityv1beta1.ConditionProgressing, metav1.ConditionFalse, "Stable", "Reconcile stable")
+	setCondition(oc, observabilityv1beta1.ConditionDegraded, metav1.ConditionFalse, reason, msg)
 	oc.Status.Phase = "Ready"
 	oc.Status.Reason = reason
 }
 
-func setProgressing(oc *observabilityv1alpha1.ObservabilityConfig, reason, msg string) {
-	setCondition(oc, "Ready", metav1.ConditionFalse, reason, msg)
+func setProgressing(oc *observabilityv1beta1.ObservabilityConfig, reason, msg string) {
+	setCondition(oc, observabilityv1beta1.ConditionReady, metav1.ConditionFalse, reason, msg)
+	setCondition(oc, observabilityv1beta1.ConditionProgressing, metav1.ConditionTrue, reason, msg)
+	setCondition(oc, observabilityv1beta1.ConditionDegraded, metav1.ConditionFalse, reason, msg)
 	oc.Status.Phase = "Reconciling"
 	oc.Status.Reason = reason
 }
 
-func setDegraded(oc *observabilityv1alpha1.ObservabilityConfig, reason, msg string) {
-	setCondition(oc, "Ready", metav1.ConditionFalse, reason, msg)
+func setDegraded(oc *observabilityv1beta1.ObservabilityConfig, reason, msg string) {
+	setCondition(oc, observabilityv1beta1.ConditionReady, metav1.ConditionFalse, reason, msg)
+	setCondition(oc, observabilityv1beta1.ConditionProgressing, metav1.ConditionFalse, reason, msg)
+	setCondition(oc, observabilityv1beta1.ConditionDegraded, metav1.ConditionTrue, reason, msg)
 	oc.Status.Phase = "Error"
 	oc.Status.Reason = reason
 }
 
-func (r *ObservabilityConfigReconciler) desiredDeployment(oc *observabilityv1alpha1.ObservabilityConfig) *appsv1.Deployment {
-	labels := map[string]string{
-		"app.kubernetes.io/name":       "oc-sidecar",
-		"app.kubernetes.io/managed-by": "cloudnative-observability-operator",
-		"obscfg":                       oc.Name,
-	}
-
-	dep := &appsv1.Deployment{
-		ObjectMeta: metav1.ObjectMeta{
-			Name:      oc.Name + "-oc-sidecar",
-			Namespace: oc.Namespace,
-			Labels:    labels,
-		},
-		Spec: appsv1.DeploymentSpec{
-			Replicas: ptr.To(int32(1)),
-			Selector: &metav1.LabelSelector{MatchLabels: labels},
-			Strategy: appsv1.DeploymentStrategy{
-				Type: appsv1.RollingUpdateDeploymentStrategyType,
-				RollingUpdate: &appsv1.RollingUpdateDeployment{
-					MaxUnavailable: ptr.To(intstr.FromString("25%")),
-					MaxSurge:       ptr.To(intstr.FromString("25%")),
-				},
-			},
-			Template: corev1.PodTemplateSpec{
-				ObjectMeta: metav1.ObjectMeta{Labels: labels},
-				Spec: corev1.PodSpec{
-					Containers: []corev1.Container{
-						{
-							Name:            "oc-agent",
-							Image:           "example/oc-agent:dummy",
-							ImagePullPolicy: corev1.PullIfNotPresent,
-							Ports: []corev1.ContainerPort{
-								{Name: "otlp-grpc", ContainerPort: 4317},
-								{Name: "otlp-http", ContainerPort: 4318},
-							},
-							Env: []corev1.EnvVar{
-								{Name: "OTEL_EXPORTER_OTLP_ENDPOINT", Value: oc.Spec.Endpoint},
-							},
-						},
-					},
-				},
-			},
-		},
-	}
-	return dep
-}
-
-func (r *ObservabilityConfigReconciler) desiredService(oc *observabilityv1alpha1.ObservabilityConfig) *corev1.Service {
+func (r *ObservabilityConfigReconciler) desiredService(oc *observabilityv1beta1.ObservabilityConfig) *corev1.Service {
 	labels := map[string]string{
 		"app.kubernetes.io/name": "oc-sidecar",
 		"obscfg":                 oc.Name,
 	}
-	return &corev1.Service{
+	svc := &corev1.Service{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      oc.Name + "-oc",
 			Namespace: oc.Namespace,
@@ -227,69 +361,89 @@ func (r *ObservabilityConfigReconciler) desiredService(oc *observabilityv1alpha1
 		Spec: corev1.ServiceSpec{
 			Selector: labels,
 			Ports: []corev1.ServicePort{
-				{Name: "otlp-grpc", Port: 4317, TargetPort: intstr.FromInt(4317)},
-				{Name: "otlp-http", Port: 4318, TargetPort: intstr.FromInt(4318)},
+				{Name: "otlp-grpc", Port: collector.OTLPGRPCPort, TargetPort: intstr.FromInt32(collector.OTLPGRPCPort)},
+				{Name: "otlp-http", Port: collector.OTLPHTTPPort, TargetPort: intstr.FromInt32(collector.OTLPHTTPPort)},
 			},
 			Type: corev1.ServiceTypeClusterIP,
 		},
 	}
+	// DaemonSet では同じノードの agent にだけ送る
+	if oc.Spec.Mode == observabilityv1beta1.AgentModeDaemonSet {
+		svc.Spec.InternalTrafficPolicy = ptr.To(corev1.ServiceInternalTrafficPolicyLocal)
+	}
+	return svc
 }
 
-func (r *ObservabilityConfigReconciler) applyDesired(ctx context.Context, oc *observabilityv1alpha1.ObservabilityConfig) (bool, error) {
-	wantDep := r.desiredDeployment(oc)
-	haveDep := &appsv1.Deployment{ObjectMeta: wantDep.ObjectMeta}
+func (r *ObservabilityConfigReconciler) applyDesired(ctx context.Context, oc *observabilityv1beta1.ObservabilityConfig, cfg *collector.Config) (bool, error) {
+	// Pod が起動する前に ServiceAccount と権限を揃えておく (gateway の Pod も同じ ServiceAccount で動く)
+	if err := r.applyAgentRBAC(ctx, oc); err != nil {
+		return false, err
+	}
+	// gateway 構成では agent の転送先を先に用意し、使えるようになるまで agent には触らない。
+	// gateway の Deployment の変化は Owns の event で reconcile し直される
+	gatewayChanged, gatewayUp, err := r.applyGateway(ctx, oc)
+	if err != nil || !gatewayUp {
+		return gatewayChanged, err
+	}
 
-	// CreateOrPatch は “変化が無ければ OperationResultNone”
-	op, err := controllerutil.CreateOrPatch(ctx, r.Client, haveDep, func() error {
-		if err := controllerutil.SetControllerReference(oc, haveDep, r.Scheme); err != nil {
-			return err
-		}
-		r.mutateDeployment(haveDep, wantDep)
-		return nil
-	})
+	wantCM, err := desiredConfigMap(oc, cfg)
+	if err != nil {
+		return false, err
+	}
+	opCM, err := r.applyConfigMap(ctx, oc, wantCM)
+	if err != nil {
+		return false, err
+	}
+	opEnv, err := r.applyConfigMap(ctx, oc, desiredEnvConfigMap(oc))
 	if err != nil {
 		return false, err
 	}
 
-	wantSvc := r.desiredService(oc)
-	haveSvc := &corev1.Service{ObjectMeta: wantSvc.ObjectMeta}
-
-	op2, err := controllerutil.CreateOrPatch(ctx, r.Client, haveSvc, func() error {
-		if err := controllerutil.SetControllerReference(oc, haveSvc, r.Scheme); err != nil {
-			return err
-		}
-
-		// ClusterIP は不変なので保持
-		clusterIP := haveSvc.Spec.ClusterIP
-		clusterIPs := haveSvc.Spec.ClusterIPs
-
-		r.mutateService(haveSvc, wantSvc)
+	op, err := r.applyWorkload(ctx, oc, cfg, configHash(wantCM))
+	if err != nil {
+		return false, err
+	}
+	if err := r.applyQueueClaims(ctx, oc); err != nil {
+		return false, err
+	}
+	// HPA の変更は Pod を入れ替えないのでロールアウト待ちの対象にしない
+	if err := r.applyAutoscaler(ctx, oc); err != nil {
+		return false, err
+	}
 
-		haveSvc.Spec.ClusterIP = clusterIP
-		if len(clusterIPs) > 0 {
-			haveSvc.Spec.ClusterIPs = clusterIPs
-		}
-		return nil
-	})
+	op2, err := r.applyService(ctx, oc, r.desiredService(oc))
 	if err != nil {
 		return false, err
 	}
+	// StatefulSet の Pod に安定した DNS 名を与える headless Service
+	op3 := controllerutil.OperationResultNone
+	if oc.Spec.Mode == observabilityv1beta1.AgentModeStatefulSet {
+		if op3, err = r.applyService(ctx, oc, desiredHeadlessService(oc)); err != nil {
+			return false, err
+		}
+	} else if err := r.deleteOwned(ctx, oc, &corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: headlessServiceName(oc), Namespace: oc.Namespace}}); err != nil {
+		return false, err
+	}
 
-	changed := (op != controllerutil.OperationResultNone) || (op2 != controllerutil.OperationResultNone)
+	changed := gatewayChanged ||
+		opCM != controllerutil.OperationResultNone ||
+		opEnv != controllerutil.OperationResultNone ||
+		op != controllerutil.OperationResultNone ||
+		op2 != controllerutil.OperationResultNone ||
+		op3 != controllerutil.OperationResultNone
 	return changed, nil
 }
 
-func (r *ObservabilityConfigReconciler) mutateDeployment(have, want *appsv1.Deployment) {
-	// ※ Deployment の Selector は不変なので “既存と同じ値”以外にはしない前提で代入
-	have.Labels = want.Labels
-	have.Spec.Selector = want.Spec.Selector
-	have.Spec.Replicas = want.Spec.Replicas
-	have.Spec.Strategy = want.Spec.Strategy
-
-	r.normPodSpec(&want.Spec.Template.Spec)
-	r.normPodSpec(&have.Spec.Template.Spec)
-
-	have.Spec.Template = want.Spec.Template
+func (r *ObservabilityConfigReconciler) applyConfigMap(ctx context.Context, oc *observabilityv1beta1.ObservabilityConfig, want *corev1.ConfigMap) (controllerutil.OperationResult, error) {
+	have := &corev1.ConfigMap{ObjectMeta: want.ObjectMeta}
+	return controllerutil.CreateOrPatch(ctx, r.Client, have, func() error {
+		if err := controllerutil.SetControllerReference(oc, have, r.Scheme); err != nil {
+			return err
+		}
+		have.Labels = want.Labels
+		have.Data = want.Data
+		return nil
+	})
 }
 
 func (r *ObservabilityConfigReconciler) mutateService(have, want *corev1.Service) {
@@ -301,6 +455,32 @@ func (r *ObservabilityConfigReconciler) mutateService(have, want *corev1.Service
 	have.Spec.Ports = want.Spec.Ports
 
 	have.Spec.Type = want.Spec.Type
+	have.Spec.InternalTrafficPolicy = want.Spec.InternalTrafficPolicy
+}
+
+func (r *ObservabilityConfigReconciler) applyService(ctx context.Context, oc *observabilityv1beta1.ObservabilityConfig, want *corev1.Service) (controllerutil.OperationResult, error) {
+	have := &corev1.Service{ObjectMeta: want.ObjectMeta}
+	return controllerutil.CreateOrPatch(ctx, r.Client, have, func() error {
+		if err := controllerutil.SetControllerReference(oc, have, r.Scheme); err != nil {
+			return err
+		}
+
+		// ClusterIP は不変なので割り当て済みなら保持
+		clusterIP := have.Spec.ClusterIP
+		clusterIPs := have.Spec.ClusterIPs
+
+		r.mutateService(have, want)
+
+		if clusterIP != "" {
+			have.Spec.ClusterIP = clusterIP
+		} else {
+			have.Spec.ClusterIP = want.Spec.ClusterIP
+		}
+		if len(clusterIPs) > 0 {
+			have.Spec.ClusterIPs = clusterIPs
+		}
+		return nil
+	})
 }
 
 func (r *ObservabilityConfigReconciler) normPodSpec(p *corev1.PodSpec) {
@@ -22,6 +22,7 @@ import (
 
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
+	appsv1 "k8s.io/api/apps/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/tools/record"
@@ -30,7 +31,7 @@ import (
 	apimeta "k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
-	observabilityv1alpha1 "github.com/shtsukada/cloudnative-observability-operator/api/v1alpha1"
+	observabilityv1beta1 "github.com/shtsukada/cloudnative-observability-operator/api/v1beta1"
 	conditions "github.com/shtsukada/cloudnative-observability-operator/internal/shared/conditions"
 )
 
@@ -45,19 +46,19 @@ var _ = Describe("ObservabilityConfig Controller", func() {
 			Name:      resourceName,
 			Namespace: namespace, // TODO(user):Modify as needed
 		}
-		observabilityconfig := &observabilityv1alpha1.ObservabilityConfig{}
+		observabilityconfig := &observabilityv1beta1.ObservabilityConfig{}
 
 		BeforeEach(func() {
 			By("creating the custom resource for the Kind ObservabilityConfig")
 			err := k8sClient.Get(ctx, typeNamespacedName, observabilityconfig)
 			if err != nil && errors.IsNotFound(err) {
-				resource := &observabilityv1alpha1.ObservabilityConfig{
+				resource := &observabilityv1beta1.ObservabilityConfig{
 					ObjectMeta: metav1.ObjectMeta{
 						Name:      resourceName,
 						Namespace: namespace,
 					},
-					Spec: observabilityv1alpha1.ObservabilityConfigSpec{
-						Endpoint: "otel-collector.monitoring:4317",
+					Spec: observabilityv1beta1.ObservabilityConfigSpec{
+						Endpoints: []observabilityv1beta1.Endpoint{"otel-collector.monitoring:4317"},
 					},
 				}
 				Expect(k8sClient.Create(ctx, resource)).To(Succeed())
@@ -66,7 +67,7 @@ var _ = Describe("ObservabilityConfig Controller", func() {
 
 		AfterEach(func() {
 			By("cleanup the specific resource instance observabilityConfig")
-			resource := &observabilityv1alpha1.ObservabilityConfig{}
+			resource := &observabilityv1beta1.ObservabilityConfig{}
 			Expect(k8sClient.Get(ctx, typeNamespacedName, resource)).To(Succeed())
 			Expect(k8sClient.Delete(ctx, resource)).To(Succeed())
 		})
@@ -82,20 +83,33 @@ var _ = Describe("ObservabilityConfig Controller", func() {
 			})
 			Expect(err).NotTo(HaveOccurred())
 
-			oc := &observabilityv1alpha1.ObservabilityConfig{}
+			oc := &observabilityv1beta1.ObservabilityConfig{}
 			Expect(k8sClient.Get(ctx, typeNamespacedName, oc)).To(Succeed())
 
 			cond1 := apimeta.FindStatusCondition(oc.Status.Conditions, "Ready")
 			Expect(cond1).NotTo(BeNil())
 			Expect(cond1.Status).To(Equal(metav1.ConditionFalse))
-			Expect(oc.Status.Phase).To(Or(Equal("Reconciling"), Equal("Error"), Equal("Ready")))
+			Expect(oc.Status.Phase).To(Equal("Reconciling"))
 			Expect(oc.Status.ObservedGeneration).To(Equal(oc.Generation))
 
+			// envtest には Deployment controller が無いので、ロールアウト完了を status で模擬する
+			By("marking the agent Deployment as rolled out")
+			deploy := &appsv1.Deployment{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: resourceName + "-oc-sidecar", Namespace: namespace}, deploy)).To(Succeed())
+			deploy.Status = appsv1.DeploymentStatus{
+				ObservedGeneration: deploy.Generation,
+				Replicas:           1,
+				UpdatedReplicas:    1,
+				ReadyReplicas:      1,
+				AvailableReplicas:  1,
+			}
+			Expect(k8sClient.Status().Update(ctx, deploy)).To(Succeed())
+
 			Eventually(func(g Gomega) {
 				_, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
 				g.Expect(err).NotTo(HaveOccurred())
 
-				curr := &observabilityv1alpha1.ObservabilityConfig{}
+				curr := &observabilityv1beta1.ObservabilityConfig{}
 				g.Expect(k8sClient.Get(ctx, typeNamespacedName, curr)).To(Succeed())
 
 				cond := apimeta.FindStatusCondition(curr.Status.Conditions, "Ready")
@@ -103,12 +117,8 @@ var _ = Describe("ObservabilityConfig Controller", func() {
 				g.Expect(cond.Status).To(Equal(metav1.ConditionTrue))
 				g.Expect(curr.Status.ObservedGeneration).To(Equal(curr.Generation))
 				g.Expect(curr.Status.Phase).To(Equal("Ready"))
-
-				// Reason は共有語彙（Reconciled だけでなく DeploymentAvailable も許容）
-				g.Expect(curr.Status.Reason).To(Or(
-					Equal("Reconciled"),
-					Equal(conditions.ReasonDeploymentAvailable),
-				))
+				g.Expect(curr.Status.ReadyReplicas).To(Equal(int32(1)))
+				g.Expect(curr.Status.Reason).To(Equal(conditions.ReasonDeploymentAvailable))
 			}, 5*time.Second, 200*time.Millisecond).Should(Succeed())
 		})
 	})
@@ -0,0 +1,198 @@
+package controller
+
+import (
+	"context"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	observabilityv1beta1 "github.com/shtsukada/cloudnative-observability-operator/api/v1beta1"
+	"github.com/shtsukada/cloudnative-observability-operator/internal/collector"
+	conditions "github.com/shtsukada/cloudnative-observability-operator/internal/shared/conditions"
+)
+
+// gatewayContainerName は gateway の Pod のコンテナ名。
+const gatewayContainerName = "oc-gateway"
+
+// agent の Service が gateway の Pod を選ばないよう name を分ける
+func gatewayLabels(oc *observabilityv1beta1.ObservabilityConfig) map[string]string {
+	return map[string]string{
+		"app.kubernetes.io/name":       "oc-gateway",
+		"app.kubernetes.io/managed-by": "cloudnative-observability-operator",
+		"obscfg":                       oc.Name,
+	}
+}
+
+func gatewayEnabled(oc *observabilityv1beta1.ObservabilityConfig) bool {
+	return oc.Spec.Topology == observabilityv1beta1.TopologyGateway
+}
+
+func (r *ObservabilityConfigReconciler) desiredGateway(oc *observabilityv1beta1.ObservabilityConfig, cfg *collector.Config, cfgHash string) *appsv1.Deployment {
+	labels := gatewayLabels(oc)
+	gw := oc.Spec.Gateway
+	if gw == nil {
+		gw = &observabilityv1beta1.GatewaySpec{}
+	}
+	// イメージと追加の引数は agent と揃え、資源だけ gateway 用にする
+	agent := *agentSpec(oc)
+	agent.Resources = gw.Resources
+	container := collector.AgentContainer(gatewayContainerName, collector.AgentImage(&agent, r.defaultAgentImage()), &agent, cfg)
+	return &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      collector.GatewayName(oc),
+			Namespace: oc.Namespace,
+			Labels:    labels,
+		},
+		Spec: appsv1.DeploymentSpec{
+			Replicas: ptr.To(ptr.Deref(gw.Replicas, observabilityv1beta1.DefaultGatewayReplicas)),
+			Selector: &metav1.LabelSelector{MatchLabels: labels},
+			Strategy: appsv1.DeploymentStrategy{
+				Type: appsv1.RollingUpdateDeploymentStrategyType,
+				// trace の振り分け先が一度に多く変わらないよう 1 台ずつ入れ替える
+				RollingUpdate: &appsv1.RollingUpdateDeployment{
+					MaxUnavailable: ptr.To(intstr.FromInt32(0)),
+					MaxSurge:       ptr.To(intstr.FromInt32(1)),
+				},
+			},
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels:      labels,
+					Annotations: map[string]string{configHashAnnotation: cfgHash},
+				},
+				Spec: corev1.PodSpec{
+					ServiceAccountName: agentServiceAccountName(oc),
+					Containers:         []corev1.Container{container},
+					Volumes:            collector.GatewayVolumes(oc, cfg),
+				},
+			},
+		},
+	}
+}
+
+// desiredGatewayServices は agent が metrics と logs を送る Service と、
+// loadbalancing exporter が gateway の Pod を列挙する headless Service を返す。
+func desiredGatewayServices(oc *observabilityv1beta1.ObservabilityConfig) []*corev1.Service {
+	labels := gatewayLabels(oc)
+	ports := []corev1.ServicePort{
+		{Name: "otlp-grpc", Port: collector.OTLPGRPCPort, TargetPort: intstr.FromInt32(collector.OTLPGRPCPort)},
+		{Name: "otlp-http", Port: collector.OTLPHTTPPort, TargetPort: intstr.FromInt32(collector.OTLPHTTPPort)},
+	}
+	svc := func(name, clusterIP string) *corev1.Service {
+		return &corev1.Service{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: oc.Namespace, Labels: labels},
+			Spec: corev1.ServiceSpec{
+				ClusterIP: clusterIP,
+				Selector:  labels,
+				Ports:     ports,
+				Type:      corev1.ServiceTypeClusterIP,
+			},
+		}
+	}
+	return []*corev1.Service{svc(collector.GatewayName(oc), ""), svc(collector.GatewayHeadlessServiceName(oc), corev1.ClusterIPNone)}
+}
+
+// applyGateway は topology が gateway のとき gateway の ConfigMap、Service、Deployment を適用する。
+// gateway でなければ片付ける。agent を更新してよい (gateway が使える) かを返す。
+func (r *ObservabilityConfigReconciler) applyGateway(ctx context.Context, oc *observabilityv1beta1.ObservabilityConfig) (bool, bool, error) {
+	key := metav1.ObjectMeta{Name: collector.GatewayName(oc), Namespace: oc.Namespace}
+	deploy := &appsv1.Deployment{ObjectMeta: key}
+	if !gatewayEnabled(oc) {
+		for _, obj := range []client.Object{
+			deploy,
+			&corev1.Service{ObjectMeta: key},
+			&corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: collector.GatewayHeadlessServiceName(oc), Namespace: oc.Namespace}},
+			&corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: collector.GatewayConfigMapName(oc), Namespace: oc.Namespace}},
+		} {
+			if err := r.deleteOwned(ctx, oc, obj); err != nil {
+				return false, false, err
+			}
+		}
+		return false, true, nil
+	}
+
+	cfg, err := collector.RenderGateway(oc)
+	if err != nil {
+		return false, false, err
+	}
+	wantCM, err := desiredConfigMap(oc, cfg)
+	if err != nil {
+		return false, false, err
+	}
+	wantCM.Name = collector.GatewayConfigMapName(oc)
+	delete(wantCM.Data, collector.SidecarConfigKey)
+	wantCM.Labels = gatewayLabels(oc)
+	opCM, err := r.applyConfigMap(ctx, oc, wantCM)
+	if err != nil {
+		return false, false, err
+	}
+	changed := opCM != controllerutil.OperationResultNone
+	for _, svc := range desiredGatewayServices(oc) {
+		op, err := r.applyService(ctx, oc, svc)
+		if err != nil {
+			return false, false, err
+		}
+		changed = changed || op != controllerutil.OperationResultNone
+	}
+
+	want := r.desiredGateway(oc, cfg, configHash(wantCM))
+	op, err := controllerutil.CreateOrPatch(ctx, r.Client, deploy, func() error {
+		if err := controllerutil.SetControllerReference(oc, deploy, r.Scheme); err != nil {
+			return err
+		}
+		deploy.Labels = want.Labels
+		deploy.Spec.Selector = want.Spec.Selector
+		deploy.Spec.Replicas = want.Spec.Replicas
+		deploy.Spec.Strategy = want.Spec.Strategy
+		r.setTemplate(&deploy.Spec.Template, &want.Spec.Template)
+		return nil
+	})
+	if err != nil {
+		return false, false, err
+	}
+	changed = changed || op != controllerutil.OperationResultNone
+	// 転送先が 1 台も無いうちに agent を切り替えるとデータを落とす
+	return changed, deploy.Status.AvailableReplicas > 0, nil
+}
+
+// gatewayRollout は gateway の Deployment のロールアウトの状態を返す。
+func (r *ObservabilityConfigReconciler) gatewayRollout(ctx context.Context, oc *observabilityv1beta1.ObservabilityConfig) (agentRollout, error) {
+	var d appsv1.Deployment
+	if err := r.Get(ctx, client.ObjectKey{Name: collector.GatewayName(oc), Namespace: oc.Namespace}, &d); err != nil {
+		return agentRollout{}, client.IgnoreNotFound(err)
+	}
+	return deploymentRollout(&d), nil
+}
+
+// setTierStatus は gateway 構成の tier ごとの condition を書き、
+// Ready の判断に使う両 tier をまとめたロールアウトの状態を返す。
+func setTierStatus(oc *observabilityv1beta1.ObservabilityConfig, agent, gateway agentRollout) agentRollout {
+	tier := func(t string, a agentRollout) {
+		switch {
+		case a.complete:
+			setCondition(oc, t, metav1.ConditionTrue, conditions.ReasonDeploymentAvailable, a.String())
+		case a.failed != "":
+			setCondition(oc, t, metav1.ConditionFalse, a.failed, a.String())
+		default:
+			setCondition(oc, t, metav1.ConditionFalse, conditions.ReasonWaitingForDeployment, a.String())
+		}
+	}
+	tier(observabilityv1beta1.ConditionGatewayReady, gateway)
+	if !agent.complete && agent.failed == "" && gateway.available == 0 {
+		setCondition(oc, observabilityv1beta1.ConditionAgentReady, metav1.ConditionFalse, conditions.ReasonWaitingForGateway,
+			"waiting for an available gateway: "+gateway.String())
+	} else {
+		tier(observabilityv1beta1.ConditionAgentReady, agent)
+	}
+
+	combined := agent
+	combined.complete = agent.complete && gateway.complete
+	if gateway.failed != "" {
+		combined.failed = gateway.failed
+	}
+	return combined
+}
@@ -0,0 +1,116 @@
+package controller
+
+import (
+	"context"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	apiv1beta1 "github.com/shtsukada/cloudnative-observability-operator/api/v1beta1"
+	"github.com/shtsukada/cloudnative-observability-operator/internal/collector"
+	conditions "github.com/shtsukada/cloudnative-observability-operator/internal/shared/conditions"
+)
+
+func TestApplyGateway(t *testing.T) {
+	oc := testObservabilityConfig()
+	oc.Spec.Mode = apiv1beta1.AgentModeDaemonSet
+	oc.Spec.Topology = apiv1beta1.TopologyGateway
+	oc.Spec.KubernetesAttributes = &apiv1beta1.KubernetesAttributesSpec{}
+	oc.ApplyDefaults()
+	scheme := newTestScheme(t)
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(oc).Build()
+	r := &ObservabilityConfigReconciler{Client: c, Scheme: scheme}
+	ctx := context.Background()
+	gatewayKey := types.NamespacedName{Namespace: "default", Name: "oc-oc-gateway"}
+	agentKey := types.NamespacedName{Namespace: "default", Name: "oc-oc-sidecar"}
+	exists := func(obj client.Object, key types.NamespacedName) bool {
+		t.Helper()
+		err := c.Get(ctx, key, obj)
+		if err != nil && !apierrors.IsNotFound(err) {
+			t.Fatal(err)
+		}
+		return err == nil
+	}
+	apply := func() {
+		t.Helper()
+		if _, err := r.applyDesired(ctx, oc, mustRender(t, oc)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// gateway が使えるまで agent は作らない
+	apply()
+	var gw appsv1.Deployment
+	if !exists(&gw, gatewayKey) || *gw.Spec.Replicas != apiv1beta1.DefaultGatewayReplicas {
+		t.Fatalf("gateway Deployment not created: %+v", gw.Spec)
+	}
+	if v := gw.Spec.Template.Spec.Volumes[0]; v.ConfigMap == nil || v.ConfigMap.Name != "oc-oc-gateway-config" {
+		t.Fatalf("gateway reads the wrong config: %+v", v)
+	}
+	if !exists(&corev1.ConfigMap{}, types.NamespacedName{Namespace: "default", Name: "oc-oc-gateway-config"}) {
+		t.Fatal("gateway ConfigMap not created")
+	}
+	var headless corev1.Service
+	if !exists(&corev1.Service{}, gatewayKey) || !exists(&headless, types.NamespacedName{Namespace: "default", Name: "oc-oc-gateway-headless"}) ||
+		headless.Spec.ClusterIP != corev1.ClusterIPNone {
+		t.Fatalf("gateway Services not created: %+v", headless.Spec)
+	}
+	if exists(&appsv1.DaemonSet{}, agentKey) {
+		t.Fatal("agents created before the gateway is available")
+	}
+	// gateway の Pod が使う ServiceAccount は gateway より先に作る
+	if sa := gw.Spec.Template.Spec.ServiceAccountName; sa != "oc-oc-agent" ||
+		!exists(&corev1.ServiceAccount{}, types.NamespacedName{Namespace: "default", Name: sa}) {
+		t.Fatalf("gateway ServiceAccount %q not created", sa)
+	}
+
+	gw.Status.AvailableReplicas = 1
+	if err := c.Status().Update(ctx, &gw); err != nil {
+		t.Fatal(err)
+	}
+	apply()
+	if !exists(&appsv1.DaemonSet{}, agentKey) {
+		t.Fatal("agents not created once the gateway is available")
+	}
+
+	// 1 階層に戻すと gateway は片付ける
+	oc.Spec.Topology = apiv1beta1.TopologyAgent
+	apply()
+	if exists(&appsv1.Deployment{}, gatewayKey) || exists(&corev1.Service{}, gatewayKey) ||
+		exists(&corev1.ConfigMap{}, types.NamespacedName{Namespace: "default", Name: collector.GatewayConfigMapName(oc)}) {
+		t.Fatal("gateway resources not removed")
+	}
+}
+
+func TestSetTierStatus(t *testing.T) {
+	oc := testObservabilityConfig()
+	combined := setTierStatus(oc, agentRollout{}, agentRollout{desired: 2})
+	if c := apimeta.FindStatusCondition(oc.Status.Conditions, apiv1beta1.ConditionAgentReady); c == nil || c.Reason != conditions.ReasonWaitingForGateway {
+		t.Fatalf("AgentReady = %+v", c)
+	}
+	if combined.complete {
+		t.Fatal("rollout complete without either tier")
+	}
+
+	agent := agentRollout{desired: 3, ready: 3, available: 3, complete: true}
+	gateway := agentRollout{desired: 2, ready: 1, available: 1}
+	if combined = setTierStatus(oc, agent, gateway); combined.complete {
+		t.Fatal("rollout complete while the gateway is rolling out")
+	}
+	if !apimeta.IsStatusConditionTrue(oc.Status.Conditions, apiv1beta1.ConditionAgentReady) ||
+		apimeta.IsStatusConditionTrue(oc.Status.Conditions, apiv1beta1.ConditionGatewayReady) {
+		t.Fatalf("conditions = %+v", oc.Status.Conditions)
+	}
+
+	gateway.ready, gateway.available, gateway.complete = 2, 2, true
+	if combined = setTierStatus(oc, agent, gateway); !combined.complete ||
+		!apimeta.IsStatusConditionTrue(oc.Status.Conditions, apiv1beta1.ConditionGatewayReady) {
+		t.Fatalf("conditions = %+v", oc.Status.Conditions)
+	}
+}
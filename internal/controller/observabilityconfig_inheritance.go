@@ -0,0 +1,59 @@
+package controller
+
+import (
+	"context"
+	"errors"
+
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	observabilityv1beta1 "github.com/shtsukada/cloudnative-observability-operator/api/v1beta1"
+	"github.com/shtsukada/cloudnative-observability-operator/internal/inherit"
+	conditions "github.com/shtsukada/cloudnative-observability-operator/internal/shared/conditions"
+)
+
+// inheritReason は利用者が直すまで解消しない継承の失敗なら reason を返す。
+func inheritReason(err error) (string, bool) {
+	switch {
+	case errors.Is(err, inherit.ErrSourceNotFound):
+		return conditions.ReasonInheritSourceNotFound, true
+	case errors.Is(err, inherit.ErrNamespaceNotSelected):
+		return conditions.ReasonNamespaceNotSelected, true
+	case errors.Is(err, inherit.ErrInvalidSelector):
+		return conditions.ReasonErrInvalid, true
+	}
+	return "", false
+}
+
+// clusterConfigToObservabilityConfigs は ClusterObservabilityConfig の変化を継承している ObservabilityConfig に伝える。
+func (r *ObservabilityConfigReconciler) clusterConfigToObservabilityConfigs(ctx context.Context, obj client.Object) []reconcile.Request {
+	var list observabilityv1beta1.ObservabilityConfigList
+	if err := r.List(ctx, &list); err != nil {
+		return nil
+	}
+	return inheritingRequests(list.Items, func(oc *observabilityv1beta1.ObservabilityConfig) bool {
+		return oc.Spec.InheritFrom == obj.GetName()
+	})
+}
+
+// namespaceToObservabilityConfigs は namespace の label の変化で継承の可否が変わるものを再評価する。
+func (r *ObservabilityConfigReconciler) namespaceToObservabilityConfigs(ctx context.Context, obj client.Object) []reconcile.Request {
+	var list observabilityv1beta1.ObservabilityConfigList
+	if err := r.List(ctx, &list, client.InNamespace(obj.GetName())); err != nil {
+		return nil
+	}
+	return inheritingRequests(list.Items, func(oc *observabilityv1beta1.ObservabilityConfig) bool {
+		return oc.Spec.InheritFrom != ""
+	})
+}
+
+func inheritingRequests(items []observabilityv1beta1.ObservabilityConfig, match func(*observabilityv1beta1.ObservabilityConfig) bool) []reconcile.Request {
+	var reqs []reconcile.Request
+	for i := range items {
+		if match(&items[i]) {
+			reqs = append(reqs, reconcile.Request{NamespacedName: types.NamespacedName{Namespace: items[i].Namespace, Name: items[i].Name}})
+		}
+	}
+	return reqs
+}
@@ -0,0 +1,83 @@
+package controller
+
+import (
+	"context"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	apiv1beta1 "github.com/shtsukada/cloudnative-observability-operator/api/v1beta1"
+	conditions "github.com/shtsukada/cloudnative-observability-operator/internal/shared/conditions"
+)
+
+func TestReconcileInheritsClusterConfig(t *testing.T) {
+	coc := &apiv1beta1.ClusterObservabilityConfig{
+		ObjectMeta: metav1.ObjectMeta{Name: "platform"},
+		Spec: apiv1beta1.ClusterObservabilityConfigSpec{
+			NamespaceSelector: metav1.LabelSelector{MatchLabels: map[string]string{"tier": "standard"}},
+			InheritableSpec: apiv1beta1.InheritableSpec{
+				Endpoints:       []apiv1beta1.Endpoint{"otel-collector.monitoring:4317"},
+				SamplingPercent: ptr.To(int32(5)),
+			},
+		},
+	}
+	ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "default"}}
+	oc := &apiv1beta1.ObservabilityConfig{
+		ObjectMeta: metav1.ObjectMeta{Name: "oc", Namespace: "default"},
+		Spec:       apiv1beta1.ObservabilityConfigSpec{InheritFrom: "platform", SamplingPercent: ptr.To(int32(50))},
+	}
+	scheme := newTestScheme(t)
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(coc, ns, oc).WithStatusSubresource(oc).Build()
+	r := &ObservabilityConfigReconciler{Client: c, Scheme: scheme, Recorder: record.NewFakeRecorder(32)}
+	ctx := context.Background()
+	req := reconcile.Request{NamespacedName: types.NamespacedName{Namespace: "default", Name: "oc"}}
+
+	// namespace が選ばれていなければ Degraded
+	if _, err := r.Reconcile(ctx, req); err != nil {
+		t.Fatal(err)
+	}
+	var got apiv1beta1.ObservabilityConfig
+	if err := c.Get(ctx, req.NamespacedName, &got); err != nil {
+		t.Fatal(err)
+	}
+	cond := apimeta.FindStatusCondition(got.Status.Conditions, apiv1beta1.ConditionInherited)
+	if cond == nil || cond.Status != metav1.ConditionFalse || cond.Reason != conditions.ReasonNamespaceNotSelected {
+		t.Fatalf("inherited condition = %+v", cond)
+	}
+	if !apimeta.IsStatusConditionTrue(got.Status.Conditions, apiv1beta1.ConditionDegraded) {
+		t.Fatalf("conditions = %+v", got.Status.Conditions)
+	}
+
+	ns.Labels = map[string]string{"tier": "standard"}
+	if err := c.Update(ctx, ns); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Reconcile(ctx, req); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Get(ctx, req.NamespacedName, &got); err != nil {
+		t.Fatal(err)
+	}
+	if !apimeta.IsStatusConditionTrue(got.Status.Conditions, apiv1beta1.ConditionInherited) {
+		t.Fatalf("conditions = %+v", got.Status.Conditions)
+	}
+	in := got.Status.Inheritance
+	if in == nil || in.From != "platform" || len(in.Effective.Endpoints) != 1 {
+		t.Fatalf("inheritance = %+v", in)
+	}
+	// namespace 側の samplingPercent が優先される
+	if *got.Status.EffectiveSamplingPercent != 50 || *in.Effective.SamplingPercent != 50 {
+		t.Fatalf("sampling = %d", *got.Status.EffectiveSamplingPercent)
+	}
+	// spec 自体には継承した値を書き戻さない
+	if len(got.Spec.Endpoints) != 0 {
+		t.Fatalf("spec.endpoints = %v", got.Spec.Endpoints)
+	}
+}
@@ -0,0 +1,89 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	corev1 "k8s.io/api/core/v1"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	observabilityv1beta1 "github.com/shtsukada/cloudnative-observability-operator/api/v1beta1"
+	"github.com/shtsukada/cloudnative-observability-operator/internal/inject"
+	conditions "github.com/shtsukada/cloudnative-observability-operator/internal/shared/conditions"
+)
+
+// status に載せる注入失敗の上限 (CRD の MaxItems と揃える)
+const maxInjectionFailures = 10
+
+// podToObservabilityConfig は注入を求める Pod の変化を参照先の ObservabilityConfig に伝える。
+func podToObservabilityConfig(_ context.Context, obj client.Object) []reconcile.Request {
+	name := obj.GetAnnotations()[inject.Annotation]
+	if name == "" {
+		return nil
+	}
+	return []reconcile.Request{{NamespacedName: types.NamespacedName{Namespace: obj.GetNamespace(), Name: name}}}
+}
+
+// updateInjection は webhook が Pod に残した結果を集計して status.injection に反映する。
+// 新しく失敗した Pod には Warning イベントを出す。
+func (r *ObservabilityConfigReconciler) updateInjection(ctx context.Context, oc, orig *observabilityv1beta1.ObservabilityConfig) error {
+	pods := &metav1.PartialObjectMetadataList{}
+	pods.SetGroupVersionKind(corev1.SchemeGroupVersion.WithKind("PodList"))
+	if err := r.List(ctx, pods, client.InNamespace(oc.Namespace)); err != nil {
+		return err
+	}
+
+	status := &observabilityv1beta1.InjectionStatus{}
+	var failed []*metav1.PartialObjectMetadata
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		if pod.Annotations[inject.Annotation] != oc.Name || pod.DeletionTimestamp != nil {
+			continue
+		}
+		switch pod.Annotations[inject.StatusAnnotation] {
+		case inject.StatusInjected:
+			status.InjectedPods++
+		case inject.StatusFailed:
+			failed = append(failed, pod)
+		}
+	}
+	if status.InjectedPods == 0 && len(failed) == 0 {
+		oc.Status.Injection = nil
+		apimeta.RemoveStatusCondition(&oc.Status.Conditions, observabilityv1beta1.ConditionSidecarInjected)
+		return nil
+	}
+
+	seen := map[string]bool{}
+	if orig.Status.Injection != nil {
+		for _, f := range orig.Status.Injection.Failures {
+			seen[f.Pod] = true
+		}
+	}
+	sort.Slice(failed, func(i, j int) bool { return failed[i].Name < failed[j].Name })
+	for _, pod := range failed {
+		msg := pod.Annotations[inject.ErrorAnnotation]
+		if !seen[pod.Name] && r.Recorder != nil {
+			pod.SetGroupVersionKind(corev1.SchemeGroupVersion.WithKind("Pod"))
+			r.Recorder.Event(pod, corev1.EventTypeWarning, conditions.ReasonInjectionFailed, "sidecar injection from "+oc.Name+" failed: "+msg)
+			conditions.Emit(r.Recorder, oc, corev1.EventTypeWarning, conditions.ReasonInjectionFailed, "sidecar injection into pod %s failed: %s", pod.Name, msg)
+		}
+		if len(status.Failures) < maxInjectionFailures {
+			status.Failures = append(status.Failures, observabilityv1beta1.InjectionFailure{Pod: pod.Name, Error: truncate(msg, 1024)})
+		}
+	}
+	oc.Status.Injection = status
+
+	if len(failed) > 0 {
+		setCondition(oc, observabilityv1beta1.ConditionSidecarInjected, metav1.ConditionFalse, conditions.ReasonInjectionFailed,
+			fmt.Sprintf("%d pods failed injection, %d injected", len(failed), status.InjectedPods))
+		return nil
+	}
+	setCondition(oc, observabilityv1beta1.ConditionSidecarInjected, metav1.ConditionTrue, conditions.ReasonInjected,
+		fmt.Sprintf("%d pods injected", status.InjectedPods))
+	return nil
+}
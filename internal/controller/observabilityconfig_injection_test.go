@@ -0,0 +1,78 @@
+package controller
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	apiv1beta1 "github.com/shtsukada/cloudnative-observability-operator/api/v1beta1"
+	"github.com/shtsukada/cloudnative-observability-operator/internal/inject"
+)
+
+func TestUpdateInjection(t *testing.T) {
+	pod := func(name, ocName, status, msg string) client.Object {
+		annotations := map[string]string{inject.Annotation: ocName, inject.StatusAnnotation: status}
+		if msg != "" {
+			annotations[inject.ErrorAnnotation] = msg
+		}
+		return &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default", Annotations: annotations}}
+	}
+	oc := testObservabilityConfig()
+	scheme := newTestScheme(t)
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+		pod("a", "oc", inject.StatusInjected, ""),
+		pod("b", "oc", inject.StatusInjected, ""),
+		pod("c", "oc", inject.StatusFailed, "pods with hostNetwork cannot receive a sidecar agent"),
+		pod("other", "other", inject.StatusFailed, "boom"),
+	).Build()
+	recorder := record.NewFakeRecorder(10)
+	r := &ObservabilityConfigReconciler{Client: c, Scheme: scheme, Recorder: recorder}
+	ctx := context.Background()
+
+	if err := r.updateInjection(ctx, oc, oc.DeepCopy()); err != nil {
+		t.Fatal(err)
+	}
+	st := oc.Status.Injection
+	if st == nil || st.InjectedPods != 2 || len(st.Failures) != 1 || st.Failures[0].Pod != "c" {
+		t.Fatalf("injection = %+v", st)
+	}
+	cond := apimeta.FindStatusCondition(oc.Status.Conditions, apiv1beta1.ConditionSidecarInjected)
+	if cond == nil || cond.Status != metav1.ConditionFalse || cond.Message != "1 pods failed injection, 2 injected" {
+		t.Fatalf("condition = %+v", cond)
+	}
+	// Pod と ObservabilityConfig の両方にイベントを出す
+	if len(recorder.Events) != 2 {
+		t.Fatalf("events = %d, want 2", len(recorder.Events))
+	}
+	for range 2 {
+		if e := <-recorder.Events; !strings.HasPrefix(e, "Warning InjectionFailed") {
+			t.Fatalf("event = %s", e)
+		}
+	}
+
+	// 既に報告済みの失敗ではイベントを繰り返さない
+	if err := r.updateInjection(ctx, oc, oc.DeepCopy()); err != nil {
+		t.Fatal(err)
+	}
+	if len(recorder.Events) != 0 {
+		t.Fatalf("duplicate events: %d", len(recorder.Events))
+	}
+
+	// 参照する Pod が無くなれば status から外す
+	if err := c.DeleteAllOf(ctx, &corev1.Pod{}, client.InNamespace("default")); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.updateInjection(ctx, oc, oc.DeepCopy()); err != nil {
+		t.Fatal(err)
+	}
+	if oc.Status.Injection != nil || apimeta.FindStatusCondition(oc.Status.Conditions, apiv1beta1.ConditionSidecarInjected) != nil {
+		t.Fatalf("status = %+v", oc.Status)
+	}
+}
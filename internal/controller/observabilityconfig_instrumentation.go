@@ -0,0 +1,277 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	klabels "k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	observabilityv1beta1 "github.com/shtsukada/cloudnative-observability-operator/api/v1beta1"
+	"github.com/shtsukada/cloudnative-observability-operator/internal/collector"
+	conditions "github.com/shtsukada/cloudnative-observability-operator/internal/shared/conditions"
+	"github.com/shtsukada/cloudnative-observability-operator/internal/shared/otelenv"
+)
+
+const (
+	// instrumentationFinalizer は削除時に spec.inject で入れた環境変数を戻すためのもの。
+	instrumentationFinalizer = "observability.shtsukada.dev/instrumentation"
+
+	// instrumentedByAnnotation は workload を patch した ObservabilityConfig の名前。
+	instrumentedByAnnotation = "observability.shtsukada.dev/instrumented-by"
+	// injectedEnvAnnotation はコンテナごとに operator が追加した環境変数名 (JSON)。
+	// 利用者が元から設定していた変数は含めないので、戻すときに消しすぎない。
+	injectedEnvAnnotation = "observability.shtsukada.dev/injected-env"
+)
+
+// downward API から resource attributes を組み立てるための変数
+const (
+	podNameEnv   = "OTEL_K8S_POD_NAME"
+	nodeNameEnv  = "OTEL_K8S_NODE_NAME"
+	namespaceEnv = "OTEL_K8S_NAMESPACE_NAME"
+)
+
+// instrumentedWorkload は patch 対象になり得る workload とその Pod テンプレート。
+type instrumentedWorkload struct {
+	kind     string
+	obj      client.Object
+	template *corev1.PodTemplateSpec
+}
+
+func (r *ObservabilityConfigReconciler) listWorkloads(ctx context.Context, namespace string) ([]instrumentedWorkload, error) {
+	var out []instrumentedWorkload
+	var deploys appsv1.DeploymentList
+	if err := r.List(ctx, &deploys, client.InNamespace(namespace)); err != nil {
+		return nil, err
+	}
+	for i := range deploys.Items {
+		d := &deploys.Items[i]
+		out = append(out, instrumentedWorkload{kind: "Deployment", obj: d, template: &d.Spec.Template})
+	}
+	var sets appsv1.StatefulSetList
+	if err := r.List(ctx, &sets, client.InNamespace(namespace)); err != nil {
+		return nil, err
+	}
+	for i := range sets.Items {
+		s := &sets.Items[i]
+		out = append(out, instrumentedWorkload{kind: "StatefulSet", obj: s, template: &s.Spec.Template})
+	}
+	var daemons appsv1.DaemonSetList
+	if err := r.List(ctx, &daemons, client.InNamespace(namespace)); err != nil {
+		return nil, err
+	}
+	for i := range daemons.Items {
+		d := &daemons.Items[i]
+		out = append(out, instrumentedWorkload{kind: "DaemonSet", obj: d, template: &d.Spec.Template})
+	}
+	return out, nil
+}
+
+// instrumentWorkloads は spec.inject に一致する workload に OTEL_* を入れ、外れたものから取り除く。
+func (r *ObservabilityConfigReconciler) instrumentWorkloads(ctx context.Context, oc *observabilityv1beta1.ObservabilityConfig) error {
+	selector := klabels.Nothing()
+	if oc.Spec.Inject != nil && oc.DeletionTimestamp == nil {
+		var err error
+		if selector, err = metav1.LabelSelectorAsSelector(&oc.Spec.Inject.Selector); err != nil {
+			return err
+		}
+	}
+	workloads, err := r.listWorkloads(ctx, oc.Namespace)
+	if err != nil {
+		return err
+	}
+
+	var instrumented []observabilityv1beta1.WorkloadReference
+	for _, w := range workloads {
+		owner := w.obj.GetAnnotations()[instrumentedByAnnotation]
+		// 他の controller が管理する workload は patch しても戻されるので対象外
+		selected := selector.Matches(klabels.Set(w.obj.GetLabels())) && metav1.GetControllerOf(w.obj) == nil &&
+			(owner == "" || owner == oc.Name)
+
+		switch {
+		case selected:
+			patched, err := r.patchWorkload(ctx, w, func() { instrument(oc, w) })
+			if err != nil {
+				return fmt.Errorf("instrument %s %s: %w", w.kind, w.obj.GetName(), err)
+			}
+			if patched {
+				conditions.Emit(r.Recorder, oc, corev1.EventTypeNormal, conditions.ReasonInstrumented, "injected OTEL environment into %s %s", w.kind, w.obj.GetName())
+			}
+			instrumented = append(instrumented, observabilityv1beta1.WorkloadReference{Kind: w.kind, Name: w.obj.GetName()})
+		case owner == oc.Name:
+			if _, err := r.patchWorkload(ctx, w, func() { uninstrument(w) }); err != nil {
+				return fmt.Errorf("revert %s %s: %w", w.kind, w.obj.GetName(), err)
+			}
+			conditions.Emit(r.Recorder, oc, corev1.EventTypeNormal, conditions.ReasonUninstrumented, "removed OTEL environment from %s %s", w.kind, w.obj.GetName())
+		}
+	}
+	sort.Slice(instrumented, func(i, j int) bool {
+		if instrumented[i].Kind != instrumented[j].Kind {
+			return instrumented[i].Kind < instrumented[j].Kind
+		}
+		return instrumented[i].Name < instrumented[j].Name
+	})
+	oc.Status.InstrumentedWorkloads = instrumented
+	return nil
+}
+
+// patchWorkload は mutate で変化があったときだけ merge patch を送り、送ったかを返す。
+// merge patch は containers を丸ごと置き換えるので、読んだ後に他者が変更していれば conflict にする。
+func (r *ObservabilityConfigReconciler) patchWorkload(ctx context.Context, w instrumentedWorkload, mutate func()) (bool, error) {
+	before := w.obj.DeepCopyObject().(client.Object)
+	mutate()
+	if equality.Semantic.DeepEqual(before, w.obj) {
+		return false, nil
+	}
+	return true, r.Patch(ctx, w.obj, client.MergeFromWithOptions(before, client.MergeFromWithOptimisticLock{}))
+}
+
+// instrumentEnv は spec.inject で workload のコンテナに追加する環境変数。
+// $(VAR) の展開は先に定義された変数しか参照できないので順序に意味がある。
+func instrumentEnv(oc *observabilityv1beta1.ObservabilityConfig, kind, name string) []corev1.EnvVar {
+	fieldRef := func(path string) *corev1.EnvVarSource {
+		return &corev1.EnvVarSource{FieldRef: &corev1.ObjectFieldSelector{FieldPath: path}}
+	}
+	env := []corev1.EnvVar{
+		{Name: otelenv.Endpoint, Value: agentEndpoint(oc)},
+		{Name: otelenv.ServiceName, Value: name},
+	}
+	// 間引くのは collector だけなので spec.samplingPercent は SDK に渡さない
+	sampler := collector.SamplerEnv()
+	keys := make([]string, 0, len(sampler))
+	for k := range sampler {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	for _, k := range keys {
+		env = append(env, corev1.EnvVar{Name: k, Value: sampler[k]})
+	}
+	attrs := []string{
+		"k8s.namespace.name=$(" + namespaceEnv + ")",
+		"k8s.pod.name=$(" + podNameEnv + ")",
+		"k8s.node.name=$(" + nodeNameEnv + ")",
+		"k8s." + strings.ToLower(kind) + ".name=" + name,
+	}
+	return append(env,
+		corev1.EnvVar{Name: podNameEnv, ValueFrom: fieldRef("metadata.name")},
+		corev1.EnvVar{Name: nodeNameEnv, ValueFrom: fieldRef("spec.nodeName")},
+		corev1.EnvVar{Name: namespaceEnv, ValueFrom: fieldRef("metadata.namespace")},
+		corev1.EnvVar{Name: otelenv.ResourceAttributes, Value: strings.Join(attrs, ",")},
+	)
+}
+
+// instrument は一旦以前の注入分を取り除いてから、現在の設定で入れ直す。
+func instrument(oc *observabilityv1beta1.ObservabilityConfig, w instrumentedWorkload) {
+	uninstrument(w)
+	want := instrumentEnv(oc, w.kind, w.obj.GetName())
+	injected := map[string][]string{}
+	for i := range w.template.Spec.Containers {
+		c := &w.template.Spec.Containers[i]
+		if containers := oc.Spec.Inject.Containers; len(containers) > 0 && !slices.Contains(containers, c.Name) {
+			continue
+		}
+		for _, e := range want {
+			// 利用者が設定済みの変数はそのまま残す
+			if slices.ContainsFunc(c.Env, func(have corev1.EnvVar) bool { return have.Name == e.Name }) {
+				continue
+			}
+			c.Env = append(c.Env, e)
+			injected[c.Name] = append(injected[c.Name], e.Name)
+		}
+	}
+	data, _ := json.Marshal(injected)
+	annotations := w.obj.GetAnnotations()
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	annotations[instrumentedByAnnotation] = oc.Name
+	annotations[injectedEnvAnnotation] = string(data)
+	w.obj.SetAnnotations(annotations)
+}
+
+// uninstrument は injectedEnvAnnotation に記録した環境変数だけを取り除く。
+func uninstrument(w instrumentedWorkload) {
+	annotations := w.obj.GetAnnotations()
+	injected := map[string][]string{}
+	if raw := annotations[injectedEnvAnnotation]; raw != "" {
+		_ = json.Unmarshal([]byte(raw), &injected)
+	}
+	for i := range w.template.Spec.Containers {
+		c := &w.template.Spec.Containers[i]
+		names := injected[c.Name]
+		if len(names) == 0 {
+			continue
+		}
+		c.Env = slices.DeleteFunc(c.Env, func(e corev1.EnvVar) bool { return slices.Contains(names, e.Name) })
+		if len(c.Env) == 0 {
+			c.Env = nil
+		}
+	}
+	delete(annotations, instrumentedByAnnotation)
+	delete(annotations, injectedEnvAnnotation)
+	if len(annotations) == 0 {
+		annotations = nil
+	}
+	w.obj.SetAnnotations(annotations)
+}
+
+// ensureInstrumentationFinalizer は spec.inject を使う間だけ finalizer を付ける。
+// spec には既定値が入っているので Update ではなく finalizer だけを patch する。
+func (r *ObservabilityConfigReconciler) ensureInstrumentationFinalizer(ctx context.Context, oc *observabilityv1beta1.ObservabilityConfig) error {
+	want := oc.Spec.Inject != nil || len(oc.Status.InstrumentedWorkloads) > 0
+	if want == controllerutil.ContainsFinalizer(oc, instrumentationFinalizer) {
+		return nil
+	}
+	patch := client.MergeFrom(oc.DeepCopy())
+	if want {
+		controllerutil.AddFinalizer(oc, instrumentationFinalizer)
+	} else {
+		controllerutil.RemoveFinalizer(oc, instrumentationFinalizer)
+	}
+	return r.Patch(ctx, oc, patch)
+}
+
+// finalizeInstrumentation は削除中の ObservabilityConfig が patch した workload を元に戻す。
+func (r *ObservabilityConfigReconciler) finalizeInstrumentation(ctx context.Context, oc *observabilityv1beta1.ObservabilityConfig) error {
+	if !controllerutil.ContainsFinalizer(oc, instrumentationFinalizer) {
+		return nil
+	}
+	if err := r.instrumentWorkloads(ctx, oc); err != nil {
+		return err
+	}
+	patch := client.MergeFrom(oc.DeepCopy())
+	controllerutil.RemoveFinalizer(oc, instrumentationFinalizer)
+	return r.Patch(ctx, oc, patch)
+}
+
+// workloadToObservabilityConfigs は workload の変化を、それを選択し得る ObservabilityConfig に伝える。
+func (r *ObservabilityConfigReconciler) workloadToObservabilityConfigs(ctx context.Context, obj client.Object) []reconcile.Request {
+	var list observabilityv1beta1.ObservabilityConfigList
+	if err := r.List(ctx, &list, client.InNamespace(obj.GetNamespace())); err != nil {
+		return nil
+	}
+	var reqs []reconcile.Request
+	for _, oc := range list.Items {
+		matches := false
+		if oc.Spec.Inject != nil {
+			if sel, err := metav1.LabelSelectorAsSelector(&oc.Spec.Inject.Selector); err == nil {
+				matches = sel.Matches(klabels.Set(obj.GetLabels()))
+			}
+		}
+		if matches || obj.GetAnnotations()[instrumentedByAnnotation] == oc.Name {
+			reqs = append(reqs, reconcile.Request{NamespacedName: types.NamespacedName{Namespace: oc.Namespace, Name: oc.Name}})
+		}
+	}
+	return reqs
+}
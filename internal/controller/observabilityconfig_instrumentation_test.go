@@ -0,0 +1,289 @@
+package controller
+
+import (
+	"context"
+	"math"
+	"strconv"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/yaml"
+
+	apiv1beta1 "github.com/shtsukada/cloudnative-observability-operator/api/v1beta1"
+	"github.com/shtsukada/cloudnative-observability-operator/internal/collector"
+)
+
+func testWorkloads() (*appsv1.Deployment, *appsv1.StatefulSet, *appsv1.Deployment) {
+	template := func(env ...corev1.EnvVar) corev1.PodTemplateSpec {
+		return corev1.PodTemplateSpec{Spec: corev1.PodSpec{Containers: []corev1.Container{
+			{Name: "app", Image: "app:1.0.0", Env: env},
+			{Name: "proxy", Image: "proxy:1.0.0"},
+		}}}
+	}
+	labels := map[string]string{"team": "payments"}
+	app := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "checkout", Namespace: "default", Labels: labels},
+		Spec:       appsv1.DeploymentSpec{Template: template(corev1.EnvVar{Name: "OTEL_SERVICE_NAME", Value: "checkout-api"})},
+	}
+	db := &appsv1.StatefulSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "ledger", Namespace: "default", Labels: labels},
+		Spec:       appsv1.StatefulSetSpec{Template: template()},
+	}
+	// GrpcBurner などが管理する Deployment は対象外
+	owned := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "burner", Namespace: "default", Labels: labels,
+			OwnerReferences: []metav1.OwnerReference{{APIVersion: "v1", Kind: "ConfigMap", Name: "x", UID: "x", Controller: ptr.To(true)}}},
+		Spec: appsv1.DeploymentSpec{Template: template()},
+	}
+	return app, db, owned
+}
+
+func envValue(c corev1.Container, name string) (corev1.EnvVar, bool) {
+	for _, e := range c.Env {
+		if e.Name == name {
+			return e, true
+		}
+	}
+	return corev1.EnvVar{}, false
+}
+
+func TestInstrumentWorkloads(t *testing.T) {
+	app, db, owned := testWorkloads()
+	oc := testObservabilityConfig()
+	oc.Spec.Inject = &apiv1beta1.InjectSpec{
+		Selector:   metav1.LabelSelector{MatchLabels: map[string]string{"team": "payments"}},
+		Containers: []string{"app"},
+	}
+	scheme := newTestScheme(t)
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(app.DeepCopy(), db.DeepCopy(), owned.DeepCopy()).Build()
+	r := &ObservabilityConfigReconciler{Client: c, Scheme: scheme, Recorder: record.NewFakeRecorder(32)}
+	ctx := context.Background()
+
+	if err := r.instrumentWorkloads(ctx, oc); err != nil {
+		t.Fatal(err)
+	}
+	want := []apiv1beta1.WorkloadReference{{Kind: "Deployment", Name: "checkout"}, {Kind: "StatefulSet", Name: "ledger"}}
+	if !equality.Semantic.DeepEqual(oc.Status.InstrumentedWorkloads, want) {
+		t.Fatalf("instrumented = %+v", oc.Status.InstrumentedWorkloads)
+	}
+
+	var gotApp appsv1.Deployment
+	if err := c.Get(ctx, client.ObjectKeyFromObject(app), &gotApp); err != nil {
+		t.Fatal(err)
+	}
+	container := gotApp.Spec.Template.Spec.Containers[0]
+	if e, _ := envValue(container, "OTEL_EXPORTER_OTLP_ENDPOINT"); e.Value != "http://oc-oc.default.svc:4317" {
+		t.Fatalf("endpoint = %+v", e)
+	}
+	// 利用者が設定した service name は残す
+	if e, _ := envValue(container, "OTEL_SERVICE_NAME"); e.Value != "checkout-api" {
+		t.Fatalf("service name = %+v", e)
+	}
+	if e, _ := envValue(container, "OTEL_TRACES_SAMPLER"); e.Value != "parentbased_always_on" {
+		t.Fatalf("sampler = %+v", e)
+	}
+	if e, _ := envValue(container, "OTEL_K8S_POD_NAME"); e.ValueFrom == nil || e.ValueFrom.FieldRef.FieldPath != "metadata.name" {
+		t.Fatalf("pod name = %+v", e)
+	}
+	attrs, _ := envValue(container, "OTEL_RESOURCE_ATTRIBUTES")
+	if attrs.Value != "k8s.namespace.name=$(OTEL_K8S_NAMESPACE_NAME),k8s.pod.name=$(OTEL_K8S_POD_NAME),k8s.node.name=$(OTEL_K8S_NODE_NAME),k8s.deployment.name=checkout" {
+		t.Fatalf("resource attributes = %s", attrs.Value)
+	}
+	if len(gotApp.Spec.Template.Spec.Containers[1].Env) != 0 {
+		t.Fatalf("unselected container patched: %+v", gotApp.Spec.Template.Spec.Containers[1].Env)
+	}
+
+	var gotDB appsv1.StatefulSet
+	if err := c.Get(ctx, client.ObjectKeyFromObject(db), &gotDB); err != nil {
+		t.Fatal(err)
+	}
+	if e, _ := envValue(gotDB.Spec.Template.Spec.Containers[0], "OTEL_SERVICE_NAME"); e.Value != "ledger" {
+		t.Fatalf("service name = %+v", e)
+	}
+	var gotOwned appsv1.Deployment
+	if err := c.Get(ctx, client.ObjectKeyFromObject(owned), &gotOwned); err != nil {
+		t.Fatal(err)
+	}
+	if !equality.Semantic.DeepEqual(gotOwned.Spec.Template, owned.Spec.Template) {
+		t.Fatal("controlled workload was patched")
+	}
+
+	// 変化が無ければ patch しない
+	rv := gotApp.ResourceVersion
+	if err := r.instrumentWorkloads(ctx, oc); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Get(ctx, client.ObjectKeyFromObject(app), &gotApp); err != nil {
+		t.Fatal(err)
+	}
+	if gotApp.ResourceVersion != rv {
+		t.Fatal("unchanged workload was patched again")
+	}
+
+	// selector を外すと元に戻る
+	oc.Spec.Inject = nil
+	if err := r.instrumentWorkloads(ctx, oc); err != nil {
+		t.Fatal(err)
+	}
+	if len(oc.Status.InstrumentedWorkloads) != 0 {
+		t.Fatalf("instrumented = %+v", oc.Status.InstrumentedWorkloads)
+	}
+	if err := c.Get(ctx, client.ObjectKeyFromObject(app), &gotApp); err != nil {
+		t.Fatal(err)
+	}
+	if !equality.Semantic.DeepEqual(gotApp.Spec.Template, app.Spec.Template) || len(gotApp.Annotations) != 0 {
+		t.Fatalf("not reverted: %+v %+v", gotApp.Annotations, gotApp.Spec.Template.Spec.Containers)
+	}
+}
+
+func TestInstrumentationFinalizer(t *testing.T) {
+	app, _, _ := testWorkloads()
+	oc := testObservabilityConfig()
+	oc.Spec.Inject = &apiv1beta1.InjectSpec{Selector: metav1.LabelSelector{MatchLabels: map[string]string{"team": "payments"}}}
+	scheme := newTestScheme(t)
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(oc, app.DeepCopy()).WithStatusSubresource(oc).Build()
+	r := &ObservabilityConfigReconciler{Client: c, Scheme: scheme, Recorder: record.NewFakeRecorder(32)}
+	ctx := context.Background()
+	req := reconcile.Request{NamespacedName: types.NamespacedName{Namespace: "default", Name: "oc"}}
+
+	if _, err := r.Reconcile(ctx, req); err != nil {
+		t.Fatal(err)
+	}
+	var got apiv1beta1.ObservabilityConfig
+	if err := c.Get(ctx, req.NamespacedName, &got); err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Finalizers) != 1 || len(got.Status.InstrumentedWorkloads) != 1 {
+		t.Fatalf("finalizers = %v, instrumented = %+v", got.Finalizers, got.Status.InstrumentedWorkloads)
+	}
+
+	// 削除時に patch した workload を戻してから finalizer を外す
+	if err := c.Delete(ctx, &got); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Reconcile(ctx, req); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Get(ctx, req.NamespacedName, &got); !apierrors.IsNotFound(err) {
+		t.Fatalf("ObservabilityConfig still present: %v", err)
+	}
+	var gotApp appsv1.Deployment
+	if err := c.Get(ctx, client.ObjectKeyFromObject(app), &gotApp); err != nil {
+		t.Fatal(err)
+	}
+	if !equality.Semantic.DeepEqual(gotApp.Spec.Template, app.Spec.Template) {
+		t.Fatalf("not reverted: %+v", gotApp.Spec.Template.Spec.Containers)
+	}
+}
+
+func TestInstrumentedSamplingRate(t *testing.T) {
+	app, _, _ := testWorkloads()
+	oc := testObservabilityConfig()
+	oc.Spec.SamplingPercent = ptr.To(int32(10))
+	oc.Spec.Inject = &apiv1beta1.InjectSpec{Selector: metav1.LabelSelector{MatchLabels: map[string]string{"team": "payments"}}}
+	scheme := newTestScheme(t)
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(oc, app.DeepCopy()).WithStatusSubresource(oc).Build()
+	r := &ObservabilityConfigReconciler{Client: c, Scheme: scheme, Recorder: record.NewFakeRecorder(32)}
+	ctx := context.Background()
+	req := reconcile.Request{NamespacedName: types.NamespacedName{Namespace: "default", Name: "oc"}}
+
+	if _, err := r.Reconcile(ctx, req); err != nil {
+		t.Fatal(err)
+	}
+	var got apiv1beta1.ObservabilityConfig
+	if err := c.Get(ctx, req.NamespacedName, &got); err != nil {
+		t.Fatal(err)
+	}
+
+	// SDK が残す割合
+	var gotApp appsv1.Deployment
+	if err := c.Get(ctx, client.ObjectKeyFromObject(app), &gotApp); err != nil {
+		t.Fatal(err)
+	}
+	sdk := 1.0
+	sampler, _ := envValue(gotApp.Spec.Template.Spec.Containers[0], "OTEL_TRACES_SAMPLER")
+	switch sampler.Value {
+	case "always_on", "parentbased_always_on":
+	case "traceidratio", "parentbased_traceidratio":
+		arg, _ := envValue(gotApp.Spec.Template.Spec.Containers[0], "OTEL_TRACES_SAMPLER_ARG")
+		var err error
+		if sdk, err = strconv.ParseFloat(arg.Value, 64); err != nil {
+			t.Fatal(err)
+		}
+	default:
+		t.Fatalf("sampler = %+v", sampler)
+	}
+
+	// agent が残す割合
+	var cm corev1.ConfigMap
+	if err := c.Get(ctx, types.NamespacedName{Namespace: "default", Name: "oc-oc-config"}, &cm); err != nil {
+		t.Fatal(err)
+	}
+	var agentCfg struct {
+		Processors map[string]struct {
+			SamplingPercentage *float64 `json:"sampling_percentage"`
+		} `json:"processors"`
+		Service struct {
+			Pipelines map[string]struct {
+				Processors []string `json:"processors"`
+			} `json:"pipelines"`
+		} `json:"service"`
+	}
+	if err := yaml.Unmarshal([]byte(cm.Data[collector.ConfigKey]), &agentCfg); err != nil {
+		t.Fatal(err)
+	}
+	agent := 1.0
+	for _, id := range agentCfg.Service.Pipelines["traces"].Processors {
+		if p := agentCfg.Processors[id].SamplingPercentage; p != nil {
+			agent *= *p / 100
+		}
+	}
+
+	// 二重に間引かず、spec の割合がそのまま end to end の割合になる
+	if rate := sdk * agent * 100; math.Abs(rate-10) > 1e-9 || got.Status.EffectiveSamplingPercent == nil || *got.Status.EffectiveSamplingPercent != 10 {
+		t.Fatalf("end-to-end rate = %g%% (sdk %g, agent %g), status = %v", rate, sdk, agent, got.Status.EffectiveSamplingPercent)
+	}
+}
+
+func TestPatchWorkloadConflict(t *testing.T) {
+	app, _, _ := testWorkloads()
+	oc := testObservabilityConfig()
+	oc.Spec.Inject = &apiv1beta1.InjectSpec{Selector: metav1.LabelSelector{MatchLabels: map[string]string{"team": "payments"}}}
+	scheme := newTestScheme(t)
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(app.DeepCopy()).Build()
+	r := &ObservabilityConfigReconciler{Client: c, Scheme: scheme}
+	ctx := context.Background()
+
+	var stale appsv1.Deployment
+	if err := c.Get(ctx, client.ObjectKeyFromObject(app), &stale); err != nil {
+		t.Fatal(err)
+	}
+	// 読んだ後に他者がコンテナを追加した
+	latest := stale.DeepCopy()
+	latest.Spec.Template.Spec.Containers = append(latest.Spec.Template.Spec.Containers, corev1.Container{Name: "debug", Image: "busybox"})
+	if err := c.Update(ctx, latest); err != nil {
+		t.Fatal(err)
+	}
+
+	w := instrumentedWorkload{kind: "Deployment", obj: &stale, template: &stale.Spec.Template}
+	if _, err := r.patchWorkload(ctx, w, func() { instrument(oc, w) }); !apierrors.IsConflict(err) {
+		t.Fatalf("err = %v, want conflict", err)
+	}
+	var got appsv1.Deployment
+	if err := c.Get(ctx, client.ObjectKeyFromObject(app), &got); err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Spec.Template.Spec.Containers) != 3 {
+		t.Fatalf("containers = %+v", got.Spec.Template.Spec.Containers)
+	}
+}
@@ -0,0 +1,198 @@
+package controller
+
+import (
+	"context"
+	"slices"
+	"strings"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	observabilityv1beta1 "github.com/shtsukada/cloudnative-observability-operator/api/v1beta1"
+	"github.com/shtsukada/cloudnative-observability-operator/internal/collector"
+	conditions "github.com/shtsukada/cloudnative-observability-operator/internal/shared/conditions"
+)
+
+// queueRetentionAnnotation は persistence を外したときに queue の PVC を消すかどうか。
+// spec から消えた後でも判断できるよう PVC 自身に持たせる。
+const queueRetentionAnnotation = "observability.shtsukada.dev/queue-retention"
+
+// queueClaimPrefix は StatefulSet が作る queue の PVC の名前の接頭辞 (<template>-<statefulset>-<ordinal>)。
+func queueClaimPrefix(oc *observabilityv1beta1.ObservabilityConfig) string {
+	return collector.QueueVolumeName + "-" + agentWorkloadName(oc) + "-"
+}
+
+// queueClaimTemplates は replica ごとに作る queue の PVC。
+func queueClaimTemplates(oc *observabilityv1beta1.ObservabilityConfig) []corev1.PersistentVolumeClaim {
+	p := oc.Spec.Persistence
+	if p == nil {
+		return nil
+	}
+	size := observabilityv1beta1.DefaultPersistenceSize
+	if p.Size != nil {
+		size = *p.Size
+	}
+	return []corev1.PersistentVolumeClaim{{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        collector.QueueVolumeName,
+			Labels:      agentLabels(oc),
+			Annotations: map[string]string{queueRetentionAnnotation: string(whenDeleted(p))},
+		},
+		Spec: corev1.PersistentVolumeClaimSpec{
+			AccessModes:      []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce},
+			StorageClassName: p.StorageClassName,
+			Resources: corev1.VolumeResourceRequirements{
+				Requests: corev1.ResourceList{corev1.ResourceStorage: size},
+			},
+		},
+	}}
+}
+
+func whenDeleted(p *observabilityv1beta1.PersistenceSpec) observabilityv1beta1.PersistenceRetention {
+	if p.RetentionPolicy == nil || p.RetentionPolicy.WhenDeleted == "" {
+		return observabilityv1beta1.PersistenceDelete
+	}
+	return p.RetentionPolicy.WhenDeleted
+}
+
+// claimRetentionPolicy は spec.persistence.retentionPolicy を StatefulSet の設定に写す。
+// 縮退時と削除時の PVC の扱いは StatefulSet controller に任せる。
+func claimRetentionPolicy(oc *observabilityv1beta1.ObservabilityConfig) *appsv1.StatefulSetPersistentVolumeClaimRetentionPolicy {
+	p := oc.Spec.Persistence
+	if p == nil {
+		return nil
+	}
+	scaled := observabilityv1beta1.PersistenceRetain
+	if p.RetentionPolicy != nil && p.RetentionPolicy.WhenScaled != "" {
+		scaled = p.RetentionPolicy.WhenScaled
+	}
+	return &appsv1.StatefulSetPersistentVolumeClaimRetentionPolicy{
+		WhenScaled:  appsv1.PersistentVolumeClaimRetentionPolicyType(scaled),
+		WhenDeleted: appsv1.PersistentVolumeClaimRetentionPolicyType(whenDeleted(p)),
+	}
+}
+
+// addQueueClaims は sidecar 用の emptyDir を replica ごとの PVC に置き換える。
+func addQueueClaims(sts *appsv1.StatefulSet, oc *observabilityv1beta1.ObservabilityConfig) {
+	if oc.Spec.Persistence == nil {
+		return
+	}
+	spec := &sts.Spec.Template.Spec
+	spec.Volumes = slices.DeleteFunc(spec.Volumes, func(v corev1.Volume) bool { return v.Name == collector.QueueVolumeName })
+	// collector のイメージは root 以外で動くので、volume をそのグループで書けるようにする
+	spec.SecurityContext = &corev1.PodSecurityContext{
+		FSGroup:             ptr.To(collector.AgentGID),
+		FSGroupChangePolicy: ptr.To(corev1.FSGroupChangeOnRootMismatch),
+	}
+	sts.Spec.VolumeClaimTemplates = queueClaimTemplates(oc)
+	sts.Spec.PersistentVolumeClaimRetentionPolicy = claimRetentionPolicy(oc)
+}
+
+// claimTemplatesChanged は volumeClaimTemplates を作り直す必要があるかを返す。
+// API server が補う値 (volumeMode など) や annotation の違いは無視する。
+func claimTemplatesChanged(have, want []corev1.PersistentVolumeClaim) bool {
+	if len(have) != len(want) {
+		return true
+	}
+	for i := range want {
+		h, w := have[i], want[i]
+		if h.Name != w.Name ||
+			!equality.Semantic.DeepEqual(h.Spec.StorageClassName, w.Spec.StorageClassName) ||
+			!equality.Semantic.DeepEqual(h.Spec.AccessModes, w.Spec.AccessModes) ||
+			h.Spec.Resources.Requests.Storage().Cmp(*w.Spec.Resources.Requests.Storage()) != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// recreateStatefulSet は volumeClaimTemplates が変わった StatefulSet を削除する。
+// volumeClaimTemplates は更新できないため、Pod と PVC は残したまま (Orphan) 消し、
+// 削除の event で次の reconcile が新しい StatefulSet を作って Pod を引き取る。
+// 削除中または削除した場合は true を返す。
+func (r *ObservabilityConfigReconciler) recreateStatefulSet(ctx context.Context, oc *observabilityv1beta1.ObservabilityConfig, sts *appsv1.StatefulSet, want []corev1.PersistentVolumeClaim) (bool, error) {
+	if err := r.Get(ctx, client.ObjectKeyFromObject(sts), sts); err != nil {
+		return false, client.IgnoreNotFound(err)
+	}
+	if !metav1.IsControlledBy(sts, oc) {
+		return false, nil
+	}
+	if sts.DeletionTimestamp != nil {
+		return true, nil
+	}
+	if !claimTemplatesChanged(sts.Spec.VolumeClaimTemplates, want) {
+		return false, nil
+	}
+	conditions.Emit(r.Recorder, oc, corev1.EventTypeNormal, conditions.ReasonRecreating,
+		"recreating StatefulSet %s to change its queue volumes", sts.Name)
+	err := r.Delete(ctx, sts, client.PropagationPolicy(metav1.DeletePropagationOrphan))
+	return true, client.IgnoreNotFound(err)
+}
+
+// applyQueueClaims は既存の queue の PVC に今の削除時の扱いを書き、
+// persistence を外したときは Delete の PVC を削除する。
+// 縮退時と ObservabilityConfig の削除時は StatefulSet の retention policy が扱う。
+func (r *ObservabilityConfigReconciler) applyQueueClaims(ctx context.Context, oc *observabilityv1beta1.ObservabilityConfig) error {
+	var pvcs corev1.PersistentVolumeClaimList
+	if err := r.List(ctx, &pvcs, client.InNamespace(oc.Namespace), client.MatchingLabels(agentLabels(oc))); err != nil {
+		return err
+	}
+	for i := range pvcs.Items {
+		pvc := &pvcs.Items[i]
+		if !strings.HasPrefix(pvc.Name, queueClaimPrefix(oc)) {
+			continue
+		}
+		if p := oc.Spec.Persistence; p != nil {
+			want := string(whenDeleted(p))
+			if pvc.Annotations[queueRetentionAnnotation] == want {
+				continue
+			}
+			patch := client.MergeFrom(pvc.DeepCopy())
+			if pvc.Annotations == nil {
+				pvc.Annotations = map[string]string{}
+			}
+			pvc.Annotations[queueRetentionAnnotation] = want
+			if err := r.Patch(ctx, pvc, patch); err != nil {
+				return err
+			}
+			continue
+		}
+		if pvc.Annotations[queueRetentionAnnotation] != string(observabilityv1beta1.PersistenceDelete) || pvc.DeletionTimestamp != nil {
+			continue
+		}
+		if err := r.Delete(ctx, pvc); client.IgnoreNotFound(err) != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// updatePersistenceStatus は StatefulSet が queue の PVC を使っているかを status.persistence に書く。
+func (r *ObservabilityConfigReconciler) updatePersistenceStatus(ctx context.Context, oc *observabilityv1beta1.ObservabilityConfig) error {
+	if oc.Spec.Persistence == nil {
+		oc.Status.Persistence = nil
+		return nil
+	}
+	st := &observabilityv1beta1.PersistenceStatus{}
+	oc.Status.Persistence = st
+	var sts appsv1.StatefulSet
+	if err := r.Get(ctx, client.ObjectKey{Name: agentWorkloadName(oc), Namespace: oc.Namespace}, &sts); err != nil {
+		return client.IgnoreNotFound(err)
+	}
+	st.Active = sts.DeletionTimestamp == nil && !claimTemplatesChanged(sts.Spec.VolumeClaimTemplates, queueClaimTemplates(oc))
+
+	var pvcs corev1.PersistentVolumeClaimList
+	if err := r.List(ctx, &pvcs, client.InNamespace(oc.Namespace), client.MatchingLabels(agentLabels(oc))); err != nil {
+		return err
+	}
+	for _, pvc := range pvcs.Items {
+		if strings.HasPrefix(pvc.Name, queueClaimPrefix(oc)) && pvc.Status.Phase == corev1.ClaimBound {
+			st.BoundClaims++
+		}
+	}
+	return nil
+}
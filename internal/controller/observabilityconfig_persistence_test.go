@@ -0,0 +1,122 @@
+package controller
+
+import (
+	"context"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	apiv1beta1 "github.com/shtsukada/cloudnative-observability-operator/api/v1beta1"
+	"github.com/shtsukada/cloudnative-observability-operator/internal/collector"
+)
+
+func TestApplyPersistence(t *testing.T) {
+	oc := testObservabilityConfig()
+	oc.Spec.Mode = apiv1beta1.AgentModeStatefulSet
+	oc.Spec.Persistence = &apiv1beta1.PersistenceSpec{
+		StorageClassName: ptr.To("fast"),
+		RetentionPolicy:  &apiv1beta1.PersistenceRetentionPolicy{WhenDeleted: apiv1beta1.PersistenceRetain},
+	}
+	oc.ApplyDefaults()
+	// StatefulSet controller が replica 0 のために作った PVC
+	pvc := &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "queue-oc-oc-sidecar-0", Namespace: "default", Labels: agentLabels(oc),
+			Annotations: map[string]string{queueRetentionAnnotation: string(apiv1beta1.PersistenceRetain)},
+		},
+		Status: corev1.PersistentVolumeClaimStatus{Phase: corev1.ClaimBound},
+	}
+	scheme := newTestScheme(t)
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(oc, pvc).Build()
+	r := &ObservabilityConfigReconciler{Client: c, Scheme: scheme}
+	ctx := context.Background()
+	key := types.NamespacedName{Namespace: "default", Name: "oc-oc-sidecar"}
+	apply := func() {
+		t.Helper()
+		if _, err := r.applyDesired(ctx, oc, mustRender(t, oc)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	getSTS := func() *appsv1.StatefulSet {
+		t.Helper()
+		var sts appsv1.StatefulSet
+		if err := c.Get(ctx, key, &sts); err != nil {
+			if apierrors.IsNotFound(err) {
+				return nil
+			}
+			t.Fatal(err)
+		}
+		return &sts
+	}
+
+	apply()
+	sts := getSTS()
+	if sts == nil || len(sts.Spec.VolumeClaimTemplates) != 1 {
+		t.Fatalf("StatefulSet without queue claims: %+v", sts)
+	}
+	claim := sts.Spec.VolumeClaimTemplates[0]
+	if claim.Name != collector.QueueVolumeName || *claim.Spec.StorageClassName != "fast" || !claim.Spec.Resources.Requests.Storage().Equal(resource.MustParse("1Gi")) {
+		t.Fatalf("claim template = %+v", claim)
+	}
+	if p := sts.Spec.PersistentVolumeClaimRetentionPolicy; p == nil || p.WhenScaled != appsv1.RetainPersistentVolumeClaimRetentionPolicyType || p.WhenDeleted != appsv1.RetainPersistentVolumeClaimRetentionPolicyType {
+		t.Fatalf("retention policy = %+v", p)
+	}
+	// PVC が sidecar 用の emptyDir を置き換える
+	for _, v := range sts.Spec.Template.Spec.Volumes {
+		if v.Name == collector.QueueVolumeName {
+			t.Fatalf("queue volume left in the pod template: %+v", v)
+		}
+	}
+	if sc := sts.Spec.Template.Spec.SecurityContext; sc == nil || ptr.Deref(sc.FSGroup, 0) != collector.AgentGID {
+		t.Fatalf("securityContext = %+v", sc)
+	}
+	if err := r.updatePersistenceStatus(ctx, oc); err != nil {
+		t.Fatal(err)
+	}
+	if st := oc.Status.Persistence; st == nil || !st.Active || st.BoundClaims != 1 {
+		t.Fatalf("status = %+v", st)
+	}
+
+	// volumeClaimTemplates は変更できないので StatefulSet を作り直す
+	oc.Spec.Persistence.Size = ptr.To(resource.MustParse("5Gi"))
+	oc.Spec.Persistence.RetentionPolicy.WhenDeleted = apiv1beta1.PersistenceDelete
+	apply()
+	if getSTS() != nil {
+		t.Fatal("StatefulSet not deleted for the new claim template")
+	}
+	if err := r.updatePersistenceStatus(ctx, oc); err != nil {
+		t.Fatal(err)
+	}
+	if oc.Status.Persistence.Active {
+		t.Fatal("persistence reported active while recreating")
+	}
+	apply()
+	if sts := getSTS(); sts == nil || !sts.Spec.VolumeClaimTemplates[0].Spec.Resources.Requests.Storage().Equal(resource.MustParse("5Gi")) {
+		t.Fatalf("StatefulSet not recreated with the new size: %+v", sts)
+	}
+	if err := c.Get(ctx, client.ObjectKeyFromObject(pvc), pvc); err != nil || pvc.Annotations[queueRetentionAnnotation] != string(apiv1beta1.PersistenceDelete) {
+		t.Fatalf("retention not recorded on the existing claim: %v %v", pvc.Annotations, err)
+	}
+
+	// persistence を外すと whenDeleted: Delete の PVC を消す
+	oc.Spec.Persistence = nil
+	apply()
+	apply()
+	if sts := getSTS(); sts == nil || len(sts.Spec.VolumeClaimTemplates) != 0 {
+		t.Fatalf("StatefulSet not recreated without claims: %+v", sts)
+	}
+	if err := c.Get(ctx, client.ObjectKeyFromObject(pvc), pvc); !apierrors.IsNotFound(err) {
+		t.Fatalf("queue claim not deleted: %v", err)
+	}
+	if err := r.updatePersistenceStatus(ctx, oc); err != nil || oc.Status.Persistence != nil {
+		t.Fatalf("status = %+v, err = %v", oc.Status.Persistence, err)
+	}
+}
@@ -0,0 +1,139 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	observabilityv1beta1 "github.com/shtsukada/cloudnative-observability-operator/api/v1beta1"
+	"github.com/shtsukada/cloudnative-observability-operator/internal/agenthealth"
+	conditions "github.com/shtsukada/cloudnative-observability-operator/internal/shared/conditions"
+)
+
+const (
+	// DefaultAgentPollInterval is how often the agent pods are polled once an ObservabilityConfig is in sync.
+	DefaultAgentPollInterval = 30 * time.Second
+
+	// DefaultExportFailureThreshold is the fraction of items failing to send
+	// between two polls above which ExportFailing is set.
+	DefaultExportFailureThreshold = 0.05
+)
+
+// pollAgents は agent の Pod の health_check と自身のメトリクスを読み、
+// status.pipeline と ExportFailing を更新する。
+// gateway 構成では receiver の数を agent から、exporter の数を gateway から取る。
+func (r *ObservabilityConfigReconciler) pollAgents(ctx context.Context, oc *observabilityv1beta1.ObservabilityConfig) error {
+	if r.Poller == nil {
+		return nil
+	}
+	prev := oc.Status.Pipeline
+	// status 更新でも Reconcile が走るので、間隔内に確認済みなら結果をそのまま使う
+	if prev != nil && prev.LastPollTime != nil && prev.LastPollTime.After(time.Now().Add(-r.pollInterval()/2)) {
+		return nil
+	}
+
+	agents, err := r.pollTargets(ctx, oc.Namespace, agentLabels(oc))
+	if err != nil {
+		return err
+	}
+	exporting := agents
+	if gatewayEnabled(oc) {
+		if exporting, err = r.pollTargets(ctx, oc.Namespace, gatewayLabels(oc)); err != nil {
+			return err
+		}
+	}
+	if len(agents) == 0 && len(exporting) == 0 {
+		oc.Status.Pipeline = nil
+		apimeta.RemoveStatusCondition(&oc.Status.Conditions, observabilityv1beta1.ConditionExportFailing)
+		r.Poller.Forget(client.ObjectKeyFromObject(oc).String())
+		return nil
+	}
+
+	results := r.Poller.PollAll(ctx, agents)
+	if gatewayEnabled(oc) {
+		results = append(results, r.Poller.PollAll(ctx, exporting)...)
+	}
+	now := metav1.Now()
+	st := &observabilityv1beta1.PipelineStatus{Pods: int32(len(results)), LastPollTime: &now}
+	var errs []string
+	for i, res := range results {
+		if res.Healthy {
+			st.HealthyPods++
+		}
+		if res.Err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", res.Target.Pod, res.Err))
+			continue
+		}
+		receiver, exporter := i < len(agents), i >= len(results)-len(exporting)
+		if receiver {
+			st.AcceptedSpans += res.Metrics.AcceptedSpans
+			st.RefusedSpans += res.Metrics.RefusedSpans
+		}
+		if exporter {
+			st.SentItems += res.Metrics.SentItems
+			st.SendFailedItems += res.Metrics.SendFailedItems
+		}
+	}
+	if len(errs) > 0 {
+		st.LastError = truncate(fmt.Sprintf("%d/%d pods not polled: %s", len(errs), len(results), strings.Join(errs, "; ")), 1024)
+	}
+	oc.Status.Pipeline = st
+	// 累計の合計は Pod が読めなかったり入れ替わったりすると増減するので、Pod ごとの増分で判断する。
+	// 読めた exporter が無ければ前回の判断を残す
+	if delta, ok := r.Poller.Increase(client.ObjectKeyFromObject(oc).String(), results[len(results)-len(exporting):]); ok {
+		r.setExportFailing(oc, delta)
+	}
+	return nil
+}
+
+// pollTargets は IP が割り当てられた Running の Pod を返す。
+func (r *ObservabilityConfigReconciler) pollTargets(ctx context.Context, namespace string, labels map[string]string) ([]agenthealth.Target, error) {
+	var pods corev1.PodList
+	if err := r.List(ctx, &pods, client.InNamespace(namespace), client.MatchingLabels(labels)); err != nil {
+		return nil, err
+	}
+	var targets []agenthealth.Target
+	for _, p := range pods.Items {
+		if p.Status.Phase != corev1.PodRunning || p.Status.PodIP == "" || p.DeletionTimestamp != nil {
+			continue
+		}
+		targets = append(targets, agenthealth.Target{Pod: p.Name, UID: string(p.UID), Host: p.Status.PodIP})
+	}
+	return targets, nil
+}
+
+// setExportFailing は前回の poll からの送信失敗の割合で ExportFailing を決める。
+func (r *ObservabilityConfigReconciler) setExportFailing(oc *observabilityv1beta1.ObservabilityConfig, delta agenthealth.Metrics) {
+	sent, failed := delta.SentItems, delta.SendFailedItems
+	threshold := r.exportFailureThreshold()
+	if failed == 0 || float64(failed) <= threshold*float64(sent+failed) {
+		setCondition(oc, observabilityv1beta1.ConditionExportFailing, metav1.ConditionFalse, conditions.ReasonSendSucceeded,
+			fmt.Sprintf("%d items sent, %d failed since the last poll", sent, failed))
+		return
+	}
+	msg := fmt.Sprintf("%d of %d items failed to send since the last poll (threshold %g%%)", failed, sent+failed, threshold*100)
+	if !apimeta.IsStatusConditionTrue(oc.Status.Conditions, observabilityv1beta1.ConditionExportFailing) {
+		conditions.Emit(r.Recorder, oc, corev1.EventTypeWarning, conditions.ReasonSendFailed, "%s", msg)
+	}
+	setCondition(oc, observabilityv1beta1.ConditionExportFailing, metav1.ConditionTrue, conditions.ReasonSendFailed, msg)
+}
+
+func (r *ObservabilityConfigReconciler) pollInterval() time.Duration {
+	if r.PollInterval > 0 {
+		return r.PollInterval
+	}
+	return DefaultAgentPollInterval
+}
+
+func (r *ObservabilityConfigReconciler) exportFailureThreshold() float64 {
+	if r.ExportFailureThreshold > 0 {
+		return r.ExportFailureThreshold
+	}
+	return DefaultExportFailureThreshold
+}
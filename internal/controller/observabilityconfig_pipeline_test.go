@@ -0,0 +1,125 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	apiv1beta1 "github.com/shtsukada/cloudnative-observability-operator/api/v1beta1"
+	"github.com/shtsukada/cloudnative-observability-operator/internal/agenthealth"
+	conditions "github.com/shtsukada/cloudnative-observability-operator/internal/shared/conditions"
+)
+
+func TestPollAgents(t *testing.T) {
+	// agent の health_check と自身のメトリクスの代わり。Pod は IP で見分ける
+	type counters struct{ sent, failed int }
+	agents := map[string]*counters{"10.0.0.1": {}, "10.0.0.2": {}}
+	down := map[string]bool{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host, _, _ := net.SplitHostPort(r.Host)
+		if down[host] {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		if c := agents[host]; r.URL.Path == "/metrics" {
+			_, _ = fmt.Fprintf(w, "otelcol_receiver_accepted_spans_total{receiver=\"otlp\"} 50\n"+
+				"otelcol_exporter_sent_spans_total{exporter=\"otlp/0\"} %d\n"+
+				"otelcol_exporter_send_failed_spans_total{exporter=\"otlp/0\"} %d\n", c.sent, c.failed)
+		}
+	}))
+	defer srv.Close()
+	_, port, _ := net.SplitHostPort(srv.Listener.Addr().String())
+	n, _ := strconv.Atoi(port)
+	var d net.Dialer
+	httpClient := &http.Client{Transport: &http.Transport{DialContext: func(ctx context.Context, network, _ string) (net.Conn, error) {
+		return d.DialContext(ctx, network, srv.Listener.Addr().String())
+	}}}
+
+	oc := testObservabilityConfig()
+	oc.ApplyDefaults()
+	pod := func(name, ip string, phase corev1.PodPhase) *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: name, UID: types.UID(name), Labels: agentLabels(oc)},
+			Status:     corev1.PodStatus{Phase: phase, PodIP: ip},
+		}
+	}
+	scheme := newTestScheme(t)
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(oc,
+		pod("agent-a", "10.0.0.1", corev1.PodRunning), pod("agent-b", "10.0.0.2", corev1.PodRunning), pod("agent-c", "10.0.0.3", corev1.PodPending)).Build()
+	r := &ObservabilityConfigReconciler{
+		Client: c, Scheme: scheme,
+		Poller: &agenthealth.Poller{Client: httpClient, HealthPort: int32(n), MetricsPort: int32(n)},
+		// 毎回 poll し直す
+		PollInterval:           time.Nanosecond,
+		ExportFailureThreshold: 0.1,
+	}
+	ctx := context.Background()
+	poll := func() *apiv1beta1.PipelineStatus {
+		t.Helper()
+		if err := r.pollAgents(ctx, oc); err != nil {
+			t.Fatal(err)
+		}
+		return oc.Status.Pipeline
+	}
+	exportFailing := func() *metav1.Condition {
+		return apimeta.FindStatusCondition(oc.Status.Conditions, apiv1beta1.ConditionExportFailing)
+	}
+	a, b := agents["10.0.0.1"], agents["10.0.0.2"]
+
+	a.sent, b.sent = 100, 100
+	st := poll()
+	if st == nil || st.Pods != 2 || st.HealthyPods != 2 || st.AcceptedSpans != 100 || st.SentItems != 200 || st.LastError != "" {
+		t.Fatalf("status = %+v", st)
+	}
+	if c := exportFailing(); c == nil || c.Status != metav1.ConditionFalse {
+		t.Fatalf("ExportFailing = %+v", c)
+	}
+
+	// 判断は前回からの増分で行う (130 件中 20 件の失敗)
+	a.sent, a.failed, b.sent = 110, 20, 200
+	if poll(); !apimeta.IsStatusConditionTrue(oc.Status.Conditions, apiv1beta1.ConditionExportFailing) {
+		t.Fatalf("ExportFailing = %+v", exportFailing())
+	}
+	if c := exportFailing(); c.Reason != conditions.ReasonSendFailed {
+		t.Fatalf("ExportFailing = %+v", c)
+	}
+
+	// 読めない Pod があっても、読めた Pod の増分だけで判断する
+	down["10.0.0.2"] = true
+	a.sent = 120
+	st = poll()
+	if st.Pods != 2 || st.HealthyPods != 1 || st.LastError == "" {
+		t.Fatalf("status = %+v", st)
+	}
+	if c := exportFailing(); c.Status != metav1.ConditionFalse || c.Message != "10 items sent, 0 failed since the last poll" {
+		t.Fatalf("ExportFailing = %+v", c)
+	}
+
+	// 再起動で累計が減った Pod はその Pod の今回の累計を増分とする
+	delete(down, "10.0.0.2")
+	a.sent, a.failed, b.sent = 5, 1, 300
+	if poll(); exportFailing().Message != "105 items sent, 1 failed since the last poll" {
+		t.Fatalf("ExportFailing = %+v", exportFailing())
+	}
+
+	// 応答しない Pod は理由を残し、前回の判断を変えない
+	srv.Close()
+	st = poll()
+	if st.Pods != 2 || st.HealthyPods != 0 || st.LastError == "" {
+		t.Fatalf("status = %+v", st)
+	}
+	if c := exportFailing(); c == nil || c.Status != metav1.ConditionFalse {
+		t.Fatalf("ExportFailing = %+v", c)
+	}
+}
@@ -0,0 +1,230 @@
+package controller
+
+import (
+	"context"
+
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	observabilityv1beta1 "github.com/shtsukada/cloudnative-observability-operator/api/v1beta1"
+)
+
+const (
+	// agentRBACFinalizer は cluster スコープの ClusterRole/ClusterRoleBinding を削除時に片付けるためのもの。
+	// namespace をまたぐ ownerReference は使えないので GC に任せられない。
+	agentRBACFinalizer = "observability.shtsukada.dev/agent-rbac"
+
+	// ownerNamespaceLabel と ownerNameLabel は cluster スコープの RBAC を作った ObservabilityConfig。
+	ownerNamespaceLabel = "observability.shtsukada.dev/owner-namespace"
+	ownerNameLabel      = "observability.shtsukada.dev/owner-name"
+)
+
+// agentServiceAccountName は agent の Pod が使う ServiceAccount。
+// k8sattributes や scrape に権限を与えるため、指定が無ければ専用のものを作る。
+func agentServiceAccountName(oc *observabilityv1beta1.ObservabilityConfig) string {
+	if name := agentSpec(oc).ServiceAccountName; name != "" || (oc.Spec.KubernetesAttributes == nil && len(oc.Spec.Scrape) == 0) {
+		return name
+	}
+	return oc.Name + "-oc-agent"
+}
+
+// k8sAttributesRoleName は namespace スコープの Role/RoleBinding の名前。
+func k8sAttributesRoleName(oc *observabilityv1beta1.ObservabilityConfig) string {
+	return oc.Name + "-oc-k8sattributes"
+}
+
+// scrapeRoleName は spec.scrape の service discovery に使う Role/RoleBinding の名前。
+func scrapeRoleName(oc *observabilityv1beta1.ObservabilityConfig) string {
+	return oc.Name + "-oc-scrape"
+}
+
+// scrapeRules は prometheus receiver の kubernetes_sd (endpoints role) が watch する resource。
+func scrapeRules() []rbacv1.PolicyRule {
+	return []rbacv1.PolicyRule{
+		{APIGroups: []string{""}, Resources: []string{"services", "endpoints", "pods"}, Verbs: []string{"get", "list", "watch"}},
+	}
+}
+
+// k8sAttributesClusterRoleName は cluster 内で一意になるよう namespace を含める。
+// ":" は namespace 名にも ObservabilityConfig 名にも使えないので区切りが曖昧にならない。
+func k8sAttributesClusterRoleName(oc *observabilityv1beta1.ObservabilityConfig) string {
+	return "observability.shtsukada.dev:k8sattributes:" + oc.Namespace + ":" + oc.Name
+}
+
+func k8sAttributesScope(oc *observabilityv1beta1.ObservabilityConfig) observabilityv1beta1.KubernetesAttributesScope {
+	if oc.Spec.KubernetesAttributes == nil {
+		return ""
+	}
+	return oc.Spec.KubernetesAttributes.Scope
+}
+
+// k8sAttributesRules は k8sattributes processor が watch する resource。
+// replicaset は deployment 名、job は cronjob 名を辿るのに使う。
+func k8sAttributesRules(cluster bool) []rbacv1.PolicyRule {
+	read := []string{"get", "list", "watch"}
+	core := []string{"pods"}
+	// namespace の label/annotation は cluster スコープでしか読めない
+	if cluster {
+		core = append(core, "namespaces")
+	}
+	return []rbacv1.PolicyRule{
+		{APIGroups: []string{""}, Resources: core, Verbs: read},
+		{APIGroups: []string{"apps"}, Resources: []string{"replicasets"}, Verbs: read},
+		{APIGroups: []string{"batch"}, Resources: []string{"jobs"}, Verbs: read},
+	}
+}
+
+// applyAgentRBAC は spec.kubernetesAttributes と spec.scrape に必要な ServiceAccount と権限を作成し、
+// 使わなくなった分を削除する。
+func (r *ObservabilityConfigReconciler) applyAgentRBAC(ctx context.Context, oc *observabilityv1beta1.ObservabilityConfig) error {
+	labels := agentLabels(oc)
+	scope := k8sAttributesScope(oc)
+	sa := &corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Name: oc.Name + "-oc-agent", Namespace: oc.Namespace}}
+	if agentServiceAccountName(oc) == sa.Name {
+		if _, err := controllerutil.CreateOrPatch(ctx, r.Client, sa, func() error {
+			sa.Labels = labels
+			return controllerutil.SetControllerReference(oc, sa, r.Scheme)
+		}); err != nil {
+			return err
+		}
+	} else if err := r.deleteOwned(ctx, oc, sa); err != nil {
+		return err
+	}
+	subjects := []rbacv1.Subject{{Kind: rbacv1.ServiceAccountKind, Name: agentServiceAccountName(oc), Namespace: oc.Namespace}}
+	if subjects[0].Name == "" {
+		subjects[0].Name = "default"
+	}
+
+	var rules []rbacv1.PolicyRule
+	if scope == observabilityv1beta1.KubernetesAttributesScopeNamespace {
+		rules = k8sAttributesRules(false)
+	}
+	if err := r.applyAgentRole(ctx, oc, k8sAttributesRoleName(oc), rules, subjects); err != nil {
+		return err
+	}
+	rules = nil
+	if len(oc.Spec.Scrape) > 0 {
+		rules = scrapeRules()
+	}
+	if err := r.applyAgentRole(ctx, oc, scrapeRoleName(oc), rules, subjects); err != nil {
+		return err
+	}
+
+	// cluster スコープの片付けは finalizer を外すときに行う (namespaced な operator では参照もできない)
+	if scope != observabilityv1beta1.KubernetesAttributesScopeCluster {
+		return nil
+	}
+	clusterLabels := map[string]string{ownerNamespaceLabel: oc.Namespace, ownerNameLabel: oc.Name}
+	for k, v := range labels {
+		clusterLabels[k] = v
+	}
+	cr := &rbacv1.ClusterRole{ObjectMeta: metav1.ObjectMeta{Name: k8sAttributesClusterRoleName(oc)}}
+	if _, err := controllerutil.CreateOrPatch(ctx, r.Client, cr, func() error {
+		cr.Labels = clusterLabels
+		cr.Rules = k8sAttributesRules(true)
+		return nil
+	}); err != nil {
+		return err
+	}
+	crb := &rbacv1.ClusterRoleBinding{ObjectMeta: metav1.ObjectMeta{Name: cr.Name}}
+	_, err := controllerutil.CreateOrPatch(ctx, r.Client, crb, func() error {
+		crb.Labels = clusterLabels
+		if crb.ResourceVersion == "" {
+			crb.RoleRef = rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "ClusterRole", Name: cr.Name}
+		}
+		crb.Subjects = subjects
+		return nil
+	})
+	return err
+}
+
+// applyAgentRole は agent に rules を与える Role と RoleBinding を適用する。rules が空なら削除する。
+func (r *ObservabilityConfigReconciler) applyAgentRole(ctx context.Context, oc *observabilityv1beta1.ObservabilityConfig, name string, rules []rbacv1.PolicyRule, subjects []rbacv1.Subject) error {
+	key := metav1.ObjectMeta{Name: name, Namespace: oc.Namespace}
+	role, binding := &rbacv1.Role{ObjectMeta: key}, &rbacv1.RoleBinding{ObjectMeta: key}
+	if len(rules) == 0 {
+		for _, obj := range []client.Object{binding, role} {
+			if err := r.deleteOwned(ctx, oc, obj); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	labels := agentLabels(oc)
+	if _, err := controllerutil.CreateOrPatch(ctx, r.Client, role, func() error {
+		role.Labels = labels
+		role.Rules = rules
+		return controllerutil.SetControllerReference(oc, role, r.Scheme)
+	}); err != nil {
+		return err
+	}
+	_, err := controllerutil.CreateOrPatch(ctx, r.Client, binding, func() error {
+		binding.Labels = labels
+		// roleRef は変更できないので作成時だけ書く
+		if binding.ResourceVersion == "" {
+			binding.RoleRef = rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "Role", Name: role.Name}
+		}
+		binding.Subjects = subjects
+		return controllerutil.SetControllerReference(oc, binding, r.Scheme)
+	})
+	return err
+}
+
+// deleteClusterRBAC は oc のために作った ClusterRoleBinding と ClusterRole を削除する。
+// 同名でも別の ObservabilityConfig のもの (label が違うもの) には触らない。
+func (r *ObservabilityConfigReconciler) deleteClusterRBAC(ctx context.Context, oc *observabilityv1beta1.ObservabilityConfig) error {
+	name := k8sAttributesClusterRoleName(oc)
+	for _, obj := range []client.Object{&rbacv1.ClusterRoleBinding{}, &rbacv1.ClusterRole{}} {
+		if err := r.Get(ctx, client.ObjectKey{Name: name}, obj); err != nil {
+			if client.IgnoreNotFound(err) != nil {
+				return err
+			}
+			continue
+		}
+		l := obj.GetLabels()
+		if l[ownerNamespaceLabel] != oc.Namespace || l[ownerNameLabel] != oc.Name {
+			continue
+		}
+		if err := r.Delete(ctx, obj); client.IgnoreNotFound(err) != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ensureAgentRBACFinalizer は cluster スコープの権限を持つ間だけ finalizer を付ける。
+// 外すときは先に権限を片付けてから外す。
+func (r *ObservabilityConfigReconciler) ensureAgentRBACFinalizer(ctx context.Context, oc *observabilityv1beta1.ObservabilityConfig) error {
+	// 既定値の適用前なので scope 未指定も Cluster として扱う
+	k := oc.Spec.KubernetesAttributes
+	want := k != nil && k.Scope != observabilityv1beta1.KubernetesAttributesScopeNamespace
+	if want == controllerutil.ContainsFinalizer(oc, agentRBACFinalizer) {
+		return nil
+	}
+	patch := client.MergeFrom(oc.DeepCopy())
+	if want {
+		controllerutil.AddFinalizer(oc, agentRBACFinalizer)
+	} else {
+		if err := r.deleteClusterRBAC(ctx, oc); err != nil {
+			return err
+		}
+		controllerutil.RemoveFinalizer(oc, agentRBACFinalizer)
+	}
+	return r.Patch(ctx, oc, patch)
+}
+
+// finalizeAgentRBAC は削除中の ObservabilityConfig の cluster スコープの権限を削除する。
+func (r *ObservabilityConfigReconciler) finalizeAgentRBAC(ctx context.Context, oc *observabilityv1beta1.ObservabilityConfig) error {
+	if !controllerutil.ContainsFinalizer(oc, agentRBACFinalizer) {
+		return nil
+	}
+	if err := r.deleteClusterRBAC(ctx, oc); err != nil {
+		return err
+	}
+	patch := client.MergeFrom(oc.DeepCopy())
+	controllerutil.RemoveFinalizer(oc, agentRBACFinalizer)
+	return r.Patch(ctx, oc, patch)
+}
@@ -0,0 +1,124 @@
+package controller
+
+import (
+	"context"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	apiv1beta1 "github.com/shtsukada/cloudnative-observability-operator/api/v1beta1"
+)
+
+func TestAgentRBAC(t *testing.T) {
+	oc := testObservabilityConfig()
+	oc.Spec.KubernetesAttributes = &apiv1beta1.KubernetesAttributesSpec{}
+	oc.ApplyDefaults()
+	scheme := newTestScheme(t)
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(oc).Build()
+	r := &ObservabilityConfigReconciler{Client: c, Scheme: scheme}
+	ctx := context.Background()
+	clusterKey := types.NamespacedName{Name: "observability.shtsukada.dev:k8sattributes:default:oc"}
+	nsKey := types.NamespacedName{Namespace: "default", Name: "oc-oc-k8sattributes"}
+	exists := func(obj client.Object, key types.NamespacedName) bool {
+		t.Helper()
+		err := c.Get(ctx, key, obj)
+		if err != nil && !apierrors.IsNotFound(err) {
+			t.Fatal(err)
+		}
+		return err == nil
+	}
+	apply := func() {
+		t.Helper()
+		// finalizer の patch で spec が読み直されるので先に保存しておく
+		if err := c.Update(ctx, oc); err != nil {
+			t.Fatal(err)
+		}
+		if err := r.ensureAgentRBACFinalizer(ctx, oc); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := r.applyDesired(ctx, oc, mustRender(t, oc)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	apply()
+	if !controllerutil.ContainsFinalizer(oc, agentRBACFinalizer) {
+		t.Fatal("finalizer not added for cluster-scoped RBAC")
+	}
+	if !exists(&corev1.ServiceAccount{}, types.NamespacedName{Namespace: "default", Name: "oc-oc-agent"}) {
+		t.Fatal("agent ServiceAccount not created")
+	}
+	var crb rbacv1.ClusterRoleBinding
+	if !exists(&rbacv1.ClusterRole{}, clusterKey) || !exists(&crb, clusterKey) {
+		t.Fatal("ClusterRole/ClusterRoleBinding not created")
+	}
+	if s := crb.Subjects; len(s) != 1 || s[0].Name != "oc-oc-agent" || s[0].Namespace != "default" || crb.RoleRef.Name != clusterKey.Name {
+		t.Fatalf("binding = %+v", crb)
+	}
+	var d appsv1.Deployment
+	if !exists(&d, types.NamespacedName{Namespace: "default", Name: "oc-oc-sidecar"}) || d.Spec.Template.Spec.ServiceAccountName != "oc-oc-agent" {
+		t.Fatalf("agent pods do not run as the bound ServiceAccount: %q", d.Spec.Template.Spec.ServiceAccountName)
+	}
+
+	// Namespace スコープに切り替えると cluster の権限は片付けて Role に移る
+	oc.Spec.KubernetesAttributes.Scope = apiv1beta1.KubernetesAttributesScopeNamespace
+	apply()
+	if controllerutil.ContainsFinalizer(oc, agentRBACFinalizer) {
+		t.Fatal("finalizer kept without cluster-scoped RBAC")
+	}
+	if exists(&rbacv1.ClusterRole{}, clusterKey) || exists(&rbacv1.ClusterRoleBinding{}, clusterKey) {
+		t.Fatal("cluster-scoped RBAC not removed")
+	}
+	var role rbacv1.Role
+	if !exists(&role, nsKey) || !exists(&rbacv1.RoleBinding{}, nsKey) {
+		t.Fatal("Role/RoleBinding not created")
+	}
+	for _, rule := range role.Rules {
+		for _, res := range rule.Resources {
+			if res == "namespaces" {
+				t.Fatal("Role grants cluster-scoped namespaces")
+			}
+		}
+	}
+
+	// 無効にすると専用の ServiceAccount と Role も消える
+	oc.Spec.KubernetesAttributes = nil
+	apply()
+	if exists(&rbacv1.Role{}, nsKey) || exists(&corev1.ServiceAccount{}, types.NamespacedName{Namespace: "default", Name: "oc-oc-agent"}) {
+		t.Fatal("namespaced RBAC not removed")
+	}
+}
+
+func TestFinalizeAgentRBAC(t *testing.T) {
+	oc := testObservabilityConfig()
+	oc.Spec.KubernetesAttributes = &apiv1beta1.KubernetesAttributesSpec{}
+	oc.ApplyDefaults()
+	// 同じ名前でも別の ObservabilityConfig のものは消さない
+	foreign := &rbacv1.ClusterRoleBinding{}
+	foreign.Name = "observability.shtsukada.dev:k8sattributes:default:oc"
+	foreign.Labels = map[string]string{ownerNamespaceLabel: "other", ownerNameLabel: "oc"}
+	scheme := newTestScheme(t)
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(oc, foreign).Build()
+	r := &ObservabilityConfigReconciler{Client: c, Scheme: scheme}
+	ctx := context.Background()
+
+	if err := r.ensureAgentRBACFinalizer(ctx, oc); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.finalizeAgentRBAC(ctx, oc); err != nil {
+		t.Fatal(err)
+	}
+	if controllerutil.ContainsFinalizer(oc, agentRBACFinalizer) {
+		t.Fatal("finalizer not removed")
+	}
+	if err := c.Get(ctx, client.ObjectKeyFromObject(foreign), &rbacv1.ClusterRoleBinding{}); err != nil {
+		t.Fatalf("foreign ClusterRoleBinding was touched: %v", err)
+	}
+}
@@ -0,0 +1,94 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	observabilityv1beta1 "github.com/shtsukada/cloudnative-observability-operator/api/v1beta1"
+	"github.com/shtsukada/cloudnative-observability-operator/internal/reachability"
+	conditions "github.com/shtsukada/cloudnative-observability-operator/internal/shared/conditions"
+)
+
+// DefaultEndpointCheckInterval is how often endpoints are re-checked once an ObservabilityConfig is in sync.
+const DefaultEndpointCheckInterval = time.Minute
+
+// checkEndpoints は exporter の送り先を確認して status.endpoints と EndpointReachable を更新する。
+func (r *ObservabilityConfigReconciler) checkEndpoints(ctx context.Context, oc *observabilityv1beta1.ObservabilityConfig) {
+	if r.Checker == nil {
+		return
+	}
+	targets := reachability.Targets(oc)
+	// status 更新でも Reconcile が走るので、間隔内に確認済みなら結果をそのまま使う
+	if endpointsFresh(oc.Status.Endpoints, targets, time.Now().Add(-r.checkInterval()/2)) {
+		return
+	}
+	owner := oc.Namespace + "/" + oc.Name
+	results := r.Checker.CheckAll(ctx, owner, targets)
+	if len(results) == 0 {
+		oc.Status.Endpoints = nil
+		setCondition(oc, observabilityv1beta1.ConditionEndpointReachable, metav1.ConditionTrue, conditions.ReasonNoEndpoints, "no network exporters configured")
+		return
+	}
+
+	statuses := make([]observabilityv1beta1.EndpointStatus, 0, len(results))
+	var failed []string
+	var slowest time.Duration
+	for _, res := range results {
+		checked := metav1.NewTime(res.CheckedAt)
+		st := observabilityv1beta1.EndpointStatus{
+			Name:          res.Target.Name,
+			Address:       res.Target.Address,
+			Reachable:     res.Reachable,
+			LastCheckTime: &checked,
+		}
+		if res.Reachable {
+			st.Latency = &metav1.Duration{Duration: res.Latency.Round(time.Millisecond)}
+			slowest = max(slowest, res.Latency)
+		} else {
+			st.LastError = truncate(res.Err.Error(), 1024)
+			failed = append(failed, fmt.Sprintf("%s: %s", res.Target.Name, st.LastError))
+		}
+		statuses = append(statuses, st)
+	}
+	oc.Status.Endpoints = statuses
+
+	if len(failed) > 0 {
+		msg := fmt.Sprintf("%d/%d endpoints unreachable: %s", len(failed), len(results), strings.Join(failed, "; "))
+		setCondition(oc, observabilityv1beta1.ConditionEndpointReachable, metav1.ConditionFalse, conditions.ReasonEndpointUnreachable, truncate(msg, 32768))
+		return
+	}
+	msg := fmt.Sprintf("%d endpoints reachable, max latency %s", len(results), slowest.Round(time.Millisecond))
+	setCondition(oc, observabilityv1beta1.ConditionEndpointReachable, metav1.ConditionTrue, conditions.ReasonEndpointReachable, msg)
+}
+
+// endpointsFresh は status が同じ送り先を since 以降に確認済みかを返す。
+func endpointsFresh(statuses []observabilityv1beta1.EndpointStatus, targets []reachability.Target, since time.Time) bool {
+	if len(statuses) == 0 || len(statuses) != len(targets) {
+		return false
+	}
+	for i, st := range statuses {
+		if st.Name != targets[i].Name || st.Address != targets[i].Address ||
+			st.LastCheckTime == nil || st.LastCheckTime.Time.Before(since) {
+			return false
+		}
+	}
+	return true
+}
+
+func (r *ObservabilityConfigReconciler) checkInterval() time.Duration {
+	if r.CheckInterval > 0 {
+		return r.CheckInterval
+	}
+	return DefaultEndpointCheckInterval
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n]
+}
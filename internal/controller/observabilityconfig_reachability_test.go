@@ -0,0 +1,75 @@
+package controller
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	apiv1beta1 "github.com/shtsukada/cloudnative-observability-operator/api/v1beta1"
+	"github.com/shtsukada/cloudnative-observability-operator/internal/reachability"
+)
+
+func TestCheckEndpointsSetsCondition(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = lis.Close() }()
+	go func() {
+		for {
+			conn, err := lis.Accept()
+			if err != nil {
+				return
+			}
+			_ = conn.Close()
+		}
+	}()
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = closed.Close()
+
+	oc := testObservabilityConfig()
+	oc.Spec.Endpoints = []apiv1beta1.Endpoint{apiv1beta1.Endpoint(lis.Addr().String())}
+	r := &ObservabilityConfigReconciler{Checker: reachability.NewChecker(false)}
+	ctx := context.Background()
+
+	r.checkEndpoints(ctx, oc)
+	cond := apimeta.FindStatusCondition(oc.Status.Conditions, apiv1beta1.ConditionEndpointReachable)
+	if cond == nil || cond.Status != metav1.ConditionTrue {
+		t.Fatalf("condition = %+v", cond)
+	}
+	if len(oc.Status.Endpoints) != 1 || !oc.Status.Endpoints[0].Reachable || oc.Status.Endpoints[0].Latency == nil {
+		t.Fatalf("endpoints = %+v", oc.Status.Endpoints)
+	}
+
+	// 送り先が変わったら間隔内でも確認し直す
+	oc.Spec.Endpoints = append(oc.Spec.Endpoints, apiv1beta1.Endpoint(closed.Addr().String()))
+	r.checkEndpoints(ctx, oc)
+	cond = apimeta.FindStatusCondition(oc.Status.Conditions, apiv1beta1.ConditionEndpointReachable)
+	if cond.Status != metav1.ConditionFalse || !strings.HasPrefix(cond.Message, "1/2 endpoints unreachable: endpoint-1: dial") {
+		t.Fatalf("condition = %+v", cond)
+	}
+	if st := oc.Status.Endpoints[1]; st.Reachable || st.LastError == "" || st.Latency != nil {
+		t.Fatalf("endpoint-1 = %+v", st)
+	}
+
+	// 間隔内に確認済みなら前回の結果を使う
+	checked := oc.Status.Endpoints[0].LastCheckTime
+	r.checkEndpoints(ctx, oc)
+	if oc.Status.Endpoints[0].LastCheckTime != checked {
+		t.Fatal("endpoints re-checked within the interval")
+	}
+	stale := metav1.NewTime(time.Now().Add(-time.Hour))
+	oc.Status.Endpoints[0].LastCheckTime = &stale
+	r.checkEndpoints(ctx, oc)
+	if oc.Status.Endpoints[0].LastCheckTime == &stale {
+		t.Fatal("stale endpoints were not re-checked")
+	}
+}
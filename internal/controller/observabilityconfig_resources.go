@@ -0,0 +1,73 @@
+package controller
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	observabilityv1beta1 "github.com/shtsukada/cloudnative-observability-operator/api/v1beta1"
+	"github.com/shtsukada/cloudnative-observability-operator/internal/collector"
+	"github.com/shtsukada/cloudnative-observability-operator/internal/shared/otelenv"
+)
+
+const configHashAnnotation = "observability.shtsukada.dev/config-hash"
+
+func envConfigMapName(oc *observabilityv1beta1.ObservabilityConfig) string {
+	return oc.Name + "-oc-env"
+}
+
+// desiredConfigMap は collector の設定を ConfigMap に描画する。
+func desiredConfigMap(oc *observabilityv1beta1.ObservabilityConfig, cfg *collector.Config) (*corev1.ConfigMap, error) {
+	data, err := cfg.YAML()
+	if err != nil {
+		return nil, err
+	}
+	sidecar, err := cfg.SidecarYAML()
+	if err != nil {
+		return nil, err
+	}
+	return &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      collector.ConfigMapName(oc),
+			Namespace: oc.Namespace,
+			Labels:    agentLabels(oc),
+		},
+		Data: map[string]string{collector.ConfigKey: string(data), collector.SidecarConfigKey: string(sidecar)},
+	}, nil
+}
+
+// agentEndpoint はアプリが agent の Service に送るための OTLP/gRPC endpoint。
+func agentEndpoint(oc *observabilityv1beta1.ObservabilityConfig) string {
+	return fmt.Sprintf("http://%s-oc.%s.svc:%d", oc.Name, oc.Namespace, collector.OTLPGRPCPort)
+}
+
+// desiredEnvConfigMap は agent へ送る側のアプリが envFrom で読む OTEL_* を用意する。
+// spec.samplingPercent は agent 側だけで効かせるので、サンプラーは全て送る設定で固定する。
+func desiredEnvConfigMap(oc *observabilityv1beta1.ObservabilityConfig) *corev1.ConfigMap {
+	data := collector.SamplerEnv()
+	data[otelenv.Endpoint] = agentEndpoint(oc)
+	return &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      envConfigMapName(oc),
+			Namespace: oc.Namespace,
+			Labels:    agentLabels(oc),
+		},
+		Data: data,
+	}
+}
+
+// configHash は描画済みの設定内容をハッシュ化する
+func configHash(cm *corev1.ConfigMap) string {
+	sum := sha256.Sum256([]byte(cm.Data[collector.ConfigKey]))
+	return hex.EncodeToString(sum[:])[:16]
+}
+
+func (r *ObservabilityConfigReconciler) defaultAgentImage() string {
+	if r.DefaultAgentImage != "" {
+		return r.DefaultAgentImage
+	}
+	return collector.DefaultImage
+}
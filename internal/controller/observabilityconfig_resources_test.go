@@ -0,0 +1,175 @@
+package controller
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	apiv1beta1 "github.com/shtsukada/cloudnative-observability-operator/api/v1beta1"
+	"github.com/shtsukada/cloudnative-observability-operator/internal/collector"
+)
+
+func testObservabilityConfig() *apiv1beta1.ObservabilityConfig {
+	oc := &apiv1beta1.ObservabilityConfig{
+		ObjectMeta: metav1.ObjectMeta{Name: "oc", Namespace: "default", UID: "oc-uid"},
+		Spec: apiv1beta1.ObservabilityConfigSpec{
+			Endpoints: []apiv1beta1.Endpoint{"otel-collector.monitoring:4317"},
+		},
+	}
+	oc.ApplyDefaults()
+	return oc
+}
+
+func TestApplyDesiredRendersCollectorConfig(t *testing.T) {
+	oc := testObservabilityConfig()
+	scheme := newTestScheme(t)
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(oc).Build()
+	r := &ObservabilityConfigReconciler{Client: c, Scheme: scheme}
+	ctx := context.Background()
+
+	if _, err := r.applyDesired(ctx, oc, mustRender(t, oc)); err != nil {
+		t.Fatal(err)
+	}
+
+	var cm corev1.ConfigMap
+	if err := c.Get(ctx, types.NamespacedName{Namespace: "default", Name: "oc-oc-config"}, &cm); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(cm.Data[collector.ConfigKey], "otel-collector.monitoring:4317") {
+		t.Fatalf("exporter endpoint missing from config:\n%s", cm.Data[collector.ConfigKey])
+	}
+	if len(cm.OwnerReferences) != 1 || cm.OwnerReferences[0].UID != oc.UID {
+		t.Fatalf("ConfigMap is not owned by the ObservabilityConfig: %v", cm.OwnerReferences)
+	}
+
+	var dep appsv1.Deployment
+	key := types.NamespacedName{Namespace: "default", Name: "oc-oc-sidecar"}
+	if err := c.Get(ctx, key, &dep); err != nil {
+		t.Fatal(err)
+	}
+	pod := dep.Spec.Template.Spec
+	if pod.Volumes[0].ConfigMap.Name != cm.Name || pod.Containers[0].VolumeMounts[0].MountPath != collector.ConfigMountPath {
+		t.Fatalf("config is not mounted: %+v", pod)
+	}
+	hash := dep.Spec.Template.Annotations[configHashAnnotation]
+	if hash == "" {
+		t.Fatal("config hash annotation missing")
+	}
+
+	// 変更がなければ何も更新しない
+	changed, err := r.applyDesired(ctx, oc, mustRender(t, oc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if changed {
+		t.Fatal("unchanged spec reported a change")
+	}
+
+	// 設定が変われば Pod テンプレートも変わる
+	oc.Spec.Endpoints = []apiv1beta1.Endpoint{"otel-collector.other:4317"}
+	if _, err := r.applyDesired(ctx, oc, mustRender(t, oc)); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Get(ctx, key, &dep); err != nil {
+		t.Fatal(err)
+	}
+	if dep.Spec.Template.Annotations[configHashAnnotation] == hash {
+		t.Fatal("config hash did not change with the config")
+	}
+}
+
+func TestApplyDesiredPublishesSamplerEnv(t *testing.T) {
+	oc := testObservabilityConfig()
+	oc.Spec.SamplingPercent = ptr.To(int32(25))
+	scheme := newTestScheme(t)
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(oc).Build()
+	r := &ObservabilityConfigReconciler{Client: c, Scheme: scheme}
+
+	if _, err := r.applyDesired(context.Background(), oc, mustRender(t, oc)); err != nil {
+		t.Fatal(err)
+	}
+	var cm corev1.ConfigMap
+	if err := c.Get(context.Background(), types.NamespacedName{Namespace: "default", Name: "oc-oc-env"}, &cm); err != nil {
+		t.Fatal(err)
+	}
+	if cm.Data["OTEL_TRACES_SAMPLER"] != "parentbased_always_on" || cm.Data["OTEL_TRACES_SAMPLER_ARG"] != "" {
+		t.Fatalf("unexpected sampler env: %v", cm.Data)
+	}
+	if cm.Data["OTEL_EXPORTER_OTLP_ENDPOINT"] != "http://oc-oc.default.svc:4317" {
+		t.Fatalf("unexpected endpoint: %v", cm.Data)
+	}
+}
+
+func mustRender(t *testing.T, oc *apiv1beta1.ObservabilityConfig) *collector.Config {
+	t.Helper()
+	cfg, err := collector.Render(oc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cfg
+}
+
+func TestDesiredDeploymentAgent(t *testing.T) {
+	oc := testObservabilityConfig()
+	oc.Spec.Agent = &apiv1beta1.AgentSpec{
+		Replicas:           ptr.To(int32(3)),
+		NodeSelector:       map[string]string{"kubernetes.io/os": "linux"},
+		Tolerations:        []corev1.Toleration{{Key: "dedicated", Operator: corev1.TolerationOpExists}},
+		ServiceAccountName: "otel-agent",
+		ExtraArgs:          []string{"--feature-gates=+foo"},
+	}
+	r := &ObservabilityConfigReconciler{DefaultAgentImage: "example.com/otelcol:1.0.0"}
+
+	dep := r.desiredDeployment(oc, mustRender(t, oc), "hash")
+	pod := dep.Spec.Template.Spec
+	if *dep.Spec.Replicas != 3 || pod.ServiceAccountName != "otel-agent" || len(pod.Tolerations) != 1 || pod.NodeSelector["kubernetes.io/os"] != "linux" {
+		t.Fatalf("agent settings not applied: %+v", dep.Spec)
+	}
+	c := pod.Containers[0]
+	if c.Image != "example.com/otelcol:1.0.0" {
+		t.Fatalf("image = %s", c.Image)
+	}
+	if len(c.Args) != 2 || c.Args[1] != "--feature-gates=+foo" {
+		t.Fatalf("args = %v", c.Args)
+	}
+}
+
+func TestDesiredDaemonSetPodLogs(t *testing.T) {
+	oc := testObservabilityConfig()
+	oc.Spec.Mode = apiv1beta1.AgentModeDaemonSet
+	oc.Spec.Agent.Tolerations = []corev1.Toleration{{Key: "dedicated", Operator: corev1.TolerationOpExists}}
+	oc.Spec.Logs = &apiv1beta1.LogsSpec{}
+	oc.ApplyDefaults()
+	r := &ObservabilityConfigReconciler{}
+
+	pod := r.desiredDaemonSet(oc, mustRender(t, oc), "hash").Spec.Template.Spec
+	if len(pod.Volumes) != 2 || pod.Volumes[1].HostPath == nil || pod.Volumes[1].HostPath.Path != "/var/log/pods" {
+		t.Fatalf("volumes = %+v", pod.Volumes)
+	}
+	c := pod.Containers[0]
+	if m := c.VolumeMounts[len(c.VolumeMounts)-1]; m.MountPath != "/var/log/pods" || !m.ReadOnly {
+		t.Fatalf("mounts = %+v", c.VolumeMounts)
+	}
+	if sc := c.SecurityContext; sc == nil || *sc.RunAsUser != 0 || !*sc.ReadOnlyRootFilesystem || *sc.AllowPrivilegeEscalation {
+		t.Fatalf("securityContext = %+v", sc)
+	}
+	if len(pod.Tolerations) != 2 || pod.Tolerations[1].Operator != corev1.TolerationOpExists || pod.Tolerations[1].Key != "" {
+		t.Fatalf("tolerations = %+v", pod.Tolerations)
+	}
+	// spec の tolerations は書き換えない
+	if len(oc.Spec.Agent.Tolerations) != 1 {
+		t.Fatalf("spec tolerations modified: %+v", oc.Spec.Agent.Tolerations)
+	}
+
+	oc.Spec.Logs.TolerateAllTaints = ptr.To(false)
+	if pod := r.desiredDaemonSet(oc, mustRender(t, oc), "hash").Spec.Template.Spec; len(pod.Tolerations) != 1 {
+		t.Fatalf("tolerations = %+v", pod.Tolerations)
+	}
+}
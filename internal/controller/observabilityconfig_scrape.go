@@ -0,0 +1,69 @@
+package controller
+
+import (
+	"context"
+	"slices"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	klabels "k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/selection"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	observabilityv1beta1 "github.com/shtsukada/cloudnative-observability-operator/api/v1beta1"
+	"github.com/shtsukada/cloudnative-observability-operator/internal/collector"
+)
+
+// scrapeTargets は spec.scrape の selector を Service の label selector に解決する。
+// GrpcBurner の label は Service に付かないので、選ばれた GrpcBurner の名前で Service を絞る。
+// どの GrpcBurner も選ばない job は scrape するものが無いので除く。
+func (r *ObservabilityConfigReconciler) scrapeTargets(ctx context.Context, oc *observabilityv1beta1.ObservabilityConfig) ([]collector.ScrapeTarget, error) {
+	var targets []collector.ScrapeTarget
+	for _, job := range oc.Spec.Scrape {
+		sel, err := metav1.LabelSelectorAsSelector(&job.Selector)
+		if err != nil {
+			// validation で弾いているので、ここには来ない
+			return nil, err
+		}
+		if job.Kind == observabilityv1beta1.ScrapeService {
+			targets = append(targets, collector.ScrapeTarget{Job: job, ServiceSelector: sel.String()})
+			continue
+		}
+		var burners observabilityv1beta1.GrpcBurnerList
+		if err := r.List(ctx, &burners, client.InNamespace(oc.Namespace), client.MatchingLabelsSelector{Selector: sel}); err != nil {
+			return nil, err
+		}
+		names := make([]string, 0, len(burners.Items))
+		for i := range burners.Items {
+			names = append(names, burners.Items[i].Name)
+		}
+		if len(names) == 0 {
+			continue
+		}
+		slices.Sort(names)
+		// grpcburner_resources.go の labels と同じキー
+		name, err := klabels.NewRequirement("app.kubernetes.io/name", selection.Equals, []string{"grpcburner"})
+		if err != nil {
+			return nil, err
+		}
+		instance, err := klabels.NewRequirement("app.kubernetes.io/instance", selection.In, names)
+		if err != nil {
+			return nil, err
+		}
+		targets = append(targets, collector.ScrapeTarget{Job: job, ServiceSelector: klabels.NewSelector().Add(*name, *instance).String()})
+	}
+	return targets, nil
+}
+
+// grpcBurnerToObservabilityConfigs は GrpcBurner の作成・削除・label の変化を同じ namespace で scrape しているものに伝える。
+func (r *ObservabilityConfigReconciler) grpcBurnerToObservabilityConfigs(ctx context.Context, obj client.Object) []reconcile.Request {
+	var list observabilityv1beta1.ObservabilityConfigList
+	if err := r.List(ctx, &list, client.InNamespace(obj.GetNamespace())); err != nil {
+		return nil
+	}
+	return inheritingRequests(list.Items, func(oc *observabilityv1beta1.ObservabilityConfig) bool {
+		return slices.ContainsFunc(oc.Spec.Scrape, func(j observabilityv1beta1.ScrapeJob) bool {
+			return j.Kind != observabilityv1beta1.ScrapeService
+		})
+	})
+}
@@ -0,0 +1,88 @@
+package controller
+
+import (
+	"context"
+	"testing"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	apiv1beta1 "github.com/shtsukada/cloudnative-observability-operator/api/v1beta1"
+)
+
+func TestScrapeTargets(t *testing.T) {
+	oc := testObservabilityConfig()
+	oc.Spec.Scrape = []apiv1beta1.ScrapeJob{
+		{Name: "burners", Port: "metrics", Selector: metav1.LabelSelector{MatchLabels: map[string]string{"team": "a"}}},
+		{Name: "none", Port: "metrics", Selector: metav1.LabelSelector{MatchLabels: map[string]string{"team": "z"}}},
+		{Name: "apps", Kind: apiv1beta1.ScrapeService, Port: "http-metrics", Selector: metav1.LabelSelector{MatchLabels: map[string]string{"app": "web"}}},
+	}
+	oc.ApplyDefaults()
+	burner := func(ns, name, team string) *apiv1beta1.GrpcBurner {
+		return &apiv1beta1.GrpcBurner{ObjectMeta: metav1.ObjectMeta{Namespace: ns, Name: name, Labels: map[string]string{"team": team}}}
+	}
+	scheme := newTestScheme(t)
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(oc,
+		burner("default", "b2", "a"), burner("default", "b1", "a"), burner("default", "other", "b"), burner("elsewhere", "b3", "a"),
+	).Build()
+	r := &ObservabilityConfigReconciler{Client: c, Scheme: scheme}
+	ctx := context.Background()
+
+	targets, err := r.scrapeTargets(ctx, oc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 何も選ばない job は除く
+	if len(targets) != 2 {
+		t.Fatalf("targets = %+v", targets)
+	}
+	if got := targets[0].ServiceSelector; got != "app.kubernetes.io/instance in (b1,b2),app.kubernetes.io/name=grpcburner" {
+		t.Fatalf("GrpcBurner selector = %q", got)
+	}
+	if got := targets[1].ServiceSelector; targets[1].Job.Name != "apps" || got != "app=web" {
+		t.Fatalf("Service selector = %q", got)
+	}
+
+	if reqs := r.grpcBurnerToObservabilityConfigs(ctx, burner("default", "new", "a")); len(reqs) != 1 {
+		t.Fatalf("requests = %v", reqs)
+	}
+	if reqs := r.grpcBurnerToObservabilityConfigs(ctx, burner("elsewhere", "new", "a")); len(reqs) != 0 {
+		t.Fatalf("requests = %v", reqs)
+	}
+}
+
+func TestApplyScrapeRBAC(t *testing.T) {
+	oc := testObservabilityConfig()
+	oc.Spec.Scrape = []apiv1beta1.ScrapeJob{{Name: "burners", Port: "metrics"}}
+	oc.ApplyDefaults()
+	scheme := newTestScheme(t)
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(oc).Build()
+	r := &ObservabilityConfigReconciler{Client: c, Scheme: scheme}
+	ctx := context.Background()
+	key := types.NamespacedName{Namespace: "default", Name: "oc-oc-scrape"}
+
+	if _, err := r.applyDesired(ctx, oc, mustRender(t, oc)); err != nil {
+		t.Fatal(err)
+	}
+	var binding rbacv1.RoleBinding
+	if err := c.Get(ctx, key, &rbacv1.Role{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Get(ctx, key, &binding); err != nil {
+		t.Fatal(err)
+	}
+	if s := binding.Subjects; len(s) != 1 || s[0].Name != "oc-oc-agent" {
+		t.Fatalf("subjects = %+v", s)
+	}
+
+	oc.Spec.Scrape = nil
+	if _, err := r.applyDesired(ctx, oc, mustRender(t, oc)); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Get(ctx, key, &rbacv1.Role{}); !apierrors.IsNotFound(err) {
+		t.Fatalf("scrape Role not removed: %v", err)
+	}
+}
@@ -0,0 +1,120 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	observabilityv1beta1 "github.com/shtsukada/cloudnative-observability-operator/api/v1beta1"
+	conditions "github.com/shtsukada/cloudnative-observability-operator/internal/shared/conditions"
+)
+
+// agentRollout は agent workload の status から読み取ったロールアウトの状態。
+type agentRollout struct {
+	desired   int32
+	ready     int32
+	available int32
+	// complete は最新の spec が観測され、全 replica が更新済みかつ利用可能なこと。
+	complete bool
+	// failed は Deployment が progressDeadlineSeconds を超えたときの理由。
+	failed string
+}
+
+func (a agentRollout) String() string {
+	return fmt.Sprintf("ready %d/%d, available %d", a.ready, a.desired, a.available)
+}
+
+// agentRollout は現在のモードの workload を読み、ロールアウトの状態を返す。
+// キャッシュにまだ載っていない workload は replica 0 として扱う。
+func (r *ObservabilityConfigReconciler) agentRollout(ctx context.Context, oc *observabilityv1beta1.ObservabilityConfig) (agentRollout, error) {
+	key := client.ObjectKey{Name: agentWorkloadName(oc), Namespace: oc.Namespace}
+	switch oc.Spec.Mode {
+	case observabilityv1beta1.AgentModeDaemonSet:
+		var ds appsv1.DaemonSet
+		if err := r.Get(ctx, key, &ds); err != nil {
+			return agentRollout{}, client.IgnoreNotFound(err)
+		}
+		return daemonSetRollout(&ds), nil
+	case observabilityv1beta1.AgentModeStatefulSet:
+		var sts appsv1.StatefulSet
+		if err := r.Get(ctx, key, &sts); err != nil {
+			return agentRollout{}, client.IgnoreNotFound(err)
+		}
+		return statefulSetRollout(&sts), nil
+	default:
+		var d appsv1.Deployment
+		if err := r.Get(ctx, key, &d); err != nil {
+			return agentRollout{}, client.IgnoreNotFound(err)
+		}
+		return deploymentRollout(&d), nil
+	}
+}
+
+func deploymentRollout(d *appsv1.Deployment) agentRollout {
+	a := agentRollout{
+		desired:   ptr.Deref(d.Spec.Replicas, 1),
+		ready:     d.Status.ReadyReplicas,
+		available: d.Status.AvailableReplicas,
+		complete:  deploymentComplete(d),
+	}
+	for _, c := range d.Status.Conditions {
+		if c.Type == appsv1.DeploymentProgressing && c.Status == corev1.ConditionFalse && c.Reason == conditions.ReasonProgressDeadlineExceeded {
+			a.failed = conditions.ReasonProgressDeadlineExceeded
+		}
+	}
+	return a
+}
+
+func daemonSetRollout(ds *appsv1.DaemonSet) agentRollout {
+	want := ds.Status.DesiredNumberScheduled
+	return agentRollout{
+		desired:   want,
+		ready:     ds.Status.NumberReady,
+		available: ds.Status.NumberAvailable,
+		// 対象ノードが無い DaemonSet は agent が動いていないので完了扱いにしない
+		complete: want > 0 &&
+			ds.Status.ObservedGeneration >= ds.Generation &&
+			ds.Status.UpdatedNumberScheduled == want &&
+			ds.Status.NumberAvailable == want,
+	}
+}
+
+func statefulSetRollout(sts *appsv1.StatefulSet) agentRollout {
+	want := ptr.Deref(sts.Spec.Replicas, 1)
+	return agentRollout{
+		desired:   want,
+		ready:     sts.Status.ReadyReplicas,
+		available: sts.Status.AvailableReplicas,
+		complete: sts.Status.ObservedGeneration >= sts.Generation &&
+			sts.Status.UpdatedReplicas == want &&
+			sts.Status.ReadyReplicas == want &&
+			sts.Status.AvailableReplicas == want,
+	}
+}
+
+// setRolloutStatus は ReadyReplicas と Available/Ready を workload の状態から決める。
+// ロールアウト中なら true を返す。
+func setRolloutStatus(oc *observabilityv1beta1.ObservabilityConfig, a agentRollout) bool {
+	oc.Status.ReadyReplicas = a.ready
+	if a.available > 0 {
+		setCondition(oc, observabilityv1beta1.ConditionAvailable, metav1.ConditionTrue, conditions.ReasonDeploymentAvailable, a.String())
+	} else {
+		setCondition(oc, observabilityv1beta1.ConditionAvailable, metav1.ConditionFalse, conditions.ReasonDeploymentUnavailable, a.String())
+	}
+
+	switch {
+	case a.failed != "":
+		setDegraded(oc, a.failed, "agent rollout failed: "+a.String())
+		return false
+	case !a.complete:
+		setProgressing(oc, conditions.ReasonWaitingForDeployment, "waiting for agent rollout: "+a.String())
+		return true
+	}
+	setReady(oc, conditions.ReasonDeploymentAvailable, "agent rollout complete: "+a.String())
+	return false
+}
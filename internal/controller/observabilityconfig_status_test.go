@@ -0,0 +1,166 @@
+package controller
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	apiv1beta1 "github.com/shtsukada/cloudnative-observability-operator/api/v1beta1"
+	"github.com/shtsukada/cloudnative-observability-operator/internal/collector"
+	conditions "github.com/shtsukada/cloudnative-observability-operator/internal/shared/conditions"
+)
+
+func TestReconcileReadyFollowsAgentRollout(t *testing.T) {
+	oc := testObservabilityConfig()
+	oc.Generation = 1
+	scheme := newTestScheme(t)
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(oc).WithStatusSubresource(oc, &appsv1.Deployment{}).Build()
+	r := &ObservabilityConfigReconciler{Client: c, Scheme: scheme, Recorder: record.NewFakeRecorder(32)}
+	ctx := context.Background()
+	req := reconcile.Request{NamespacedName: types.NamespacedName{Namespace: "default", Name: "oc"}}
+
+	reconcileOC := func() *apiv1beta1.ObservabilityConfig {
+		t.Helper()
+		if _, err := r.Reconcile(ctx, req); err != nil {
+			t.Fatal(err)
+		}
+		var got apiv1beta1.ObservabilityConfig
+		if err := c.Get(ctx, req.NamespacedName, &got); err != nil {
+			t.Fatal(err)
+		}
+		return &got
+	}
+	expect := func(got *apiv1beta1.ObservabilityConfig, condType string, status metav1.ConditionStatus, reason string) {
+		t.Helper()
+		cond := apimeta.FindStatusCondition(got.Status.Conditions, condType)
+		if cond == nil || cond.Status != status || cond.Reason != reason {
+			t.Fatalf("%s = %+v, want %s/%s", condType, cond, status, reason)
+		}
+	}
+
+	// 適用直後は Pod が無いので Ready にはならない
+	got := reconcileOC()
+	expect(got, apiv1beta1.ConditionReady, metav1.ConditionFalse, conditions.ReasonWaitingForDeployment)
+	expect(got, apiv1beta1.ConditionProgressing, metav1.ConditionTrue, conditions.ReasonWaitingForDeployment)
+	expect(got, apiv1beta1.ConditionAvailable, metav1.ConditionFalse, conditions.ReasonDeploymentUnavailable)
+	if got.Status.Phase != "Reconciling" || got.Status.ReadyReplicas != 0 {
+		t.Fatalf("status = %+v", got.Status)
+	}
+
+	var d appsv1.Deployment
+	if err := c.Get(ctx, types.NamespacedName{Namespace: "default", Name: "oc-oc-sidecar"}, &d); err != nil {
+		t.Fatal(err)
+	}
+	// 古い世代しか観測していない間は利用可能でも完了扱いにしない
+	d.Status = appsv1.DeploymentStatus{ObservedGeneration: d.Generation - 1, Replicas: 1, UpdatedReplicas: 1, ReadyReplicas: 1, AvailableReplicas: 1}
+	if err := c.Status().Update(ctx, &d); err != nil {
+		t.Fatal(err)
+	}
+	got = reconcileOC()
+	expect(got, apiv1beta1.ConditionReady, metav1.ConditionFalse, conditions.ReasonWaitingForDeployment)
+	expect(got, apiv1beta1.ConditionAvailable, metav1.ConditionTrue, conditions.ReasonDeploymentAvailable)
+
+	d.Status.ObservedGeneration = d.Generation
+	if err := c.Status().Update(ctx, &d); err != nil {
+		t.Fatal(err)
+	}
+	got = reconcileOC()
+	expect(got, apiv1beta1.ConditionReady, metav1.ConditionTrue, conditions.ReasonDeploymentAvailable)
+	expect(got, apiv1beta1.ConditionProgressing, metav1.ConditionFalse, "Stable")
+	expect(got, apiv1beta1.ConditionDegraded, metav1.ConditionFalse, conditions.ReasonDeploymentAvailable)
+	if got.Status.Phase != "Ready" || got.Status.ReadyReplicas != 1 || got.Status.ObservedGeneration != 1 {
+		t.Fatalf("status = %+v", got.Status)
+	}
+
+	d.Status = appsv1.DeploymentStatus{
+		ObservedGeneration:  d.Generation,
+		Replicas:            1,
+		UnavailableReplicas: 1,
+		Conditions: []appsv1.DeploymentCondition{{
+			Type:   appsv1.DeploymentProgressing,
+			Status: corev1.ConditionFalse,
+			Reason: conditions.ReasonProgressDeadlineExceeded,
+		}},
+	}
+	if err := c.Status().Update(ctx, &d); err != nil {
+		t.Fatal(err)
+	}
+	got = reconcileOC()
+	expect(got, apiv1beta1.ConditionDegraded, metav1.ConditionTrue, conditions.ReasonProgressDeadlineExceeded)
+	expect(got, apiv1beta1.ConditionReady, metav1.ConditionFalse, conditions.ReasonProgressDeadlineExceeded)
+	if got.Status.Phase != "Error" {
+		t.Fatalf("phase = %s", got.Status.Phase)
+	}
+}
+
+func TestDaemonSetRollout(t *testing.T) {
+	ds := &appsv1.DaemonSet{ObjectMeta: metav1.ObjectMeta{Generation: 2}}
+	if daemonSetRollout(ds).complete {
+		t.Fatal("DaemonSet without scheduled nodes reported complete")
+	}
+	ds.Status = appsv1.DaemonSetStatus{ObservedGeneration: 2, DesiredNumberScheduled: 3, UpdatedNumberScheduled: 2, NumberReady: 3, NumberAvailable: 3}
+	if daemonSetRollout(ds).complete {
+		t.Fatal("DaemonSet with a pending update reported complete")
+	}
+	ds.Status.UpdatedNumberScheduled = 3
+	if a := daemonSetRollout(ds); !a.complete || a.ready != 3 {
+		t.Fatalf("rollout = %+v", a)
+	}
+}
+
+func TestReconcileKeepsLastGoodConfig(t *testing.T) {
+	oc := testObservabilityConfig()
+	scheme := newTestScheme(t)
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(oc).WithStatusSubresource(oc).Build()
+	r := &ObservabilityConfigReconciler{Client: c, Scheme: scheme, Recorder: record.NewFakeRecorder(32)}
+	ctx := context.Background()
+	req := reconcile.Request{NamespacedName: types.NamespacedName{Namespace: "default", Name: "oc"}}
+
+	if _, err := r.Reconcile(ctx, req); err != nil {
+		t.Fatal(err)
+	}
+	var good corev1.ConfigMap
+	if err := c.Get(ctx, types.NamespacedName{Namespace: "default", Name: "oc-oc-config"}, &good); err != nil {
+		t.Fatal(err)
+	}
+
+	// CRD の pattern を経由しない名前は collector の component ID として不正になる
+	var cur apiv1beta1.ObservabilityConfig
+	if err := c.Get(ctx, req.NamespacedName, &cur); err != nil {
+		t.Fatal(err)
+	}
+	cur.Spec.Processors = []apiv1beta1.ProcessorSpec{{Name: "drop health", Type: apiv1beta1.ProcessorFilter,
+		Filter: &apiv1beta1.FilterProcessor{SpanNames: []string{"^grpc.health"}}}}
+	if err := c.Update(ctx, &cur); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Reconcile(ctx, req); err != nil {
+		t.Fatal(err)
+	}
+
+	var cm corev1.ConfigMap
+	if err := c.Get(ctx, types.NamespacedName{Namespace: "default", Name: "oc-oc-config"}, &cm); err != nil {
+		t.Fatal(err)
+	}
+	if cm.Data[collector.ConfigKey] != good.Data[collector.ConfigKey] {
+		t.Fatal("invalid config replaced the last good ConfigMap")
+	}
+	var got apiv1beta1.ObservabilityConfig
+	if err := c.Get(ctx, req.NamespacedName, &got); err != nil {
+		t.Fatal(err)
+	}
+	cond := apimeta.FindStatusCondition(got.Status.Conditions, apiv1beta1.ConditionDegraded)
+	if cond == nil || cond.Status != metav1.ConditionTrue || cond.Reason != conditions.ReasonInvalidConfig ||
+		!strings.Contains(cond.Message, `invalid component ID "filter/drop health"`) {
+		t.Fatalf("degraded = %+v", cond)
+	}
+}
@@ -0,0 +1,289 @@
+package controller
+
+import (
+	"context"
+	"slices"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	observabilityv1beta1 "github.com/shtsukada/cloudnative-observability-operator/api/v1beta1"
+	"github.com/shtsukada/cloudnative-observability-operator/internal/collector"
+)
+
+func agentLabels(oc *observabilityv1beta1.ObservabilityConfig) map[string]string {
+	return map[string]string{
+		"app.kubernetes.io/name":       "oc-sidecar",
+		"app.kubernetes.io/managed-by": "cloudnative-observability-operator",
+		"obscfg":                       oc.Name,
+	}
+}
+
+// モードに関わらず agent の workload は同じ名前を使う
+func agentWorkloadName(oc *observabilityv1beta1.ObservabilityConfig) string {
+	return oc.Name + "-oc-sidecar"
+}
+
+func headlessServiceName(oc *observabilityv1beta1.ObservabilityConfig) string {
+	return oc.Name + "-oc-headless"
+}
+
+func agentSpec(oc *observabilityv1beta1.ObservabilityConfig) *observabilityv1beta1.AgentSpec {
+	if oc.Spec.Agent == nil {
+		return &observabilityv1beta1.AgentSpec{}
+	}
+	return oc.Spec.Agent
+}
+
+func (r *ObservabilityConfigReconciler) desiredPodTemplate(oc *observabilityv1beta1.ObservabilityConfig, cfg *collector.Config, cfgHash string) corev1.PodTemplateSpec {
+	agent := agentSpec(oc)
+	container := collector.AgentContainer(collector.AgentContainerName, collector.AgentImage(agent, r.defaultAgentImage()), agent, cfg)
+	if agent.HostPort && oc.Spec.Mode == observabilityv1beta1.AgentModeDaemonSet {
+		for i := range container.Ports {
+			container.Ports[i].HostPort = container.Ports[i].ContainerPort
+		}
+	}
+
+	template := corev1.PodTemplateSpec{
+		ObjectMeta: metav1.ObjectMeta{
+			Labels: agentLabels(oc),
+			// 設定の内容が変わったら Pod を入れ替える
+			Annotations: map[string]string{configHashAnnotation: cfgHash},
+		},
+		Spec: corev1.PodSpec{
+			ServiceAccountName: agentServiceAccountName(oc),
+			NodeSelector:       agent.NodeSelector,
+			Tolerations:        agent.Tolerations,
+			Containers:         []corev1.Container{container},
+			Volumes:            collector.AgentVolumes(oc, cfg),
+		},
+	}
+	if oc.Spec.Logs != nil && oc.Spec.Mode == observabilityv1beta1.AgentModeDaemonSet {
+		addPodLogs(&template.Spec, oc.Spec.Logs)
+	}
+	return template
+}
+
+// podLogsVolume はノードのコンテナログを agent に読ませる hostPath volume。
+const podLogsVolume = "varlogpods"
+
+// addPodLogs は filelog receiver がノードのログを読むための volume と権限を足す。
+// sidecar には入れない (注入先の Pod にノードのログを見せない) よう workload の Pod にだけ足す。
+func addPodLogs(spec *corev1.PodSpec, logs *observabilityv1beta1.LogsSpec) {
+	spec.Volumes = append(spec.Volumes, corev1.Volume{
+		Name: podLogsVolume,
+		VolumeSource: corev1.VolumeSource{HostPath: &corev1.HostPathVolumeSource{
+			Path: collector.PodLogsPath,
+			Type: ptr.To(corev1.HostPathDirectory),
+		}},
+	})
+	c := &spec.Containers[0]
+	c.VolumeMounts = append(c.VolumeMounts, corev1.VolumeMount{Name: podLogsVolume, MountPath: collector.PodLogsPath, ReadOnly: true})
+	// ログファイルは root 所有で他者に読めないことがあるので root で読む。
+	// 書き込みや権限の昇格は要らないので、それ以外は絞る
+	c.SecurityContext = &corev1.SecurityContext{
+		RunAsUser:                ptr.To(int64(0)),
+		RunAsGroup:               ptr.To(int64(0)),
+		RunAsNonRoot:             ptr.To(false),
+		ReadOnlyRootFilesystem:   ptr.To(true),
+		AllowPrivilegeEscalation: ptr.To(false),
+		Capabilities:             &corev1.Capabilities{Drop: []corev1.Capability{"ALL"}},
+		SeccompProfile:           &corev1.SeccompProfile{Type: corev1.SeccompProfileTypeRuntimeDefault},
+	}
+	// taint のあるノード (control plane など) のログも取りこぼさない
+	if ptr.Deref(logs.TolerateAllTaints, true) {
+		spec.Tolerations = append(slices.Clone(spec.Tolerations), corev1.Toleration{Operator: corev1.TolerationOpExists})
+	}
+}
+
+func (r *ObservabilityConfigReconciler) desiredDeployment(oc *observabilityv1beta1.ObservabilityConfig, cfg *collector.Config, cfgHash string) *appsv1.Deployment {
+	labels := agentLabels(oc)
+	return &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      agentWorkloadName(oc),
+			Namespace: oc.Namespace,
+			Labels:    labels,
+		},
+		Spec: appsv1.DeploymentSpec{
+			Replicas: agentReplicas(oc),
+			Selector: &metav1.LabelSelector{MatchLabels: labels},
+			Strategy: appsv1.DeploymentStrategy{
+				Type: appsv1.RollingUpdateDeploymentStrategyType,
+				RollingUpdate: &appsv1.RollingUpdateDeployment{
+					MaxUnavailable: ptr.To(intstr.FromString("25%")),
+					MaxSurge:       ptr.To(intstr.FromString("25%")),
+				},
+			},
+			Template: r.desiredPodTemplate(oc, cfg, cfgHash),
+		},
+	}
+}
+
+func (r *ObservabilityConfigReconciler) desiredDaemonSet(oc *observabilityv1beta1.ObservabilityConfig, cfg *collector.Config, cfgHash string) *appsv1.DaemonSet {
+	labels := agentLabels(oc)
+	return &appsv1.DaemonSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      agentWorkloadName(oc),
+			Namespace: oc.Namespace,
+			Labels:    labels,
+		},
+		Spec: appsv1.DaemonSetSpec{
+			Selector: &metav1.LabelSelector{MatchLabels: labels},
+			UpdateStrategy: appsv1.DaemonSetUpdateStrategy{
+				Type: appsv1.RollingUpdateDaemonSetStrategyType,
+			},
+			Template: r.desiredPodTemplate(oc, cfg, cfgHash),
+		},
+	}
+}
+
+func (r *ObservabilityConfigReconciler) desiredStatefulSet(oc *observabilityv1beta1.ObservabilityConfig, cfg *collector.Config, cfgHash string) *appsv1.StatefulSet {
+	labels := agentLabels(oc)
+	sts := &appsv1.StatefulSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      agentWorkloadName(oc),
+			Namespace: oc.Namespace,
+			Labels:    labels,
+		},
+		Spec: appsv1.StatefulSetSpec{
+			Replicas:            agentReplicas(oc),
+			ServiceName:         headlessServiceName(oc),
+			Selector:            &metav1.LabelSelector{MatchLabels: labels},
+			PodManagementPolicy: appsv1.ParallelPodManagement,
+			UpdateStrategy: appsv1.StatefulSetUpdateStrategy{
+				Type: appsv1.RollingUpdateStatefulSetStrategyType,
+			},
+			Template: r.desiredPodTemplate(oc, cfg, cfgHash),
+		},
+	}
+	addQueueClaims(sts, oc)
+	return sts
+}
+
+func desiredHeadlessService(oc *observabilityv1beta1.ObservabilityConfig) *corev1.Service {
+	labels := agentLabels(oc)
+	return &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      headlessServiceName(oc),
+			Namespace: oc.Namespace,
+			Labels:    labels,
+		},
+		Spec: corev1.ServiceSpec{
+			ClusterIP: corev1.ClusterIPNone,
+			Selector:  labels,
+			Ports: []corev1.ServicePort{
+				{Name: "otlp-grpc", Port: collector.OTLPGRPCPort, TargetPort: intstr.FromInt32(collector.OTLPGRPCPort)},
+				{Name: "otlp-http", Port: collector.OTLPHTTPPort, TargetPort: intstr.FromInt32(collector.OTLPHTTPPort)},
+			},
+			Type: corev1.ServiceTypeClusterIP,
+		},
+	}
+}
+
+// applyWorkload は spec.mode の workload を適用し、他の種類の workload を片付ける。
+func (r *ObservabilityConfigReconciler) applyWorkload(ctx context.Context, oc *observabilityv1beta1.ObservabilityConfig, cfg *collector.Config, cfgHash string) (controllerutil.OperationResult, error) {
+	var have client.Object
+	var stale []client.Object
+	var mutate func()
+	var claims []corev1.PersistentVolumeClaim
+	key := metav1.ObjectMeta{Name: agentWorkloadName(oc), Namespace: oc.Namespace}
+	deploy, ds, sts := &appsv1.Deployment{ObjectMeta: key}, &appsv1.DaemonSet{ObjectMeta: key}, &appsv1.StatefulSet{ObjectMeta: key}
+
+	switch oc.Spec.Mode {
+	case observabilityv1beta1.AgentModeDaemonSet:
+		want := r.desiredDaemonSet(oc, cfg, cfgHash)
+		have, stale = ds, []client.Object{deploy, sts}
+		mutate = func() {
+			ds.Labels = want.Labels
+			ds.Spec.Selector = want.Spec.Selector
+			ds.Spec.UpdateStrategy = want.Spec.UpdateStrategy
+			r.setTemplate(&ds.Spec.Template, &want.Spec.Template)
+		}
+	case observabilityv1beta1.AgentModeStatefulSet:
+		want := r.desiredStatefulSet(oc, cfg, cfgHash)
+		have, stale, claims = sts, []client.Object{deploy, ds}, want.Spec.VolumeClaimTemplates
+		mutate = func() {
+			sts.Labels = want.Labels
+			setReplicas(&sts.Spec.Replicas, want.Spec.Replicas, sts, oc)
+			sts.Spec.ServiceName = want.Spec.ServiceName
+			sts.Spec.Selector = want.Spec.Selector
+			sts.Spec.PodManagementPolicy = want.Spec.PodManagementPolicy
+			sts.Spec.UpdateStrategy = want.Spec.UpdateStrategy
+			// volumeClaimTemplates は作成時だけ書ける。変わったときは recreateStatefulSet が作り直す
+			if sts.ResourceVersion == "" {
+				sts.Spec.VolumeClaimTemplates = want.Spec.VolumeClaimTemplates
+			}
+			sts.Spec.PersistentVolumeClaimRetentionPolicy = want.Spec.PersistentVolumeClaimRetentionPolicy
+			r.setTemplate(&sts.Spec.Template, &want.Spec.Template)
+		}
+	default:
+		want := r.desiredDeployment(oc, cfg, cfgHash)
+		have, stale = deploy, []client.Object{ds, sts}
+		mutate = func() {
+			// ※ Deployment の Selector は不変なので “既存と同じ値”以外にはしない前提で代入
+			deploy.Labels = want.Labels
+			deploy.Spec.Selector = want.Spec.Selector
+			setReplicas(&deploy.Spec.Replicas, want.Spec.Replicas, deploy, oc)
+			deploy.Spec.Strategy = want.Spec.Strategy
+			r.setTemplate(&deploy.Spec.Template, &want.Spec.Template)
+		}
+	}
+
+	// モード変更時は前の workload を先に消す (hostPort などの衝突を避ける)
+	for _, obj := range stale {
+		if err := r.deleteOwned(ctx, oc, obj); err != nil {
+			return controllerutil.OperationResultNone, err
+		}
+	}
+
+	if oc.Spec.Mode == observabilityv1beta1.AgentModeStatefulSet {
+		// 作り直しの途中は削除の完了 (Owns の event) を待ってから作る
+		if recreating, err := r.recreateStatefulSet(ctx, oc, sts, claims); err != nil || recreating {
+			return controllerutil.OperationResultUpdated, err
+		}
+	}
+
+	// CreateOrPatch は “変化が無ければ OperationResultNone”
+	return controllerutil.CreateOrPatch(ctx, r.Client, have, func() error {
+		if err := controllerutil.SetControllerReference(oc, have, r.Scheme); err != nil {
+			return err
+		}
+		mutate()
+		return nil
+	})
+}
+
+// setReplicas は replica 数を書く。autoscaling 中は HPA が決めた値を上書きしないよう作成時だけ書く。
+func setReplicas(have **int32, want *int32, obj client.Object, oc *observabilityv1beta1.ObservabilityConfig) {
+	if autoscaled(oc) && obj.GetResourceVersion() != "" {
+		return
+	}
+	*have = want
+}
+
+func (r *ObservabilityConfigReconciler) setTemplate(have, want *corev1.PodTemplateSpec) {
+	r.normPodSpec(&want.Spec)
+	r.normPodSpec(&have.Spec)
+	*have = *want
+}
+
+// deleteOwned は oc が所有している obj だけを削除する。
+func (r *ObservabilityConfigReconciler) deleteOwned(ctx context.Context, oc *observabilityv1beta1.ObservabilityConfig, obj client.Object) error {
+	if err := r.Get(ctx, client.ObjectKeyFromObject(obj), obj); err != nil {
+		return client.IgnoreNotFound(err)
+	}
+	if !metav1.IsControlledBy(obj, oc) {
+		return nil
+	}
+	err := r.Delete(ctx, obj, client.PropagationPolicy(metav1.DeletePropagationBackground))
+	if apierrors.IsNotFound(err) {
+		return nil
+	}
+	return err
+}
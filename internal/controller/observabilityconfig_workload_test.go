@@ -0,0 +1,104 @@
+package controller
+
+import (
+	"context"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	apiv1beta1 "github.com/shtsukada/cloudnative-observability-operator/api/v1beta1"
+)
+
+func TestApplyDesiredModeChange(t *testing.T) {
+	oc := testObservabilityConfig()
+	scheme := newTestScheme(t)
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(oc).Build()
+	r := &ObservabilityConfigReconciler{Client: c, Scheme: scheme}
+	ctx := context.Background()
+	key := types.NamespacedName{Namespace: "default", Name: "oc-oc-sidecar"}
+	headless := types.NamespacedName{Namespace: "default", Name: "oc-oc-headless"}
+
+	exists := func(obj client.Object, key types.NamespacedName) bool {
+		t.Helper()
+		err := c.Get(ctx, key, obj)
+		if err != nil && !apierrors.IsNotFound(err) {
+			t.Fatal(err)
+		}
+		return err == nil
+	}
+	apply := func(mode apiv1beta1.AgentMode) {
+		t.Helper()
+		oc.Spec.Mode = mode
+		if _, err := r.applyDesired(ctx, oc, mustRender(t, oc)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	apply(apiv1beta1.AgentModeDeployment)
+	if !exists(&appsv1.Deployment{}, key) {
+		t.Fatal("Deployment not created")
+	}
+
+	oc.Spec.Agent.HostPort = true
+	apply(apiv1beta1.AgentModeDaemonSet)
+	if exists(&appsv1.Deployment{}, key) {
+		t.Fatal("Deployment not removed after switching to DaemonSet")
+	}
+	var ds appsv1.DaemonSet
+	if !exists(&ds, key) {
+		t.Fatal("DaemonSet not created")
+	}
+	if ds.Spec.Template.Spec.Containers[0].Ports[0].HostPort == 0 {
+		t.Fatal("hostPort not set in DaemonSet mode")
+	}
+	var svc corev1.Service
+	if !exists(&svc, types.NamespacedName{Namespace: "default", Name: "oc-oc"}) {
+		t.Fatal("Service missing")
+	}
+	if p := svc.Spec.InternalTrafficPolicy; p == nil || *p != corev1.ServiceInternalTrafficPolicyLocal {
+		t.Fatalf("internalTrafficPolicy = %v", p)
+	}
+
+	oc.Spec.Agent.HostPort = false
+	apply(apiv1beta1.AgentModeStatefulSet)
+	if exists(&appsv1.DaemonSet{}, key) {
+		t.Fatal("DaemonSet not removed after switching to StatefulSet")
+	}
+	var sts appsv1.StatefulSet
+	if !exists(&sts, key) || sts.Spec.ServiceName != headless.Name {
+		t.Fatalf("StatefulSet not created with the headless service: %+v", sts.Spec)
+	}
+	var hs corev1.Service
+	if !exists(&hs, headless) || hs.Spec.ClusterIP != corev1.ClusterIPNone {
+		t.Fatalf("headless Service not created: %+v", hs.Spec)
+	}
+
+	apply(apiv1beta1.AgentModeDeployment)
+	if exists(&appsv1.StatefulSet{}, key) || exists(&corev1.Service{}, headless) {
+		t.Fatal("StatefulSet resources not removed after switching back")
+	}
+	if !exists(&appsv1.Deployment{}, key) {
+		t.Fatal("Deployment not recreated")
+	}
+}
+
+func TestDeleteOwnedKeepsForeignObjects(t *testing.T) {
+	oc := testObservabilityConfig()
+	foreign := &appsv1.DaemonSet{}
+	foreign.Name, foreign.Namespace = "oc-oc-sidecar", "default"
+	scheme := newTestScheme(t)
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(oc, foreign).Build()
+	r := &ObservabilityConfigReconciler{Client: c, Scheme: scheme}
+
+	if _, err := r.applyDesired(context.Background(), oc, mustRender(t, oc)); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Get(context.Background(), client.ObjectKeyFromObject(foreign), &appsv1.DaemonSet{}); err != nil {
+		t.Fatalf("DaemonSet not owned by the ObservabilityConfig was touched: %v", err)
+	}
+}
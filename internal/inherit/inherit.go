@@ -0,0 +1,204 @@
+package inherit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	apiv1beta1 "github.com/shtsukada/cloudnative-observability-operator/api/v1beta1"
+)
+
+var (
+	// ErrSourceNotFound は spec.inheritFrom の ClusterObservabilityConfig が存在しないことを表す。
+	ErrSourceNotFound = errors.New("ClusterObservabilityConfig not found")
+	// ErrNamespaceNotSelected は ClusterObservabilityConfig の namespaceSelector が namespace を選んでいないことを表す。
+	ErrNamespaceNotSelected = errors.New("namespace is not selected")
+	// ErrInvalidSelector は namespaceSelector を解釈できないことを表す。
+	ErrInvalidSelector = errors.New("invalid namespaceSelector")
+)
+
+// Resolve は spec.inheritFrom の ClusterObservabilityConfig を oc.Spec に合成し、
+// 既定値まで適用した結果の出どころを返す。継承しない場合は nil を返す。
+func Resolve(ctx context.Context, c client.Reader, oc *apiv1beta1.ObservabilityConfig) (*apiv1beta1.InheritanceStatus, error) {
+	name := oc.Spec.InheritFrom
+	if name == "" {
+		return nil, nil
+	}
+	var coc apiv1beta1.ClusterObservabilityConfig
+	if err := c.Get(ctx, client.ObjectKey{Name: name}, &coc); err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, fmt.Errorf("%w: %s", ErrSourceNotFound, name)
+		}
+		return nil, err
+	}
+	var ns corev1.Namespace
+	if err := c.Get(ctx, client.ObjectKey{Name: oc.Namespace}, &ns); err != nil {
+		return nil, err
+	}
+	ok, err := Selects(&coc, &ns)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, fmt.Errorf("%w: ClusterObservabilityConfig %s does not select namespace %s", ErrNamespaceNotSelected, name, ns.Name)
+	}
+	return Merge(oc, &coc), nil
+}
+
+// Selects は coc の namespaceSelector が ns に一致するかを返す。
+func Selects(coc *apiv1beta1.ClusterObservabilityConfig, ns *corev1.Namespace) (bool, error) {
+	sel, err := metav1.LabelSelectorAsSelector(&coc.Spec.NamespaceSelector)
+	if err != nil {
+		return false, fmt.Errorf("%w in ClusterObservabilityConfig %s: %v", ErrInvalidSelector, coc.Name, err)
+	}
+	return sel.Matches(labels.Set(ns.Labels)), nil
+}
+
+// Merge は oc.Spec の未設定の項目を coc から補い、既定値を適用する。
+// exporter と processor は名前ごと、agent は項目ごとに oc 側が優先される。
+func Merge(oc *apiv1beta1.ObservabilityConfig, coc *apiv1beta1.ClusterObservabilityConfig) *apiv1beta1.InheritanceStatus {
+	s := &oc.Spec
+	// oc と値を共有しないよう複製してから取り込む
+	in := coc.Spec.InheritableSpec.DeepCopy()
+	m := merger{}
+
+	m.pick("endpoints", len(s.Endpoints) > 0, len(in.Endpoints) > 0, func() { s.Endpoints = in.Endpoints })
+	local := map[string]bool{}
+	for _, e := range s.Exporters {
+		local[e.Name] = true
+		m.record("exporters["+e.Name+"]", apiv1beta1.ConfigSourceLocal)
+	}
+	for _, e := range in.Exporters {
+		if !local[e.Name] {
+			s.Exporters = append(s.Exporters, e)
+			m.record("exporters["+e.Name+"]", apiv1beta1.ConfigSourceCluster)
+		}
+	}
+	s.Processors = m.processors(s.Processors, in.Processors)
+	m.pick("pipelines", s.Pipelines != nil, in.Pipelines != nil, func() { s.Pipelines = in.Pipelines })
+	m.pick("samplingPercent", s.SamplingPercent != nil, in.SamplingPercent != nil, func() { s.SamplingPercent = in.SamplingPercent })
+	m.pick("metricsEnabled", s.MetricsEnabled != nil, in.MetricsEnabled != nil, func() { s.MetricsEnabled = in.MetricsEnabled })
+	// persistence と gateway 構成は mode が決まっているので、mode が無くても継承しない
+	m.pick("mode", s.Mode != "" || s.Persistence != nil || s.Topology == apiv1beta1.TopologyGateway, in.Mode != "", func() { s.Mode = in.Mode })
+
+	if in.Agent != nil && s.Agent == nil {
+		s.Agent = &apiv1beta1.AgentSpec{}
+	}
+	if a := s.Agent; a != nil {
+		ia := in.Agent
+		if ia == nil {
+			ia = &apiv1beta1.AgentSpec{}
+		}
+		m.pick("agent.image", a.Image != "", ia.Image != "", func() { a.Image = ia.Image })
+		m.pick("agent.version", a.Version != "", ia.Version != "", func() { a.Version = ia.Version })
+		m.pick("agent.replicas", a.Replicas != nil, ia.Replicas != nil, func() { a.Replicas = ia.Replicas })
+		m.pick("agent.resources", hasResources(a.Resources), hasResources(ia.Resources), func() { a.Resources = ia.Resources })
+		m.pick("agent.nodeSelector", len(a.NodeSelector) > 0, len(ia.NodeSelector) > 0, func() { a.NodeSelector = ia.NodeSelector })
+		m.pick("agent.tolerations", len(a.Tolerations) > 0, len(ia.Tolerations) > 0, func() { a.Tolerations = ia.Tolerations })
+		m.pick("agent.serviceAccountName", a.ServiceAccountName != "", ia.ServiceAccountName != "", func() { a.ServiceAccountName = ia.ServiceAccountName })
+		m.pick("agent.hostPort", a.HostPort, ia.HostPort, func() { a.HostPort = ia.HostPort })
+		m.pick("agent.extraArgs", len(a.ExtraArgs) > 0, len(ia.ExtraArgs) > 0, func() { a.ExtraArgs = ia.ExtraArgs })
+		m.pick("agent.autoscaling", a.Autoscaling != nil, ia.Autoscaling != nil, func() { a.Autoscaling = ia.Autoscaling })
+	}
+
+	// どちらにも無く既定値で埋まる項目
+	if s.SamplingPercent == nil {
+		m.record("samplingPercent", apiv1beta1.ConfigSourceDefault)
+	}
+	if s.MetricsEnabled == nil {
+		m.record("metricsEnabled", apiv1beta1.ConfigSourceDefault)
+	}
+	if s.Mode == "" {
+		m.record("mode", apiv1beta1.ConfigSourceDefault)
+	}
+	if s.Agent == nil || s.Agent.Replicas == nil {
+		m.record("agent.replicas", apiv1beta1.ConfigSourceDefault)
+	}
+	oc.ApplyDefaults()
+
+	effective := apiv1beta1.InheritableSpec{
+		Endpoints:       s.Endpoints,
+		Exporters:       s.Exporters,
+		Pipelines:       s.Pipelines,
+		Processors:      s.Processors,
+		SamplingPercent: s.SamplingPercent,
+		MetricsEnabled:  s.MetricsEnabled,
+		Mode:            s.Mode,
+		Agent:           s.Agent,
+	}
+	return &apiv1beta1.InheritanceStatus{
+		From:      coc.Name,
+		Effective: *effective.DeepCopy(),
+		Sources:   m.sources(),
+	}
+}
+
+type merger map[string]apiv1beta1.ConfigSource
+
+// processors は継承した processor を先に並べ、同名のものは oc 側で置き換える。
+func (m merger) processors(local, cluster []apiv1beta1.ProcessorSpec) []apiv1beta1.ProcessorSpec {
+	if len(cluster) == 0 {
+		for _, p := range local {
+			m.record("processors["+p.Name+"]", apiv1beta1.ConfigSourceLocal)
+		}
+		return local
+	}
+	byName := map[string]int{}
+	for i, p := range local {
+		byName[p.Name] = i
+	}
+	out := make([]apiv1beta1.ProcessorSpec, 0, len(local)+len(cluster))
+	used := map[string]bool{}
+	for _, p := range cluster {
+		if i, ok := byName[p.Name]; ok {
+			out = append(out, local[i])
+			used[p.Name] = true
+			m.record("processors["+p.Name+"]", apiv1beta1.ConfigSourceLocal)
+			continue
+		}
+		out = append(out, p)
+		m.record("processors["+p.Name+"]", apiv1beta1.ConfigSourceCluster)
+	}
+	for _, p := range local {
+		if !used[p.Name] {
+			out = append(out, p)
+			m.record("processors["+p.Name+"]", apiv1beta1.ConfigSourceLocal)
+		}
+	}
+	return out
+}
+
+// pick は oc 側に値があればそれを使い、無ければ set で coc の値を取り込む。
+func (m merger) pick(field string, local, cluster bool, set func()) {
+	switch {
+	case local:
+		m.record(field, apiv1beta1.ConfigSourceLocal)
+	case cluster:
+		set()
+		m.record(field, apiv1beta1.ConfigSourceCluster)
+	}
+}
+
+func (m merger) record(field string, src apiv1beta1.ConfigSource) {
+	m[field] = src
+}
+
+func (m merger) sources() []apiv1beta1.FieldSource {
+	out := make([]apiv1beta1.FieldSource, 0, len(m))
+	for f, src := range m {
+		out = append(out, apiv1beta1.FieldSource{Field: f, Source: src})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Field < out[j].Field })
+	return out
+}
+
+func hasResources(r corev1.ResourceRequirements) bool {
+	return len(r.Limits) > 0 || len(r.Requests) > 0 || len(r.Claims) > 0
+}
@@ -0,0 +1,169 @@
+package inherit
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	apiv1beta1 "github.com/shtsukada/cloudnative-observability-operator/api/v1beta1"
+)
+
+func testClusterConfig() *apiv1beta1.ClusterObservabilityConfig {
+	return &apiv1beta1.ClusterObservabilityConfig{
+		ObjectMeta: metav1.ObjectMeta{Name: "platform"},
+		Spec: apiv1beta1.ClusterObservabilityConfigSpec{
+			NamespaceSelector: metav1.LabelSelector{MatchLabels: map[string]string{"tier": "standard"}},
+			InheritableSpec: apiv1beta1.InheritableSpec{
+				Endpoints: []apiv1beta1.Endpoint{"otel-collector.monitoring:4317"},
+				Exporters: []apiv1beta1.ExporterSpec{
+					{Name: "vendor", Type: apiv1beta1.ExporterOTLPHTTP, Endpoint: "https://otlp.vendor.example.com"},
+					{Name: "stdout", Type: apiv1beta1.ExporterDebug},
+				},
+				SamplingPercent: ptr.To(int32(5)),
+				Agent: &apiv1beta1.AgentSpec{
+					Image: "otel/opentelemetry-collector-contrib:0.114.0",
+					Resources: corev1.ResourceRequirements{Requests: corev1.ResourceList{
+						corev1.ResourceCPU: resource.MustParse("50m"),
+					}},
+				},
+			},
+		},
+	}
+}
+
+func TestMerge(t *testing.T) {
+	coc := testClusterConfig()
+	oc := &apiv1beta1.ObservabilityConfig{
+		ObjectMeta: metav1.ObjectMeta{Name: "oc", Namespace: "app"},
+		Spec: apiv1beta1.ObservabilityConfigSpec{
+			InheritFrom: "platform",
+			Exporters:   []apiv1beta1.ExporterSpec{{Name: "vendor", Type: apiv1beta1.ExporterOTLP, Endpoint: "otlp.team.example:4317"}},
+			Agent:       &apiv1beta1.AgentSpec{Version: "0.115.0"},
+		},
+	}
+	st := Merge(oc, coc)
+
+	s := oc.Spec
+	if len(s.Endpoints) != 1 || s.Endpoints[0] != "otel-collector.monitoring:4317" {
+		t.Fatalf("endpoints = %v", s.Endpoints)
+	}
+	// 同名の exporter は namespace 側が優先される
+	if len(s.Exporters) != 2 || s.Exporters[0].Endpoint != "otlp.team.example:4317" || s.Exporters[1].Name != "stdout" {
+		t.Fatalf("exporters = %+v", s.Exporters)
+	}
+	if *s.SamplingPercent != 5 || !*s.MetricsEnabled || s.Mode != apiv1beta1.AgentModeDeployment {
+		t.Fatalf("spec = %+v", s)
+	}
+	if s.Agent.Image != coc.Spec.Agent.Image || s.Agent.Version != "0.115.0" || *s.Agent.Replicas != 1 {
+		t.Fatalf("agent = %+v", s.Agent)
+	}
+
+	want := []apiv1beta1.FieldSource{
+		{Field: "agent.image", Source: apiv1beta1.ConfigSourceCluster},
+		{Field: "agent.replicas", Source: apiv1beta1.ConfigSourceDefault},
+		{Field: "agent.resources", Source: apiv1beta1.ConfigSourceCluster},
+		{Field: "agent.version", Source: apiv1beta1.ConfigSourceLocal},
+		{Field: "endpoints", Source: apiv1beta1.ConfigSourceCluster},
+		{Field: "exporters[stdout]", Source: apiv1beta1.ConfigSourceCluster},
+		{Field: "exporters[vendor]", Source: apiv1beta1.ConfigSourceLocal},
+		{Field: "metricsEnabled", Source: apiv1beta1.ConfigSourceDefault},
+		{Field: "mode", Source: apiv1beta1.ConfigSourceDefault},
+		{Field: "samplingPercent", Source: apiv1beta1.ConfigSourceCluster},
+	}
+	if st.From != "platform" || !equality.Semantic.DeepEqual(st.Sources, want) {
+		t.Fatalf("sources = %+v", st.Sources)
+	}
+	if !equality.Semantic.DeepEqual(st.Effective.Exporters, s.Exporters) || *st.Effective.SamplingPercent != 5 {
+		t.Fatalf("effective = %+v", st.Effective)
+	}
+
+	// 合成結果は継承元と値を共有しない
+	s.Agent.Resources.Requests[corev1.ResourceCPU] = resource.MustParse("1")
+	if coc.Spec.Agent.Resources.Requests.Cpu().String() != "50m" {
+		t.Fatal("merge aliased the cluster config")
+	}
+}
+
+func TestResolve(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	if err := apiv1beta1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	selected := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "app", Labels: map[string]string{"tier": "standard"}}}
+	other := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "batch"}}
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(testClusterConfig(), selected, other).Build()
+	ctx := context.Background()
+
+	cases := []struct {
+		name      string
+		namespace string
+		from      string
+		wantErr   error
+	}{
+		{name: "no inheritance", namespace: "app"},
+		{name: "selected", namespace: "app", from: "platform"},
+		{name: "not selected", namespace: "batch", from: "platform", wantErr: ErrNamespaceNotSelected},
+		{name: "missing", namespace: "app", from: "absent", wantErr: ErrSourceNotFound},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			oc := &apiv1beta1.ObservabilityConfig{
+				ObjectMeta: metav1.ObjectMeta{Name: "oc", Namespace: tc.namespace},
+				Spec:       apiv1beta1.ObservabilityConfigSpec{InheritFrom: tc.from},
+			}
+			st, err := Resolve(ctx, c, oc)
+			if !errors.Is(err, tc.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tc.wantErr)
+			}
+			if (st != nil) != (tc.from != "" && tc.wantErr == nil) {
+				t.Fatalf("status = %+v", st)
+			}
+			if st != nil && len(oc.Spec.Endpoints) != 1 {
+				t.Fatalf("endpoints not inherited: %+v", oc.Spec)
+			}
+		})
+	}
+}
+
+func TestMergeProcessors(t *testing.T) {
+	coc := testClusterConfig()
+	coc.Spec.Processors = []apiv1beta1.ProcessorSpec{
+		{Name: "cluster", Type: apiv1beta1.ProcessorResource, Resource: []apiv1beta1.AttributeAction{{Key: "k8s.cluster.name", Action: "upsert", Value: ptr.To("prod-1")}}},
+		{Name: "drop-health", Type: apiv1beta1.ProcessorFilter, Filter: &apiv1beta1.FilterProcessor{SpanNames: []string{"^grpc.health"}}},
+	}
+	oc := &apiv1beta1.ObservabilityConfig{
+		ObjectMeta: metav1.ObjectMeta{Name: "oc", Namespace: "app"},
+		Spec: apiv1beta1.ObservabilityConfigSpec{
+			InheritFrom: "platform",
+			Processors: []apiv1beta1.ProcessorSpec{
+				{Name: "team", Type: apiv1beta1.ProcessorResource, Resource: []apiv1beta1.AttributeAction{{Key: "team", Action: "upsert", Value: ptr.To("payments")}}},
+				{Name: "drop-health", Type: apiv1beta1.ProcessorFilter, Filter: &apiv1beta1.FilterProcessor{SpanNames: []string{"^grpc.health", "^/healthz"}}},
+			},
+		},
+	}
+	Merge(oc, coc)
+
+	// 継承分が先、同名はその位置で置き換え、残りの namespace 側は後ろ
+	var names []string
+	for _, p := range oc.Spec.Processors {
+		names = append(names, p.Name)
+	}
+	if want := []string{"cluster", "drop-health", "team"}; !equality.Semantic.DeepEqual(names, want) {
+		t.Fatalf("processors = %v, want %v", names, want)
+	}
+	if len(oc.Spec.Processors[1].Filter.SpanNames) != 2 {
+		t.Fatalf("drop-health was not overridden: %+v", oc.Spec.Processors[1])
+	}
+}
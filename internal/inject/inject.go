@@ -0,0 +1,115 @@
+package inject
+
+import (
+	"fmt"
+	"slices"
+
+	corev1 "k8s.io/api/core/v1"
+
+	apiv1beta1 "github.com/shtsukada/cloudnative-observability-operator/api/v1beta1"
+	"github.com/shtsukada/cloudnative-observability-operator/internal/collector"
+	"github.com/shtsukada/cloudnative-observability-operator/internal/shared/otelenv"
+)
+
+const (
+	// Label を LabelValue にした Pod だけが webhook に送られる (objectSelector はラベルしか見ない)。
+	Label      = "observability.shtsukada.dev/inject"
+	LabelValue = "true"
+	// Annotation は注入元の ObservabilityConfig 名を Pod に指定する。
+	Annotation = "observability.shtsukada.dev/inject"
+	// StatusAnnotation は webhook が注入の結果 (StatusInjected / StatusFailed) を書き込む。
+	StatusAnnotation = "observability.shtsukada.dev/inject-status"
+	// ErrorAnnotation は注入に失敗した理由。
+	ErrorAnnotation = "observability.shtsukada.dev/inject-error"
+
+	StatusInjected = "injected"
+	StatusFailed   = "failed"
+
+	// ContainerName は注入する sidecar の名前。
+	ContainerName = "otel-agent"
+
+	// 利用者の volume と衝突しないよう sidecar の volume には接頭辞を付ける
+	volumePrefix = "otel-agent-"
+)
+
+// LocalEndpoint はアプリが sidecar へ送るための OTLP endpoint。
+var LocalEndpoint = fmt.Sprintf("http://localhost:%d", collector.OTLPGRPCPort)
+
+// Requested は pod が注入を求めていれば ObservabilityConfig 名を返す。
+func Requested(pod *corev1.Pod) (string, bool) {
+	if pod.Labels[Label] != LabelValue {
+		return "", false
+	}
+	name, ok := pod.Annotations[Annotation]
+	return name, ok && name != ""
+}
+
+// Injected は pod に sidecar が既に入っているかを返す。
+func Injected(pod *corev1.Pod) bool {
+	return slices.ContainsFunc(pod.Spec.Containers, func(c corev1.Container) bool { return c.Name == ContainerName })
+}
+
+// Pod は oc の設定で agent sidecar を pod に追加し、アプリのコンテナが sidecar に送るようにする。
+func Pod(pod *corev1.Pod, oc *apiv1beta1.ObservabilityConfig, cfg *collector.Config, image string) error {
+	agent := oc.Spec.Agent
+	if agent == nil {
+		agent = &apiv1beta1.AgentSpec{}
+	}
+	if pod.Spec.HostNetwork {
+		// hostNetwork の Pod ではノード上の agent とポートが衝突する
+		return fmt.Errorf("pods with hostNetwork cannot receive a sidecar agent")
+	}
+
+	sidecar := collector.AgentContainer(ContainerName, image, agent, cfg)
+	// spec.scrape と operator からの監視は workload の agent だけが行う
+	sidecar.Args[0] = "--config=" + collector.ConfigMountPath + "/" + collector.SidecarConfigKey
+	sidecar.Ports = slices.DeleteFunc(sidecar.Ports, func(p corev1.ContainerPort) bool {
+		return p.ContainerPort == collector.MetricsPort || p.ContainerPort == collector.HealthCheckPort
+	})
+	volumes := collector.AgentVolumes(oc, cfg)
+	for i := range sidecar.VolumeMounts {
+		sidecar.VolumeMounts[i].Name = volumePrefix + sidecar.VolumeMounts[i].Name
+	}
+	for i := range volumes {
+		volumes[i].Name = volumePrefix + volumes[i].Name
+		if slices.ContainsFunc(pod.Spec.Volumes, func(v corev1.Volume) bool { return v.Name == volumes[i].Name }) {
+			return fmt.Errorf("pod already has a volume named %q", volumes[i].Name)
+		}
+	}
+	for _, c := range pod.Spec.Containers {
+		for _, p := range c.Ports {
+			if p.ContainerPort == collector.OTLPGRPCPort || p.ContainerPort == collector.OTLPHTTPPort {
+				return fmt.Errorf("container %q already uses port %d", c.Name, p.ContainerPort)
+			}
+		}
+	}
+
+	// 利用者が明示した endpoint は上書きしない
+	for i := range pod.Spec.Containers {
+		c := &pod.Spec.Containers[i]
+		if !slices.ContainsFunc(c.Env, func(e corev1.EnvVar) bool { return e.Name == otelenv.Endpoint }) {
+			c.Env = append(c.Env, corev1.EnvVar{Name: otelenv.Endpoint, Value: LocalEndpoint})
+		}
+	}
+	pod.Spec.Containers = append(pod.Spec.Containers, sidecar)
+	pod.Spec.Volumes = append(pod.Spec.Volumes, volumes...)
+	setStatus(pod, StatusInjected, "")
+	return nil
+}
+
+// MarkFailed は注入の失敗を pod に記録する。Pod の作成自体は妨げない。
+func MarkFailed(pod *corev1.Pod, err error) {
+	setStatus(pod, StatusFailed, err.Error())
+}
+
+func setStatus(pod *corev1.Pod, status, msg string) {
+	if pod.Annotations == nil {
+		pod.Annotations = map[string]string{}
+	}
+	pod.Annotations[StatusAnnotation] = status
+	if msg != "" {
+		pod.Annotations[ErrorAnnotation] = msg
+	} else {
+		delete(pod.Annotations, ErrorAnnotation)
+	}
+}
@@ -0,0 +1,115 @@
+package inject
+
+import (
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	apiv1beta1 "github.com/shtsukada/cloudnative-observability-operator/api/v1beta1"
+	"github.com/shtsukada/cloudnative-observability-operator/internal/collector"
+)
+
+func testConfig(t *testing.T) (*apiv1beta1.ObservabilityConfig, *collector.Config) {
+	t.Helper()
+	oc := &apiv1beta1.ObservabilityConfig{
+		ObjectMeta: metav1.ObjectMeta{Name: "oc", Namespace: "app"},
+		Spec: apiv1beta1.ObservabilityConfigSpec{
+			Exporters: []apiv1beta1.ExporterSpec{{
+				Name: "vendor", Type: apiv1beta1.ExporterOTLP, Endpoint: "otlp.vendor.example:443",
+				TLS: &apiv1beta1.ExporterTLS{SecretName: "vendor-tls"},
+			}},
+		},
+	}
+	oc.ApplyDefaults()
+	cfg, err := collector.Render(oc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return oc, cfg
+}
+
+func testPod() *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "app",
+			Labels:      map[string]string{Label: LabelValue},
+			Annotations: map[string]string{Annotation: "oc"},
+		},
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{
+				{Name: "app", Image: "app:1.0.0"},
+				{Name: "pinned", Image: "app:1.0.0", Env: []corev1.EnvVar{{Name: "OTEL_EXPORTER_OTLP_ENDPOINT", Value: "http://elsewhere:4317"}}},
+			},
+			Volumes: []corev1.Volume{{Name: "config"}},
+		},
+	}
+}
+
+func TestPod(t *testing.T) {
+	oc, cfg := testConfig(t)
+	pod := testPod()
+	if name, ok := Requested(pod); !ok || name != "oc" {
+		t.Fatalf("Requested = %q, %v", name, ok)
+	}
+	// ラベルの無い Pod は webhook の objectSelector に掛からないので注入対象にしない
+	unlabeled := testPod()
+	unlabeled.Labels = nil
+	if _, ok := Requested(unlabeled); ok {
+		t.Fatal("pod without the inject label was requested")
+	}
+	if err := Pod(pod, oc, cfg, "otelcol:1.0.0"); err != nil {
+		t.Fatal(err)
+	}
+	if !Injected(pod) || pod.Annotations[StatusAnnotation] != StatusInjected {
+		t.Fatalf("pod not marked injected: %+v", pod.Annotations)
+	}
+
+	sidecar := pod.Spec.Containers[2]
+	if sidecar.Image != "otelcol:1.0.0" || sidecar.Args[0] != "--config=/conf/sidecar.yaml" {
+		t.Fatalf("sidecar = %+v", sidecar)
+	}
+	// 利用者の "config" volume とは別名でマウントする
+	var names []string
+	for _, v := range pod.Spec.Volumes {
+		names = append(names, v.Name)
+	}
+	if got := strings.Join(names, ","); got != "config,otel-agent-config,otel-agent-tls-vendor" {
+		t.Fatalf("volumes = %s", got)
+	}
+	if sidecar.VolumeMounts[0].Name != "otel-agent-config" || sidecar.VolumeMounts[1].Name != "otel-agent-tls-vendor" {
+		t.Fatalf("mounts = %+v", sidecar.VolumeMounts)
+	}
+	if pod.Spec.Volumes[1].ConfigMap.Name != "oc-oc-config" {
+		t.Fatalf("config volume = %+v", pod.Spec.Volumes[1])
+	}
+
+	if env := pod.Spec.Containers[0].Env; len(env) != 1 || env[0].Value != "http://localhost:4317" {
+		t.Fatalf("app env = %+v", env)
+	}
+	if env := pod.Spec.Containers[1].Env; len(env) != 1 || env[0].Value != "http://elsewhere:4317" {
+		t.Fatalf("explicit endpoint was overwritten: %+v", env)
+	}
+}
+
+func TestPodRejectsConflicts(t *testing.T) {
+	oc, cfg := testConfig(t)
+
+	pod := testPod()
+	pod.Spec.Containers[0].Ports = []corev1.ContainerPort{{ContainerPort: 4317}}
+	if err := Pod(pod, oc, cfg, "otelcol:1.0.0"); err == nil || Injected(pod) {
+		t.Fatalf("port conflict: err = %v", err)
+	}
+
+	pod = testPod()
+	pod.Spec.HostNetwork = true
+	err := Pod(pod, oc, cfg, "otelcol:1.0.0")
+	if err == nil {
+		t.Fatal("hostNetwork pod was injected")
+	}
+	MarkFailed(pod, err)
+	if pod.Annotations[StatusAnnotation] != StatusFailed || pod.Annotations[ErrorAnnotation] == "" {
+		t.Fatalf("failure not recorded: %+v", pod.Annotations)
+	}
+}
@@ -0,0 +1,157 @@
+package migration
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+)
+
+// +kubebuilder:rbac:groups=apiextensions.k8s.io,resources=customresourcedefinitions,verbs=get;list;watch
+// +kubebuilder:rbac:groups=apiextensions.k8s.io,resources=customresourcedefinitions/status,verbs=get;update;patch
+
+// Resource is a custom resource whose stored objects are migrated.
+type Resource struct {
+	// CRD is the name of the CustomResourceDefinition, e.g. grpcburners.observability.shtsukada.dev.
+	CRD string
+	// NewList returns an empty list of the storage version.
+	NewList func() client.ObjectList
+}
+
+// StorageVersionMigrator rewrites every stored object of the given resources
+// in the current storage version and then drops older versions from the
+// CRD's status.storedVersions, so that they can later be removed from the CRD.
+type StorageVersionMigrator struct {
+	Client    client.Client
+	Resources []Resource
+	// Backoff controls the retries when a migration fails. Zero means the default.
+	Backoff wait.Backoff
+}
+
+var _ manager.LeaderElectionRunnable = &StorageVersionMigrator{}
+
+// NeedLeaderElection implements manager.LeaderElectionRunnable.
+func (m *StorageVersionMigrator) NeedLeaderElection() bool { return true }
+
+// Start implements manager.Runnable. 移行は起動時に一度だけ行う。
+func (m *StorageVersionMigrator) Start(ctx context.Context) error {
+	log := logf.FromContext(ctx).WithName("storage-version-migrator")
+	backoff := m.Backoff
+	if backoff.Steps == 0 {
+		backoff = wait.Backoff{Duration: 5 * time.Second, Factor: 2, Jitter: 0.1, Steps: 6, Cap: 5 * time.Minute}
+	}
+	for _, res := range m.Resources {
+		err := wait.ExponentialBackoffWithContext(ctx, backoff, func(ctx context.Context) (bool, error) {
+			if err := m.migrate(ctx, res); err != nil {
+				log.Error(err, "storage version migration failed, retrying", "crd", res.CRD)
+				return false, nil
+			}
+			return true, nil
+		})
+		if err != nil {
+			// 移行に失敗しても reconcile は継続できるので manager は止めない
+			log.Error(err, "giving up storage version migration", "crd", res.CRD)
+		}
+	}
+	return nil
+}
+
+func (m *StorageVersionMigrator) migrate(ctx context.Context, res Resource) error {
+	log := logf.FromContext(ctx)
+
+	var crd apiextensionsv1.CustomResourceDefinition
+	if err := m.Client.Get(ctx, types.NamespacedName{Name: res.CRD}, &crd); err != nil {
+		return err
+	}
+	storage := storageVersion(&crd)
+	if storage == "" {
+		return fmt.Errorf("crd %s has no storage version", res.CRD)
+	}
+	if len(crd.Status.StoredVersions) == 1 && crd.Status.StoredVersions[0] == storage {
+		return nil
+	}
+	// conversion webhook が無いと旧 version の field は読み出し時に落ちるので、書き直すと失われる
+	if crd.Spec.Conversion == nil || crd.Spec.Conversion.Strategy != apiextensionsv1.WebhookConverter {
+		log.Info("skipping storage version migration, the CRD has no conversion webhook", "crd", res.CRD)
+		return nil
+	}
+
+	list := res.NewList()
+	if err := m.Client.List(ctx, list); err != nil {
+		return err
+	}
+	objs, err := meta.ExtractList(list)
+	if err != nil {
+		return err
+	}
+	var rejected int
+	for _, o := range objs {
+		obj, ok := o.(client.Object)
+		if !ok {
+			return fmt.Errorf("unexpected list item %T", o)
+		}
+		if err := m.rewrite(ctx, obj); err != nil {
+			// 今の validation を通らない旧オブジェクトは利用者が直すまで書き直せない。残りは進める
+			if apierrors.IsInvalid(err) || apierrors.IsForbidden(err) {
+				log.Error(err, "object rejected, fix it and restart the manager to finish the migration",
+					"crd", res.CRD, "namespace", obj.GetNamespace(), "name", obj.GetName())
+				rejected++
+				continue
+			}
+			return fmt.Errorf("rewrite %s/%s: %w", obj.GetNamespace(), obj.GetName(), err)
+		}
+	}
+	if rejected > 0 {
+		// 旧 version のまま残ったオブジェクトがあるので storedVersions は変えない
+		log.Info("storage version migration incomplete", "crd", res.CRD, "rejected", rejected, "objects", len(objs))
+		return nil
+	}
+
+	// 全オブジェクトを書き直したので storage version 以外の記録を外す
+	err = retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		if err := m.Client.Get(ctx, types.NamespacedName{Name: res.CRD}, &crd); err != nil {
+			return err
+		}
+		crd.Status.StoredVersions = []string{storage}
+		return m.Client.Status().Update(ctx, &crd)
+	})
+	if err != nil {
+		return err
+	}
+	log.Info("migrated stored objects", "crd", res.CRD, "storageVersion", storage, "objects", len(objs))
+	return nil
+}
+
+// rewrite は内容を変えずに update し、API server に storage version で保存し直させる。
+func (m *StorageVersionMigrator) rewrite(ctx context.Context, obj client.Object) error {
+	key := client.ObjectKeyFromObject(obj)
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		if err := m.Client.Update(ctx, obj); err != nil {
+			if apierrors.IsConflict(err) {
+				if getErr := m.Client.Get(ctx, key, obj); getErr != nil {
+					return getErr
+				}
+			}
+			return client.IgnoreNotFound(err)
+		}
+		return nil
+	})
+}
+
+func storageVersion(crd *apiextensionsv1.CustomResourceDefinition) string {
+	for _, v := range crd.Spec.Versions {
+		if v.Storage {
+			return v.Name
+		}
+	}
+	return ""
+}
@@ -0,0 +1,180 @@
+package migration
+
+import (
+	"context"
+	"testing"
+
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+
+	apiv1beta1 "github.com/shtsukada/cloudnative-observability-operator/api/v1beta1"
+)
+
+func newScheme(t *testing.T) *runtime.Scheme {
+	t.Helper()
+	scheme := runtime.NewScheme()
+	for _, add := range []func(*runtime.Scheme) error{clientgoscheme.AddToScheme, apiextensionsv1.AddToScheme, apiv1beta1.AddToScheme} {
+		if err := add(scheme); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return scheme
+}
+
+func multiVersionCRD(conversion apiextensionsv1.ConversionStrategyType) *apiextensionsv1.CustomResourceDefinition {
+	return &apiextensionsv1.CustomResourceDefinition{
+		ObjectMeta: metav1.ObjectMeta{Name: "grpcburners.observability.shtsukada.dev"},
+		Spec: apiextensionsv1.CustomResourceDefinitionSpec{
+			Versions: []apiextensionsv1.CustomResourceDefinitionVersion{
+				{Name: "v1alpha1", Served: true},
+				{Name: "v1beta1", Served: true, Storage: true},
+			},
+			Conversion: &apiextensionsv1.CustomResourceConversion{Strategy: conversion},
+		},
+		Status: apiextensionsv1.CustomResourceDefinitionStatus{StoredVersions: []string{"v1alpha1", "v1beta1"}},
+	}
+}
+
+func TestStorageVersionMigrator(t *testing.T) {
+	scheme := newScheme(t)
+
+	crd := multiVersionCRD(apiextensionsv1.WebhookConverter)
+	gb := &apiv1beta1.GrpcBurner{ObjectMeta: metav1.ObjectMeta{Name: "gb", Namespace: "default"}}
+	c := fake.NewClientBuilder().WithScheme(scheme).
+		WithObjects(crd, gb).
+		WithStatusSubresource(crd).
+		Build()
+
+	var before apiv1beta1.GrpcBurner
+	if err := c.Get(context.Background(), client.ObjectKeyFromObject(gb), &before); err != nil {
+		t.Fatal(err)
+	}
+
+	m := &StorageVersionMigrator{
+		Client: c,
+		Resources: []Resource{{
+			CRD:     crd.Name,
+			NewList: func() client.ObjectList { return &apiv1beta1.GrpcBurnerList{} },
+		}},
+	}
+	if err := m.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	var after apiv1beta1.GrpcBurner
+	if err := c.Get(context.Background(), client.ObjectKeyFromObject(gb), &after); err != nil {
+		t.Fatal(err)
+	}
+	if after.ResourceVersion == before.ResourceVersion {
+		t.Fatal("object was not rewritten")
+	}
+
+	var got apiextensionsv1.CustomResourceDefinition
+	if err := c.Get(context.Background(), types.NamespacedName{Name: crd.Name}, &got); err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Status.StoredVersions) != 1 || got.Status.StoredVersions[0] != "v1beta1" {
+		t.Fatalf("storedVersions = %v", got.Status.StoredVersions)
+	}
+
+	// 移行済みなら再実行しても書き換えない
+	if err := m.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	var again apiv1beta1.GrpcBurner
+	if err := c.Get(context.Background(), client.ObjectKeyFromObject(gb), &again); err != nil {
+		t.Fatal(err)
+	}
+	if again.ResourceVersion != after.ResourceVersion {
+		t.Fatal("object was rewritten after migration completed")
+	}
+}
+
+func TestStorageVersionMigratorSkipsRejected(t *testing.T) {
+	scheme := newScheme(t)
+	crd := multiVersionCRD(apiextensionsv1.WebhookConverter)
+	good := &apiv1beta1.GrpcBurner{ObjectMeta: metav1.ObjectMeta{Name: "good", Namespace: "default"}}
+	legacy := &apiv1beta1.GrpcBurner{ObjectMeta: metav1.ObjectMeta{Name: "legacy", Namespace: "default"}}
+	// 今の validating webhook に通らない旧オブジェクトの代わり
+	c := fake.NewClientBuilder().WithScheme(scheme).
+		WithObjects(crd, good, legacy).
+		WithStatusSubresource(crd).
+		WithInterceptorFuncs(interceptor.Funcs{Update: func(ctx context.Context, c client.WithWatch, obj client.Object, opts ...client.UpdateOption) error {
+			if obj.GetName() == "legacy" {
+				return apierrors.NewInvalid(apiv1beta1.GroupVersion.WithKind("GrpcBurner").GroupKind(), obj.GetName(),
+					field.ErrorList{field.Invalid(field.NewPath("spec", "image"), "grpc-burner:latest", "must not use :latest")})
+			}
+			return c.Update(ctx, obj, opts...)
+		}}).
+		Build()
+	ctx := context.Background()
+
+	var before apiv1beta1.GrpcBurner
+	if err := c.Get(ctx, client.ObjectKeyFromObject(good), &before); err != nil {
+		t.Fatal(err)
+	}
+	m := &StorageVersionMigrator{
+		Client:    c,
+		Resources: []Resource{{CRD: crd.Name, NewList: func() client.ObjectList { return &apiv1beta1.GrpcBurnerList{} }}},
+	}
+	if err := m.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// 通るものは書き直し、旧 version が残るので storedVersions は変えない
+	var after apiv1beta1.GrpcBurner
+	if err := c.Get(ctx, client.ObjectKeyFromObject(good), &after); err != nil {
+		t.Fatal(err)
+	}
+	if after.ResourceVersion == before.ResourceVersion {
+		t.Fatal("valid object was not rewritten")
+	}
+	var got apiextensionsv1.CustomResourceDefinition
+	if err := c.Get(ctx, types.NamespacedName{Name: crd.Name}, &got); err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Status.StoredVersions) != 2 {
+		t.Fatalf("storedVersions = %v", got.Status.StoredVersions)
+	}
+}
+
+func TestStorageVersionMigratorWithoutConversion(t *testing.T) {
+	scheme := newScheme(t)
+	crd := multiVersionCRD(apiextensionsv1.NoneConverter)
+	gb := &apiv1beta1.GrpcBurner{ObjectMeta: metav1.ObjectMeta{Name: "gb", Namespace: "default"}}
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(crd, gb).WithStatusSubresource(crd).Build()
+	ctx := context.Background()
+
+	var before apiv1beta1.GrpcBurner
+	if err := c.Get(ctx, client.ObjectKeyFromObject(gb), &before); err != nil {
+		t.Fatal(err)
+	}
+	m := &StorageVersionMigrator{
+		Client:    c,
+		Resources: []Resource{{CRD: crd.Name, NewList: func() client.ObjectList { return &apiv1beta1.GrpcBurnerList{} }}},
+	}
+	if err := m.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// 変換できずに field を落としたまま書き直さない
+	var after apiv1beta1.GrpcBurner
+	if err := c.Get(ctx, client.ObjectKeyFromObject(gb), &after); err != nil {
+		t.Fatal(err)
+	}
+	var got apiextensionsv1.CustomResourceDefinition
+	if err := c.Get(ctx, types.NamespacedName{Name: crd.Name}, &got); err != nil {
+		t.Fatal(err)
+	}
+	if after.ResourceVersion != before.ResourceVersion || len(got.Status.StoredVersions) != 2 {
+		t.Fatalf("migrated without conversion: rv %s -> %s, storedVersions = %v", before.ResourceVersion, after.ResourceVersion, got.Status.StoredVersions)
+	}
+}
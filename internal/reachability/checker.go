@@ -0,0 +1,296 @@
+package reachability
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	collectortrace "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	DefaultTimeout       = 5 * time.Second
+	DefaultMaxConcurrent = 8
+	DefaultBaseBackoff   = 10 * time.Second
+	DefaultMaxBackoff    = 5 * time.Minute
+)
+
+// Resolver は名前解決を差し替えるためのインターフェース。*net.Resolver を満たす。
+type Resolver interface {
+	LookupHost(ctx context.Context, host string) ([]string, error)
+}
+
+// Result は 1 つの Target に対する確認結果。
+type Result struct {
+	Target    Target
+	Reachable bool
+	Latency   time.Duration
+	Err       error
+	CheckedAt time.Time
+}
+
+// Checker は DNS 解決、TCP/TLS 接続、任意で OTLP の空 export を順に試す。
+// 同時実行数は MaxConcurrent で制限し、失敗した Target は指数バックオフの間
+// 直前の結果を返して再確認しない。複数の Reconcile から共有して使う。
+type Checker struct {
+	// Handshake が true なら接続後に空の OTLP export を送る。
+	Handshake     bool
+	Timeout       time.Duration
+	MaxConcurrent int
+	BaseBackoff   time.Duration
+	MaxBackoff    time.Duration
+	Resolver      Resolver
+
+	// テストで時刻を固定するため
+	now func() time.Time
+
+	once  sync.Once
+	sem   chan struct{}
+	mu    sync.Mutex
+	state map[string]*entry
+}
+
+type entry struct {
+	last     Result
+	failures int
+	next     time.Time
+}
+
+// NewChecker は既定値で初期化した Checker を返す。
+func NewChecker(handshake bool) *Checker {
+	return &Checker{Handshake: handshake}
+}
+
+func (c *Checker) init() {
+	c.once.Do(func() {
+		if c.Timeout == 0 {
+			c.Timeout = DefaultTimeout
+		}
+		if c.MaxConcurrent <= 0 {
+			c.MaxConcurrent = DefaultMaxConcurrent
+		}
+		if c.BaseBackoff == 0 {
+			c.BaseBackoff = DefaultBaseBackoff
+		}
+		if c.MaxBackoff == 0 {
+			c.MaxBackoff = DefaultMaxBackoff
+		}
+		if c.Resolver == nil {
+			c.Resolver = net.DefaultResolver
+		}
+		if c.now == nil {
+			c.now = time.Now
+		}
+		c.sem = make(chan struct{}, c.MaxConcurrent)
+		c.state = map[string]*entry{}
+	})
+}
+
+// CheckAll は owner (namespace/name) に属する Target をまとめて確認し、入力と同じ順で結果を返す。
+// owner が持っていない Target の記録は破棄する。
+func (c *Checker) CheckAll(ctx context.Context, owner string, targets []Target) []Result {
+	c.init()
+	results := make([]Result, len(targets))
+	keep := map[string]bool{}
+	var wg sync.WaitGroup
+	for i, t := range targets {
+		key := stateKey(owner, t)
+		keep[key] = true
+		if r, ok := c.cached(key); ok {
+			results[i] = r
+			continue
+		}
+		wg.Add(1)
+		go func(i int, t Target, key string) {
+			defer wg.Done()
+			select {
+			case c.sem <- struct{}{}:
+			case <-ctx.Done():
+				results[i] = Result{Target: t, Err: ctx.Err(), CheckedAt: c.now()}
+				return
+			}
+			r := c.Check(ctx, t)
+			<-c.sem
+			c.record(key, r)
+			results[i] = r
+		}(i, t, key)
+	}
+	wg.Wait()
+
+	c.mu.Lock()
+	for key := range c.state {
+		if strings.HasPrefix(key, owner+"|") && !keep[key] {
+			delete(c.state, key)
+		}
+	}
+	c.mu.Unlock()
+	return results
+}
+
+// Forget は owner の記録をすべて破棄する。
+func (c *Checker) Forget(owner string) {
+	c.init()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for key := range c.state {
+		if strings.HasPrefix(key, owner+"|") {
+			delete(c.state, key)
+		}
+	}
+}
+
+func stateKey(owner string, t Target) string {
+	return owner + "|" + t.Name + "|" + t.Address
+}
+
+// cached はバックオフ中の Target について直前の結果を返す。
+func (c *Checker) cached(key string) (Result, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.state[key]
+	if !ok || e.failures == 0 || !c.now().Before(e.next) {
+		return Result{}, false
+	}
+	return e.last, true
+}
+
+func (c *Checker) record(key string, r Result) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.state[key]
+	if !ok {
+		e = &entry{}
+		c.state[key] = e
+	}
+	e.last = r
+	if r.Reachable {
+		e.failures = 0
+		e.next = time.Time{}
+		return
+	}
+	e.failures++
+	backoff := c.BaseBackoff
+	for i := 1; i < e.failures && backoff < c.MaxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > c.MaxBackoff {
+		backoff = c.MaxBackoff
+	}
+	e.next = r.CheckedAt.Add(backoff)
+}
+
+// Check は 1 つの Target を確認する。バックオフは考慮しない。
+func (c *Checker) Check(ctx context.Context, t Target) Result {
+	c.init()
+	ctx, cancel := context.WithTimeout(ctx, c.Timeout)
+	defer cancel()
+
+	start := time.Now()
+	err := c.probe(ctx, t)
+	r := Result{Target: t, Reachable: err == nil, Err: err, CheckedAt: c.now()}
+	if err == nil {
+		r.Latency = time.Since(start)
+	}
+	return r
+}
+
+func (c *Checker) probe(ctx context.Context, t Target) error {
+	host, port, err := net.SplitHostPort(t.Address)
+	if err != nil {
+		return fmt.Errorf("parse address %q: %w", t.Address, err)
+	}
+	addrs, err := c.Resolver.LookupHost(ctx, host)
+	if err != nil {
+		return fmt.Errorf("resolve %s: %w", host, err)
+	}
+	if len(addrs) == 0 {
+		return fmt.Errorf("resolve %s: no addresses", host)
+	}
+
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", net.JoinHostPort(addrs[0], port))
+	if err != nil {
+		return fmt.Errorf("dial %s: %w", t.Address, err)
+	}
+	if t.TLS {
+		tc := tls.Client(conn, c.tlsConfig(t, host))
+		err = tc.HandshakeContext(ctx)
+		conn = tc
+	}
+	_ = conn.Close()
+	if err != nil {
+		return fmt.Errorf("tls handshake %s: %w", t.Address, err)
+	}
+
+	if !c.Handshake {
+		return nil
+	}
+	switch t.Protocol {
+	case ProtocolGRPC:
+		return c.grpcExport(ctx, t, host)
+	case ProtocolHTTP:
+		return c.httpExport(ctx, t, host)
+	}
+	return nil
+}
+
+// 証明書の検証は collector 側の設定に任せ、ここでは到達性だけを見る
+func (c *Checker) tlsConfig(t Target, host string) *tls.Config {
+	serverName := t.ServerName
+	if serverName == "" {
+		serverName = host
+	}
+	return &tls.Config{ServerName: serverName, InsecureSkipVerify: true} //nolint:gosec
+}
+
+// grpcExport は空の ExportTraceServiceRequest を送る。認証エラーは OTLP を話しているとみなして成功扱い。
+func (c *Checker) grpcExport(ctx context.Context, t Target, host string) error {
+	creds := insecure.NewCredentials()
+	if t.TLS {
+		creds = credentials.NewTLS(c.tlsConfig(t, host))
+	}
+	cc, err := grpc.NewClient(t.Address, grpc.WithTransportCredentials(creds))
+	if err != nil {
+		return fmt.Errorf("otlp handshake %s: %w", t.Address, err)
+	}
+	defer func() { _ = cc.Close() }()
+
+	_, err = collectortrace.NewTraceServiceClient(cc).Export(ctx, &collectortrace.ExportTraceServiceRequest{})
+	switch status.Code(err) {
+	case codes.OK, codes.Unauthenticated, codes.PermissionDenied:
+		return nil
+	}
+	return fmt.Errorf("otlp handshake %s: %w", t.Address, err)
+}
+
+// httpExport は空の protobuf ボディを POST する。
+func (c *Checker) httpExport(ctx context.Context, t Target, host string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.URL, bytes.NewReader(nil))
+	if err != nil {
+		return fmt.Errorf("otlp handshake %s: %w", t.URL, err)
+	}
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: c.tlsConfig(t, host)}}
+	defer client.CloseIdleConnections()
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("otlp handshake %s: %w", t.URL, err)
+	}
+	_ = resp.Body.Close()
+	switch {
+	case resp.StatusCode < 300, resp.StatusCode == http.StatusUnauthorized, resp.StatusCode == http.StatusForbidden:
+		return nil
+	}
+	return fmt.Errorf("otlp handshake %s: unexpected status %s", t.URL, resp.Status)
+}
@@ -0,0 +1,258 @@
+package reachability
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	collectortrace "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	"google.golang.org/grpc"
+
+	apiv1beta1 "github.com/shtsukada/cloudnative-observability-operator/api/v1beta1"
+)
+
+// otlpStandIn は空の export を受け付けるだけの OTLP/gRPC receiver。
+type otlpStandIn struct {
+	collectortrace.UnimplementedTraceServiceServer
+	exports atomic.Int32
+}
+
+func (s *otlpStandIn) Export(context.Context, *collectortrace.ExportTraceServiceRequest) (*collectortrace.ExportTraceServiceResponse, error) {
+	s.exports.Add(1)
+	return &collectortrace.ExportTraceServiceResponse{}, nil
+}
+
+func startOTLP(t *testing.T, register bool) (string, *otlpStandIn) {
+	t.Helper()
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := grpc.NewServer()
+	standIn := &otlpStandIn{}
+	if register {
+		collectortrace.RegisterTraceServiceServer(srv, standIn)
+	}
+	go func() { _ = srv.Serve(lis) }()
+	t.Cleanup(srv.Stop)
+	return lis.Addr().String(), standIn
+}
+
+// closedAddr は接続を拒否するアドレスを返す。
+func closedAddr(t *testing.T) string {
+	t.Helper()
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := lis.Addr().String()
+	_ = lis.Close()
+	return addr
+}
+
+type fakeResolver struct {
+	mu       sync.Mutex
+	calls    int
+	inFlight int
+	peak     int
+	delay    time.Duration
+	err      error
+}
+
+func (r *fakeResolver) LookupHost(_ context.Context, host string) ([]string, error) {
+	r.mu.Lock()
+	r.calls++
+	r.inFlight++
+	if r.inFlight > r.peak {
+		r.peak = r.inFlight
+	}
+	r.mu.Unlock()
+	time.Sleep(r.delay)
+	r.mu.Lock()
+	r.inFlight--
+	r.mu.Unlock()
+	if r.err != nil {
+		return nil, r.err
+	}
+	return []string{host}, nil
+}
+
+func TestCheckOTLPHandshake(t *testing.T) {
+	addr, standIn := startOTLP(t, true)
+	c := NewChecker(true)
+
+	r := c.Check(context.Background(), Target{Name: "endpoint-0", Address: addr, Protocol: ProtocolGRPC})
+	if !r.Reachable || r.Err != nil {
+		t.Fatalf("result = %+v", r)
+	}
+	if r.Latency <= 0 {
+		t.Fatalf("latency = %v", r.Latency)
+	}
+	if standIn.exports.Load() != 1 {
+		t.Fatalf("exports = %d, want 1", standIn.exports.Load())
+	}
+
+	// TCP は通るが OTLP を話さない相手は handshake で失敗する
+	plain, _ := startOTLP(t, false)
+	if r := c.Check(context.Background(), Target{Name: "plain", Address: plain, Protocol: ProtocolGRPC}); r.Reachable {
+		t.Fatalf("non-OTLP server reported reachable: %+v", r)
+	}
+	c.Handshake = false
+	if r := c.Check(context.Background(), Target{Name: "plain", Address: plain, Protocol: ProtocolGRPC}); !r.Reachable {
+		t.Fatalf("dial only: %+v", r)
+	}
+}
+
+func TestCheckOTLPHTTPHandshake(t *testing.T) {
+	var paths []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		paths = append(paths, req.URL.Path)
+		if req.URL.Path != "/v1/traces" {
+			http.NotFound(w, req)
+		}
+	}))
+	defer srv.Close()
+
+	oc := &apiv1beta1.ObservabilityConfig{Spec: apiv1beta1.ObservabilityConfigSpec{
+		Exporters: []apiv1beta1.ExporterSpec{
+			{Name: "vendor", Type: apiv1beta1.ExporterOTLPHTTP, Endpoint: srv.URL},
+			{Name: "wrong", Type: apiv1beta1.ExporterOTLPHTTP, Endpoint: srv.URL + "/prefix"},
+		},
+	}}
+	results := NewChecker(true).CheckAll(context.Background(), "ns/oc", Targets(oc))
+	if !results[0].Reachable {
+		t.Fatalf("vendor = %+v", results[0])
+	}
+	if results[1].Reachable {
+		t.Fatalf("wrong path reported reachable: %+v", results[1])
+	}
+	if len(paths) != 2 {
+		t.Fatalf("requests = %v", paths)
+	}
+}
+
+func TestCheckFailures(t *testing.T) {
+	c := NewChecker(false)
+	c.Resolver = &fakeResolver{err: errors.New("no such host")}
+	r := c.Check(context.Background(), Target{Address: "missing.example:4317"})
+	if r.Reachable || r.Err == nil || r.Err.Error() != "resolve missing.example: no such host" {
+		t.Fatalf("dns failure = %+v", r)
+	}
+
+	c = NewChecker(false)
+	addr := closedAddr(t)
+	if r := c.Check(context.Background(), Target{Address: addr}); r.Reachable || r.Err == nil {
+		t.Fatalf("refused = %+v", r)
+	}
+
+	// TLS を期待する相手が平文なら handshake で失敗する
+	plain, _ := startOTLP(t, true)
+	if r := c.Check(context.Background(), Target{Address: plain, TLS: true}); r.Reachable {
+		t.Fatalf("tls against plaintext = %+v", r)
+	}
+
+	tlsSrv := httptest.NewTLSServer(http.NotFoundHandler())
+	defer tlsSrv.Close()
+	if r := c.Check(context.Background(), Target{Address: tlsSrv.Listener.Addr().String(), TLS: true}); !r.Reachable {
+		t.Fatalf("tls = %+v", r)
+	}
+}
+
+func TestCheckAllBackoff(t *testing.T) {
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	res := &fakeResolver{err: errors.New("no such host")}
+	c := &Checker{Resolver: res, BaseBackoff: 10 * time.Second, MaxBackoff: 30 * time.Second, now: func() time.Time { return now }}
+	targets := []Target{{Name: "endpoint-0", Address: "missing.example:4317"}}
+
+	check := func() Result {
+		t.Helper()
+		return c.CheckAll(context.Background(), "ns/oc", targets)[0]
+	}
+	check()
+	// バックオフ中は直前の結果を返す
+	now = now.Add(5 * time.Second)
+	if r := check(); r.Reachable || res.calls != 1 {
+		t.Fatalf("calls = %d, result = %+v", res.calls, r)
+	}
+	now = now.Add(5 * time.Second)
+	check()
+	if res.calls != 2 {
+		t.Fatalf("calls after first backoff = %d", res.calls)
+	}
+	// 2 回目の失敗で 20s、3 回目以降は MaxBackoff で頭打ち
+	now = now.Add(19 * time.Second)
+	check()
+	if res.calls != 2 {
+		t.Fatalf("calls within second backoff = %d", res.calls)
+	}
+	now = now.Add(time.Second)
+	check()
+	now = now.Add(30 * time.Second)
+	check()
+	if res.calls != 4 {
+		t.Fatalf("calls = %d, want 4", res.calls)
+	}
+
+	// 成功したらバックオフは解除される
+	res.err = nil
+	c.Forget("ns/oc")
+	addr, _ := startOTLP(t, true)
+	targets[0].Address = addr
+	check()
+	check()
+	if res.calls != 6 {
+		t.Fatalf("calls after success = %d, want 6", res.calls)
+	}
+}
+
+func TestCheckAllBoundsConcurrency(t *testing.T) {
+	addr, _ := startOTLP(t, true)
+	res := &fakeResolver{delay: 20 * time.Millisecond}
+	c := &Checker{Resolver: res, MaxConcurrent: 2}
+	var targets []Target
+	for _, name := range []string{"a", "b", "c", "d", "e", "f"} {
+		targets = append(targets, Target{Name: name, Address: addr})
+	}
+	for i, r := range c.CheckAll(context.Background(), "ns/oc", targets) {
+		if !r.Reachable || r.Target.Name != targets[i].Name {
+			t.Fatalf("results[%d] = %+v", i, r)
+		}
+	}
+	if res.peak > 2 {
+		t.Fatalf("peak concurrency = %d, want <= 2", res.peak)
+	}
+}
+
+func TestTargets(t *testing.T) {
+	oc := &apiv1beta1.ObservabilityConfig{Spec: apiv1beta1.ObservabilityConfigSpec{
+		Endpoints: []apiv1beta1.Endpoint{"otel-collector.monitoring:4317", "https://otlp.example.com"},
+		Exporters: []apiv1beta1.ExporterSpec{
+			{Name: "tempo", Type: apiv1beta1.ExporterOTLP, Endpoint: "tempo:4317", TLS: &apiv1beta1.ExporterTLS{Insecure: true}},
+			{Name: "vendor", Type: apiv1beta1.ExporterOTLP, Endpoint: "otlp.vendor.example:443", TLS: &apiv1beta1.ExporterTLS{ServerName: "vendor.example"}},
+			{Name: "mimir", Type: apiv1beta1.ExporterPrometheusRemoteWrite, Endpoint: "https://mimir.example/api/v1/push"},
+			{Name: "stdout", Type: apiv1beta1.ExporterDebug},
+		},
+	}}
+	want := []Target{
+		{Name: "endpoint-0", Address: "otel-collector.monitoring:4317", Protocol: ProtocolGRPC},
+		{Name: "endpoint-1", Address: "otlp.example.com:4317", TLS: true, Protocol: ProtocolGRPC},
+		{Name: "tempo", Address: "tempo:4317", Protocol: ProtocolGRPC},
+		{Name: "vendor", Address: "otlp.vendor.example:443", TLS: true, ServerName: "vendor.example", Protocol: ProtocolGRPC},
+		{Name: "mimir", Address: "mimir.example:443", TLS: true},
+	}
+	got := Targets(oc)
+	if len(got) != len(want) {
+		t.Fatalf("targets = %+v", got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("targets[%d] = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package reachability
+
+import (
+	"net"
+	"net/url"
+	"strings"
+
+	apiv1beta1 "github.com/shtsukada/cloudnative-observability-operator/api/v1beta1"
+	"github.com/shtsukada/cloudnative-observability-operator/internal/collector"
+)
+
+// Protocol は handshake で使う OTLP のトランスポート。
+type Protocol string
+
+const (
+	ProtocolNone Protocol = ""
+	ProtocolGRPC Protocol = "grpc"
+	ProtocolHTTP Protocol = "http"
+)
+
+// Target は疎通確認の対象となる exporter の送り先。
+type Target struct {
+	// Name は exporter 名 (spec.endpoints なら endpoint-<i>)。
+	Name string
+	// Address は host:port。
+	Address string
+	// TLS が true なら TCP 接続後に TLS handshake まで行う。
+	TLS        bool
+	ServerName string
+	// Protocol が空なら handshake は行わない。
+	Protocol Protocol
+	// URL は OTLP/HTTP の handshake 先 (ProtocolHTTP のときのみ)。
+	URL string
+}
+
+// Targets は ObservabilityConfig が送信する先を collector の設定と同じ規則で列挙する。
+func Targets(oc *apiv1beta1.ObservabilityConfig) []Target {
+	var targets []Target
+	for i, ep := range oc.Spec.Endpoints {
+		t := grpcTarget(collector.EndpointExporterName(i), string(ep), strings.HasPrefix(string(ep), "https://"))
+		targets = append(targets, t)
+	}
+	for _, e := range oc.Spec.Exporters {
+		var t Target
+		switch e.Type {
+		case apiv1beta1.ExporterOTLP:
+			// otlp exporter は insecure を指定しない限り TLS を使う
+			secure := !strings.HasPrefix(e.Endpoint, "http://") && (e.TLS == nil || !e.TLS.Insecure)
+			t = grpcTarget(e.Name, e.Endpoint, secure)
+		case apiv1beta1.ExporterOTLPHTTP:
+			t = httpTarget(e.Name, e.Endpoint)
+			t.Protocol = ProtocolHTTP
+			t.URL = strings.TrimSuffix(e.Endpoint, "/") + "/v1/traces"
+		case apiv1beta1.ExporterPrometheusRemoteWrite:
+			// remote write は OTLP ではないので接続確認まで
+			t = httpTarget(e.Name, e.Endpoint)
+		default:
+			continue
+		}
+		if e.TLS != nil && e.TLS.ServerName != "" {
+			t.ServerName = e.TLS.ServerName
+		}
+		targets = append(targets, t)
+	}
+	return targets
+}
+
+func grpcTarget(name, endpoint string, secure bool) Target {
+	addr := endpoint
+	if u, err := url.Parse(endpoint); err == nil && u.Host != "" {
+		addr = u.Host
+	}
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		addr = net.JoinHostPort(addr, "4317")
+	}
+	return Target{Name: name, Address: addr, TLS: secure, Protocol: ProtocolGRPC}
+}
+
+func httpTarget(name, endpoint string) Target {
+	t := Target{Name: name, Address: endpoint}
+	u, err := url.Parse(endpoint)
+	if err != nil || u.Host == "" {
+		return t
+	}
+	t.TLS = u.Scheme == "https"
+	t.Address = u.Host
+	if u.Port() == "" {
+		port := "80"
+		if t.TLS {
+			port = "443"
+		}
+		t.Address = net.JoinHostPort(u.Hostname(), port)
+	}
+	return t
+}
@@ -21,6 +21,34 @@ const (
 	ReasonErrNotFound           = "NotFound"
 	ReasonErrConflict           = "Conflict"
 	ReasonErrUnknown            = "Unknown"
+	ReasonInvalidConfig         = "InvalidConfig"
+
+	ReasonProgressDeadlineExceeded = "ProgressDeadlineExceeded"
+	ReasonCrashLoopBackOff         = "CrashLoopBackOff"
+	ReasonRolledBack               = "RolledBack"
+	ReasonSpecUpdated              = "SpecUpdated"
+
+	ReasonEndpointReachable   = "Reachable"
+	ReasonEndpointUnreachable = "Unreachable"
+	ReasonNoEndpoints         = "NoEndpoints"
+
+	ReasonInjected        = "Injected"
+	ReasonInjectionFailed = "InjectionFailed"
+	ReasonInstrumented    = "Instrumented"
+	ReasonUninstrumented  = "Uninstrumented"
+
+	ReasonInherited             = "Inherited"
+	ReasonInheritSourceNotFound = "InheritSourceNotFound"
+	ReasonNamespaceNotSelected  = "NamespaceNotSelected"
+
+	ReasonScaled = "Scaled"
+
+	ReasonRecreating = "Recreating"
+
+	ReasonWaitingForGateway = "WaitingForGateway"
+
+	ReasonSendFailed    = "SendFailed"
+	ReasonSendSucceeded = "SendSucceeded"
 )
 
 const (
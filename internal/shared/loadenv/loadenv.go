@@ -0,0 +1,21 @@
+package loadenv
+
+// spec.load から grpc-burner に渡す環境変数
+const (
+	Mode         = "BURNER_LOAD_MODE"
+	QPS          = "BURNER_LOAD_QPS"
+	Concurrency  = "BURNER_LOAD_CONCURRENCY"
+	PayloadBytes = "BURNER_LOAD_PAYLOAD_BYTES"
+)
+
+var managed = map[string]bool{
+	Mode:         true,
+	QPS:          true,
+	Concurrency:  true,
+	PayloadBytes: true,
+}
+
+// IsManaged は name が spec.load 由来の環境変数かを返す。
+func IsManaged(name string) bool {
+	return managed[name]
+}
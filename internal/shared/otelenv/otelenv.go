@@ -0,0 +1,8 @@
+package otelenv
+
+// ObservabilityConfig の spec.inject と sidecar 注入で workload に入れる OpenTelemetry SDK の環境変数
+const (
+	Endpoint           = "OTEL_EXPORTER_OTLP_ENDPOINT"
+	ServiceName        = "OTEL_SERVICE_NAME"
+	ResourceAttributes = "OTEL_RESOURCE_ATTRIBUTES"
+)
@@ -0,0 +1,42 @@
+package validation
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+// ObservabilityConfig の CEL ルールと同じ形式
+var (
+	urlEndpoint      = regexp.MustCompile(`^https?://.+`)
+	hostPortEndpoint = regexp.MustCompile(`^[A-Za-z0-9_.-]+:([0-9]{1,5})$`)
+)
+
+// Endpoint は OTLP endpoint が http(s) URL か host:port であることを検証する。
+func Endpoint(endpoint string, fldPath *field.Path) field.ErrorList {
+	if urlEndpoint.MatchString(endpoint) {
+		return nil
+	}
+	m := hostPortEndpoint.FindStringSubmatch(endpoint)
+	if m == nil {
+		return field.ErrorList{field.Invalid(fldPath, endpoint, "must be an http(s) URL or host:port")}
+	}
+	if port, _ := strconv.Atoi(m[1]); port < 1 || port > 65535 {
+		return field.ErrorList{field.Invalid(fldPath, endpoint, "port must be between 1 and 65535")}
+	}
+	return nil
+}
+
+// Image は hack/verify-no-latest.sh と同じく :latest タグを拒否する。
+func Image(image string, fldPath *field.Path) field.ErrorList {
+	if strings.Contains(image, "@") {
+		return nil
+	}
+	name := image[strings.LastIndex(image, "/")+1:]
+	if i := strings.LastIndex(name, ":"); i >= 0 && name[i+1:] == "latest" {
+		return field.ErrorList{field.Invalid(fldPath, image, "the :latest tag is not allowed, pin an explicit tag")}
+	}
+	return nil
+}
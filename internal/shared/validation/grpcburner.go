@@ -0,0 +1,128 @@
+package validation
+
+import (
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+
+	apiv1beta1 "github.com/shtsukada/cloudnative-observability-operator/api/v1beta1"
+	"github.com/shtsukada/cloudnative-observability-operator/internal/shared/loadenv"
+)
+
+// GrpcBurnerSpec は API server では検出できない spec の不整合を返す。
+func GrpcBurnerSpec(spec *apiv1beta1.GrpcBurnerSpec, fldPath *field.Path) field.ErrorList {
+	var errs field.ErrorList
+	errs = append(errs, ports(spec, fldPath)...)
+	errs = append(errs, env(spec, fldPath)...)
+	errs = append(errs, images(spec, fldPath)...)
+	errs = append(errs, containerNames(spec, fldPath)...)
+	errs = append(errs, volumes(spec, fldPath)...)
+	if spec.OTLP != nil {
+		errs = append(errs, Endpoint(spec.OTLP.Endpoint, fldPath.Child("otlp", "endpoint"))...)
+	}
+	return errs
+}
+
+// ports は Service の apply 時に API server が拒否する組み合わせを事前に検出する。
+func ports(spec *apiv1beta1.GrpcBurnerSpec, fldPath *field.Path) field.ErrorList {
+	var errs field.ErrorList
+	names := map[string]bool{}
+	containerPorts := map[string]bool{}
+	servicePorts := map[string]bool{}
+	for i, p := range spec.Ports {
+		idx := fldPath.Child("ports").Index(i)
+		proto := string(p.Protocol)
+		if proto == "" {
+			proto = string(corev1.ProtocolTCP)
+		}
+
+		switch {
+		case p.Name == "" && len(spec.Ports) > 1:
+			errs = append(errs, field.Required(idx.Child("name"), "must be specified when more than one port is defined"))
+		case p.Name != "" && names[p.Name]:
+			errs = append(errs, field.Duplicate(idx.Child("name"), p.Name))
+		}
+		names[p.Name] = true
+
+		key := fmt.Sprintf("%d/%s", p.ContainerPort, proto)
+		if containerPorts[key] {
+			errs = append(errs, field.Duplicate(idx.Child("containerPort"), p.ContainerPort))
+		}
+		containerPorts[key] = true
+
+		svcPort := p.ContainerPort
+		if p.ServicePort != nil {
+			svcPort = *p.ServicePort
+		}
+		key = fmt.Sprintf("%d/%s", svcPort, proto)
+		if servicePorts[key] {
+			errs = append(errs, field.Duplicate(idx.Child("servicePort"), svcPort))
+		}
+		servicePorts[key] = true
+	}
+	return errs
+}
+
+// env は spec.load で指定する設定を env でも重ねて指定していないかを検査する。
+func env(spec *apiv1beta1.GrpcBurnerSpec, fldPath *field.Path) field.ErrorList {
+	var errs field.ErrorList
+	for i, e := range spec.Env {
+		if spec.Load != nil && loadenv.IsManaged(e.Name) {
+			errs = append(errs, field.Invalid(fldPath.Child("env").Index(i).Child("name"), e.Name, "conflicts with spec.load"))
+		}
+	}
+	return errs
+}
+
+func images(spec *apiv1beta1.GrpcBurnerSpec, fldPath *field.Path) field.ErrorList {
+	errs := Image(spec.Image, fldPath.Child("image"))
+	for i, c := range spec.InitContainers {
+		errs = append(errs, Image(c.Image, fldPath.Child("initContainers").Index(i).Child("image"))...)
+	}
+	for i, c := range spec.Sidecars {
+		errs = append(errs, Image(c.Image, fldPath.Child("sidecars").Index(i).Child("image"))...)
+	}
+	return errs
+}
+
+// containerNames は init/sidecar を含めた Pod 内のコンテナ名の重複を検出する。
+func containerNames(spec *apiv1beta1.GrpcBurnerSpec, fldPath *field.Path) field.ErrorList {
+	var errs field.ErrorList
+	seen := map[string]bool{apiv1beta1.ServerContainerName: true}
+	check := func(p *field.Path, name string) {
+		if name == apiv1beta1.ServerContainerName {
+			errs = append(errs, field.Invalid(p, name, "collides with the operator-managed container"))
+			return
+		}
+		if seen[name] {
+			errs = append(errs, field.Duplicate(p, name))
+			return
+		}
+		seen[name] = true
+	}
+	for i, c := range spec.InitContainers {
+		check(fldPath.Child("initContainers").Index(i).Child("name"), c.Name)
+	}
+	for i, c := range spec.Sidecars {
+		check(fldPath.Child("sidecars").Index(i).Child("name"), c.Name)
+	}
+	return errs
+}
+
+func volumes(spec *apiv1beta1.GrpcBurnerSpec, fldPath *field.Path) field.ErrorList {
+	var errs field.ErrorList
+	defined := map[string]bool{}
+	for i, v := range spec.Volumes {
+		if defined[v.Name] {
+			errs = append(errs, field.Duplicate(fldPath.Child("volumes").Index(i).Child("name"), v.Name))
+		}
+		defined[v.Name] = true
+	}
+	for i, m := range spec.VolumeMounts {
+		if !defined[m.Name] {
+			errs = append(errs, field.NotFound(fldPath.Child("volumeMounts").Index(i).Child("name"), m.Name))
+		}
+	}
+	return errs
+}
@@ -0,0 +1,44 @@
+package validation_test
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+
+	apiv1beta1 "github.com/shtsukada/cloudnative-observability-operator/api/v1beta1"
+	. "github.com/shtsukada/cloudnative-observability-operator/internal/shared/validation"
+)
+
+func TestGrpcBurnerSpecContainers(t *testing.T) {
+	spec := &apiv1beta1.GrpcBurnerSpec{
+		InitContainers: []corev1.Container{{Name: "fetch-config"}},
+		Sidecars:       []corev1.Container{{Name: "tcpdump"}, {Name: "profiler"}},
+	}
+	if errs := GrpcBurnerSpec(spec, field.NewPath("spec")); len(errs) != 0 {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+
+	spec.Sidecars = append(spec.Sidecars, corev1.Container{Name: "server"}, corev1.Container{Name: "fetch-config"})
+	errs := GrpcBurnerSpec(spec, field.NewPath("spec"))
+	if len(errs) != 2 {
+		t.Fatalf("want 2 errors, got %v", errs)
+	}
+	if errs[0].Field != "spec.sidecars[2].name" || errs[1].Type != field.ErrorTypeDuplicate {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+}
+
+func TestGrpcBurnerSpecVolumes(t *testing.T) {
+	spec := &apiv1beta1.GrpcBurnerSpec{
+		Volumes:      []corev1.Volume{{Name: "config"}, {Name: "config"}},
+		VolumeMounts: []corev1.VolumeMount{{Name: "config"}, {Name: "missing"}},
+	}
+	errs := GrpcBurnerSpec(spec, field.NewPath("spec"))
+	if len(errs) != 2 {
+		t.Fatalf("want 2 errors, got %v", errs)
+	}
+	if errs[0].Type != field.ErrorTypeDuplicate || errs[1].Type != field.ErrorTypeNotFound {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+}
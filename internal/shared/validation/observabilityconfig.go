@@ -0,0 +1,246 @@
+package validation
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	metav1validation "k8s.io/apimachinery/pkg/apis/meta/v1/validation"
+	k8svalidation "k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+
+	apiv1beta1 "github.com/shtsukada/cloudnative-observability-operator/api/v1beta1"
+	"github.com/shtsukada/cloudnative-observability-operator/internal/collector"
+)
+
+// ObservabilityConfigSpec は API server では検出できない spec の不整合を返す。
+func ObservabilityConfigSpec(spec *apiv1beta1.ObservabilityConfigSpec, fldPath *field.Path) field.ErrorList {
+	var errs field.ErrorList
+	if len(spec.Endpoints) == 0 && len(spec.Exporters) == 0 {
+		errs = append(errs, field.Required(fldPath.Child("endpoints"), "at least one endpoint or exporter is required"))
+	}
+	for i, ep := range spec.Endpoints {
+		errs = append(errs, Endpoint(string(ep), fldPath.Child("endpoints").Index(i))...)
+	}
+	errs = append(errs, exporters(spec, fldPath)...)
+	errs = append(errs, pipelines(spec, fldPath)...)
+	errs = append(errs, processors(spec, fldPath)...)
+	if spec.Agent != nil {
+		errs = append(errs, agent(spec.Agent, fldPath.Child("agent"))...)
+		if spec.Agent.HostPort && spec.Mode != apiv1beta1.AgentModeDaemonSet {
+			errs = append(errs, field.Invalid(fldPath.Child("agent", "hostPort"), true, "requires mode DaemonSet"))
+		}
+		if spec.Agent.Autoscaling != nil && spec.Mode == apiv1beta1.AgentModeDaemonSet {
+			errs = append(errs, field.Forbidden(fldPath.Child("agent", "autoscaling"), "not supported in mode DaemonSet"))
+		}
+	}
+	if spec.Logs != nil {
+		errs = append(errs, logs(spec, fldPath.Child("logs"))...)
+	}
+	// gateway へ転送する agent はノードごとに置く
+	if spec.Topology == apiv1beta1.TopologyGateway && spec.Mode != apiv1beta1.AgentModeDaemonSet {
+		errs = append(errs, field.Invalid(fldPath.Child("topology"), spec.Topology, "requires mode DaemonSet"))
+	}
+	if p := spec.Persistence; p != nil {
+		// 各 replica が自分の volume を持てるのは StatefulSet だけ
+		if spec.Mode != apiv1beta1.AgentModeStatefulSet {
+			errs = append(errs, field.Invalid(fldPath.Child("persistence"), spec.Mode, "requires mode StatefulSet"))
+		}
+		if p.Size != nil && p.Size.Sign() <= 0 {
+			errs = append(errs, field.Invalid(fldPath.Child("persistence", "size"), p.Size.String(), "must be positive"))
+		}
+	}
+	if spec.KubernetesAttributes != nil {
+		errs = append(errs, kubernetesAttributes(spec.KubernetesAttributes, fldPath.Child("kubernetesAttributes"))...)
+	}
+	if len(spec.Scrape) > 0 {
+		errs = append(errs, scrape(spec, fldPath.Child("scrape"))...)
+	}
+	if spec.Inject != nil {
+		errs = append(errs, metav1validation.ValidateLabelSelector(&spec.Inject.Selector, metav1validation.LabelSelectorValidationOptions{}, fldPath.Child("inject", "selector"))...)
+	}
+	return errs
+}
+
+func agent(a *apiv1beta1.AgentSpec, fldPath *field.Path) field.ErrorList {
+	var errs field.ErrorList
+	if a.Image != "" {
+		errs = append(errs, Image(a.Image, fldPath.Child("image"))...)
+	}
+	if a.Version == "latest" {
+		errs = append(errs, field.Invalid(fldPath.Child("version"), a.Version, "the latest tag is not allowed, pin an explicit version"))
+	}
+	for i, arg := range a.ExtraArgs {
+		if arg == "--config" || strings.HasPrefix(arg, "--config=") {
+			errs = append(errs, field.Invalid(fldPath.Child("extraArgs").Index(i), arg, "the config file is managed by the operator"))
+		}
+	}
+	if as := a.Autoscaling; as != nil {
+		if as.MinReplicas != nil && *as.MinReplicas > as.MaxReplicas {
+			errs = append(errs, field.Invalid(fldPath.Child("autoscaling", "minReplicas"), *as.MinReplicas, "must not exceed maxReplicas"))
+		}
+		if q := as.TargetQueueSize; q != nil && q.Sign() <= 0 {
+			errs = append(errs, field.Invalid(fldPath.Child("autoscaling", "targetQueueSize"), q.String(), "must be positive"))
+		}
+	}
+	return errs
+}
+
+func logs(spec *apiv1beta1.ObservabilityConfigSpec, fldPath *field.Path) field.ErrorList {
+	var errs field.ErrorList
+	// ノードごとの agent でないと全ノードのログを読めない
+	if spec.Mode != apiv1beta1.AgentModeDaemonSet {
+		errs = append(errs, field.Invalid(fldPath, spec.Mode, "requires mode DaemonSet"))
+	}
+	check := func(name string, namespaces []string) {
+		for i, ns := range namespaces {
+			for _, msg := range k8svalidation.IsDNS1123Label(ns) {
+				errs = append(errs, field.Invalid(fldPath.Child(name).Index(i), ns, msg))
+			}
+		}
+	}
+	check("includeNamespaces", spec.Logs.IncludeNamespaces)
+	check("excludeNamespaces", spec.Logs.ExcludeNamespaces)
+	return errs
+}
+
+func scrape(spec *apiv1beta1.ObservabilityConfigSpec, fldPath *field.Path) field.ErrorList {
+	var errs field.ErrorList
+	if spec.MetricsEnabled != nil && !*spec.MetricsEnabled {
+		errs = append(errs, field.Invalid(fldPath, len(spec.Scrape), "requires metricsEnabled"))
+	}
+	// DaemonSet はノードで分担するが、それ以外は replica の数だけ同じ target を scrape してしまう
+	if spec.Mode != apiv1beta1.AgentModeDaemonSet && spec.Agent != nil {
+		if spec.Agent.Autoscaling != nil {
+			errs = append(errs, field.Forbidden(fldPath, "cannot be combined with agent.autoscaling outside mode DaemonSet"))
+		} else if r := spec.Agent.Replicas; r != nil && *r > 1 {
+			errs = append(errs, field.Forbidden(fldPath, "requires a single agent replica outside mode DaemonSet"))
+		}
+	}
+	seen := map[string]bool{}
+	for i, job := range spec.Scrape {
+		idx := fldPath.Index(i)
+		if seen[job.Name] {
+			errs = append(errs, field.Duplicate(idx.Child("name"), job.Name))
+		}
+		seen[job.Name] = true
+		errs = append(errs, metav1validation.ValidateLabelSelector(&job.Selector, metav1validation.LabelSelectorValidationOptions{}, idx.Child("selector"))...)
+		for _, msg := range k8svalidation.IsValidPortName(job.Port) {
+			errs = append(errs, field.Invalid(idx.Child("port"), job.Port, msg))
+		}
+		if job.Interval != nil && job.Interval.Duration < time.Second {
+			errs = append(errs, field.Invalid(idx.Child("interval"), job.Interval.Duration.String(), "must be at least 1s"))
+		}
+	}
+	return errs
+}
+
+func kubernetesAttributes(k *apiv1beta1.KubernetesAttributesSpec, fldPath *field.Path) field.ErrorList {
+	var errs field.ErrorList
+	check := func(name string, tags []apiv1beta1.KubernetesTag) {
+		seen := map[string]bool{}
+		for i, t := range tags {
+			idx := fldPath.Child(name).Index(i)
+			// namespace は cluster スコープの resource なので Role では読めない
+			if t.From == apiv1beta1.KubernetesTagFromNamespace && k.Scope == apiv1beta1.KubernetesAttributesScopeNamespace {
+				errs = append(errs, field.Invalid(idx.Child("from"), t.From, "requires scope Cluster"))
+			}
+			if key := string(t.From) + "/" + t.Key; seen[key] {
+				errs = append(errs, field.Duplicate(idx.Child("key"), t.Key))
+			} else {
+				seen[key] = true
+			}
+		}
+	}
+	check("labels", k.Labels)
+	check("annotations", k.Annotations)
+	return errs
+}
+
+func exporters(spec *apiv1beta1.ObservabilityConfigSpec, fldPath *field.Path) field.ErrorList {
+	var errs field.ErrorList
+	seen := map[string]bool{}
+	for i := range spec.Exporters {
+		e := &spec.Exporters[i]
+		idx := fldPath.Child("exporters").Index(i)
+		switch {
+		case strings.HasPrefix(e.Name, "endpoint-"):
+			errs = append(errs, field.Invalid(idx.Child("name"), e.Name, "the endpoint- prefix is reserved for spec.endpoints"))
+		case seen[e.Name]:
+			errs = append(errs, field.Duplicate(idx.Child("name"), e.Name))
+		}
+		seen[e.Name] = true
+
+		switch e.Type {
+		case apiv1beta1.ExporterDebug:
+			if e.Endpoint != "" || e.TLS != nil || e.Compression != "" || e.Retry != nil || e.Queue != nil ||
+				len(e.Headers) > 0 || len(e.HeadersFrom) > 0 {
+				errs = append(errs, field.Forbidden(idx, "the debug exporter takes no connection settings"))
+			}
+			continue
+		case apiv1beta1.ExporterOTLP:
+			errs = append(errs, Endpoint(e.Endpoint, idx.Child("endpoint"))...)
+		default:
+			if !urlEndpoint.MatchString(e.Endpoint) {
+				errs = append(errs, field.Invalid(idx.Child("endpoint"), e.Endpoint, fmt.Sprintf("%s requires an http(s) URL", e.Type)))
+			}
+		}
+		if e.Type == apiv1beta1.ExporterPrometheusRemoteWrite && e.Compression != "" {
+			errs = append(errs, field.Forbidden(idx.Child("compression"), "not supported by prometheusremotewrite"))
+		}
+		if e.TLS != nil && e.TLS.MTLS && e.TLS.SecretName == "" {
+			errs = append(errs, field.Required(idx.Child("tls", "secretName"), "required for mtls"))
+		}
+		headers := map[string]bool{}
+		for k := range e.Headers {
+			headers[strings.ToLower(k)] = true
+		}
+		for j, h := range e.HeadersFrom {
+			if headers[strings.ToLower(h.Name)] {
+				errs = append(errs, field.Duplicate(idx.Child("headersFrom").Index(j).Child("name"), h.Name))
+			}
+			headers[strings.ToLower(h.Name)] = true
+		}
+	}
+	return errs
+}
+
+// pipelines は spec.pipelines が存在し、signal を扱える exporter だけを参照していることを確認する。
+func pipelines(spec *apiv1beta1.ObservabilityConfigSpec, fldPath *field.Path) field.ErrorList {
+	p := spec.Pipelines
+	if p == nil {
+		return nil
+	}
+	types := map[string]apiv1beta1.ExporterType{}
+	for i := range spec.Endpoints {
+		types[collector.EndpointExporterName(i)] = apiv1beta1.ExporterOTLP
+	}
+	for _, e := range spec.Exporters {
+		types[e.Name] = e.Type
+	}
+
+	var errs field.ErrorList
+	check := func(signal string, names []string) {
+		path := fldPath.Child("pipelines", signal)
+		for i, name := range names {
+			typ, ok := types[name]
+			switch {
+			case !ok:
+				errs = append(errs, field.NotFound(path.Index(i), name))
+			case !collector.Supports(typ, signal):
+				errs = append(errs, field.Invalid(path.Index(i), name, fmt.Sprintf("%s exporters do not support %s", typ, signal)))
+			}
+		}
+	}
+	check("traces", p.Traces)
+	check("metrics", p.Metrics)
+	check("logs", p.Logs)
+
+	if spec.MetricsEnabled != nil && !*spec.MetricsEnabled && len(p.Metrics) > 0 {
+		errs = append(errs, field.Invalid(fldPath.Child("pipelines", "metrics"), p.Metrics, "metricsEnabled is false"))
+	}
+	if len(p.Traces) == 0 && len(p.Logs) == 0 && (len(p.Metrics) == 0 || (spec.MetricsEnabled != nil && !*spec.MetricsEnabled)) {
+		errs = append(errs, field.Required(fldPath.Child("pipelines"), "at least one pipeline needs an exporter"))
+	}
+	return errs
+}
@@ -0,0 +1,284 @@
+package validation_test
+
+import (
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/utils/ptr"
+
+	apiv1beta1 "github.com/shtsukada/cloudnative-observability-operator/api/v1beta1"
+	. "github.com/shtsukada/cloudnative-observability-operator/internal/shared/validation"
+)
+
+func TestObservabilityConfigSpecAgent(t *testing.T) {
+	spec := &apiv1beta1.ObservabilityConfigSpec{
+		Endpoints: []apiv1beta1.Endpoint{"otel-collector:4317"},
+		Agent: &apiv1beta1.AgentSpec{
+			Image:     "otel/opentelemetry-collector-contrib:0.114.0",
+			ExtraArgs: []string{"--feature-gates=-component.UseLocalHostAsDefaultHost"},
+		},
+	}
+	if errs := ObservabilityConfigSpec(spec, field.NewPath("spec")); len(errs) != 0 {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+
+	spec.Agent.Version = "latest"
+	spec.Agent.ExtraArgs = append(spec.Agent.ExtraArgs, "--config=/tmp/other.yaml")
+	errs := ObservabilityConfigSpec(spec, field.NewPath("spec"))
+	if len(errs) != 2 {
+		t.Fatalf("want 2 errors, got %v", errs)
+	}
+	if errs[0].Field != "spec.agent.version" || errs[1].Field != "spec.agent.extraArgs[1]" {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+}
+
+func TestObservabilityConfigSpecAutoscaling(t *testing.T) {
+	spec := &apiv1beta1.ObservabilityConfigSpec{
+		Endpoints: []apiv1beta1.Endpoint{"otel-collector:4317"},
+		Mode:      apiv1beta1.AgentModeDeployment,
+		Agent: &apiv1beta1.AgentSpec{Autoscaling: &apiv1beta1.AutoscalingSpec{
+			MinReplicas:     ptr.To(int32(2)),
+			MaxReplicas:     6,
+			TargetQueueSize: ptr.To(resource.MustParse("500")),
+		}},
+	}
+	if errs := ObservabilityConfigSpec(spec, field.NewPath("spec")); len(errs) != 0 {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+
+	spec.Mode = apiv1beta1.AgentModeDaemonSet
+	spec.Agent.Autoscaling.MinReplicas = ptr.To(int32(8))
+	spec.Agent.Autoscaling.TargetQueueSize = ptr.To(resource.MustParse("0"))
+	errs := ObservabilityConfigSpec(spec, field.NewPath("spec"))
+	want := []string{"spec.agent.autoscaling.minReplicas", "spec.agent.autoscaling.targetQueueSize", "spec.agent.autoscaling"}
+	if len(errs) != len(want) {
+		t.Fatalf("want %d errors, got %v", len(want), errs)
+	}
+	for i, w := range want {
+		if errs[i].Field != w {
+			t.Errorf("errs[%d] = %s, want %s", i, errs[i].Field, w)
+		}
+	}
+}
+
+func TestObservabilityConfigSpecKubernetesAttributes(t *testing.T) {
+	spec := &apiv1beta1.ObservabilityConfigSpec{
+		Endpoints: []apiv1beta1.Endpoint{"otel-collector:4317"},
+		KubernetesAttributes: &apiv1beta1.KubernetesAttributesSpec{
+			Scope: apiv1beta1.KubernetesAttributesScopeCluster,
+			Labels: []apiv1beta1.KubernetesTag{
+				{Key: "team", From: apiv1beta1.KubernetesTagFromPod},
+				{Key: "team", From: apiv1beta1.KubernetesTagFromNamespace},
+			},
+		},
+	}
+	if errs := ObservabilityConfigSpec(spec, field.NewPath("spec")); len(errs) != 0 {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+
+	spec.KubernetesAttributes.Scope = apiv1beta1.KubernetesAttributesScopeNamespace
+	spec.KubernetesAttributes.Annotations = []apiv1beta1.KubernetesTag{
+		{Key: "owner", From: apiv1beta1.KubernetesTagFromPod},
+		{Key: "owner", From: apiv1beta1.KubernetesTagFromPod},
+	}
+	errs := ObservabilityConfigSpec(spec, field.NewPath("spec"))
+	want := []string{"spec.kubernetesAttributes.labels[1].from", "spec.kubernetesAttributes.annotations[1].key"}
+	if len(errs) != len(want) {
+		t.Fatalf("want %d errors, got %v", len(want), errs)
+	}
+	for i, w := range want {
+		if errs[i].Field != w {
+			t.Errorf("errs[%d] = %s, want %s", i, errs[i].Field, w)
+		}
+	}
+}
+
+func TestObservabilityConfigSpecLogs(t *testing.T) {
+	spec := &apiv1beta1.ObservabilityConfigSpec{
+		Endpoints: []apiv1beta1.Endpoint{"otel-collector:4317"},
+		Mode:      apiv1beta1.AgentModeDaemonSet,
+		Logs:      &apiv1beta1.LogsSpec{IncludeNamespaces: []string{"burners"}, ExcludeNamespaces: []string{"kube-system"}},
+	}
+	if errs := ObservabilityConfigSpec(spec, field.NewPath("spec")); len(errs) != 0 {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+
+	spec.Mode = apiv1beta1.AgentModeDeployment
+	spec.Logs.ExcludeNamespaces = []string{"Kube_System"}
+	errs := ObservabilityConfigSpec(spec, field.NewPath("spec"))
+	if len(errs) != 2 || errs[0].Field != "spec.logs" || errs[1].Field != "spec.logs.excludeNamespaces[0]" {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+}
+
+func TestObservabilityConfigSpecPersistence(t *testing.T) {
+	spec := &apiv1beta1.ObservabilityConfigSpec{
+		Endpoints:   []apiv1beta1.Endpoint{"otel-collector:4317"},
+		Mode:        apiv1beta1.AgentModeStatefulSet,
+		Persistence: &apiv1beta1.PersistenceSpec{Size: ptr.To(resource.MustParse("2Gi"))},
+	}
+	if errs := ObservabilityConfigSpec(spec, field.NewPath("spec")); len(errs) != 0 {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+
+	spec.Mode = apiv1beta1.AgentModeDeployment
+	spec.Persistence.Size = ptr.To(resource.MustParse("0"))
+	errs := ObservabilityConfigSpec(spec, field.NewPath("spec"))
+	if len(errs) != 2 || errs[0].Field != "spec.persistence" || errs[1].Field != "spec.persistence.size" {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+}
+
+func TestObservabilityConfigSpecTopology(t *testing.T) {
+	spec := &apiv1beta1.ObservabilityConfigSpec{
+		Endpoints: []apiv1beta1.Endpoint{"otel-collector:4317"},
+		Mode:      apiv1beta1.AgentModeDaemonSet,
+		Topology:  apiv1beta1.TopologyGateway,
+	}
+	if errs := ObservabilityConfigSpec(spec, field.NewPath("spec")); len(errs) != 0 {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+
+	spec.Mode = apiv1beta1.AgentModeDeployment
+	errs := ObservabilityConfigSpec(spec, field.NewPath("spec"))
+	if len(errs) != 1 || errs[0].Field != "spec.topology" {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+}
+
+func TestObservabilityConfigSpecScrape(t *testing.T) {
+	spec := &apiv1beta1.ObservabilityConfigSpec{
+		Endpoints: []apiv1beta1.Endpoint{"otel-collector:4317"},
+		Mode:      apiv1beta1.AgentModeDeployment,
+		Agent:     &apiv1beta1.AgentSpec{Replicas: ptr.To(int32(1))},
+		Scrape:    []apiv1beta1.ScrapeJob{{Name: "burners", Port: "metrics"}},
+	}
+	if errs := ObservabilityConfigSpec(spec, field.NewPath("spec")); len(errs) != 0 {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+
+	spec.Agent.Replicas = ptr.To(int32(3))
+	spec.Scrape = append(spec.Scrape, apiv1beta1.ScrapeJob{
+		Name: "burners", Port: "not_a_port_name",
+		Selector: metav1.LabelSelector{MatchExpressions: []metav1.LabelSelectorRequirement{{Key: "tier", Operator: metav1.LabelSelectorOpIn}}},
+		Interval: &metav1.Duration{Duration: 100 * time.Millisecond},
+	})
+	errs := ObservabilityConfigSpec(spec, field.NewPath("spec"))
+	want := []string{"spec.scrape", "spec.scrape[1].name", "spec.scrape[1].selector.matchExpressions[0].values", "spec.scrape[1].port", "spec.scrape[1].interval"}
+	if len(errs) != len(want) {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+	for i, f := range want {
+		if errs[i].Field != f {
+			t.Fatalf("errs[%d] = %v, want field %s", i, errs[i], f)
+		}
+	}
+
+	// DaemonSet ならノードで分担するので replica 数は問わない
+	spec.Mode = apiv1beta1.AgentModeDaemonSet
+	spec.Scrape = spec.Scrape[:1]
+	if errs := ObservabilityConfigSpec(spec, field.NewPath("spec")); len(errs) != 0 {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+}
+
+func TestObservabilityConfigSpecExporters(t *testing.T) {
+	spec := &apiv1beta1.ObservabilityConfigSpec{
+		Endpoints: []apiv1beta1.Endpoint{"otel-collector:4317"},
+		Exporters: []apiv1beta1.ExporterSpec{
+			{Name: "vendor", Type: apiv1beta1.ExporterOTLPHTTP, Endpoint: "https://otlp.vendor.example.com"},
+			{Name: "mimir", Type: apiv1beta1.ExporterPrometheusRemoteWrite, Endpoint: "http://mimir/api/v1/push"},
+			{Name: "stdout", Type: apiv1beta1.ExporterDebug},
+		},
+		Pipelines: &apiv1beta1.PipelinesSpec{
+			Traces:  []string{"endpoint-0", "vendor"},
+			Metrics: []string{"mimir"},
+			Logs:    []string{"stdout"},
+		},
+	}
+	if errs := ObservabilityConfigSpec(spec, field.NewPath("spec")); len(errs) != 0 {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+
+	spec.Exporters = append(spec.Exporters,
+		apiv1beta1.ExporterSpec{Name: "endpoint-9", Type: apiv1beta1.ExporterOTLP, Endpoint: "x:4317"},
+		apiv1beta1.ExporterSpec{Name: "plain", Type: apiv1beta1.ExporterOTLPHTTP, Endpoint: "otlp:4318"},
+	)
+	spec.Pipelines.Traces = append(spec.Pipelines.Traces, "mimir", "missing")
+	errs := ObservabilityConfigSpec(spec, field.NewPath("spec"))
+	want := []string{"spec.exporters[3].name", "spec.exporters[4].endpoint", "spec.pipelines.traces[2]", "spec.pipelines.traces[3]"}
+	if len(errs) != len(want) {
+		t.Fatalf("want %d errors, got %v", len(want), errs)
+	}
+	for i, w := range want {
+		if errs[i].Field != w {
+			t.Errorf("errs[%d] = %s, want %s", i, errs[i].Field, w)
+		}
+	}
+}
+
+func TestObservabilityConfigSpecInject(t *testing.T) {
+	spec := &apiv1beta1.ObservabilityConfigSpec{
+		Endpoints: []apiv1beta1.Endpoint{"otel-collector:4317"},
+		Inject: &apiv1beta1.InjectSpec{
+			Selector: metav1.LabelSelector{MatchLabels: map[string]string{"team": "payments"}},
+		},
+	}
+	if errs := ObservabilityConfigSpec(spec, field.NewPath("spec")); len(errs) != 0 {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+
+	spec.Inject.Selector.MatchExpressions = []metav1.LabelSelectorRequirement{{Key: "tier", Operator: metav1.LabelSelectorOpIn}}
+	errs := ObservabilityConfigSpec(spec, field.NewPath("spec"))
+	if len(errs) != 1 || errs[0].Field != "spec.inject.selector.matchExpressions[0].values" {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+}
+
+func TestObservabilityConfigSpecProcessors(t *testing.T) {
+	spec := &apiv1beta1.ObservabilityConfigSpec{
+		Endpoints: []apiv1beta1.Endpoint{"otel-collector:4317"},
+		Processors: []apiv1beta1.ProcessorSpec{
+			{Name: "drop-health", Type: apiv1beta1.ProcessorFilter, Filter: &apiv1beta1.FilterProcessor{SpanNames: []string{"^grpc.health"}}},
+			{Name: "tags", Type: apiv1beta1.ProcessorResource, Resource: []apiv1beta1.AttributeAction{{Key: "team", Action: "upsert", Value: ptr.To("payments")}}},
+			{Name: "sample", Type: apiv1beta1.ProcessorTailSampling, TailSampling: &apiv1beta1.TailSamplingProcessor{
+				Policies: []apiv1beta1.TailSamplingPolicy{{Name: "all", Type: "always_sample"}},
+			}},
+		},
+	}
+	if errs := ObservabilityConfigSpec(spec, field.NewPath("spec")); len(errs) != 0 {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+
+	spec.Processors = append(spec.Processors,
+		apiv1beta1.ProcessorSpec{Name: "tags", Type: apiv1beta1.ProcessorAttributes,
+			Attributes: []apiv1beta1.AttributeAction{{Key: "secret", Action: "delete", Value: ptr.To("x")}}},
+		apiv1beta1.ProcessorSpec{Name: "bad-regex", Type: apiv1beta1.ProcessorFilter, Signals: []apiv1beta1.Signal{apiv1beta1.SignalLogs},
+			Filter: &apiv1beta1.FilterProcessor{SpanNames: []string{"("}}},
+		apiv1beta1.ProcessorSpec{Name: "rewrite", Type: apiv1beta1.ProcessorTransform, Transform: &apiv1beta1.TransformProcessor{
+			Metrics: []apiv1beta1.TransformStatements{{Context: "span", Statements: []string{`set(name, "x")`}}},
+		}},
+	)
+	errs := ObservabilityConfigSpec(spec, field.NewPath("spec"))
+	want := []string{
+		"spec.processors[3].name",
+		"spec.processors[3].attributes[0]",
+		"spec.processors[4].filter.spanNames[0]",
+		"spec.processors[4].signals[0]",
+		"spec.processors[5].transform.metrics[0].context",
+		// attributes は traces にも入るので tail_sampling より後ろに置けない
+		"spec.processors[3]",
+	}
+	if len(errs) != len(want) {
+		t.Fatalf("want %d errors, got %v", len(want), errs)
+	}
+	for i, w := range want {
+		if errs[i].Field != w {
+			t.Errorf("errs[%d] = %s, want %s", i, errs[i].Field, w)
+		}
+	}
+}
@@ -0,0 +1,211 @@
+package validation
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+
+	"k8s.io/apimachinery/pkg/util/validation/field"
+
+	apiv1beta1 "github.com/shtsukada/cloudnative-observability-operator/api/v1beta1"
+	"github.com/shtsukada/cloudnative-observability-operator/internal/collector"
+)
+
+// CRD の MaxItems と揃える (継承で合成した結果にも同じ上限をかける)
+const maxProcessors = 32
+
+// signal ごとに transform processor で使える OTTL の context
+var transformContexts = map[string][]string{
+	"traces":  {"resource", "scope", "span", "spanevent"},
+	"metrics": {"resource", "scope", "metric", "datapoint"},
+	"logs":    {"resource", "scope", "log"},
+}
+
+// processors は spec.processors の名前、種類ごとの設定、並び順を確認する。
+func processors(spec *apiv1beta1.ObservabilityConfigSpec, fldPath *field.Path) field.ErrorList {
+	var errs field.ErrorList
+	if n := len(spec.Processors); n > maxProcessors {
+		errs = append(errs, field.TooMany(fldPath.Child("processors"), n, maxProcessors))
+	}
+	seen := map[string]bool{}
+	lastTraces, tailSampling := -1, -1
+	for i := range spec.Processors {
+		p := &spec.Processors[i]
+		idx := fldPath.Child("processors").Index(i)
+		if seen[p.Name] {
+			errs = append(errs, field.Duplicate(idx.Child("name"), p.Name))
+		}
+		seen[p.Name] = true
+
+		errs = append(errs, processorSettings(p, idx)...)
+
+		// signals には設定が扱える signal だけを書ける
+		all := *p
+		all.Signals = nil
+		supported := collector.ProcessorSignals(&all)
+		for j, s := range p.Signals {
+			if !slices.Contains(supported, string(s)) {
+				errs = append(errs, field.Invalid(idx.Child("signals").Index(j), s, fmt.Sprintf("%s settings do not apply to %s", p.Type, s)))
+			}
+		}
+
+		if slices.Contains(collector.ProcessorSignals(p), "traces") {
+			lastTraces = i
+		}
+		if p.Type == apiv1beta1.ProcessorTailSampling {
+			if tailSampling >= 0 {
+				errs = append(errs, field.Duplicate(idx.Child("type"), p.Type))
+			}
+			tailSampling = i
+		}
+	}
+	// tail_sampling は trace 全体を見て判断するので、後ろで span を書き換えたり捨てたりしない
+	if tailSampling >= 0 && lastTraces > tailSampling {
+		errs = append(errs, field.Invalid(fldPath.Child("processors").Index(lastTraces), spec.Processors[lastTraces].Name,
+			"trace processors must come before the tail_sampling processor"))
+	}
+	return errs
+}
+
+func processorSettings(p *apiv1beta1.ProcessorSpec, fldPath *field.Path) field.ErrorList {
+	set := map[apiv1beta1.ProcessorType]bool{
+		apiv1beta1.ProcessorAttributes:   p.Attributes != nil,
+		apiv1beta1.ProcessorFilter:       p.Filter != nil,
+		apiv1beta1.ProcessorResource:     p.Resource != nil,
+		apiv1beta1.ProcessorTailSampling: p.TailSampling != nil,
+		apiv1beta1.ProcessorTransform:    p.Transform != nil,
+	}
+	for typ, ok := range set {
+		if ok != (typ == p.Type) {
+			return field.ErrorList{field.Invalid(fldPath, p.Name, fmt.Sprintf("exactly the settings block for %s must be set", p.Type))}
+		}
+	}
+
+	switch p.Type {
+	case apiv1beta1.ProcessorAttributes:
+		return attributeActions(p.Attributes, fldPath.Child("attributes"))
+	case apiv1beta1.ProcessorResource:
+		return attributeActions(p.Resource, fldPath.Child("resource"))
+	case apiv1beta1.ProcessorFilter:
+		return filter(p.Filter, fldPath.Child("filter"))
+	case apiv1beta1.ProcessorTailSampling:
+		return tailSampling(p.TailSampling, fldPath.Child("tailSampling"))
+	case apiv1beta1.ProcessorTransform:
+		return transform(p.Transform, fldPath.Child("transform"))
+	}
+	return nil
+}
+
+func attributeActions(actions []apiv1beta1.AttributeAction, fldPath *field.Path) field.ErrorList {
+	if len(actions) == 0 {
+		return field.ErrorList{field.Required(fldPath, "at least one action is required")}
+	}
+	var errs field.ErrorList
+	for i, a := range actions {
+		idx := fldPath.Index(i)
+		if a.Key == "" {
+			errs = append(errs, field.Required(idx.Child("key"), ""))
+		}
+		hasValue, hasFrom := a.Value != nil, a.FromAttribute != ""
+		switch a.Action {
+		case "delete":
+			if hasValue || hasFrom {
+				errs = append(errs, field.Forbidden(idx, "delete takes no value"))
+			}
+		case "insert", "update", "upsert":
+			if hasValue == hasFrom {
+				errs = append(errs, field.Invalid(idx, a.Key, "exactly one of value or fromAttribute is required"))
+			}
+		default:
+			errs = append(errs, field.NotSupported(idx.Child("action"), a.Action, []string{"insert", "update", "upsert", "delete"}))
+		}
+	}
+	return errs
+}
+
+func filter(f *apiv1beta1.FilterProcessor, fldPath *field.Path) field.ErrorList {
+	if len(f.SpanNames) == 0 && len(f.MetricNames) == 0 && len(f.Attributes) == 0 {
+		return field.ErrorList{field.Required(fldPath, "at least one condition is required")}
+	}
+	var errs field.ErrorList
+	check := func(name string, patterns []string) {
+		for i, p := range patterns {
+			if _, err := regexp.Compile(p); err != nil {
+				errs = append(errs, field.Invalid(fldPath.Child(name).Index(i), p, err.Error()))
+			}
+		}
+	}
+	check("spanNames", f.SpanNames)
+	check("metricNames", f.MetricNames)
+	for i, m := range f.Attributes {
+		if m.Key == "" {
+			errs = append(errs, field.Required(fldPath.Child("attributes").Index(i).Child("key"), ""))
+		}
+	}
+	return errs
+}
+
+func tailSampling(t *apiv1beta1.TailSamplingProcessor, fldPath *field.Path) field.ErrorList {
+	if len(t.Policies) == 0 {
+		return field.ErrorList{field.Required(fldPath.Child("policies"), "at least one policy is required")}
+	}
+	var errs field.ErrorList
+	seen := map[string]bool{}
+	for i, p := range t.Policies {
+		idx := fldPath.Child("policies").Index(i)
+		if seen[p.Name] {
+			errs = append(errs, field.Duplicate(idx.Child("name"), p.Name))
+		}
+		seen[p.Name] = true
+		switch p.Type {
+		case "always_sample":
+		case "latency":
+			if p.Latency == nil || p.Latency.Duration <= 0 {
+				errs = append(errs, field.Required(idx.Child("latency"), "a positive threshold is required"))
+			}
+		case "status_code":
+			if len(p.StatusCodes) == 0 {
+				errs = append(errs, field.Required(idx.Child("statusCodes"), ""))
+			}
+			for j, c := range p.StatusCodes {
+				if !slices.Contains([]string{"OK", "ERROR", "UNSET"}, c) {
+					errs = append(errs, field.NotSupported(idx.Child("statusCodes").Index(j), c, []string{"OK", "ERROR", "UNSET"}))
+				}
+			}
+		case "probabilistic":
+			if p.SamplingPercent == nil {
+				errs = append(errs, field.Required(idx.Child("samplingPercent"), ""))
+			}
+		case "string_attribute":
+			if p.Key == "" || len(p.Values) == 0 {
+				errs = append(errs, field.Required(idx.Child("values"), "key and values are required"))
+			}
+		default:
+			errs = append(errs, field.NotSupported(idx.Child("type"), p.Type,
+				[]string{"always_sample", "latency", "status_code", "probabilistic", "string_attribute"}))
+		}
+	}
+	return errs
+}
+
+func transform(t *apiv1beta1.TransformProcessor, fldPath *field.Path) field.ErrorList {
+	if len(t.Traces) == 0 && len(t.Metrics) == 0 && len(t.Logs) == 0 {
+		return field.ErrorList{field.Required(fldPath, "at least one statement is required")}
+	}
+	var errs field.ErrorList
+	check := func(signal string, groups []apiv1beta1.TransformStatements) {
+		for i, g := range groups {
+			idx := fldPath.Child(signal).Index(i)
+			if !slices.Contains(transformContexts[signal], g.Context) {
+				errs = append(errs, field.NotSupported(idx.Child("context"), g.Context, transformContexts[signal]))
+			}
+			if len(g.Statements) == 0 {
+				errs = append(errs, field.Required(idx.Child("statements"), ""))
+			}
+		}
+	}
+	check("traces", t.Traces)
+	check("metrics", t.Metrics)
+	check("logs", t.Logs)
+	return errs
+}
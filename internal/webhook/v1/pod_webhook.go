@@ -0,0 +1,122 @@
+/*
+Copyright 2025 shtsukada.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	observabilityv1beta1 "github.com/shtsukada/cloudnative-observability-operator/api/v1beta1"
+	"github.com/shtsukada/cloudnative-observability-operator/internal/collector"
+	"github.com/shtsukada/cloudnative-observability-operator/internal/inherit"
+	"github.com/shtsukada/cloudnative-observability-operator/internal/inject"
+	"github.com/shtsukada/cloudnative-observability-operator/internal/shared/validation"
+)
+
+var podlog = logf.Log.WithName("pod-resource")
+
+// SetupPodWebhookWithManager registers the sidecar injection webhook for Pods in the manager.
+func SetupPodWebhookWithManager(mgr ctrl.Manager, defaultAgentImage string) error {
+	return ctrl.NewWebhookManagedBy(mgr).For(&corev1.Pod{}).
+		WithDefaulter(&PodCustomDefaulter{Client: mgr.GetClient(), DefaultAgentImage: defaultAgentImage}).
+		Complete()
+}
+
+// +kubebuilder:webhook:path=/mutate--v1-pod,mutating=true,failurePolicy=ignore,sideEffects=None,groups="",resources=pods,verbs=create,versions=v1,name=mpod-v1.kb.io,admissionReviewVersions=v1
+
+// PodCustomDefaulter injects an agent sidecar into Pods labeled
+// observability.shtsukada.dev/inject=true and annotated with
+// observability.shtsukada.dev/inject. Injection failures never block the Pod;
+// they are recorded on the Pod and surfaced by the ObservabilityConfig controller.
+type PodCustomDefaulter struct {
+	Client client.Reader
+	// DefaultAgentImage is used when spec.agent.image is empty.
+	DefaultAgentImage string
+}
+
+var _ webhook.CustomDefaulter = &PodCustomDefaulter{}
+
+// Default implements webhook.CustomDefaulter.
+func (d *PodCustomDefaulter) Default(ctx context.Context, obj runtime.Object) error {
+	pod, ok := obj.(*corev1.Pod)
+	if !ok {
+		return fmt.Errorf("expected a Pod object but got %T", obj)
+	}
+	name, ok := inject.Requested(pod)
+	if !ok || inject.Injected(pod) {
+		return nil
+	}
+
+	// 作成時の Pod は namespace が空のことがあるので admission request から取る
+	namespace := pod.Namespace
+	if req, err := admission.RequestFromContext(ctx); err == nil && req.Namespace != "" {
+		namespace = req.Namespace
+	}
+	if err := d.inject(ctx, pod, namespace, name); err != nil {
+		podlog.Info("sidecar injection failed", "namespace", namespace, "pod", podName(pod), "observabilityconfig", name, "err", err)
+		inject.MarkFailed(pod, err)
+		return nil
+	}
+	podlog.V(1).Info("sidecar injected", "namespace", namespace, "pod", podName(pod), "observabilityconfig", name)
+	return nil
+}
+
+func (d *PodCustomDefaulter) inject(ctx context.Context, pod *corev1.Pod, namespace, name string) error {
+	var oc observabilityv1beta1.ObservabilityConfig
+	if err := d.Client.Get(ctx, client.ObjectKey{Namespace: namespace, Name: name}, &oc); err != nil {
+		return fmt.Errorf("get ObservabilityConfig %s/%s: %w", namespace, name, err)
+	}
+	if _, err := inherit.Resolve(ctx, d.Client, &oc); err != nil {
+		return fmt.Errorf("ObservabilityConfig %s: %w", name, err)
+	}
+	oc.ApplyDefaults()
+	if errs := validation.ObservabilityConfigSpec(&oc.Spec, field.NewPath("spec")); len(errs) > 0 {
+		return fmt.Errorf("ObservabilityConfig %s is invalid: %w", name, errs.ToAggregate())
+	}
+	cfg, err := collector.Render(&oc)
+	if err != nil {
+		return fmt.Errorf("render collector config: %w", err)
+	}
+	data, err := cfg.YAML()
+	if err != nil {
+		return err
+	}
+	if err := collector.Validate(data); err != nil {
+		return fmt.Errorf("generated collector config is invalid: %w", err)
+	}
+	defaultImage := d.DefaultAgentImage
+	if defaultImage == "" {
+		defaultImage = collector.DefaultImage
+	}
+	return inject.Pod(pod, &oc, cfg, collector.AgentImage(oc.Spec.Agent, defaultImage))
+}
+
+func podName(pod *corev1.Pod) string {
+	if pod.Name != "" {
+		return pod.Name
+	}
+	return pod.GenerateName
+}
@@ -0,0 +1,92 @@
+/*
+Copyright 2025 shtsukada.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	observabilityv1beta1 "github.com/shtsukada/cloudnative-observability-operator/api/v1beta1"
+	"github.com/shtsukada/cloudnative-observability-operator/internal/inject"
+)
+
+func TestPodDefaulter(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := observabilityv1beta1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	oc := &observabilityv1beta1.ObservabilityConfig{
+		ObjectMeta: metav1.ObjectMeta{Name: "oc", Namespace: "app"},
+		Spec: observabilityv1beta1.ObservabilityConfigSpec{
+			Endpoints: []observabilityv1beta1.Endpoint{"otel-collector.monitoring:4317"},
+			Agent:     &observabilityv1beta1.AgentSpec{Version: "0.115.0"},
+		},
+	}
+	d := &PodCustomDefaulter{Client: fake.NewClientBuilder().WithScheme(scheme).WithObjects(oc).Build()}
+	// 作成時の Pod は namespace を持たないことがある
+	ctx := admission.NewContextWithRequest(context.Background(), admission.Request{
+		AdmissionRequest: admissionv1.AdmissionRequest{Namespace: "app"},
+	})
+	newPod := func(annotations map[string]string) *corev1.Pod {
+		var labels map[string]string
+		if annotations != nil {
+			labels = map[string]string{inject.Label: inject.LabelValue}
+		}
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{GenerateName: "app-", Labels: labels, Annotations: annotations},
+			Spec:       corev1.PodSpec{Containers: []corev1.Container{{Name: "app", Image: "app:1.0.0"}}},
+		}
+	}
+
+	pod := newPod(map[string]string{inject.Annotation: "oc"})
+	if err := d.Default(ctx, pod); err != nil {
+		t.Fatal(err)
+	}
+	if len(pod.Spec.Containers) != 2 || pod.Spec.Containers[1].Image != "otel/opentelemetry-collector-contrib:0.115.0" {
+		t.Fatalf("containers = %+v", pod.Spec.Containers)
+	}
+	if pod.Annotations[inject.StatusAnnotation] != inject.StatusInjected {
+		t.Fatalf("annotations = %v", pod.Annotations)
+	}
+	// 再実行されても二重に注入しない
+	if err := d.Default(ctx, pod); err != nil || len(pod.Spec.Containers) != 2 {
+		t.Fatalf("reinvocation: err = %v, containers = %d", err, len(pod.Spec.Containers))
+	}
+
+	plain := newPod(nil)
+	if err := d.Default(ctx, plain); err != nil || len(plain.Spec.Containers) != 1 || plain.Annotations != nil {
+		t.Fatalf("pod without annotation was modified: %+v", plain)
+	}
+
+	// 失敗しても Pod の作成は止めず、理由を annotation に残す
+	missing := newPod(map[string]string{inject.Annotation: "missing"})
+	if err := d.Default(ctx, missing); err != nil {
+		t.Fatal(err)
+	}
+	if len(missing.Spec.Containers) != 1 || missing.Annotations[inject.StatusAnnotation] != inject.StatusFailed ||
+		!strings.Contains(missing.Annotations[inject.ErrorAnnotation], "app/missing") {
+		t.Fatalf("missing config: %+v", missing)
+	}
+}
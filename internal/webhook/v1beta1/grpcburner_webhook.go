@@ -0,0 +1,102 @@
+/*
+Copyright 2025 shtsukada.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	"context"
+	"fmt"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	observabilityv1beta1 "github.com/shtsukada/cloudnative-observability-operator/api/v1beta1"
+	"github.com/shtsukada/cloudnative-observability-operator/internal/shared/validation"
+)
+
+var grpcburnerlog = logf.Log.WithName("grpcburner-resource")
+
+// SetupGrpcBurnerWebhookWithManager registers the webhook for GrpcBurner in the manager.
+func SetupGrpcBurnerWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).For(&observabilityv1beta1.GrpcBurner{}).
+		WithValidator(&GrpcBurnerCustomValidator{}).
+		WithDefaulter(&GrpcBurnerCustomDefaulter{}).
+		Complete()
+}
+
+// +kubebuilder:webhook:path=/mutate-observability-shtsukada-dev-v1beta1-grpcburner,mutating=true,failurePolicy=fail,sideEffects=None,groups=observability.shtsukada.dev,resources=grpcburners,verbs=create;update,versions=v1beta1,name=mgrpcburner-v1beta1.kb.io,admissionReviewVersions=v1
+
+// GrpcBurnerCustomDefaulter stores every effective default in the GrpcBurner spec.
+type GrpcBurnerCustomDefaulter struct{}
+
+var _ webhook.CustomDefaulter = &GrpcBurnerCustomDefaulter{}
+
+// Default implements webhook.CustomDefaulter.
+func (d *GrpcBurnerCustomDefaulter) Default(_ context.Context, obj runtime.Object) error {
+	gb, ok := obj.(*observabilityv1beta1.GrpcBurner)
+	if !ok {
+		return fmt.Errorf("expected a GrpcBurner object but got %T", obj)
+	}
+	grpcburnerlog.V(1).Info("defaulting for GrpcBurner", "name", gb.GetName())
+	gb.ApplyDefaults()
+	return nil
+}
+
+// +kubebuilder:webhook:path=/validate-observability-shtsukada-dev-v1beta1-grpcburner,mutating=false,failurePolicy=fail,sideEffects=None,groups=observability.shtsukada.dev,resources=grpcburners,verbs=create;update,versions=v1beta1,name=vgrpcburner-v1beta1.kb.io,admissionReviewVersions=v1
+
+// GrpcBurnerCustomValidator rejects GrpcBurners whose child objects would
+// otherwise fail to apply after the CR has been accepted.
+type GrpcBurnerCustomValidator struct{}
+
+var _ webhook.CustomValidator = &GrpcBurnerCustomValidator{}
+
+// ValidateCreate implements webhook.CustomValidator.
+func (v *GrpcBurnerCustomValidator) ValidateCreate(_ context.Context, obj runtime.Object) (admission.Warnings, error) {
+	gb, ok := obj.(*observabilityv1beta1.GrpcBurner)
+	if !ok {
+		return nil, fmt.Errorf("expected a GrpcBurner object but got %T", obj)
+	}
+	grpcburnerlog.V(1).Info("validation for GrpcBurner upon creation", "name", gb.GetName())
+	return nil, validateGrpcBurner(gb)
+}
+
+// ValidateUpdate implements webhook.CustomValidator.
+func (v *GrpcBurnerCustomValidator) ValidateUpdate(_ context.Context, _, newObj runtime.Object) (admission.Warnings, error) {
+	gb, ok := newObj.(*observabilityv1beta1.GrpcBurner)
+	if !ok {
+		return nil, fmt.Errorf("expected a GrpcBurner object for the newObj but got %T", newObj)
+	}
+	grpcburnerlog.V(1).Info("validation for GrpcBurner upon update", "name", gb.GetName())
+	return nil, validateGrpcBurner(gb)
+}
+
+// ValidateDelete implements webhook.CustomValidator.
+func (v *GrpcBurnerCustomValidator) ValidateDelete(_ context.Context, _ runtime.Object) (admission.Warnings, error) {
+	return nil, nil
+}
+
+func validateGrpcBurner(gb *observabilityv1beta1.GrpcBurner) error {
+	errs := validation.GrpcBurnerSpec(&gb.Spec, field.NewPath("spec"))
+	if len(errs) == 0 {
+		return nil
+	}
+	return apierrors.NewInvalid(observabilityv1beta1.GroupVersion.WithKind("GrpcBurner").GroupKind(), gb.Name, errs)
+}
@@ -0,0 +1,161 @@
+/*
+Copyright 2025 shtsukada.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+
+	observabilityv1beta1 "github.com/shtsukada/cloudnative-observability-operator/api/v1beta1"
+)
+
+func validBurner() *observabilityv1beta1.GrpcBurner {
+	return &observabilityv1beta1.GrpcBurner{
+		ObjectMeta: metav1.ObjectMeta{Name: "gb", Namespace: "default"},
+		Spec: observabilityv1beta1.GrpcBurnerSpec{
+			Image: "ghcr.io/shtsukada/grpc-burner:v0.1.0",
+			Ports: []observabilityv1beta1.PortSpec{
+				{Name: "grpc", ContainerPort: 50051},
+				{Name: "metrics", ContainerPort: 9090},
+			},
+			OTLP: &observabilityv1beta1.OTLPSpec{Endpoint: "otel-collector.monitoring.svc:4317"},
+		},
+	}
+}
+
+func TestGrpcBurnerValidator(t *testing.T) {
+	tests := []struct {
+		name   string
+		mutate func(gb *observabilityv1beta1.GrpcBurner)
+		field  string
+	}{
+		{name: "valid", mutate: func(*observabilityv1beta1.GrpcBurner) {}},
+		{
+			name:   "duplicate port name",
+			mutate: func(gb *observabilityv1beta1.GrpcBurner) { gb.Spec.Ports[1].Name = "grpc" },
+			field:  "spec.ports[1].name",
+		},
+		{
+			name:   "duplicate container port",
+			mutate: func(gb *observabilityv1beta1.GrpcBurner) { gb.Spec.Ports[1].ContainerPort = 50051 },
+			field:  "spec.ports[1].containerPort",
+		},
+		{
+			name:   "duplicate service port",
+			mutate: func(gb *observabilityv1beta1.GrpcBurner) { gb.Spec.Ports[1].ServicePort = ptr.To(int32(50051)) },
+			field:  "spec.ports[1].servicePort",
+		},
+		{
+			name:   "missing name on multi-port spec",
+			mutate: func(gb *observabilityv1beta1.GrpcBurner) { gb.Spec.Ports[0].Name = "" },
+			field:  "spec.ports[0].name",
+		},
+		{
+			// operator は burner に OTEL_* を入れないので利用者が設定してよい
+			name: "OTEL env",
+			mutate: func(gb *observabilityv1beta1.GrpcBurner) {
+				gb.Spec.Env = []corev1.EnvVar{{Name: "OTEL_EXPORTER_OTLP_ENDPOINT", Value: "x:1"}}
+			},
+		},
+		{
+			name: "env colliding with load profile",
+			mutate: func(gb *observabilityv1beta1.GrpcBurner) {
+				gb.Spec.Load = &observabilityv1beta1.LoadProfile{Mode: observabilityv1beta1.LoadModeIO}
+				gb.Spec.Env = []corev1.EnvVar{{Name: "BURNER_LOAD_QPS", Value: "10"}}
+			},
+			field: "spec.env[0].name",
+		},
+		{
+			name:   "latest image",
+			mutate: func(gb *observabilityv1beta1.GrpcBurner) { gb.Spec.Image = "localhost:5000/grpc-burner:latest" },
+			field:  "spec.image",
+		},
+		{
+			name:   "invalid OTLP endpoint",
+			mutate: func(gb *observabilityv1beta1.GrpcBurner) { gb.Spec.OTLP.Endpoint = "otel-collector:99999" },
+			field:  "spec.otlp.endpoint",
+		},
+	}
+
+	v := &GrpcBurnerCustomValidator{}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gb := validBurner()
+			tt.mutate(gb)
+			_, err := v.ValidateCreate(context.Background(), gb)
+			if tt.field == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if !apierrors.IsInvalid(err) {
+				t.Fatalf("want Invalid, got %v", err)
+			}
+			if !strings.Contains(err.Error(), tt.field) {
+				t.Fatalf("error %q does not mention %s", err, tt.field)
+			}
+		})
+	}
+}
+
+func TestGrpcBurnerDefaulter(t *testing.T) {
+	gb := &observabilityv1beta1.GrpcBurner{
+		Spec: observabilityv1beta1.GrpcBurnerSpec{
+			Image: "ghcr.io/shtsukada/grpc-burner:v0.1.0",
+			// probe の既定は ports に関わらず 50051
+			Ports: []observabilityv1beta1.PortSpec{{Name: "metrics", ContainerPort: 9090}, {Name: "grpc", ContainerPort: 50051}},
+			OTLP:  &observabilityv1beta1.OTLPSpec{Endpoint: "otel-collector:4317"},
+			Load:  &observabilityv1beta1.LoadProfile{},
+		},
+	}
+	if err := (&GrpcBurnerCustomDefaulter{}).Default(context.Background(), gb); err != nil {
+		t.Fatal(err)
+	}
+
+	s := gb.Spec
+	if *s.Replicas != 1 || s.UpdateStrategy != observabilityv1beta1.UpdateStrategyRollingUpdate {
+		t.Fatalf("replicas/updateStrategy not defaulted: %+v", s)
+	}
+	if s.Ports[1].Protocol != corev1.ProtocolTCP || *s.Ports[1].ServicePort != 50051 {
+		t.Fatalf("port not defaulted: %+v", s.Ports[1])
+	}
+	if *s.Probe.Port != 50051 || *s.Probe.PeriodSeconds != 10 || *s.Probe.FailureThreshold != 3 {
+		t.Fatalf("probe not defaulted: %+v", s.Probe)
+	}
+	if s.OTLP.Timeout == nil || s.OTLP.Timeout.Duration != observabilityv1beta1.DefaultOTLPTimeout ||
+		s.OTLP.Protocol != observabilityv1beta1.OTLPProtocolGRPC {
+		t.Fatalf("otlp not defaulted: %+v", s.OTLP)
+	}
+	if s.Load.Mode != observabilityv1beta1.LoadModeCPU {
+		t.Fatalf("load mode not defaulted: %+v", s.Load)
+	}
+
+	// 既定値の適用は冪等
+	before := gb.DeepCopy()
+	gb.ApplyDefaults()
+	if !equality.Semantic.DeepEqual(before, gb) {
+		t.Fatal("ApplyDefaults is not idempotent")
+	}
+}
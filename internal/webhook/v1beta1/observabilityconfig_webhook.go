@@ -0,0 +1,60 @@
+/*
+Copyright 2025 shtsukada.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	"context"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+
+	observabilityv1beta1 "github.com/shtsukada/cloudnative-observability-operator/api/v1beta1"
+)
+
+var observabilityconfiglog = logf.Log.WithName("observabilityconfig-resource")
+
+// SetupObservabilityConfigWebhookWithManager registers the webhook for ObservabilityConfig in the manager.
+func SetupObservabilityConfigWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).For(&observabilityv1beta1.ObservabilityConfig{}).
+		WithDefaulter(&ObservabilityConfigCustomDefaulter{}).
+		Complete()
+}
+
+// +kubebuilder:webhook:path=/mutate-observability-shtsukada-dev-v1beta1-observabilityconfig,mutating=true,failurePolicy=fail,sideEffects=None,groups=observability.shtsukada.dev,resources=observabilityconfigs,verbs=create;update,versions=v1beta1,name=mobservabilityconfig-v1beta1.kb.io,admissionReviewVersions=v1
+
+// ObservabilityConfigCustomDefaulter stores every effective default in the ObservabilityConfig spec.
+// Specs with inheritFrom are left as written so unset fields keep inheriting.
+type ObservabilityConfigCustomDefaulter struct{}
+
+var _ webhook.CustomDefaulter = &ObservabilityConfigCustomDefaulter{}
+
+// Default implements webhook.CustomDefaulter.
+func (d *ObservabilityConfigCustomDefaulter) Default(_ context.Context, obj runtime.Object) error {
+	oc, ok := obj.(*observabilityv1beta1.ObservabilityConfig)
+	if !ok {
+		return fmt.Errorf("expected an ObservabilityConfig object but got %T", obj)
+	}
+	if oc.Spec.InheritFrom != "" {
+		return nil
+	}
+	observabilityconfiglog.V(1).Info("defaulting for ObservabilityConfig", "name", oc.GetName())
+	oc.ApplyDefaults()
+	return nil
+}
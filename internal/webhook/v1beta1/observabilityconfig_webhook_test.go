@@ -0,0 +1,58 @@
+/*
+Copyright 2025 shtsukada.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	"context"
+	"testing"
+
+	"k8s.io/utils/ptr"
+
+	observabilityv1beta1 "github.com/shtsukada/cloudnative-observability-operator/api/v1beta1"
+)
+
+func TestObservabilityConfigDefaulter(t *testing.T) {
+	oc := &observabilityv1beta1.ObservabilityConfig{
+		Spec: observabilityv1beta1.ObservabilityConfigSpec{Endpoints: []observabilityv1beta1.Endpoint{"otel-collector:4317"}},
+	}
+	if err := (&ObservabilityConfigCustomDefaulter{}).Default(context.Background(), oc); err != nil {
+		t.Fatal(err)
+	}
+	if *oc.Spec.SamplingPercent != observabilityv1beta1.DefaultSamplingPercent || !*oc.Spec.MetricsEnabled {
+		t.Fatalf("not defaulted: %+v", oc.Spec)
+	}
+
+	// metricsEnabled=false は上書きしない
+	oc.Spec.MetricsEnabled = ptr.To(false)
+	oc.ApplyDefaults()
+	if *oc.Spec.MetricsEnabled {
+		t.Fatal("explicit metricsEnabled=false was overwritten")
+	}
+}
+
+func TestObservabilityConfigDefaulterKeepsInheritedFieldsUnset(t *testing.T) {
+	oc := &observabilityv1beta1.ObservabilityConfig{
+		Spec: observabilityv1beta1.ObservabilityConfigSpec{InheritFrom: "platform"},
+	}
+	if err := (&ObservabilityConfigCustomDefaulter{}).Default(context.Background(), oc); err != nil {
+		t.Fatal(err)
+	}
+	// 既定値で埋めると継承元の値より優先されてしまう
+	if oc.Spec.SamplingPercent != nil || oc.Spec.MetricsEnabled != nil || oc.Spec.Mode != "" || oc.Spec.Agent != nil {
+		t.Fatalf("inheriting spec was defaulted: %+v", oc.Spec)
+	}
+}